@@ -0,0 +1,108 @@
+package sqjdb
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// aggregate runs the aggregate function on the named field over the documents
+// per the given query. It returns ErrNoDoc if the result is NULL, which
+// happens when no documents have the field.
+func (t *Table[T]) aggregate(conn *sqlite.Conn, fn, name string, sqls []SQL, scan func(*sqlite.Stmt)) (err error) {
+	var query strings.Builder
+	query.WriteString("select " + fn + "(" + t.fieldSQL(name) + ") from")
+	sqls = slices.Concat([]SQL{t.from()}, sqls)
+	addSQLQuery(&query, sqls)
+	sp := t.trace(conn, fn, query.String(), sqls)
+	defer func() { err = sp.finish(err) }()
+	stmt, err := prepareSQL(conn, query.String(), sqls)
+	if err != nil {
+		return err
+	}
+	defer releaseStmt(stmt)
+	if _, err := stmt.Step(); err != nil {
+		return err
+	}
+	if stmt.ColumnType(0) == sqlite.TypeNull {
+		return ErrNoDoc
+	}
+	sp.addRows(1)
+	scan(stmt)
+	return nil
+}
+
+// SumInt returns the sum of the named integer field over the documents per
+// the given query, or 0 if there are none.
+func (t *Table[T]) SumInt(conn *sqlite.Conn, name string, sqls ...SQL) (int64, error) {
+	var v int64
+	err := t.aggregate(conn, "sum", name, sqls, func(stmt *sqlite.Stmt) {
+		v = stmt.ColumnInt64(0)
+	})
+	if errors.Is(err, ErrNoDoc) {
+		return 0, nil
+	}
+	return v, err
+}
+
+// SumFloat returns the sum of the named field over the documents per the
+// given query, or 0 if there are none.
+func (t *Table[T]) SumFloat(conn *sqlite.Conn, name string, sqls ...SQL) (float64, error) {
+	var v float64
+	err := t.aggregate(conn, "total", name, sqls, func(stmt *sqlite.Stmt) {
+		v = stmt.ColumnFloat(0)
+	})
+	return v, err
+}
+
+// Avg returns the average of the named field over the documents per the given
+// query. It returns ErrNoDoc if no documents have the field.
+func (t *Table[T]) Avg(conn *sqlite.Conn, name string, sqls ...SQL) (float64, error) {
+	var v float64
+	err := t.aggregate(conn, "avg", name, sqls, func(stmt *sqlite.Stmt) {
+		v = stmt.ColumnFloat(0)
+	})
+	return v, err
+}
+
+// MinInt returns the minimum of the named integer field over the documents per
+// the given query. It returns ErrNoDoc if no documents have the field.
+func (t *Table[T]) MinInt(conn *sqlite.Conn, name string, sqls ...SQL) (int64, error) {
+	var v int64
+	err := t.aggregate(conn, "min", name, sqls, func(stmt *sqlite.Stmt) {
+		v = stmt.ColumnInt64(0)
+	})
+	return v, err
+}
+
+// MaxInt returns the maximum of the named integer field over the documents per
+// the given query. It returns ErrNoDoc if no documents have the field.
+func (t *Table[T]) MaxInt(conn *sqlite.Conn, name string, sqls ...SQL) (int64, error) {
+	var v int64
+	err := t.aggregate(conn, "max", name, sqls, func(stmt *sqlite.Stmt) {
+		v = stmt.ColumnInt64(0)
+	})
+	return v, err
+}
+
+// MinFloat returns the minimum of the named field over the documents per the
+// given query. It returns ErrNoDoc if no documents have the field.
+func (t *Table[T]) MinFloat(conn *sqlite.Conn, name string, sqls ...SQL) (float64, error) {
+	var v float64
+	err := t.aggregate(conn, "min", name, sqls, func(stmt *sqlite.Stmt) {
+		v = stmt.ColumnFloat(0)
+	})
+	return v, err
+}
+
+// MaxFloat returns the maximum of the named field over the documents per the
+// given query. It returns ErrNoDoc if no documents have the field.
+func (t *Table[T]) MaxFloat(conn *sqlite.Conn, name string, sqls ...SQL) (float64, error) {
+	var v float64
+	err := t.aggregate(conn, "max", name, sqls, func(stmt *sqlite.Stmt) {
+		v = stmt.ColumnFloat(0)
+	})
+	return v, err
+}
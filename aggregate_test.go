@@ -0,0 +1,45 @@
+package sqjdb_test
+
+import (
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestSum(t *testing.T) {
+	conn := newConn(t)
+	sum, err := jedis.SumInt(conn, "Age")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, sum, int64(980+42+42))
+	sum, err = jedis.SumInt(conn, "Age", sqjdb.Where("Name").Eq("missing").SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, sum, int64(0))
+	total, err := jedis.SumFloat(conn, "Age", sqjdb.Where("Age").Eq(42).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, total, float64(84))
+}
+
+func TestAvg(t *testing.T) {
+	conn := newConn(t)
+	avg, err := jedis.Avg(conn, "Age", sqjdb.Where("Age").Eq(42).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, avg, float64(42))
+	_, err = jedis.Avg(conn, "Missing")
+	ensure.DeepEqual(t, err, sqjdb.ErrNoDoc)
+}
+
+func TestMinMax(t *testing.T) {
+	conn := newConn(t)
+	lo, err := jedis.MinInt(conn, "Age")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, lo, int64(42))
+	hi, err := jedis.MaxInt(conn, "Age")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, hi, int64(980))
+	hiF, err := jedis.MaxFloat(conn, "Age", sqjdb.Where("Age").Lt(100).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, hiF, float64(42))
+	_, err = jedis.MinFloat(conn, "Missing")
+	ensure.DeepEqual(t, err, sqjdb.ErrNoDoc)
+}
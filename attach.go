@@ -0,0 +1,28 @@
+package sqjdb
+
+import (
+	"fmt"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// Attach attaches the database file to the connection under the given schema
+// name. Tables named with the schema, such as "archive.jedis", then live in
+// that database, which allows keeping cold data in a separate file. Attached
+// databases are per connection, so pooled connections must each be attached.
+func Attach(conn *sqlite.Conn, file, schema string) error {
+	q := "attach database ? as " + schema
+	if err := sqlitex.ExecuteTransient(conn, q, &sqlitex.ExecOptions{Args: []any{file}}); err != nil {
+		return fmt.Errorf("sqjdb: attaching %q as %q: %w", file, schema, err)
+	}
+	return nil
+}
+
+// Detach detaches the database attached under the given schema name.
+func Detach(conn *sqlite.Conn, schema string) error {
+	if err := sqlitex.ExecuteTransient(conn, "detach database "+schema, nil); err != nil {
+		return fmt.Errorf("sqjdb: detaching %q: %w", schema, err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package sqjdb_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestAttach(t *testing.T) {
+	conn := newConn(t)
+	file := fmt.Sprintf("file:%s_archive.db?mode=memory&cache=shared", t.Name())
+	ensure.Nil(t, sqjdb.Attach(conn, file, "archive"))
+	archive := sqjdb.NewTable[Jedi]("archive.jedis",
+		sqjdb.SoftDelete("DeletedAt"),
+		sqjdb.KeepHistory(),
+		sqjdb.TrackChanges(),
+		sqjdb.Promote("Name"),
+		sqjdb.Unique("Name"),
+	)
+	ensure.Nil(t, archive.Migrate(conn))
+	ensure.Nil(t, archive.Migrate(conn))
+
+	doc, err := archive.Insert(conn, &Jedi{Name: "obi-wan", Age: 57})
+	ensure.Nil(t, err)
+	_, err = archive.Insert(conn, &Jedi{Name: "obi-wan"})
+	var uve *sqjdb.UniqueViolationError
+	ensure.True(t, errors.As(err, &uve))
+	ensure.DeepEqual(t, uve.Field, "Name")
+
+	n, err := archive.Patch(conn, &Jedi{Age: 58}, archive.ByID(doc.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+	fetched, err := archive.One(conn, archive.Where("Name").Eq("obi-wan").SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, fetched.Age, 58)
+	entries, err := archive.History(conn, doc.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(entries), 1)
+	drift, err := archive.VerifyIndexes(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, drift, sqjdb.IndexDrift{})
+
+	// The main database is untouched.
+	n, err = jedis.Count(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 3)
+
+	n, err = archive.Delete(conn, archive.ByID(doc.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+	n, err = archive.Count(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 0)
+	ensure.Nil(t, sqjdb.Detach(conn, "archive"))
+}
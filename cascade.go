@@ -0,0 +1,30 @@
+package sqjdb
+
+import (
+	"slices"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// Cascade makes deletes from the parent table also delete the documents in the
+// child table whose named field holds the ID of a deleted parent. The children
+// are deleted with the child table's Delete, so its hooks run and SoftDelete
+// children are marked deleted, and further cascades apply. Everything happens
+// within the savepoint of the parent delete, so a failure undoes it all. It is
+// registered as a before delete hook on the parent, and only cascades to the
+// children of parents within its scopes.
+func Cascade[P, C any](parent *Table[P], child *Table[C], field string) {
+	parent.Before(HookDelete, func(conn *sqlite.Conn, e *HookEvent[P]) error {
+		from := parent.from()
+		sqls := slices.Concat(
+			[]SQL{
+				{Query: "where " + child.fieldSQL(field) + " in (select " + fieldExpr(parent.opts.idKey) + " from"},
+				from,
+			},
+			e.SQLs,
+			[]SQL{{Query: ")"}},
+		)
+		_, err := child.Delete(conn, sqls...)
+		return err
+	})
+}
@@ -0,0 +1,69 @@
+package sqjdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+)
+
+type LineItem struct {
+	ID      string `json:",omitempty"`
+	OrderID string `json:",omitempty"`
+}
+
+func newCascade(t *testing.T) (*sqlite.Conn, *sqjdb.Table[Customer], *sqjdb.Table[Order], *sqjdb.Table[LineItem]) {
+	conn := newConn(t)
+	customers := sqjdb.NewTable[Customer](t.Name() + "_customers")
+	orders := sqjdb.NewTable[Order](t.Name() + "_orders")
+	items := sqjdb.NewTable[LineItem](t.Name() + "_items")
+	ensure.Nil(t, customers.Migrate(conn))
+	ensure.Nil(t, orders.Migrate(conn))
+	ensure.Nil(t, items.Migrate(conn))
+	sqjdb.Cascade(&customers, &orders, "CustomerID")
+	sqjdb.Cascade(&orders, &items, "OrderID")
+	for _, c := range []*Customer{{ID: "c1"}, {ID: "c2"}} {
+		_, err := customers.Insert(conn, c)
+		ensure.Nil(t, err)
+	}
+	for _, o := range []*Order{{ID: "o1", CustomerID: "c1"}, {ID: "o2", CustomerID: "c2"}} {
+		_, err := orders.Insert(conn, o)
+		ensure.Nil(t, err)
+	}
+	for _, i := range []*LineItem{{ID: "i1", OrderID: "o1"}, {ID: "i2", OrderID: "o2"}} {
+		_, err := items.Insert(conn, i)
+		ensure.Nil(t, err)
+	}
+	return conn, &customers, &orders, &items
+}
+
+func TestCascade(t *testing.T) {
+	conn, customers, orders, items := newCascade(t)
+	n, err := customers.Delete(conn, customers.ByID("c1"))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+	remaining, err := orders.All(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, remaining, []*Order{{ID: "o2", CustomerID: "c2"}})
+	left, err := items.All(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, left, []*LineItem{{ID: "i2", OrderID: "o2"}})
+}
+
+func TestCascadeRollback(t *testing.T) {
+	conn, customers, orders, items := newCascade(t)
+	errNope := errors.New("nope")
+	items.Before(sqjdb.HookDelete, func(*sqlite.Conn, *sqjdb.HookEvent[LineItem]) error {
+		return errNope
+	})
+	_, err := customers.Delete(conn, customers.ByID("c1"))
+	ensure.DeepEqual(t, err, errNope)
+	n, err := customers.Count(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 2)
+	n, err = orders.Count(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 2)
+}
@@ -0,0 +1,123 @@
+package sqjdb
+
+import (
+	"fmt"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// ChangelogTable is the table that Changelog records changes to. It is shared
+// by all tables in a schema, unlike the per table changelog of TrackChanges,
+// and only stores the document IDs, not the documents.
+const ChangelogTable = "sqjdb_changes"
+
+// Changelog records the table, ID and operation of every insert, update and
+// delete in the ChangelogTable, so another process can follow the writes to
+// all tables in order, for example to ship them elsewhere or to update an
+// external index. The table and the triggers that maintain it are created by
+// Migrate. Tables in attached databases record their changes in the
+// ChangelogTable of that database.
+func Changelog() Option {
+	return func(o *options) {
+		o.changelog = true
+	}
+}
+
+// ChangeRecord is an entry in the ChangelogTable.
+type ChangeRecord struct {
+	// Seq increases with every change, and can be used to resume reading.
+	Seq int64
+	// Table is the name of the table that changed.
+	Table string
+	// ID is the ID of the changed document, as stored in it, so it is an int64
+	// for integer IDs and a string otherwise.
+	ID any
+	// Op is the operation, "insert", "update" or "delete".
+	Op string
+}
+
+func (t *Table[T]) migrateChangelog(conn *sqlite.Conn) error {
+	prefix, base := splitName(t.Name)
+	// doc_id has no type, so IDs keep their storage class and still compare
+	// equal to the IDs in the documents.
+	qCreate := "create table if not exists " + prefix + ChangelogTable +
+		" (seq integer primary key autoincrement, table_name text not null," +
+		" doc_id, op text not null)"
+	if err := sqlitex.ExecuteTransient(conn, qCreate, nil); err != nil {
+		return fmt.Errorf("sqjdb: creating table %q: %w", ChangelogTable, err)
+	}
+	// Triggers can only refer to unqualified tables in their own schema.
+	name := quoteString(base)
+	for op, row := range map[string]string{"insert": "new", "update": "new", "delete": "old"} {
+		id := row + "." + fieldExpr(t.opts.idKey)
+		qTrigger := "create trigger if not exists " + t.Name + "_changelog_" + op +
+			" after " + op + " on " + base + " begin" +
+			" insert into " + ChangelogTable + " (table_name, doc_id, op)" +
+			" values (" + name + ", " + id + ", '" + op + "');" +
+			" end"
+		if err := sqlitex.ExecuteTransient(conn, qTrigger, nil); err != nil {
+			return fmt.Errorf("sqjdb: creating %s changelog trigger on %q: %w", op, t.Name, err)
+		}
+	}
+	return nil
+}
+
+// ChangelogSince returns up to limit entries from the ChangelogTable after the
+// given sequence number, oldest first. A limit of 0 returns all of them.
+func ChangelogSince(conn *sqlite.Conn, seq int64, limit int) ([]ChangeRecord, error) {
+	return changelogSince(conn, seq, "", limit)
+}
+
+// changelogSince is ChangelogSince, limited to the named table if it is not
+// empty.
+func changelogSince(conn *sqlite.Conn, seq int64, table string, limit int) ([]ChangeRecord, error) {
+	query := "select seq, table_name, doc_id, op from " + ChangelogTable + " where seq > ?"
+	args := []any{seq}
+	if table != "" {
+		query += " and table_name = ?"
+		args = append(args, table)
+	}
+	query += " order by seq"
+	if limit > 0 {
+		query += " limit ?"
+		args = append(args, limit)
+	}
+	var records []ChangeRecord
+	err := sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
+		Args: args,
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			records = append(records, ChangeRecord{
+				Seq:   stmt.ColumnInt64(0),
+				Table: stmt.ColumnText(1),
+				ID:    columnValue(stmt, 2),
+				Op:    stmt.ColumnText(3),
+			})
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sqjdb: reading changelog: %w", err)
+	}
+	return records, nil
+}
+
+// lastChangelogSeq returns the sequence number of the most recent entry in the
+// ChangelogTable, or 0 if there are none.
+func lastChangelogSeq(conn *sqlite.Conn) (int64, error) {
+	seq, err := QueryScalar[int64](conn, "select coalesce(max(seq), 0) from "+ChangelogTable)
+	if err != nil {
+		return 0, fmt.Errorf("sqjdb: reading changelog: %w", err)
+	}
+	return seq, nil
+}
+
+// TrimChangelog removes entries from the ChangelogTable up to and including the
+// given sequence number.
+func TrimChangelog(conn *sqlite.Conn, seq int64) error {
+	query := "delete from " + ChangelogTable + " where seq <= ?"
+	if err := sqlitex.Execute(conn, query, &sqlitex.ExecOptions{Args: []any{seq}}); err != nil {
+		return fmt.Errorf("sqjdb: failed to trim changelog: %w", err)
+	}
+	return nil
+}
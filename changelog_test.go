@@ -0,0 +1,58 @@
+package sqjdb_test
+
+import (
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestChangelog(t *testing.T) {
+	conn := newConn(t)
+	logged := sqjdb.NewTable[Jedi]("logged_jedis", sqjdb.Changelog())
+	ensure.Nil(t, logged.Migrate(conn))
+	ships := sqjdb.NewTable[Starship]("logged_ships", sqjdb.Changelog())
+	ensure.Nil(t, ships.Migrate(conn))
+
+	doc, err := logged.Insert(conn, &Jedi{Name: "anakin"})
+	ensure.Nil(t, err)
+	ship, err := ships.Insert(conn, &Starship{Name: "falcon"})
+	ensure.Nil(t, err)
+	_, err = logged.Patch(conn, &Jedi{Name: "vader"}, sqjdb.ByID(doc.ID))
+	ensure.Nil(t, err)
+	_, err = logged.Delete(conn, sqjdb.ByID(doc.ID))
+	ensure.Nil(t, err)
+
+	records, err := sqjdb.ChangelogSince(conn, 0, 0)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, records, []sqjdb.ChangeRecord{
+		{Seq: 1, Table: "logged_jedis", ID: doc.ID, Op: "insert"},
+		{Seq: 2, Table: "logged_ships", ID: ship.ID, Op: "insert"},
+		{Seq: 3, Table: "logged_jedis", ID: doc.ID, Op: "update"},
+		{Seq: 4, Table: "logged_jedis", ID: doc.ID, Op: "delete"},
+	})
+	records, err = sqjdb.ChangelogSince(conn, 1, 2)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(records), 2)
+	ensure.DeepEqual(t, records[0].Seq, int64(2))
+
+	ensure.Nil(t, sqjdb.TrimChangelog(conn, 3))
+	records, err = sqjdb.ChangelogSince(conn, 0, 0)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(records), 1)
+	ensure.DeepEqual(t, records[0].Seq, int64(4))
+}
+
+func TestChangelogIntID(t *testing.T) {
+	conn := newConn(t)
+	tickets := sqjdb.NewTable[Ticket](t.Name(), sqjdb.Changelog())
+	ensure.Nil(t, tickets.Migrate(conn))
+	_, err := tickets.Insert(conn, &Ticket{ID: 7, Title: "ticket"})
+	ensure.Nil(t, err)
+
+	records, err := sqjdb.ChangelogSince(conn, 0, 0)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, records, []sqjdb.ChangeRecord{
+		{Seq: 1, Table: t.Name(), ID: int64(7), Op: "insert"},
+	})
+}
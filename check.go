@@ -0,0 +1,144 @@
+package sqjdb
+
+import (
+	"fmt"
+	"strings"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// CheckOptions configures Check.
+type CheckOptions struct {
+	// Quick runs PRAGMA quick_check instead of PRAGMA integrity_check, which
+	// skips verifying that indexes match the tables, and so runs in linear
+	// time.
+	Quick bool
+}
+
+// CheckReport is the result of Check.
+type CheckReport struct {
+	// Integrity lists the problems found by SQLite, and is empty if none were.
+	Integrity []string
+	// Tables has the document checks for each table, ordered by name.
+	Tables []TableCheck
+}
+
+// OK reports whether no problems were found.
+func (r *CheckReport) OK() bool {
+	if len(r.Integrity) != 0 {
+		return false
+	}
+	for _, tc := range r.Tables {
+		if !tc.OK() {
+			return false
+		}
+	}
+	return true
+}
+
+// TableCheck is the result of checking the documents in a table.
+type TableCheck struct {
+	Table     string
+	Documents int
+	// Invalid has the rowids of documents that are not valid JSON objects.
+	Invalid []int64
+	// MissingID has the rowids of documents without an ID.
+	MissingID []int64
+}
+
+// OK reports whether all the documents in the table are valid.
+func (tc *TableCheck) OK() bool {
+	return len(tc.Invalid) == 0 && len(tc.MissingID) == 0
+}
+
+// Check verifies the database with PRAGMA integrity_check, and then checks that
+// the documents in every table created by Migrate are valid JSON objects with
+// an ID. Problems are returned in the report, and the error is only for
+// failing to run the checks. It reads every row, so run it from maintenance
+// jobs rather than on startup.
+func Check(conn *sqlite.Conn, opts CheckOptions) (*CheckReport, error) {
+	pragma := "pragma integrity_check"
+	if opts.Quick {
+		pragma = "pragma quick_check"
+	}
+	report := &CheckReport{}
+	err := sqlitex.ExecuteTransient(conn, pragma, &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			if msg := stmt.ColumnText(0); msg != "ok" {
+				report.Integrity = append(report.Integrity, msg)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sqjdb: checking integrity: %w", err)
+	}
+
+	// Tables created by Migrate are recognized by their ID index, which also
+	// gives the expression for the ID.
+	var tables [][2]string
+	err = sqlitex.ExecuteTransient(conn,
+		"select t.name, i.sql from sqlite_schema t join sqlite_schema i"+
+			" on i.type = 'index' and i.tbl_name = t.name and i.name = t.name || '_ID'"+
+			" where t.type = 'table' order by t.name",
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				tables = append(tables, [2]string{stmt.ColumnText(0), stmt.ColumnText(1)})
+				return nil
+			},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("sqjdb: listing tables: %w", err)
+	}
+	for _, table := range tables {
+		tc, err := checkDocuments(conn, table[0], table[1])
+		if err != nil {
+			return nil, err
+		}
+		report.Tables = append(report.Tables, tc)
+	}
+	return report, nil
+}
+
+// checkDocuments checks the documents in the table, given the SQL creating its
+// ID index.
+func checkDocuments(conn *sqlite.Conn, table, indexSQL string) (TableCheck, error) {
+	tc := TableCheck{Table: table}
+	idExpr, err := idIndexExpr(table, indexSQL)
+	if err != nil {
+		return tc, err
+	}
+	// JSON functions fail on invalid documents, so they are only applied to
+	// valid ones.
+	q := "select rowid, valid, case when valid then coalesce(" + idExpr + ", '') != ''" +
+		" else 0 end from" +
+		" (select rowid, data, case when json_valid(data, 9)" +
+		" then json_type(data) = 'object' else 0 end as valid from " + quoteName(table) + ")"
+	err = sqlitex.ExecuteTransient(conn, q, &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			tc.Documents++
+			switch {
+			case !stmt.ColumnBool(1):
+				tc.Invalid = append(tc.Invalid, stmt.ColumnInt64(0))
+			case !stmt.ColumnBool(2):
+				tc.MissingID = append(tc.MissingID, stmt.ColumnInt64(0))
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return tc, fmt.Errorf("sqjdb: checking documents in %q: %w", table, err)
+	}
+	return tc, nil
+}
+
+// idIndexExpr returns the ID expression from the SQL creating the ID index of
+// the table.
+func idIndexExpr(table, indexSQL string) (string, error) {
+	start, end := strings.IndexByte(indexSQL, '('), strings.LastIndexByte(indexSQL, ')')
+	if start < 0 || end < start {
+		return "", fmt.Errorf("sqjdb: unexpected ID index on %q: %s", table, indexSQL)
+	}
+	return indexSQL[start+1 : end], nil
+}
@@ -0,0 +1,30 @@
+package sqjdb_test
+
+import (
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+func TestCheck(t *testing.T) {
+	conn := newConn(t)
+	report, err := sqjdb.Check(conn, sqjdb.CheckOptions{})
+	ensure.Nil(t, err)
+	ensure.True(t, report.OK())
+	ensure.DeepEqual(t, report.Tables, []sqjdb.TableCheck{{Table: "jedis", Documents: 3}})
+
+	ensure.Nil(t, sqlitex.ExecuteTransient(conn,
+		`insert into jedis (rowid, data) values (11, jsonb('[1]')), (12, jsonb('{"Name":"ahsoka"}'))`, nil))
+	report, err = sqjdb.Check(conn, sqjdb.CheckOptions{Quick: true})
+	ensure.Nil(t, err)
+	ensure.False(t, report.OK())
+	ensure.DeepEqual(t, report.Integrity, []string(nil))
+	ensure.DeepEqual(t, report.Tables, []sqjdb.TableCheck{{
+		Table:     "jedis",
+		Documents: 5,
+		Invalid:   []int64{11},
+		MissingID: []int64{12},
+	}})
+}
@@ -0,0 +1,290 @@
+// Command sqjdbgen generates typed fields for document structs, so queries on
+// them are checked at compile time. It is meant to be used with go generate:
+//
+//	//go:generate go run github.com/daaku/sqjdb/cmd/sqjdbgen -type Jedi
+//
+// For each type it generates a variable named after it, such as JediFields,
+// with a sqjdb.TypedField for each exported field stored in the document, so
+// queries can be written as JediFields.Age.Gt(40). Fields are named by their
+// JSON keys, respecting json tags, and fields of embedded structs are included
+// like encoding/json does.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma separated list of type names")
+	output := flag.String("output", "", "output file name; default <dir>/<type>_fields.go")
+	flag.Parse()
+	if *typeNames == "" {
+		fmt.Fprintln(os.Stderr, "sqjdbgen: -type is required")
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	types := strings.Split(*typeNames, ",")
+	src, err := generate(dir, types)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *output == "" {
+		*output = filepath.Join(dir, strings.ToLower(types[0])+"_fields.go")
+	}
+	if err := os.WriteFile(*output, src, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, "sqjdbgen:", err)
+		os.Exit(1)
+	}
+}
+
+// structType is a struct type declared in the package, with the file declaring
+// it to resolve imports.
+type structType struct {
+	st   *ast.StructType
+	file *ast.File
+}
+
+// field is a generated typed field.
+type field struct {
+	goName string
+	key    string
+	typ    string
+}
+
+type generator struct {
+	fset    *token.FileSet
+	structs map[string]structType
+	imports map[string]bool
+}
+
+// generate returns the source with typed fields for the named types, declared
+// in the package in dir.
+func generate(dir string, types []string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("sqjdbgen: parsing %s: %w", dir, err)
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("sqjdbgen: expected one package in %s, found %d", dir, len(pkgs))
+	}
+	g := &generator{
+		fset:    fset,
+		structs: map[string]structType{},
+		imports: map[string]bool{"github.com/daaku/sqjdb": true},
+	}
+	var pkgName string
+	for name, pkg := range pkgs {
+		pkgName = name
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					ts := spec.(*ast.TypeSpec)
+					if st, ok := ts.Type.(*ast.StructType); ok {
+						g.structs[ts.Name.Name] = structType{st: st, file: file}
+					}
+				}
+			}
+		}
+	}
+
+	var body bytes.Buffer
+	for _, name := range types {
+		st, ok := g.structs[name]
+		if !ok {
+			return nil, fmt.Errorf("sqjdbgen: struct type %s not found in %s", name, dir)
+		}
+		fields, err := g.fields(st, map[string]bool{name: true})
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&body, "\n// %sFields are the fields of %s for building queries.\n", name, name)
+		fmt.Fprintf(&body, "var %sFields = struct {\n", name)
+		for _, f := range fields {
+			fmt.Fprintf(&body, "%s sqjdb.TypedField[%s]\n", f.goName, f.typ)
+		}
+		body.WriteString("}{\n")
+		for _, f := range fields {
+			fmt.Fprintf(&body, "%s: sqjdb.TypedField[%s]{Name: %s},\n", f.goName, f.typ, strconv.Quote(f.key))
+		}
+		body.WriteString("}\n")
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by sqjdbgen -type %s; DO NOT EDIT.\n\n", strings.Join(types, ","))
+	fmt.Fprintf(&out, "package %s\n\nimport (\n", pkgName)
+	for _, imp := range importLines(g.imports) {
+		fmt.Fprintf(&out, "%s\n", imp)
+	}
+	out.WriteString(")\n")
+	out.Write(body.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("sqjdbgen: formatting generated code: %w", err)
+	}
+	return src, nil
+}
+
+// importLines returns the import specs, sorted by path. Renamed imports are
+// stored as the name and path separated by a space.
+func importLines(imports map[string]bool) []string {
+	var lines []string
+	for imp := range imports {
+		name, p, renamed := strings.Cut(imp, " ")
+		if !renamed {
+			name, p = "", imp
+		}
+		lines = append(lines, strings.TrimSpace(name+" "+strconv.Quote(p)))
+	}
+	// Standard library imports come first, like goimports groups them.
+	std := func(line string) bool {
+		p := line[strings.IndexByte(line, '"')+1:]
+		first, _, _ := strings.Cut(p, "/")
+		return !strings.Contains(first, ".")
+	}
+	slices.SortFunc(lines, func(a, b string) int {
+		if std(a) != std(b) {
+			if std(a) {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a[strings.IndexByte(a, '"'):], b[strings.IndexByte(b, '"'):])
+	})
+	for i := 1; i < len(lines); i++ {
+		if std(lines[i-1]) && !std(lines[i]) {
+			return slices.Insert(lines, i, "")
+		}
+	}
+	return lines
+}
+
+// fields returns the typed fields of the struct, flattening embedded structs
+// declared in the package. Seen guards against recursive embedding.
+func (g *generator) fields(st structType, seen map[string]bool) ([]field, error) {
+	var fields []field
+	for _, f := range st.st.Fields.List {
+		key := ""
+		if f.Tag != nil {
+			tag, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return nil, fmt.Errorf("sqjdbgen: invalid tag %s: %w", f.Tag.Value, err)
+			}
+			key, _, _ = strings.Cut(reflect.StructTag(tag).Get("json"), ",")
+		}
+		if key == "-" {
+			continue
+		}
+		typ := f.Type
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+		if len(f.Names) == 0 {
+			ident, ok := typ.(*ast.Ident)
+			if !ok || !ast.IsExported(ident.Name) {
+				continue
+			}
+			embedded, local := g.structs[ident.Name]
+			if key == "" && local && !seen[ident.Name] {
+				seen[ident.Name] = true
+				promoted, err := g.fields(embedded, seen)
+				if err != nil {
+					return nil, err
+				}
+				for _, p := range promoted {
+					if !slices.ContainsFunc(fields, func(f field) bool { return f.goName == p.goName }) {
+						fields = append(fields, p)
+					}
+				}
+				continue
+			}
+			f.Names = []*ast.Ident{ident}
+		}
+		typName, err := g.typeString(typ, st.file)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range f.Names {
+			if !ast.IsExported(name.Name) {
+				continue
+			}
+			k := key
+			if k == "" {
+				k = name.Name
+			}
+			fields = append(fields, field{goName: name.Name, key: k, typ: typName})
+		}
+	}
+	return fields, nil
+}
+
+// typeString returns the source of the type, recording the imports it uses.
+func (g *generator) typeString(typ ast.Expr, file *ast.File) (string, error) {
+	var err error
+	ast.Inspect(typ, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		pkg, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		imp, found := findImport(file, pkg.Name)
+		if !found {
+			err = fmt.Errorf("sqjdbgen: import for %s not found", pkg.Name)
+			return false
+		}
+		g.imports[imp] = true
+		return false
+	})
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, g.fset, typ); err != nil {
+		return "", fmt.Errorf("sqjdbgen: formatting type: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// findImport returns the import of the file for the package name, as the path,
+// or the name and path separated by a space if it is renamed.
+func findImport(file *ast.File, name string) (string, bool) {
+	for _, imp := range file.Imports {
+		p, _ := strconv.Unquote(imp.Path.Value)
+		if imp.Name != nil {
+			if imp.Name.Name == name {
+				return name + " " + p, true
+			}
+			continue
+		}
+		if path.Base(p) == name {
+			return p, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/daaku/ensure"
+)
+
+func TestGenerate(t *testing.T) {
+	src, err := generate("testdata/jedi", []string{"Jedi"})
+	ensure.Nil(t, err)
+	expected, err := os.ReadFile("testdata/jedi_fields.go.golden")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, string(src), string(expected))
+}
+
+func TestGenerateMissingType(t *testing.T) {
+	_, err := generate("testdata/jedi", []string{"Sith"})
+	ensure.NotNil(t, err)
+	ensure.StringContains(t, err.Error(), "Sith not found")
+}
@@ -0,0 +1,22 @@
+package jedi
+
+import (
+	"time"
+
+	"github.com/daaku/sqjdb"
+)
+
+type Base struct {
+	ID      string
+	Created time.Time `json:"created"`
+}
+
+type Jedi struct {
+	Base
+	Name    string `json:"name,omitempty"`
+	Age     *int
+	Secret  string `json:"-"`
+	private int
+	Filter  sqjdb.SQL
+	Master  Base `json:"master"`
+}
@@ -0,0 +1,168 @@
+// Command sqjdbrepl queries the documents of a sqjdb database interactively,
+// which is more ergonomic than the sqlite3 shell for JSONB documents:
+//
+//	sqjdbrepl -table jedis app.db
+//
+// Each line is a MongoDB style filter, as accepted by sqjdb.ParseFilter, and
+// prints the first page of matching documents, one JSON document per line. An
+// empty line or "next" prints the following page. "table <name>" switches to
+// another table, "page <size>" changes the page size, and "quit" exits. The
+// database is opened read only.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+)
+
+func main() {
+	table := flag.String("table", "", "table to query")
+	pageSize := flag.Int("page", 20, "documents per page")
+	flag.Parse()
+	if flag.NArg() != 1 || *table == "" {
+		fmt.Fprintln(os.Stderr, "usage: sqjdbrepl -table <name> [-page <size>] <database>")
+		os.Exit(2)
+	}
+	conn, err := sqjdb.Open(flag.Arg(0), sqjdb.ReadOnly())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer sqjdb.Close(conn)
+	r := newREPL(conn, *table, *pageSize, os.Stdout)
+	if err := r.run(os.Stdin); err != nil {
+		fmt.Fprintln(os.Stderr, "sqjdbrepl:", err)
+		os.Exit(1)
+	}
+}
+
+// errQuit ends the session.
+var errQuit = errors.New("quit")
+
+type repl struct {
+	conn     *sqlite.Conn
+	table    sqjdb.Table[json.RawMessage]
+	pageSize int
+	out      io.Writer
+	// cond is the current filter, and offset the number of matching documents
+	// already printed.
+	cond   sqjdb.Cond
+	offset int
+	// more is set if there are documents after the last page.
+	more bool
+}
+
+func newREPL(conn *sqlite.Conn, table string, pageSize int, out io.Writer) *repl {
+	return &repl{
+		conn:     conn,
+		table:    sqjdb.NewTable[json.RawMessage](table),
+		pageSize: pageSize,
+		out:      out,
+		cond:     sqjdb.Cond{Expr: "1"},
+	}
+}
+
+// run reads commands until the input ends or quit is entered. Errors from
+// commands are printed, and do not end the session.
+func (r *repl) run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 1<<20)
+	fmt.Fprint(r.out, r.prompt())
+	for scanner.Scan() {
+		err := r.exec(scanner.Text())
+		if errors.Is(err, errQuit) {
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintln(r.out, "error:", err)
+		}
+		fmt.Fprint(r.out, r.prompt())
+	}
+	return scanner.Err()
+}
+
+func (r *repl) prompt() string {
+	return r.table.Name + "> "
+}
+
+// exec runs a single command.
+func (r *repl) exec(line string) error {
+	line = strings.TrimSpace(line)
+	cmd, arg, _ := strings.Cut(line, " ")
+	switch cmd {
+	case "", "next":
+		if !r.more {
+			return errors.New("no more documents")
+		}
+		return r.page()
+	case "quit", "exit":
+		return errQuit
+	case "help":
+		fmt.Fprintln(r.out, "{filter}      print the documents matching the filter")
+		fmt.Fprintln(r.out, "next          print the next page, also an empty line")
+		fmt.Fprintln(r.out, "table <name>  query another table")
+		fmt.Fprintln(r.out, "page <size>   set the page size")
+		fmt.Fprintln(r.out, "quit          exit")
+		return nil
+	case "table":
+		if arg == "" {
+			return errors.New("table expects a name")
+		}
+		*r = *newREPL(r.conn, arg, r.pageSize, r.out)
+		return nil
+	case "page":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid page size %q", arg)
+		}
+		r.pageSize = n
+		return nil
+	}
+	if !strings.HasPrefix(line, "{") {
+		return fmt.Errorf("unknown command %q, try help", cmd)
+	}
+	f, err := sqjdb.ParseFilter([]byte(line))
+	if err != nil {
+		return err
+	}
+	cond, err := r.table.Filter(f)
+	if err != nil {
+		return err
+	}
+	r.cond, r.offset = cond, 0
+	return r.page()
+}
+
+// page prints the next page of documents matching the current filter.
+func (r *repl) page() error {
+	// Fetch one extra document to know if there is a next page.
+	docs, err := r.table.All(r.conn, r.cond.SQL(), sqjdb.SQL{Query: "order by rowid"},
+		sqjdb.Limit(r.pageSize+1), sqjdb.Offset(r.offset))
+	if err != nil {
+		return err
+	}
+	r.more = len(docs) > r.pageSize
+	if r.more {
+		docs = docs[:r.pageSize]
+	}
+	for _, doc := range docs {
+		fmt.Fprintln(r.out, string(*doc))
+	}
+	r.offset += len(docs)
+	if r.more {
+		fmt.Fprintln(r.out, "-- more, press enter --")
+	} else {
+		fmt.Fprintf(r.out, "-- %d documents --\n", r.offset)
+	}
+	return nil
+}
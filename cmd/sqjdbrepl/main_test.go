@@ -0,0 +1,70 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+)
+
+type Jedi struct {
+	ID   string `json:",omitempty"`
+	Name string `json:",omitempty"`
+	Age  int    `json:",omitempty"`
+}
+
+func newConn(t *testing.T) *sqlite.Conn {
+	conn, err := sqlite.OpenConn(filepath.Join(t.TempDir(), "db"))
+	ensure.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+	jedis := sqjdb.NewTable[Jedi]("jedis")
+	ensure.Nil(t, jedis.Migrate(conn))
+	for _, jedi := range []*Jedi{
+		{ID: "1", Name: "yoda", Age: 980},
+		{ID: "2", Name: "luke", Age: 42},
+		{ID: "3", Name: "leia", Age: 42},
+	} {
+		_, err := jedis.Insert(conn, jedi)
+		ensure.Nil(t, err)
+	}
+	return conn
+}
+
+func TestREPL(t *testing.T) {
+	var out strings.Builder
+	r := newREPL(newConn(t), "jedis", 1, &out)
+	ensure.Nil(t, r.run(strings.NewReader(`{"Age": 42}`+"\n\nnext\n")))
+	ensure.DeepEqual(t, out.String(), `jedis> {"ID":"2","Name":"luke","Age":42}
+-- more, press enter --
+jedis> {"ID":"3","Name":"leia","Age":42}
+-- 2 documents --
+jedis> error: no more documents
+jedis> `)
+}
+
+func TestREPLCommands(t *testing.T) {
+	var out strings.Builder
+	r := newREPL(newConn(t), "sith", 1, &out)
+	input := `{"Name": "vader"}
+table jedis
+page 5
+{"Age": {"$gt": 100}}
+{"Age": {"$near": 1}}
+frob
+quit
+{}
+`
+	ensure.Nil(t, r.run(strings.NewReader(input)))
+	lines := strings.Split(out.String(), "\n")
+	ensure.StringContains(t, lines[0], "sith> error: ")
+	ensure.DeepEqual(t, lines[1:], []string{
+		`sith> jedis> jedis> {"ID":"1","Name":"yoda","Age":980}`,
+		`-- 1 documents --`,
+		`jedis> error: sqjdb: unknown filter operator "$near" on "Age"`,
+		`jedis> error: unknown command "frob", try help`,
+		`jedis> `,
+	})
+}
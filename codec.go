@@ -0,0 +1,155 @@
+package sqjdb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// Codec converts JSON documents to and from the form they are stored in, such
+// as MessagePack, CBOR or a compressed encoding.
+type Codec interface {
+	// Name identifies the codec. It is stored in the table schema, so it must
+	// not change once a table uses the codec, and codecs of the same name must
+	// decode alike, though they may encode differently, as Gzip does with
+	// different thresholds.
+	Name() string
+	// Encode converts a JSON document to the stored form.
+	Encode(json []byte) ([]byte, error)
+	// Decode converts a stored document back to JSON.
+	Decode(data []byte) ([]byte, error)
+}
+
+// UseCodec stores documents encoded with the codec. The encoded document is
+// stored in a column named "raw", and the "data" column becomes a virtual
+// generated column decoding it, so queries and indexes on document fields
+// work as usual. Because the schema uses SQL functions named after the table,
+// Table.RegisterFunctions must be called on every connection that uses the
+// table. Migrate does so on its connection.
+func UseCodec(c Codec) Option {
+	return func(o *options) {
+		o.codec = c
+	}
+}
+
+// codecFunc returns the name of the SQL function doing op, either encode or
+// decode, with the codec of the named table.
+func codecFunc(table, op string) string {
+	return "sqjdb_" + op + "_" + sanitizeName(table)
+}
+
+// RegisterFunctions registers the SQL functions used by the table on the
+// connection. Only tables using UseCodec need any. With a sqlitex.Pool, call
+// it from PoolOptions.PrepareConn.
+func (t *Table[T]) RegisterFunctions(conn *sqlite.Conn) error {
+	c := t.opts.codec
+	if c == nil {
+		return nil
+	}
+	// The decode function is used by the generated column and its indexes.
+	err := conn.CreateFunction(codecFunc(t.Name, "decode"), &sqlite.FunctionImpl{
+		NArgs:         1,
+		Deterministic: true,
+		AllowIndirect: true,
+		Scalar: func(ctx sqlite.Context, args []sqlite.Value) (sqlite.Value, error) {
+			if args[0].Type() == sqlite.TypeNull {
+				return sqlite.Value{}, nil
+			}
+			jsonS, err := c.Decode(args[0].Blob())
+			if err != nil {
+				return sqlite.Value{}, fmt.Errorf("sqjdb: decoding with %q: %w", c.Name(), err)
+			}
+			return sqlite.TextValue(string(jsonS)), nil
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("sqjdb: registering functions for %q: %w", t.Name, err)
+	}
+	err = conn.CreateFunction(codecFunc(t.Name, "encode"), &sqlite.FunctionImpl{
+		NArgs:         1,
+		Deterministic: true,
+		Scalar: func(ctx sqlite.Context, args []sqlite.Value) (sqlite.Value, error) {
+			if args[0].Type() == sqlite.TypeNull {
+				return sqlite.Value{}, nil
+			}
+			data, err := c.Encode([]byte(args[0].Text()))
+			if err != nil {
+				return sqlite.Value{}, fmt.Errorf("sqjdb: encoding with %q: %w", c.Name(), err)
+			}
+			return sqlite.BlobValue(data), nil
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("sqjdb: registering functions for %q: %w", t.Name, err)
+	}
+	return nil
+}
+
+// createTableSQL returns the statement to create the table.
+func (t *Table[T]) createTableSQL() string {
+	if t.opts.codec == nil {
+		return "create table if not exists " + t.Name + " (data blob)"
+	}
+	return "create table if not exists " + t.Name + " (raw blob, data blob generated always as" +
+		" (jsonb(" + codecFunc(t.Name, "decode") + "(raw))) virtual)"
+}
+
+// assignData returns the set clause storing the JSONB expression as the
+// document.
+func (t *Table[T]) assignData(expr SQL) SQL {
+	if t.opts.codec == nil {
+		return SQL{Query: "set data = " + expr.Query, Args: expr.Args}
+	}
+	return SQL{
+		Query: "set raw = " + codecFunc(t.Name, "encode") + "(json(" + expr.Query + "))",
+		Args:  expr.Args,
+	}
+}
+
+// gzipMagic starts every gzip stream, and marks compressed documents.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+type gzipCodec struct {
+	threshold int
+}
+
+// Gzip returns a Codec that compresses documents of at least threshold bytes
+// of JSON with gzip, and stores smaller documents as JSON. Compressed
+// documents are recognized by the gzip header, so the threshold can change
+// without affecting existing documents.
+func Gzip(threshold int) Codec {
+	return gzipCodec{threshold: threshold}
+}
+
+func (gzipCodec) Name() string {
+	return "gzip"
+}
+
+func (c gzipCodec) Encode(jsonS []byte) ([]byte, error) {
+	if len(jsonS) < c.threshold {
+		return jsonS, nil
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(jsonS); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) Decode(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
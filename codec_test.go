@@ -0,0 +1,173 @@
+package sqjdb_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string { return "test-gzip" }
+
+func (gzipCodec) Encode(jsonS []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(jsonS); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) Decode(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestUseCodec(t *testing.T) {
+	conn := newConn(t)
+	gz := sqjdb.NewTable[Jedi](t.Name(),
+		sqjdb.UseCodec(gzipCodec{}),
+		sqjdb.Versioned("Age"),
+		sqjdb.Indexes(sqjdb.IndexSpec{Fields: []string{"Name"}}),
+	)
+	ensure.Nil(t, gz.Migrate(conn))
+
+	doc, err := gz.Insert(conn, &Jedi{Name: "ahsoka"})
+	ensure.Nil(t, err)
+	fetched, err := gz.One(conn, sqjdb.Where("Name").Eq("ahsoka").SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, fetched.ID, doc.ID)
+	ensure.DeepEqual(t, fetched.Age, 1)
+	ensure.True(t, usesIndex(t, conn, &gz, t.Name()+"_Name", sqjdb.Where("Name").Eq("ahsoka").SQL()))
+
+	n, err := gz.Patch(conn, &Jedi{Name: "fulcrum", Age: 1}, gz.ByID(doc.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+	fetched, err = gz.Get(conn, doc.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, fetched.Name, "fulcrum")
+	ensure.DeepEqual(t, fetched.Age, 2)
+	_, err = gz.Upsert(conn, &Jedi{ID: doc.ID, Name: "ahsoka", Age: 3})
+	ensure.Nil(t, err)
+	fetched, err = gz.Get(conn, doc.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, fetched.Name, "ahsoka")
+
+	var raw []byte
+	err = sqlitex.Execute(conn, "select raw from "+t.Name(), &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			raw = make([]byte, stmt.ColumnLen(0))
+			stmt.ColumnBytes(0, raw)
+			return nil
+		},
+	})
+	ensure.Nil(t, err)
+	ensure.True(t, bytes.HasPrefix(raw, []byte{0x1f, 0x8b}))
+}
+
+func TestGzip(t *testing.T) {
+	conn := newConn(t)
+	gz := sqjdb.NewTable[Jedi](t.Name(), sqjdb.UseCodec(sqjdb.Gzip(100)))
+	ensure.Nil(t, gz.Migrate(conn))
+	small, err := gz.Insert(conn, &Jedi{Name: "small"})
+	ensure.Nil(t, err)
+	large, err := gz.Insert(conn, &Jedi{Name: strings.Repeat("large", 100)})
+	ensure.Nil(t, err)
+
+	sizes := map[string]int{}
+	err = sqlitex.Execute(conn, "select data->>'ID', length(raw) from "+t.Name(), &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			sizes[stmt.ColumnText(0)] = stmt.ColumnInt(1)
+			return nil
+		},
+	})
+	ensure.Nil(t, err)
+	ensure.True(t, sizes[small.ID] < 100)
+	ensure.True(t, sizes[large.ID] < 100)
+
+	docs, err := gz.All(conn, sqjdb.OrderBy("Name", sqjdb.Asc))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docs, []*Jedi{large, small})
+}
+
+func TestGzipThresholdPerTable(t *testing.T) {
+	conn := newConn(t)
+	always := sqjdb.NewTable[Jedi](t.Name()+"_always", sqjdb.UseCodec(sqjdb.Gzip(0)))
+	never := sqjdb.NewTable[Jedi](t.Name()+"_never", sqjdb.UseCodec(sqjdb.Gzip(1<<20)))
+	for _, table := range []*sqjdb.Table[Jedi]{&always, &never} {
+		ensure.Nil(t, table.Migrate(conn))
+		doc, err := table.Insert(conn, &Jedi{Name: "ahsoka"})
+		ensure.Nil(t, err)
+		_, err = table.Patch(conn, &Jedi{Name: "fulcrum"}, table.ByID(doc.ID))
+		ensure.Nil(t, err)
+	}
+
+	compressed := func(name string) bool {
+		var raw []byte
+		err := sqlitex.Execute(conn, "select raw from "+name, &sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				raw = make([]byte, stmt.ColumnLen(0))
+				stmt.ColumnBytes(0, raw)
+				return nil
+			},
+		})
+		ensure.Nil(t, err)
+		return bytes.HasPrefix(raw, []byte{0x1f, 0x8b})
+	}
+	ensure.True(t, compressed(always.Name))
+	ensure.False(t, compressed(never.Name))
+}
+
+func TestMixedTableCodec(t *testing.T) {
+	conn := newConn(t)
+	vehicles := sqjdb.NewMixedTable[Vehicle](t.Name(), "Type", sqjdb.UseCodec(sqjdb.Gzip(0)))
+	cars := sqjdb.RegisterType[Car](vehicles, "car")
+	bikes := sqjdb.RegisterType[Bike](vehicles, "bike")
+	ensure.Nil(t, vehicles.Migrate(conn))
+
+	car, err := cars.Insert(conn, &Car{Model: "t"})
+	ensure.Nil(t, err)
+	_, err = bikes.Insert(conn, &Bike{Gears: 21})
+	ensure.Nil(t, err)
+	onlyCars, err := cars.All(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, onlyCars, []*Car{car})
+	_, err = bikes.Upsert(conn, &Bike{ID: car.ID})
+	ensure.NotNil(t, err)
+}
+
+func TestCodecRegisterFunctions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	conn, err := sqlite.OpenConn(path)
+	ensure.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+	gz := sqjdb.NewTable[Jedi](t.Name(), sqjdb.UseCodec(sqjdb.Gzip(0)))
+	ensure.Nil(t, gz.Migrate(conn))
+	doc, err := gz.Insert(conn, &Jedi{Name: "ahsoka"})
+	ensure.Nil(t, err)
+
+	other, err := sqlite.OpenConn(path)
+	ensure.Nil(t, err)
+	t.Cleanup(func() { other.Close() })
+	_, err = gz.Get(other, doc.ID)
+	ensure.NotNil(t, err)
+	ensure.Nil(t, gz.RegisterFunctions(other))
+	fetched, err := gz.Get(other, doc.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, fetched, doc)
+}
@@ -0,0 +1,67 @@
+package sqjdb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// Errors matched with errors.Is, so callers need not inspect SQLite errors.
+var (
+	// ErrUniqueViolation matches a UniqueViolationError.
+	ErrUniqueViolation = errors.New("sqjdb: unique violation")
+	// ErrCheckFailed matches a CheckError.
+	ErrCheckFailed = errors.New("sqjdb: check constraint failed")
+	// ErrBusy matches errors caused by the database being busy or locked by
+	// another connection, for which IsBusy reports true.
+	ErrBusy = errors.New("sqjdb: database busy")
+)
+
+// CheckError is returned when a write fails a CHECK constraint on the table.
+// It matches ErrCheckFailed with errors.Is.
+type CheckError struct {
+	// Constraint is the name of the constraint, or its expression if it is
+	// not named.
+	Constraint string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *CheckError) Error() string {
+	return fmt.Sprintf("sqjdb: check constraint %q failed: %v", e.Constraint, e.Err)
+}
+
+func (e *CheckError) Unwrap() error {
+	return e.Err
+}
+
+func (e *CheckError) Is(target error) bool {
+	return target == ErrCheckFailed
+}
+
+// writeError converts errors from writes into UniqueViolationError, CheckError
+// or ErrBusy errors, and returns other errors as is.
+func (t *Table[T]) writeError(err error) error {
+	switch sqlite.ErrCode(err) {
+	case sqlite.ResultConstraintUnique:
+		return t.uniqueViolation(err)
+	case sqlite.ResultConstraintCheck:
+		constraint := err.Error()
+		if _, after, ok := strings.Cut(constraint, "CHECK constraint failed: "); ok {
+			constraint = after
+		}
+		return &CheckError{Constraint: constraint, Err: err}
+	}
+	return busyError(err)
+}
+
+// busyError wraps busy errors to match ErrBusy, and returns other errors as
+// is.
+func busyError(err error) error {
+	if err == nil || !IsBusy(err) || errors.Is(err, ErrBusy) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrBusy, err)
+}
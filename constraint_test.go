@@ -0,0 +1,63 @@
+package sqjdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+func TestUniqueViolationIs(t *testing.T) {
+	conn := newConn(t)
+	_, err := jedis.Insert(conn, &Jedi{ID: yoda.ID})
+	ensure.True(t, errors.Is(err, sqjdb.ErrUniqueViolation), err)
+	var uerr *sqjdb.UniqueViolationError
+	ensure.True(t, errors.As(err, &uerr))
+	ensure.DeepEqual(t, uerr.Field, "ID")
+	ensure.DeepEqual(t, uerr.Index, "jedis_ID")
+	ensure.False(t, errors.Is(err, sqjdb.ErrCheckFailed))
+}
+
+func TestUniqueViolationIndex(t *testing.T) {
+	conn := newConn(t)
+	ensure.Nil(t, jedis.CreateIndex(conn, sqjdb.IndexSpec{
+		Name:   "jedis_name_age",
+		Fields: []string{"Name", "Age"},
+		Unique: true,
+	}))
+	_, err := jedis.Insert(conn, &Jedi{Name: luke.Name, Age: luke.Age})
+	var uerr *sqjdb.UniqueViolationError
+	ensure.True(t, errors.As(err, &uerr), err)
+	ensure.DeepEqual(t, uerr.Field, "")
+	ensure.DeepEqual(t, uerr.Index, "jedis_name_age")
+	ensure.StringContains(t, err.Error(), `sqjdb: unique violation on index "jedis_name_age"`)
+}
+
+func TestCheckError(t *testing.T) {
+	conn := newConn(t)
+	ensure.Nil(t, sqlitex.ExecuteTransient(conn,
+		"create table adults (data blob, constraint adult check (data->>'Age' >= 18))", nil))
+	adults := sqjdb.NewTable[Jedi]("adults")
+	ensure.Nil(t, adults.Migrate(conn))
+	_, err := adults.Insert(conn, &Jedi{Name: "grogu", Age: 3})
+	ensure.True(t, errors.Is(err, sqjdb.ErrCheckFailed), err)
+	var cerr *sqjdb.CheckError
+	ensure.True(t, errors.As(err, &cerr))
+	ensure.DeepEqual(t, cerr.Constraint, "adult")
+
+	doc, err := adults.Insert(conn, &Jedi{Name: "din", Age: 30})
+	ensure.Nil(t, err)
+	_, err = adults.Patch(conn, &Jedi{Age: 10}, adults.ByID(doc.ID))
+	ensure.True(t, errors.Is(err, sqjdb.ErrCheckFailed), err)
+}
+
+func TestErrBusy(t *testing.T) {
+	conn, other := newLockedConns(t)
+	_, err := jedis.Insert(other, &Jedi{Name: "grogu"})
+	ensure.Nil(t, err)
+	_, err = jedis.Insert(conn, &Jedi{Name: "din"})
+	ensure.True(t, errors.Is(err, sqjdb.ErrBusy), err)
+	ensure.True(t, sqjdb.IsBusy(err))
+}
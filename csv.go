@@ -0,0 +1,78 @@
+package sqjdb
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// CSVColumn is a column written by ExportCSV.
+type CSVColumn struct {
+	// Header is the column header. It defaults to Path.
+	Header string
+	// Path is the document field name, or a JSON path such as "$.Address.City"
+	// for nested fields. Objects and arrays are written as JSON, and missing
+	// fields as empty values.
+	Path string
+}
+
+// ExportCSV writes the documents per the given query to w as CSV, with a
+// header row followed by one row per document containing the given columns.
+func (t *Table[T]) ExportCSV(conn *sqlite.Conn, w io.Writer, columns []CSVColumn, sqls ...SQL) (err error) {
+	if len(columns) == 0 {
+		return fmt.Errorf("sqjdb: no columns to export from %q", t.Name)
+	}
+	var query strings.Builder
+	query.WriteString("select ")
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteString(t.fieldSQL(c.Path))
+		header[i] = c.Header
+		if header[i] == "" {
+			header[i] = c.Path
+		}
+	}
+	query.WriteString(" from")
+	sqls = slices.Concat([]SQL{t.from()}, sqls)
+	addSQLQuery(&query, sqls)
+	sp := t.trace(conn, "export_csv", query.String(), sqls)
+	defer func() { err = sp.finish(err) }()
+	stmt, err := prepareSQL(conn, query.String(), sqls)
+	if err != nil {
+		return err
+	}
+	defer releaseStmt(stmt)
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("sqjdb: exporting %q: %w", t.Name, err)
+	}
+	record := make([]string, len(columns))
+	for {
+		rowReturned, err := stmt.Step()
+		if err != nil {
+			return err
+		}
+		if !rowReturned {
+			break
+		}
+		sp.addRows(1)
+		for i := range record {
+			record[i] = stmt.ColumnText(i)
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("sqjdb: exporting %q: %w", t.Name, err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("sqjdb: exporting %q: %w", t.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package sqjdb_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestExportCSV(t *testing.T) {
+	conn := newConn(t)
+	var out strings.Builder
+	err := jedis.ExportCSV(conn, &out,
+		[]sqjdb.CSVColumn{{Header: "Jedi", Path: "Name"}, {Path: "Age"}},
+		sqjdb.OrderBy("Name", sqjdb.Asc))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, out.String(), "Jedi,Age\nleia,42\nluke,42\nyoda,980\n")
+}
+
+func TestExportCSVNested(t *testing.T) {
+	conn := newConn(t)
+	padawans := sqjdb.NewTable[Padawan](t.Name())
+	ensure.Nil(t, padawans.Migrate(conn))
+	_, err := padawans.Insert(conn, &Padawan{
+		Name:   "ahsoka",
+		Master: map[string]any{"Name": "anakin"},
+		Sabers: []string{"green", "white"},
+	})
+	ensure.Nil(t, err)
+	_, err = padawans.Insert(conn, &Padawan{Name: "grogu"})
+	ensure.Nil(t, err)
+	var out strings.Builder
+	err = padawans.ExportCSV(conn, &out, []sqjdb.CSVColumn{
+		{Path: "Name"},
+		{Header: "Master", Path: "$.Master.Name"},
+		{Path: "Sabers"},
+	}, sqjdb.OrderBy("Name", sqjdb.Asc))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, out.String(),
+		"Name,Master,Sabers\nahsoka,anakin,\"[\"\"green\"\",\"\"white\"\"]\"\ngrogu,,\n")
+}
+
+func TestExportCSVNoColumns(t *testing.T) {
+	conn := newConn(t)
+	var out strings.Builder
+	ensure.NotNil(t, jedis.ExportCSV(conn, &out, nil))
+}
@@ -0,0 +1,67 @@
+package sqjdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// DB is a database with a single write connection and a pool of read only
+// connections. SQLite allows only one writer at a time, but in WAL mode
+// readers do not block it or each other, so routing reads to their own
+// connections lets them proceed while a write is in progress. Use Table.WithDB
+// to access tables through it.
+type DB struct {
+	Writer  *sqlitex.Pool
+	Readers *sqlitex.Pool
+}
+
+// OpenDB opens the database at path with the given number of read connections,
+// configuring every connection as Open does. The database must be a file, since
+// in memory databases do not support WAL mode.
+func OpenDB(path string, readers int, opts ...OpenOption) (*DB, error) {
+	o := newOpenOptions(opts)
+	prepare := func(conn *sqlite.Conn) error { return o.prepareConn(conn) }
+	writer, err := sqlitex.NewPool(path, sqlitex.PoolOptions{
+		Flags:       o.flags,
+		PoolSize:    1,
+		PrepareConn: prepare,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sqjdb: opening %q: %w", path, err)
+	}
+	// Make sure the database exists and is in WAL mode before readers open it.
+	conn, err := writer.Take(context.Background())
+	if err != nil {
+		writer.Close()
+		return nil, fmt.Errorf("sqjdb: opening %q: %w", path, err)
+	}
+	writer.Put(conn)
+	ReadOnly()(o)
+	pool, err := sqlitex.NewPool(path, sqlitex.PoolOptions{
+		Flags:       o.flags,
+		PoolSize:    readers,
+		PrepareConn: prepare,
+	})
+	if err != nil {
+		writer.Close()
+		return nil, fmt.Errorf("sqjdb: opening %q: %w", path, err)
+	}
+	return &DB{Writer: writer, Readers: pool}, nil
+}
+
+// Close closes all the connections.
+func (db *DB) Close() error {
+	return errors.Join(db.Readers.Close(), db.Writer.Close())
+}
+
+// WithDB returns a PoolTable that uses the write connection of the DB for
+// writes, and the read connections for One, All, Count, Get, GetMany, Iter,
+// Watch and the other read only methods. Tables using WriteBackUpgrades read
+// from the write connection.
+func (t *Table[T]) WithDB(db *DB) *PoolTable[T] {
+	return &PoolTable[T]{Table: t, Pool: db.Writer, readers: db.Readers}
+}
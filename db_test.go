@@ -0,0 +1,48 @@
+package sqjdb_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+)
+
+func TestDB(t *testing.T) {
+	db, err := sqjdb.OpenDB(filepath.Join(t.TempDir(), "db"), 2)
+	ensure.Nil(t, err)
+	defer db.Close()
+	djedis := jedis.WithDB(db)
+	ctx := context.Background()
+	ensure.Nil(t, djedis.Migrate(ctx))
+	doc, err := djedis.Insert(ctx, &Jedi{Name: "grogu"})
+	ensure.Nil(t, err)
+	fetched, err := djedis.Get(ctx, doc.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, fetched, doc)
+
+	// Reads proceed while a write transaction is open.
+	err = djedis.Do(ctx, func(conn *sqlite.Conn) error {
+		return sqjdb.WithTx(conn, func(conn *sqlite.Conn) error {
+			_, err := jedis.Insert(conn, &Jedi{Name: "din"})
+			ensure.Nil(t, err)
+			n, err := djedis.Count(ctx)
+			ensure.Nil(t, err)
+			ensure.DeepEqual(t, n, 1)
+			return nil
+		})
+	})
+	ensure.Nil(t, err)
+	n, err := djedis.Count(ctx)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 2)
+
+	// Read connections can not write.
+	err = djedis.Read(ctx, func(conn *sqlite.Conn) error {
+		_, err := jedis.Insert(conn, &Jedi{Name: "cara"})
+		return err
+	})
+	ensure.NotNil(t, err)
+}
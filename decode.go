@@ -0,0 +1,47 @@
+package sqjdb
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// DisallowUnknownFields makes One, All and Iter fail when a stored document
+// contains fields that are not in the struct, which would otherwise be
+// silently dropped when the document is written back with Replace.
+func DisallowUnknownFields() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// WarnUnknownFields calls fn when One, All or Iter read a stored document
+// containing fields that are not in the struct, and decodes the document as
+// usual. The error names the first unknown field. It is ignored if
+// DisallowUnknownFields is also used.
+func WarnUnknownFields(fn func(table string, err error)) Option {
+	return func(o *options) {
+		o.onUnknownField = fn
+	}
+}
+
+// unmarshal decodes a document read from the table, checking for unknown
+// fields if configured.
+func (t *Table[T]) unmarshal(jsonS []byte, v *T) error {
+	if !t.opts.strict && t.opts.onUnknownField == nil {
+		return json.Unmarshal(jsonS, v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(jsonS))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil || t.opts.strict {
+		return err
+	}
+	// The document is otherwise valid if it decodes without the check, so
+	// the strict decode only failed on an unknown field.
+	*v = *new(T)
+	if err := json.Unmarshal(jsonS, v); err != nil {
+		return err
+	}
+	t.opts.onUnknownField(t.Name, err)
+	return nil
+}
@@ -0,0 +1,80 @@
+package sqjdb_test
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+type JediName struct {
+	ID   string `json:",omitempty"`
+	Name string `json:",omitempty"`
+}
+
+func TestDisallowUnknownFields(t *testing.T) {
+	conn := newConn(t)
+	strict := sqjdb.NewTable[JediName]("jedis", sqjdb.DisallowUnknownFields())
+	_, err := strict.Get(conn, yoda.ID)
+	ensure.NotNil(t, err)
+	ensure.True(t, strings.Contains(err.Error(), `unknown field "Age"`))
+	_, err = strict.All(conn)
+	ensure.NotNil(t, err)
+}
+
+func TestWarnUnknownFields(t *testing.T) {
+	conn := newConn(t)
+	var warnings []string
+	lenient := sqjdb.NewTable[JediName]("jedis", sqjdb.WarnUnknownFields(func(table string, err error) {
+		warnings = append(warnings, table+": "+err.Error())
+	}))
+	doc, err := lenient.Get(conn, yoda.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, doc, &JediName{ID: yoda.ID, Name: yoda.Name})
+	ensure.DeepEqual(t, warnings, []string{`jedis: json: unknown field "Age"`})
+}
+
+func TestWarnUnknownFieldsInvalidDocument(t *testing.T) {
+	conn := newConn(t)
+	var warnings []string
+	lenient := sqjdb.NewTable[JediName](t.Name(), sqjdb.WarnUnknownFields(func(table string, err error) {
+		warnings = append(warnings, err.Error())
+	}))
+	ensure.Nil(t, lenient.Migrate(conn))
+	_, err := lenient.InsertRaw(conn, json.RawMessage(`{"Extra":1,"Name":42}`))
+	ensure.Nil(t, err)
+	_, err = lenient.All(conn)
+	ensure.NotNil(t, err)
+	ensure.StringContains(t, err.Error(), "cannot unmarshal number")
+	ensure.DeepEqual(t, len(warnings), 0)
+}
+
+func TestDecodeErrorReleasesStatement(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	conn, err := sqlite.OpenConn(path)
+	ensure.Nil(t, err)
+	defer conn.Close()
+	ensure.Nil(t, jedis.Migrate(conn))
+	for _, jedi := range []*Jedi{&yoda, &luke} {
+		_, err := jedis.Insert(conn, jedi)
+		ensure.Nil(t, err)
+	}
+	strict := sqjdb.NewTable[JediName]("jedis", sqjdb.DisallowUnknownFields())
+	_, err = strict.All(conn)
+	ensure.NotNil(t, err)
+
+	// The failed read must not keep holding its read transaction, which
+	// would block checkpoints.
+	other, err := sqlite.OpenConn(path)
+	ensure.Nil(t, err)
+	defer other.Close()
+	other.SetBusyTimeout(0)
+	busy, err := sqlitex.ResultInt(other.Prep("pragma wal_checkpoint(truncate)"))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, busy, 0)
+}
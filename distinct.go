@@ -0,0 +1,79 @@
+package sqjdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// distinct runs fn for each unique value of the named field over the documents
+// per the given query, in order. The value is selected with expr, using the
+// field expression as k. Documents without the field are skipped.
+func (t *Table[T]) distinct(conn *sqlite.Conn, expr, name string, sqls []SQL, fn func(*sqlite.Stmt) error) (err error) {
+	var query strings.Builder
+	query.WriteString("select distinct " + expr + ", k from (select *, " + t.fieldSQL(name) + " as k from")
+	sqls = slices.Concat(
+		[]SQL{t.from()},
+		sqls,
+		[]SQL{{Query: ") where k is not null order by k"}},
+	)
+	addSQLQuery(&query, sqls)
+	sp := t.trace(conn, "distinct", query.String(), sqls)
+	defer func() { err = sp.finish(err) }()
+	stmt, err := prepareSQL(conn, query.String(), sqls)
+	if err != nil {
+		return err
+	}
+	defer releaseStmt(stmt)
+	for {
+		rowReturned, err := stmt.Step()
+		if err != nil {
+			return err
+		}
+		if !rowReturned {
+			return nil
+		}
+		sp.addRows(1)
+		if err := fn(stmt); err != nil {
+			return err
+		}
+	}
+}
+
+// Distinct returns the unique values of the named field, as text, over the
+// documents per the given query. Documents without the field are skipped, and
+// the values are ordered.
+func (t *Table[T]) Distinct(conn *sqlite.Conn, name string, sqls ...SQL) ([]string, error) {
+	var values []string
+	err := t.distinct(conn, "k", name, sqls, func(stmt *sqlite.Stmt) error {
+		values = append(values, stmt.ColumnText(0))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// DistinctOf is like Table.Distinct, but decodes the values into V, which can
+// be any type the JSON values of the field decode into.
+func DistinctOf[V, T any](conn *sqlite.Conn, t *Table[T], name string, sqls ...SQL) ([]V, error) {
+	var values []V
+	var buf []byte
+	err := t.distinct(conn, "json("+fieldJSONExpr(t.FieldName(name))+")", name, sqls, func(stmt *sqlite.Stmt) error {
+		var v V
+		jsonS := columnBytes(stmt, 0, &buf)
+		if err := json.Unmarshal(jsonS, &v); err != nil {
+			return fmt.Errorf("sqjdb: invalid json from db: %w\n%s", err, jsonS)
+		}
+		values = append(values, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
@@ -0,0 +1,36 @@
+package sqjdb_test
+
+import (
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestDistinct(t *testing.T) {
+	conn := newConn(t)
+	ages, err := jedis.Distinct(conn, "Age")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, ages, []string{"42", "980"})
+	names, err := jedis.Distinct(conn, "Name", sqjdb.Where("Age").Eq(42).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, names, []string{"leia", "luke"})
+	missing, err := jedis.Distinct(conn, "Missing")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(missing), 0)
+}
+
+func TestDistinctOf(t *testing.T) {
+	conn := newConn(t)
+	ages, err := sqjdb.DistinctOf[int](conn, &jedis, "Age")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, ages, []int{42, 980})
+}
+
+func TestDistinctOfRenamedField(t *testing.T) {
+	conn := newConn(t)
+	citizens := newCitizens(t, conn)
+	planets, err := sqjdb.DistinctOf[string](conn, citizens, "Planet")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, planets, []string{"Corellia", "Socorro"})
+}
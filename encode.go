@@ -0,0 +1,39 @@
+package sqjdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// jsonParam is the placeholder for JSON text bound as bytes. Without the cast
+// SQLite treats blobs as JSONB, and may mistake short JSON text for it.
+const jsonParam = "cast(? as text)"
+
+// maxPooledBuffer is the capacity above which encode buffers are not reused,
+// so a few large documents do not pin memory.
+const maxPooledBuffer = 64 << 10
+
+var encodeBuffers = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// marshal encodes v like json.Marshal, but into a pooled buffer. The release
+// func puts the buffer back, after which the returned JSON must not be used.
+// Bound arguments are copied by SQLite, so it can be released once bound.
+func marshal(v any) ([]byte, func(), error) {
+	buf := encodeBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	release := func() {
+		if buf.Cap() <= maxPooledBuffer {
+			encodeBuffers.Put(buf)
+		}
+	}
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		release()
+		return nil, nil, fmt.Errorf("sqjdb: failed to json.Marshal: %w", err)
+	}
+	// Encode adds a trailing newline.
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), release, nil
+}
@@ -0,0 +1,87 @@
+package sqjdb
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// PlanStep is a step of a QueryPlan.
+type PlanStep struct {
+	ID     int64
+	Parent int64
+	// Depth is the number of ancestors of the step.
+	Depth int
+	// Detail describes the step, for example "SEARCH jedis USING INDEX
+	// jedis_ID (<expr>=?)".
+	Detail string
+}
+
+// QueryPlan is the plan SQLite uses to run a query, as reported by EXPLAIN
+// QUERY PLAN. Steps are in tree order, with children following their parent.
+type QueryPlan []PlanStep
+
+// String returns the plan one step per line, indented by depth.
+func (p QueryPlan) String() string {
+	var b strings.Builder
+	for _, step := range p {
+		b.WriteString(strings.Repeat("  ", step.Depth))
+		b.WriteString(step.Detail)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// UsesIndex reports whether any step of the plan uses the named index.
+func UsesIndex(plan QueryPlan, index string) bool {
+	return slices.ContainsFunc(plan, func(step PlanStep) bool {
+		_, after, found := strings.Cut(step.Detail, "INDEX "+index)
+		return found && (after == "" || after[0] == ' ')
+	})
+}
+
+// Explain returns the plan for selecting the documents per the given query, as
+// One and All do.
+func (t *Table[T]) Explain(conn *sqlite.Conn, sqls ...SQL) (QueryPlan, error) {
+	var query strings.Builder
+	query.WriteString("select json(data) from")
+	sqls = slices.Concat([]SQL{t.from()}, sqls)
+	addSQLQuery(&query, sqls)
+	return explain(conn, query.String(), sqls)
+}
+
+// explain returns the EXPLAIN QUERY PLAN output for the query.
+func explain(conn *sqlite.Conn, query string, sqls []SQL) (QueryPlan, error) {
+	// The statement is transient to not fill the connection cache with plans.
+	stmt, _, err := conn.PrepareTransient("explain query plan " + query)
+	if err != nil {
+		return nil, fmt.Errorf("sqjdb: failed to explain %q: %w", query, err)
+	}
+	defer stmt.Finalize()
+	if err := bindSQLQuery(stmt, sqls); err != nil {
+		return nil, err
+	}
+	var plan QueryPlan
+	depths := map[int64]int{}
+	for {
+		rowReturned, err := stmt.Step()
+		if err != nil {
+			return nil, fmt.Errorf("sqjdb: failed to explain %q: %w", query, err)
+		}
+		if !rowReturned {
+			return plan, nil
+		}
+		step := PlanStep{
+			ID:     stmt.ColumnInt64(0),
+			Parent: stmt.ColumnInt64(1),
+			Detail: stmt.ColumnText(3),
+		}
+		if depth, ok := depths[step.Parent]; ok {
+			step.Depth = depth + 1
+		}
+		depths[step.ID] = step.Depth
+		plan = append(plan, step)
+	}
+}
@@ -0,0 +1,26 @@
+package sqjdb_test
+
+import (
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestExplain(t *testing.T) {
+	conn := newConn(t)
+	plan, err := jedis.Explain(conn, jedis.ByID(yoda.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(plan), 1)
+	ensure.StringContains(t, plan[0].Detail, "SEARCH jedis USING INDEX jedis_ID ")
+	ensure.True(t, sqjdb.UsesIndex(plan, "jedis_ID"))
+	ensure.False(t, sqjdb.UsesIndex(plan, "jedis"))
+
+	plan, err = jedis.Explain(conn, sqjdb.Where("Age").Eq(42).SQL(), jedis.OrderBy("Name", sqjdb.Asc))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, plan.String(), "SCAN jedis\nUSE TEMP B-TREE FOR ORDER BY\n")
+	ensure.False(t, sqjdb.UsesIndex(plan, "jedis_ID"))
+
+	_, err = jedis.Explain(conn, sqjdb.SQL{Query: "where nope("})
+	ensure.NotNil(t, err)
+}
@@ -0,0 +1,257 @@
+package sqjdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Filter is a MongoDB style filter document, such as
+//
+//	{"Age": {"$gt": 40}, "Name": {"$in": ["luke", "leia"]}}
+//
+// Fields map to a value for equality, or to an object of operators: $eq, $ne,
+// $gt, $gte, $lt, $lte, $in, $nin, $exists, $all, $size and $not. The
+// top level also accepts $and, $or and $nor with a list of filters. Values
+// must be strings, numbers, booleans or null, and are always bound as
+// parameters, so filters from untrusted sources, such as HTTP requests, are
+// safe to compile. Use Cond or Table.Filter to compile one.
+type Filter map[string]any
+
+// ParseFilter decodes a filter from JSON, keeping integers exact.
+func ParseFilter(data []byte) (Filter, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var f Filter
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("sqjdb: invalid filter: %w", err)
+	}
+	return f, nil
+}
+
+// Cond compiles the filter into a condition.
+func (f Filter) Cond() (Cond, error) {
+	return filterCompiler{where: Where, key: func(name string) string { return name }}.filter(f)
+}
+
+// Filter compiles the filter into a condition, resolving field names like
+// Table.Where.
+func (t *Table[T]) Filter(f Filter) (Cond, error) {
+	return filterCompiler{where: t.Where, key: t.FieldName}.filter(f)
+}
+
+type filterCompiler struct {
+	where func(name string) Field
+	key   func(name string) string
+}
+
+// filter compiles a filter document, whose conditions must all match. Keys are
+// sorted, so the same filter always compiles to the same SQL.
+func (c filterCompiler) filter(f map[string]any) (Cond, error) {
+	var conds []Cond
+	for _, name := range slices.Sorted(maps.Keys(f)) {
+		var cond Cond
+		var err error
+		switch name {
+		case "$and", "$or", "$nor":
+			cond, err = c.logical(name, f[name])
+		default:
+			if len(name) > 0 && name[0] == '$' {
+				return Cond{}, fmt.Errorf("sqjdb: unknown filter operator %q", name)
+			}
+			cond, err = c.field(name, f[name])
+		}
+		if err != nil {
+			return Cond{}, err
+		}
+		conds = append(conds, cond)
+	}
+	return allOf(conds), nil
+}
+
+// allOf returns a condition matching when all the conditions match, which is
+// always if there are none.
+func allOf(conds []Cond) Cond {
+	if len(conds) == 0 {
+		return Cond{Expr: "1"}
+	}
+	if len(conds) == 1 {
+		return conds[0]
+	}
+	return conds[0].And(conds[1:]...)
+}
+
+func (c filterCompiler) logical(op string, v any) (Cond, error) {
+	list, ok := v.([]any)
+	if !ok || len(list) == 0 {
+		return Cond{}, fmt.Errorf("sqjdb: %s expects a non-empty list of filters", op)
+	}
+	conds := make([]Cond, len(list))
+	for i, item := range list {
+		f, ok := asFilter(item)
+		if !ok {
+			return Cond{}, fmt.Errorf("sqjdb: %s expects a non-empty list of filters", op)
+		}
+		cond, err := c.filter(f)
+		if err != nil {
+			return Cond{}, err
+		}
+		conds[i] = cond
+	}
+	switch op {
+	case "$and":
+		return conds[0].And(conds[1:]...), nil
+	case "$or":
+		return conds[0].Or(conds[1:]...), nil
+	default:
+		return conds[0].Or(conds[1:]...).Not(), nil
+	}
+}
+
+// field compiles the value for the named field, which is either an object of
+// operators or a value to compare for equality.
+func (c filterCompiler) field(name string, v any) (Cond, error) {
+	ops, ok := asFilter(v)
+	if !ok {
+		return c.op(name, "$eq", v)
+	}
+	var conds []Cond
+	for _, op := range slices.Sorted(maps.Keys(ops)) {
+		cond, err := c.op(name, op, ops[op])
+		if err != nil {
+			return Cond{}, err
+		}
+		conds = append(conds, cond)
+	}
+	if len(conds) == 0 {
+		return Cond{}, fmt.Errorf("sqjdb: no operators for field %q", name)
+	}
+	return allOf(conds), nil
+}
+
+func (c filterCompiler) op(name, op string, v any) (Cond, error) {
+	f := c.where(name)
+	switch op {
+	case "$exists":
+		exists, ok := v.(bool)
+		if !ok {
+			return Cond{}, fmt.Errorf("sqjdb: $exists on %q expects a boolean", name)
+		}
+		if exists {
+			return f.IsNotNull(), nil
+		}
+		return f.IsNull(), nil
+	case "$in", "$nin", "$all":
+		values, err := filterValues(name, op, v)
+		if err != nil {
+			return Cond{}, err
+		}
+		switch op {
+		case "$in":
+			return f.In(values...), nil
+		case "$nin":
+			// Like MongoDB, missing fields match.
+			return f.IsNull().Or(f.In(values...).Not()), nil
+		default:
+			return AllOf(c.key(name), values...), nil
+		}
+	case "$size":
+		n, err := filterValue(name, op, v)
+		if err != nil {
+			return Cond{}, err
+		}
+		size, ok := n.(int64)
+		if !ok {
+			return Cond{}, fmt.Errorf("sqjdb: $size on %q expects an integer", name)
+		}
+		return ArrayLen(c.key(name), int(size)), nil
+	case "$not":
+		ops, ok := asFilter(v)
+		if !ok {
+			return Cond{}, fmt.Errorf("sqjdb: $not on %q expects an object of operators", name)
+		}
+		cond, err := c.field(name, ops)
+		if err != nil {
+			return Cond{}, err
+		}
+		return cond.Not(), nil
+	}
+	value, err := filterValue(name, op, v)
+	if err != nil {
+		return Cond{}, err
+	}
+	switch op {
+	case "$eq":
+		if value == nil {
+			return f.IsNull(), nil
+		}
+		return f.Eq(value), nil
+	case "$ne":
+		if value == nil {
+			return f.IsNotNull(), nil
+		}
+		// Like MongoDB, missing fields match.
+		return f.IsNull().Or(f.Ne(value)), nil
+	case "$gt":
+		return f.Gt(value), nil
+	case "$gte":
+		return f.Gte(value), nil
+	case "$lt":
+		return f.Lt(value), nil
+	case "$lte":
+		return f.Lte(value), nil
+	}
+	return Cond{}, fmt.Errorf("sqjdb: unknown filter operator %q on %q", op, name)
+}
+
+// asFilter returns v as a filter document if it is an object.
+func asFilter(v any) (map[string]any, bool) {
+	switch f := v.(type) {
+	case Filter:
+		return f, true
+	case map[string]any:
+		return f, true
+	}
+	return nil, false
+}
+
+// filterValues returns the list of values for the operator.
+func filterValues(name, op string, v any) ([]any, error) {
+	list, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("sqjdb: %s on %q expects a list", op, name)
+	}
+	values := make([]any, len(list))
+	for i, item := range list {
+		value, err := filterValue(name, op, item)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// filterValue returns the value converted for binding. Only scalar values are
+// allowed, since documents are compared field by field.
+func filterValue(name, op string, v any) (any, error) {
+	switch v := v.(type) {
+	case nil, string, bool, float64, int64, time.Time:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("sqjdb: invalid number %s for %s on %q", v, op, name)
+		}
+		return f, nil
+	}
+	return nil, fmt.Errorf("sqjdb: unsupported value of type %T for %s on %q", v, op, name)
+}
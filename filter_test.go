@@ -0,0 +1,71 @@
+package sqjdb_test
+
+import (
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestFilter(t *testing.T) {
+	conn := newConn(t)
+	cases := []struct {
+		filter string
+		ids    []string
+	}{
+		{`{}`, []string{leia.ID, luke.ID, yoda.ID}},
+		{`{"Name": "luke"}`, []string{luke.ID}},
+		{`{"Age": {"$gt": 40, "$lt": 100}}`, []string{leia.ID, luke.ID}},
+		{`{"Name": {"$in": ["luke", "yoda"]}}`, []string{luke.ID, yoda.ID}},
+		{`{"Name": {"$nin": ["luke", "yoda"]}}`, []string{leia.ID}},
+		{`{"Name": {"$ne": "luke"}, "Age": 42}`, []string{leia.ID}},
+		{`{"$or": [{"Name": "luke"}, {"Age": {"$gte": 900}}]}`, []string{luke.ID, yoda.ID}},
+		{`{"$nor": [{"Name": "luke"}, {"Name": "leia"}]}`, []string{yoda.ID}},
+		{`{"Age": {"$not": {"$eq": 42}}}`, []string{yoda.ID}},
+		{`{"Missing": {"$exists": false}, "Name": {"$exists": true}}`, []string{leia.ID, luke.ID, yoda.ID}},
+		{`{"Missing": null}`, []string{leia.ID, luke.ID, yoda.ID}},
+	}
+	for _, c := range cases {
+		f, err := sqjdb.ParseFilter([]byte(c.filter))
+		ensure.Nil(t, err, c.filter)
+		cond, err := jedis.Filter(f)
+		ensure.Nil(t, err, c.filter)
+		docs, err := jedis.All(conn, cond.SQL(), sqjdb.OrderBy("Name", sqjdb.Asc))
+		ensure.Nil(t, err, c.filter)
+		var ids []string
+		for _, doc := range docs {
+			ids = append(ids, doc.ID)
+		}
+		ensure.DeepEqual(t, ids, c.ids, c.filter)
+	}
+}
+
+func TestFilterArrays(t *testing.T) {
+	conn, padawans := newPadawans(t)
+	cond, err := sqjdb.Filter{"Sabers": sqjdb.Filter{"$all": []any{"green", "white"}}}.Cond()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, padawanNames(t, conn, &padawans, cond), []string{"ahsoka"})
+	cond, err = sqjdb.Filter{"Sabers": map[string]any{"$size": 1}}.Cond()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, padawanNames(t, conn, &padawans, cond), []string{"kanan"})
+}
+
+func TestFilterInvalid(t *testing.T) {
+	for _, filter := range []string{
+		`{"$where": "1"}`,
+		`{"Age": {"$regex": "x"}}`,
+		`{"Age": {"$gt": {"$gt": 1}}}`,
+		`{"Age": [1, 2]}`,
+		`{"Age": {"$in": 1}}`,
+		`{"$or": []}`,
+		`{"Age": {"$exists": 1}}`,
+		`{"Age": {"$size": 1.5}}`,
+	} {
+		f, err := sqjdb.ParseFilter([]byte(filter))
+		ensure.Nil(t, err, filter)
+		_, err = f.Cond()
+		ensure.NotNil(t, err, filter)
+	}
+	_, err := sqjdb.ParseFilter([]byte(`[1]`))
+	ensure.NotNil(t, err)
+}
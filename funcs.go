@@ -0,0 +1,62 @@
+package sqjdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// RegisterFunctions registers the SQL functions used by some Table methods,
+// such as Nearest, on the connection. With a sqlitex.Pool, call it from
+// PoolOptions.PrepareConn. Tables using UseCodec also need
+// Table.RegisterFunctions.
+func RegisterFunctions(conn *sqlite.Conn) error {
+	funcs := map[string]*sqlite.FunctionImpl{
+		"sqjdb_vec_distance": {
+			NArgs:         2,
+			Scalar:        vecDistance,
+			Deterministic: true,
+		},
+	}
+	for name, impl := range funcs {
+		if err := conn.CreateFunction(name, impl); err != nil {
+			return fmt.Errorf("sqjdb: registering functions: %w", err)
+		}
+	}
+	return nil
+}
+
+// vecDistance returns the cosine distance between two vectors encoded as JSON
+// arrays, or NULL if they are of different lengths. The second argument is
+// expected to be constant, and is only decoded once per statement.
+func vecDistance(ctx sqlite.Context, args []sqlite.Value) (sqlite.Value, error) {
+	if args[0].Type() == sqlite.TypeNull || args[1].Type() == sqlite.TypeNull {
+		return sqlite.Value{}, nil
+	}
+	var a []float64
+	if err := json.Unmarshal([]byte(args[0].Text()), &a); err != nil {
+		return sqlite.Value{}, fmt.Errorf("sqjdb: invalid vector: %w", err)
+	}
+	b, ok := ctx.AuxData(1).([]float64)
+	if !ok {
+		if err := json.Unmarshal([]byte(args[1].Text()), &b); err != nil {
+			return sqlite.Value{}, fmt.Errorf("sqjdb: invalid vector: %w", err)
+		}
+		ctx.SetAuxData(1, b)
+	}
+	if len(a) != len(b) {
+		return sqlite.Value{}, nil
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return sqlite.FloatValue(1), nil
+	}
+	return sqlite.FloatValue(1 - dot/math.Sqrt(normA*normB)), nil
+}
@@ -0,0 +1,123 @@
+package sqjdb
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+const (
+	earthRadius     = 6371000 // In meters.
+	metersPerDegree = earthRadius * math.Pi / 180
+)
+
+// Geo maintains an R*Tree index over the named latitude and longitude fields,
+// in degrees, for use with WithinBox and Nearby. The index and the triggers
+// that maintain it are created by Migrate.
+func Geo(latField, lonField string) Option {
+	return func(o *options) {
+		o.geoLat = latField
+		o.geoLon = lonField
+	}
+}
+
+func (t *Table[T]) geoName() string {
+	return t.Name + "_geo"
+}
+
+func (t *Table[T]) migrateGeo(conn *sqlite.Conn) (err error) {
+	g := t.geoName()
+	prefix, base := splitName(t.Name)
+	_, gBase := splitName(g)
+	var exists bool
+	err = sqlitex.Execute(conn, "select 1 from "+prefix+"sqlite_schema where name = ?",
+		&sqlitex.ExecOptions{
+			Args: []any{gBase},
+			ResultFunc: func(*sqlite.Stmt) error {
+				exists = true
+				return nil
+			},
+		})
+	if err != nil {
+		return fmt.Errorf("sqjdb: checking for table %q: %w", g, err)
+	}
+	if exists {
+		return nil
+	}
+	lat, lon := fieldExpr(t.FieldName(t.opts.geoLat)), fieldExpr(t.FieldName(t.opts.geoLon))
+	point := func(row string) string {
+		return "select " + row + ".rowid, " + row + "." + lat + ", " + row + "." + lat +
+			", " + row + "." + lon + ", " + row + "." + lon + " where " +
+			row + "." + lat + " is not null and " + row + "." + lon + " is not null;"
+	}
+	qs := []string{
+		"create virtual table " + g + " using rtree(id, min_lat, max_lat, min_lon, max_lon)",
+		"insert into " + g + " select rowid, " + lat + ", " + lat + ", " + lon + ", " + lon +
+			" from " + t.Name + " where " + lat + " is not null and " + lon + " is not null",
+		// Triggers can only refer to unqualified tables in their own schema.
+		"create trigger " + g + "_insert after insert on " + base + " begin" +
+			" insert into " + gBase + " " + point("new") + " end",
+		"create trigger " + g + "_update after update on " + base + " begin" +
+			" delete from " + gBase + " where id = old.rowid;" +
+			" insert into " + gBase + " " + point("new") + " end",
+		"create trigger " + g + "_delete after delete on " + base + " begin" +
+			" delete from " + gBase + " where id = old.rowid; end",
+	}
+	defer sqlitex.Save(conn)(&err)
+	for _, q := range qs {
+		if err = sqlitex.ExecuteTransient(conn, q, nil); err != nil {
+			return fmt.Errorf("sqjdb: creating geo index on %q: %w", t.Name, err)
+		}
+	}
+	return nil
+}
+
+// WithinBox matches documents located within the given bounds, in degrees. The
+// table must use Geo.
+func (t *Table[T]) WithinBox(minLat, minLon, maxLat, maxLon float64) Cond {
+	return Cond{
+		Expr: "rowid in (select id from " + t.geoName() +
+			" where min_lat >= ? and max_lat <= ? and min_lon >= ? and max_lon <= ?)",
+		Args: []any{minLat, maxLat, minLon, maxLon},
+	}
+}
+
+// Nearby matches documents located within radius meters of the given point,
+// in degrees. Candidates are found using the R*Tree index, and then filtered by
+// great-circle distance. The search area may cross the antimeridian or a pole.
+// The table must use Geo.
+func (t *Table[T]) Nearby(lat, lon, radius float64) Cond {
+	dLat := radius / metersPerDegree
+	dLon := 180.0
+	if cos := math.Cos(lat * math.Pi / 180); cos > 1e-9 {
+		dLon = min(dLon, dLat/cos)
+	}
+	minLat, maxLat := max(lat-dLat, -90), min(lat+dLat, 90)
+	// The longitude ranges to search, split in two where they cross the
+	// antimeridian. Around a pole every longitude is in range.
+	lons := [][2]float64{{lon - dLon, lon + dLon}}
+	switch {
+	case minLat == -90 || maxLat == 90 || dLon >= 180:
+		lons = [][2]float64{{-180, 180}}
+	case lon-dLon < -180:
+		lons = [][2]float64{{lon - dLon + 360, 180}, {-180, lon + dLon}}
+	case lon+dLon > 180:
+		lons = [][2]float64{{lon - dLon, 180}, {-180, lon + dLon - 360}}
+	}
+	args := []any{minLat, maxLat}
+	var lonExprs []string
+	for _, r := range lons {
+		lonExprs = append(lonExprs, "min_lon >= ? and max_lon <= ?")
+		args = append(args, r[0], r[1])
+	}
+	return Cond{
+		Expr: "rowid in (select id from " + t.geoName() +
+			" where min_lat >= ? and max_lat <= ? and (" + strings.Join(lonExprs, " or ") + ")" +
+			" and 2 * ? * asin(sqrt(pow(sin(radians(min_lat - ?) / 2), 2) +" +
+			" cos(radians(?)) * cos(radians(min_lat)) * pow(sin(radians(min_lon - ?) / 2), 2))) <= ?)",
+		Args: append(args, float64(earthRadius), lat, lat, lon, radius),
+	}
+}
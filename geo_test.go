@@ -0,0 +1,94 @@
+package sqjdb_test
+
+import (
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+)
+
+type Place struct {
+	ID   string  `json:",omitempty"`
+	Name string  `json:",omitempty"`
+	Lat  float64 `json:",omitempty"`
+	Lon  float64 `json:",omitempty"`
+}
+
+func newPlaces(t *testing.T) (*sqlite.Conn, sqjdb.Table[Place]) {
+	conn := newConn(t)
+	places := sqjdb.NewTable[Place](t.Name(), sqjdb.Geo("Lat", "Lon"))
+	ensure.Nil(t, places.Migrate(conn))
+	ensure.Nil(t, places.Migrate(conn))
+	_, err := places.InsertMany(conn, []*Place{
+		{Name: "golden gate", Lat: 37.8199, Lon: -122.4783},
+		{Name: "alcatraz", Lat: 37.8270, Lon: -122.4230},
+		{Name: "eiffel tower", Lat: 48.8584, Lon: 2.2945},
+		{Name: "nowhere"},
+	})
+	ensure.Nil(t, err)
+	return conn, places
+}
+
+func TestWithinBox(t *testing.T) {
+	conn, places := newPlaces(t)
+	docs, err := places.All(conn, places.WithinBox(37, -123, 38, -122).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 2)
+}
+
+func TestNearby(t *testing.T) {
+	conn, places := newPlaces(t)
+	// Alcatraz is about 5km from the Golden Gate.
+	docs, err := places.All(conn, places.Nearby(37.8199, -122.4783, 1000).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 1)
+	ensure.DeepEqual(t, docs[0].Name, "golden gate")
+	docs, err = places.All(conn, places.Nearby(37.8199, -122.4783, 10000).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 2)
+}
+
+func TestNearbyFollowsUpdates(t *testing.T) {
+	conn, places := newPlaces(t)
+	_, err := places.Patch(conn, &Place{Lat: 37.8, Lon: -122.45},
+		sqjdb.Where("Name").Eq("eiffel tower").SQL())
+	ensure.Nil(t, err)
+	_, err = places.Delete(conn, sqjdb.Where("Name").Eq("alcatraz").SQL())
+	ensure.Nil(t, err)
+	docs, err := places.All(conn, places.WithinBox(37, -123, 38, -122).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 2)
+}
+
+func TestNearbyAntimeridian(t *testing.T) {
+	conn, places := newPlaces(t)
+	_, err := places.InsertMany(conn, []*Place{
+		{Name: "east", Lat: -17, Lon: 179.9},
+		{Name: "west", Lat: -17, Lon: -179.9},
+	})
+	ensure.Nil(t, err)
+	// The points are about 21km apart, on either side of the antimeridian.
+	for _, lon := range []float64{179.95, -179.95} {
+		docs, err := places.All(conn, places.Nearby(-17, lon, 50000).SQL(), sqjdb.OrderBy("Name", sqjdb.Asc))
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, len(docs), 2, lon)
+		ensure.DeepEqual(t, docs[0].Name, "east")
+		ensure.DeepEqual(t, docs[1].Name, "west")
+	}
+}
+
+func TestNearbyPole(t *testing.T) {
+	conn, places := newPlaces(t)
+	_, err := places.InsertMany(conn, []*Place{
+		{Name: "near", Lat: 89.9, Lon: 10},
+		{Name: "far side", Lat: 89.9, Lon: -170},
+	})
+	ensure.Nil(t, err)
+	// The points are about 22km apart, on either side of the north pole.
+	docs, err := places.All(conn, places.Nearby(89.9, 10, 30000).SQL(), sqjdb.OrderBy("Name", sqjdb.Asc))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 2)
+	ensure.DeepEqual(t, docs[0].Name, "far side")
+	ensure.DeepEqual(t, docs[1].Name, "near")
+}
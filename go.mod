@@ -1,9 +1,10 @@
 module github.com/daaku/sqjdb
 
-go 1.22.1
+go 1.23
 
 require (
 	github.com/daaku/ensure v1.0.1
+	github.com/google/uuid v1.6.0
 	github.com/oklog/ulid/v2 v2.1.0
 	zombiezen.com/go/sqlite v1.4.0
 )
@@ -11,7 +12,6 @@ require (
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/dustin/go-humanize v1.0.1 // indirect
-	github.com/google/uuid v1.6.0 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/ncruces/go-strftime v0.1.9 // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
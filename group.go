@@ -0,0 +1,112 @@
+package sqjdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// Aggregate is an aggregate function computed for each group by GroupBy.
+type Aggregate struct {
+	// As is the key the result is decoded from, matching a field of the
+	// result struct.
+	As string
+	// Func is the SQL aggregate function, such as count or sum.
+	Func string
+	// Field is the document field to aggregate, or empty to count documents.
+	Field string
+}
+
+// CountOf counts the documents in each group.
+func CountOf(as string) Aggregate {
+	return Aggregate{As: as, Func: "count"}
+}
+
+// SumOf sums the named field in each group.
+func SumOf(as, field string) Aggregate {
+	return Aggregate{As: as, Func: "sum", Field: field}
+}
+
+// AvgOf averages the named field in each group.
+func AvgOf(as, field string) Aggregate {
+	return Aggregate{As: as, Func: "avg", Field: field}
+}
+
+// MinOf returns the minimum of the named field in each group.
+func MinOf(as, field string) Aggregate {
+	return Aggregate{As: as, Func: "min", Field: field}
+}
+
+// MaxOf returns the maximum of the named field in each group.
+func MaxOf(as, field string) Aggregate {
+	return Aggregate{As: as, Func: "max", Field: field}
+}
+
+// GroupBy groups the documents per the given query on the named fields, and
+// computes the aggregates for each group. Each group is decoded into R from a
+// JSON object containing the group fields by their JSON key and the aggregates
+// by their As key.
+// Groups are ordered by the group fields.
+func GroupBy[R, T any](conn *sqlite.Conn, t *Table[T], fields []string, aggs []Aggregate, sqls ...SQL) (_ []*R, err error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("sqjdb: no fields to group %q by", t.Name)
+	}
+	var query strings.Builder
+	query.WriteString("select json_object(")
+	var args []any
+	groupBy := make([]string, len(fields))
+	for i, field := range fields {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		key := t.FieldName(field)
+		query.WriteString("?, json(" + fieldJSONExpr(key) + ")")
+		args = append(args, key)
+		groupBy[i] = t.fieldSQL(field)
+	}
+	for _, agg := range aggs {
+		expr := "*"
+		if agg.Field != "" {
+			expr = t.fieldSQL(agg.Field)
+		}
+		query.WriteString(", ?, " + agg.Func + "(" + expr + ")")
+		args = append(args, agg.As)
+	}
+	query.WriteString(") from")
+	group := strings.Join(groupBy, ", ")
+	sqls = slices.Concat(
+		[]SQL{{Args: args}, t.from()},
+		sqls,
+		[]SQL{{Query: "group by " + group + " order by " + group}},
+	)
+	addSQLQuery(&query, sqls)
+	sp := t.trace(conn, "group", query.String(), sqls)
+	defer func() { err = sp.finish(err) }()
+	stmt, err := prepareSQL(conn, query.String(), sqls)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseStmt(stmt)
+	var groups []*R
+	var buf []byte
+	for {
+		rowReturned, err := stmt.Step()
+		if err != nil {
+			return nil, err
+		}
+		if !rowReturned {
+			break
+		}
+		sp.addRows(1)
+		jsonS := columnBytes(stmt, 0, &buf)
+		v := new(R)
+		if err := json.Unmarshal(jsonS, v); err != nil {
+			return nil, fmt.Errorf("sqjdb: invalid json from db: %w\n%s", err, jsonS)
+		}
+		groups = append(groups, v)
+	}
+	return groups, nil
+}
@@ -0,0 +1,54 @@
+package sqjdb_test
+
+import (
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestGroupBy(t *testing.T) {
+	conn := newConn(t)
+	type ageGroup struct {
+		Age   int
+		Count int
+	}
+	groups, err := sqjdb.GroupBy[ageGroup](conn, &jedis, []string{"Age"},
+		[]sqjdb.Aggregate{sqjdb.CountOf("Count")})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, groups, []*ageGroup{{42, 2}, {980, 1}})
+}
+
+func TestGroupByRenamedField(t *testing.T) {
+	conn := newConn(t)
+	citizens := newCitizens(t, conn)
+	type planet struct {
+		Planet string `json:"planet"`
+		Count  int
+	}
+	groups, err := sqjdb.GroupBy[planet](conn, citizens, []string{"Planet"},
+		[]sqjdb.Aggregate{sqjdb.CountOf("Count")})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, groups, []*planet{{"Corellia", 2}, {"Socorro", 1}})
+}
+
+func TestGroupByFiltered(t *testing.T) {
+	conn := newConn(t)
+	type stats struct {
+		Age   int
+		Total int
+		Avg   float64
+		First string
+		Last  string
+	}
+	groups, err := sqjdb.GroupBy[stats](conn, &jedis, []string{"Age"},
+		[]sqjdb.Aggregate{
+			sqjdb.SumOf("Total", "Age"),
+			sqjdb.AvgOf("Avg", "Age"),
+			sqjdb.MinOf("First", "Name"),
+			sqjdb.MaxOf("Last", "Name"),
+		},
+		sqjdb.Where("Age").Lt(100).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, groups, []*stats{{Age: 42, Total: 84, Avg: 42, First: "leia", Last: "luke"}})
+}
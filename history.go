@@ -0,0 +1,113 @@
+package sqjdb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// KeepHistory records the prior version of every updated or deleted document
+// in a shadow table named with a "_history" suffix. The table and the triggers
+// that maintain it are created by Migrate, so changes made outside of sqjdb are
+// also recorded.
+func KeepHistory() Option {
+	return func(o *options) {
+		o.history = true
+	}
+}
+
+// HistoryEntry is a prior version of a document.
+type HistoryEntry[T any] struct {
+	// Op is the operation that replaced this version, "update" or "delete".
+	Op string
+	// At is when the operation happened.
+	At time.Time
+	// Doc is the document as it was before the operation.
+	Doc *T
+}
+
+func (t *Table[T]) historyName() string {
+	return t.Name + "_history"
+}
+
+func (t *Table[T]) migrateHistory(conn *sqlite.Conn) error {
+	h := t.historyName()
+	qCreate := "create table if not exists " + h +
+		" (id text not null, op text not null, at text not null, data blob)"
+	if err := sqlitex.ExecuteTransient(conn, qCreate, nil); err != nil {
+		return fmt.Errorf("sqjdb: creating table %q: %w", h, err)
+	}
+	_, hBase := splitName(h)
+	qIndexID := "create index if not exists " + h + "_id on " + hBase + " (id)"
+	if err := sqlitex.ExecuteTransient(conn, qIndexID, nil); err != nil {
+		return fmt.Errorf("sqjdb: creating id index on %q: %w", h, err)
+	}
+	// Triggers can only refer to unqualified tables in their own schema.
+	_, base := splitName(t.Name)
+	for _, op := range []string{"update", "delete"} {
+		qTrigger := "create trigger if not exists " + h + "_" + op +
+			" after " + op + " on " + base + " begin" +
+			" insert into " + hBase + " (id, op, at, data) values" +
+			" (old." + fieldExpr(t.opts.idKey) + ", '" + op + "', strftime('%Y-%m-%dT%H:%M:%fZ'), old.data);" +
+			" end"
+		if err := sqlitex.ExecuteTransient(conn, qTrigger, nil); err != nil {
+			return fmt.Errorf("sqjdb: creating %s history trigger on %q: %w", op, t.Name, err)
+		}
+	}
+	return nil
+}
+
+// History returns the prior versions of the document with the given ID, oldest
+// first. The table must use KeepHistory.
+func (t *Table[T]) History(conn *sqlite.Conn, id any) (_ []HistoryEntry[T], err error) {
+	if !t.opts.history {
+		return nil, fmt.Errorf("sqjdb: table %q does not use KeepHistory", t.Name)
+	}
+	sqls := []SQL{{Query: t.historyName() + " where id = ?", Args: []any{id}}}
+	if t.opts.tenant != "" {
+		cond := Where(t.FieldName(t.opts.tenant)).Eq(t.opts.tenantID)
+		sqls = append(sqls, SQL{Query: "and " + cond.Expr, Args: cond.Args})
+	}
+	sqls = append(sqls, SQL{Query: "order by rowid"})
+	var query strings.Builder
+	query.WriteString("select op, at, json(data) from")
+	addSQLQuery(&query, sqls)
+	sp := t.trace(conn, "history", query.String(), sqls)
+	defer func() { err = sp.finish(err) }()
+	stmt, err := prepareSQL(conn, query.String(), sqls)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseStmt(stmt)
+	var entries []HistoryEntry[T]
+	var buf []byte
+	for {
+		rowReturned, err := stmt.Step()
+		if err != nil {
+			return nil, err
+		}
+		if !rowReturned {
+			break
+		}
+		sp.addRows(1)
+		at, err := time.Parse(time.RFC3339Nano, stmt.ColumnText(1))
+		if err != nil {
+			return nil, fmt.Errorf("sqjdb: invalid history time from db: %w", err)
+		}
+		jsonS := columnBytes(stmt, 2, &buf)
+		doc := new(T)
+		// Old documents are upgraded, but never written back.
+		if _, err := t.decode(jsonS, doc); err != nil {
+			return nil, err
+		}
+		entries = append(entries, HistoryEntry[T]{
+			Op:  stmt.ColumnText(0),
+			At:  at,
+			Doc: doc,
+		})
+	}
+	return entries, nil
+}
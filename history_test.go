@@ -0,0 +1,51 @@
+package sqjdb_test
+
+import (
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestHistory(t *testing.T) {
+	conn := newConn(t)
+	tracked := sqjdb.NewTable[Jedi](t.Name(), sqjdb.KeepHistory())
+	ensure.Nil(t, tracked.Migrate(conn))
+	ensure.Nil(t, tracked.Migrate(conn))
+	doc, err := tracked.Insert(conn, &Jedi{Name: "anakin", Age: 9})
+	ensure.Nil(t, err)
+	_, err = tracked.Patch(conn, &Jedi{Name: "vader"}, sqjdb.ByID(doc.ID))
+	ensure.Nil(t, err)
+	_, err = tracked.Delete(conn, sqjdb.ByID(doc.ID))
+	ensure.Nil(t, err)
+
+	entries, err := tracked.History(conn, doc.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(entries), 2)
+	ensure.DeepEqual(t, entries[0].Op, "update")
+	ensure.DeepEqual(t, entries[0].Doc, doc)
+	ensure.DeepEqual(t, entries[1].Op, "delete")
+	ensure.DeepEqual(t, entries[1].Doc.Name, "vader")
+	ensure.False(t, entries[1].At.Before(entries[0].At))
+}
+
+func TestHistoryNotEnabled(t *testing.T) {
+	conn := newConn(t)
+	_, err := jedis.History(conn, yoda.ID)
+	ensure.NotNil(t, err)
+}
+
+func TestHistoryDecodeOptions(t *testing.T) {
+	conn := newConn(t)
+	tracked := sqjdb.NewTable[Jedi](t.Name(), sqjdb.KeepHistory())
+	ensure.Nil(t, tracked.Migrate(conn))
+	doc, err := tracked.Insert(conn, &Jedi{Name: "anakin", Age: 9})
+	ensure.Nil(t, err)
+	_, err = tracked.Patch(conn, &Jedi{Name: "vader"}, sqjdb.ByID(doc.ID))
+	ensure.Nil(t, err)
+
+	strict := sqjdb.NewTable[JediName](t.Name(), sqjdb.KeepHistory(), sqjdb.DisallowUnknownFields())
+	_, err = strict.History(conn, doc.ID)
+	ensure.NotNil(t, err)
+	ensure.StringContains(t, err.Error(), `unknown field "Age"`)
+}
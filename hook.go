@@ -0,0 +1,104 @@
+package sqjdb
+
+import (
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// HookOp identifies the operation a hook runs for.
+type HookOp string
+
+// Operations hooks can be registered for. Upsert, InsertMany and GetOrCreate
+// run the insert hooks, and Purge runs the delete hooks.
+const (
+	HookInsert  HookOp = "insert"
+	HookPatch   HookOp = "patch"
+	HookReplace HookOp = "replace"
+	HookDelete  HookOp = "delete"
+)
+
+// HookEvent describes the operation a hook is called for.
+type HookEvent[T any] struct {
+	Op HookOp
+	// Doc is the document being inserted, or the patch or replacement. It is
+	// a shallow clone of the document given to the operation, so before hooks
+	// can modify it. It is nil for deletes, and for the patches the Notifier
+	// dispatches for writes that do not run hooks.
+	Doc *T
+	// SQLs is the query selecting the documents to patch, replace or delete.
+	SQLs []SQL
+	// N is the number of documents affected. It is only set for after hooks.
+	N int
+}
+
+// Hook is a callback run before or after an operation. Returning an error from
+// a before hook aborts the operation, and from an after hook undoes it.
+type Hook[T any] func(conn *sqlite.Conn, e *HookEvent[T]) error
+
+type hooks[T any] struct {
+	before   map[HookOp][]Hook[T]
+	after    map[HookOp][]Hook[T]
+	notifier *Notifier[T]
+}
+
+// Before registers a hook to run before the operation. Hooks run in the order
+// they are registered, and should be registered before the table is used.
+func (t *Table[T]) Before(op HookOp, hook Hook[T]) {
+	if t.hooks == nil {
+		t.hooks = &hooks[T]{}
+	}
+	if t.hooks.before == nil {
+		t.hooks.before = map[HookOp][]Hook[T]{}
+	}
+	t.hooks.before[op] = append(t.hooks.before[op], hook)
+}
+
+// After registers a hook to run after the operation. Hooks run in the order
+// they are registered, and should be registered before the table is used.
+func (t *Table[T]) After(op HookOp, hook Hook[T]) {
+	if t.hooks == nil {
+		t.hooks = &hooks[T]{}
+	}
+	if t.hooks.after == nil {
+		t.hooks.after = map[HookOp][]Hook[T]{}
+	}
+	t.hooks.after[op] = append(t.hooks.after[op], hook)
+}
+
+// runHooks runs fn between the hooks registered for the operation, and then
+// notifies the Notifier of the table. fn should set e.N, and e.Doc if it
+// changes.
+func (t *Table[T]) runHooks(conn *sqlite.Conn, e *HookEvent[T], fn func() error) error {
+	if err := t.runHooksSaved(conn, e, fn); err != nil {
+		return err
+	}
+	return t.notify(conn, *e)
+}
+
+// runHooksSaved runs fn between the hooks registered for the operation, within
+// a savepoint so errors from after hooks undo it.
+func (t *Table[T]) runHooksSaved(conn *sqlite.Conn, e *HookEvent[T], fn func() error) (err error) {
+	if t.hooks == nil || len(t.hooks.before[e.Op])+len(t.hooks.after[e.Op]) == 0 {
+		return fn()
+	}
+	defer settleNotifications(conn)
+	defer sqlitex.Save(conn)(&err)
+	if e.Doc != nil {
+		docCopy := *e.Doc
+		e.Doc = &docCopy
+	}
+	for _, hook := range t.hooks.before[e.Op] {
+		if err := hook(conn, e); err != nil {
+			return err
+		}
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	for _, hook := range t.hooks.after[e.Op] {
+		if err := hook(conn, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
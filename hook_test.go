@@ -0,0 +1,71 @@
+package sqjdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+)
+
+func TestHooks(t *testing.T) {
+	conn := newConn(t)
+	hooked := sqjdb.NewTable[Jedi](t.Name())
+	ensure.Nil(t, hooked.Migrate(conn))
+	var ops []string
+	hooked.Before(sqjdb.HookInsert, func(conn *sqlite.Conn, e *sqjdb.HookEvent[Jedi]) error {
+		if e.Doc.Age == 0 {
+			e.Doc.Age = 1
+		}
+		return nil
+	})
+	for _, op := range []sqjdb.HookOp{sqjdb.HookInsert, sqjdb.HookPatch, sqjdb.HookReplace, sqjdb.HookDelete} {
+		hooked.After(op, func(conn *sqlite.Conn, e *sqjdb.HookEvent[Jedi]) error {
+			ops = append(ops, string(e.Op))
+			ensure.DeepEqual(t, e.N, 1)
+			return nil
+		})
+	}
+
+	in := &Jedi{Name: "grogu"}
+	doc, err := hooked.Insert(conn, in)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, doc.Age, 1)
+	ensure.DeepEqual(t, in.Age, 0)
+	_, err = hooked.Patch(conn, &Jedi{Age: 50}, hooked.ByID(doc.ID))
+	ensure.Nil(t, err)
+	_, err = hooked.Replace(conn, &Jedi{ID: doc.ID, Name: "din"}, hooked.ByID(doc.ID))
+	ensure.Nil(t, err)
+	_, err = hooked.Delete(conn, hooked.ByID(doc.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, ops, []string{"insert", "patch", "replace", "delete"})
+}
+
+func TestHookErrors(t *testing.T) {
+	conn := newConn(t)
+	hooked := sqjdb.NewTable[Jedi](t.Name())
+	ensure.Nil(t, hooked.Migrate(conn))
+	errNoName := errors.New("name required")
+	hooked.Before(sqjdb.HookInsert, func(conn *sqlite.Conn, e *sqjdb.HookEvent[Jedi]) error {
+		if e.Doc.Name == "" {
+			return errNoName
+		}
+		return nil
+	})
+	errNoDelete := errors.New("no delete")
+	hooked.After(sqjdb.HookDelete, func(conn *sqlite.Conn, e *sqjdb.HookEvent[Jedi]) error {
+		return errNoDelete
+	})
+	_, err := hooked.Insert(conn, &Jedi{})
+	ensure.DeepEqual(t, err, errNoName)
+	_, err = hooked.InsertMany(conn, []*Jedi{{Name: "a"}, {}})
+	ensure.DeepEqual(t, err, errNoName)
+	doc, err := hooked.Insert(conn, &Jedi{Name: "b"})
+	ensure.Nil(t, err)
+	_, err = hooked.Delete(conn, hooked.ByID(doc.ID))
+	ensure.DeepEqual(t, err, errNoDelete)
+	n, err := hooked.Count(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+}
@@ -0,0 +1,203 @@
+package sqjdb
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/oklog/ulid/v2"
+)
+
+// IDGenerator sets the function used to generate IDs for inserted documents
+// without one. The default is ULID.
+func IDGenerator(gen func() string) Option {
+	return func(o *options) {
+		o.newID = gen
+	}
+}
+
+// ULID generates a ULID. They are lexicographically sorted by time, so
+// Paginate returns documents in insertion order.
+func ULID() string {
+	return ulid.Make().String()
+}
+
+// UUIDv4 generates a random UUID.
+func UUIDv4() string {
+	return uuid.NewString()
+}
+
+// UUIDv7 generates a UUID that, like ULID, is lexicographically sorted by time.
+func UUIDv7() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
+
+func (t *Table[T]) newID() string {
+	if t.opts.newID == nil {
+		return ULID()
+	}
+	return t.opts.newID()
+}
+
+// IDField sets the name of the struct field containing the document ID. By
+// default it is the field tagged with `sqjdb:"id"`, or the field named ID.
+// Besides strings, IDs may be integers or implement encoding.TextMarshaler, but
+// are only generated for strings.
+func IDField(name string) Option {
+	return func(o *options) {
+		o.idField = name
+	}
+}
+
+// IDSetter is implemented by documents that get and set their own string ID.
+// When *T implements it, it is used instead of reflection to handle IDs on
+// insert. It must use the ID field of the table.
+type IDSetter interface {
+	GetID() string
+	SetID(id string)
+}
+
+// resolveID finds the ID field of T, and the key it is stored under in the
+// JSON document.
+func resolveID[T any](o *options) {
+	typ := reflect.TypeFor[T]()
+	if typ.Kind() != reflect.Struct {
+		if o.idField == "" {
+			o.idField = "ID"
+		}
+		o.idKey = o.idField
+		return
+	}
+	if o.idField == "" {
+		for i := range typ.NumField() {
+			if typ.Field(i).Tag.Get("sqjdb") == "id" {
+				o.idField = typ.Field(i).Name
+				break
+			}
+		}
+	}
+	if o.idField == "" {
+		o.idField = "ID"
+	}
+	o.idKey = jsonFieldName(typ, o.idField)
+}
+
+// jsonNames caches the JSON key for field names by type and name.
+var jsonNames sync.Map // map[fieldKey]string
+
+// jsonFieldName resolves the Go field names in the dotted path to the keys
+// they are stored under in JSON, respecting json tags and following nested
+// struct types. Names that do not match a struct field, such as map keys, and
+// JSON paths and pointers are kept as is.
+func jsonFieldName(typ reflect.Type, name string) string {
+	if strings.HasPrefix(name, "$") || strings.HasPrefix(name, "/") {
+		return name
+	}
+	key := fieldKey{typ: typ, name: name}
+	if v, ok := jsonNames.Load(key); ok {
+		return v.(string)
+	}
+	segments := strings.Split(name, ".")
+	for i, s := range segments {
+		for typ != nil && typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		if typ != nil && typ.Kind() == reflect.Map {
+			// The segment is a key of the map.
+			typ = typ.Elem()
+			continue
+		}
+		if typ == nil || typ.Kind() != reflect.Struct {
+			typ = nil
+			continue
+		}
+		f, ok := typ.FieldByName(s)
+		if !ok || !f.IsExported() {
+			typ = nil
+			continue
+		}
+		if tag, _, _ := strings.Cut(f.Tag.Get("json"), ","); tag != "" && tag != "-" {
+			segments[i] = tag
+		}
+		typ = f.Type
+	}
+	resolved := strings.Join(segments, ".")
+	jsonNames.Store(key, resolved)
+	return resolved
+}
+
+// FieldName returns the JSON key for the named field of T, which may be a
+// dotted path through nested structs, respecting json tags. Table.Where,
+// Table.OrderBy and indexes resolve names this way, and it can be used with the
+// other query helpers, such as In.
+func (t *Table[T]) FieldName(name string) string {
+	return jsonFieldName(reflect.TypeFor[T](), name)
+}
+
+type fieldKey struct {
+	typ  reflect.Type
+	name string
+}
+
+// fieldIndexes caches the index of struct fields by type and name, or nil if
+// the type has no such field.
+var fieldIndexes sync.Map // map[fieldKey][]int
+
+// docField returns the named field of the document, or the zero Value if
+// there is no such field. The field index is cached, since looking it up by
+// name on every insert is slow.
+func docField[T any](doc *T, name string) reflect.Value {
+	v := reflect.ValueOf(doc).Elem()
+	key := fieldKey{typ: v.Type(), name: name}
+	index, ok := fieldIndexes.Load(key)
+	if !ok {
+		var i []int
+		if f, found := key.typ.FieldByName(name); found {
+			i = f.Index
+		}
+		index, _ = fieldIndexes.LoadOrStore(key, i)
+	}
+	if index.([]int) == nil {
+		return reflect.Value{}
+	}
+	return v.FieldByIndex(index.([]int))
+}
+
+// ByID generates a where clause to select a document by ID, using the ID field
+// of the table.
+func (t *Table[T]) ByID(id any) SQL {
+	return SQL{Query: "where " + fieldExpr(t.opts.idKey) + " = ?", Args: []any{id}}
+}
+
+// CompositeKey declares fields that together identify a document, such as a
+// tenant and a slug. Migrate creates a unique index over them, and ByKey
+// selects documents by them. The ID field is still required.
+func CompositeKey(fields ...string) Option {
+	return func(o *options) {
+		o.key = fields
+		o.indexes = append(o.indexes, IndexSpec{Fields: fields, Unique: true})
+	}
+}
+
+// ByKey generates a where clause to select a document by the fields declared
+// with CompositeKey, given in the same order. It returns an error if the number
+// of parts does not match the number of fields.
+func (t *Table[T]) ByKey(parts ...any) (SQL, error) {
+	if len(parts) != len(t.opts.key) || len(parts) == 0 {
+		return SQL{}, fmt.Errorf("sqjdb: table %q expects %d key parts but got %d",
+			t.Name, len(t.opts.key), len(parts))
+	}
+	conds := make([]Cond, len(parts))
+	for i, part := range parts {
+		conds[i] = t.Where(t.opts.key[i]).Eq(part)
+	}
+	return conds[0].And(conds[1:]...).SQL(), nil
+}
+
+// IDKey returns the key the ID is stored under in the JSON documents, which is
+// the json name of the ID field.
+func (t *Table[T]) IDKey() string {
+	return t.opts.idKey
+}
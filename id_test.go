@@ -0,0 +1,235 @@
+package sqjdb_test
+
+import (
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"github.com/google/uuid"
+)
+
+func TestIDGenerator(t *testing.T) {
+	conn := newConn(t)
+	for _, gen := range []func() string{sqjdb.UUIDv4, sqjdb.UUIDv7} {
+		uuids := sqjdb.NewTable[Jedi]("jedis", sqjdb.IDGenerator(gen))
+		doc, err := uuids.Insert(conn, &Jedi{Name: "rey"})
+		ensure.Nil(t, err)
+		_, err = uuid.Parse(doc.ID)
+		ensure.Nil(t, err)
+	}
+}
+
+func TestIDGeneratorCustom(t *testing.T) {
+	conn := newConn(t)
+	custom := sqjdb.NewTable[Jedi]("jedis", sqjdb.IDGenerator(func() string {
+		return "custom"
+	}))
+	doc, err := custom.Insert(conn, &Jedi{Name: "rey"})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, doc.ID, "custom")
+}
+
+type Article struct {
+	Slug  string `json:"slug,omitempty" sqjdb:"id"`
+	Title string `json:"title,omitempty"`
+}
+
+type Page struct {
+	Path  string `json:",omitempty"`
+	Title string `json:",omitempty"`
+}
+
+func TestIDFieldTag(t *testing.T) {
+	conn := newConn(t)
+	articles := sqjdb.NewTable[Article](t.Name())
+	ensure.Nil(t, articles.Migrate(conn))
+	a, err := articles.Insert(conn, &Article{Title: "generated"})
+	ensure.Nil(t, err)
+	ensure.NotDeepEqual(t, a.Slug, "")
+	_, err = articles.Insert(conn, &Article{Slug: "hello", Title: "Hello"})
+	ensure.Nil(t, err)
+	_, err = articles.Insert(conn, &Article{Slug: "hello"})
+	ensure.NotNil(t, err)
+	doc, err := articles.Get(conn, "hello")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, doc.Title, "Hello")
+	docs, err := articles.GetMany(conn, "hello", a.Slug)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docs[a.Slug].Title, "generated")
+}
+
+func TestIDFieldOption(t *testing.T) {
+	conn := newConn(t)
+	pages := sqjdb.NewTable[Page](t.Name(), sqjdb.IDField("Path"))
+	ensure.Nil(t, pages.Migrate(conn))
+	_, err := pages.Upsert(conn, &Page{Path: "/a", Title: "A"})
+	ensure.Nil(t, err)
+	_, err = pages.Upsert(conn, &Page{Path: "/a", Title: "B"})
+	ensure.Nil(t, err)
+	doc, err := pages.One(conn, pages.ByID("/a"))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, doc.Title, "B")
+}
+
+type Ticket struct {
+	ID    int    `json:",omitempty"`
+	Title string `json:",omitempty"`
+}
+
+type Device struct {
+	ID   uuid.UUID `json:",omitempty"`
+	Name string    `json:",omitempty"`
+}
+
+func TestIntID(t *testing.T) {
+	conn := newConn(t)
+	tickets := sqjdb.NewTable[Ticket](t.Name())
+	ensure.Nil(t, tickets.Migrate(conn))
+	_, err := tickets.Insert(conn, &Ticket{Title: "no id"})
+	ensure.NotNil(t, err)
+	for i := 1; i <= 3; i++ {
+		_, err := tickets.Insert(conn, &Ticket{ID: i, Title: "ticket"})
+		ensure.Nil(t, err)
+	}
+	_, err = tickets.Insert(conn, &Ticket{ID: 2})
+	ensure.NotNil(t, err)
+	doc, err := tickets.Get(conn, 2)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, doc.ID, 2)
+	byID, err := tickets.GetMany(conn, 1, 3)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, byID[3].ID, 3)
+	docs, next, err := tickets.Paginate(conn, "", 2)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 2)
+	docs, next, err = tickets.Paginate(conn, next, 2)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 1)
+	ensure.DeepEqual(t, docs[0].ID, 3)
+	ensure.DeepEqual(t, next, "")
+}
+
+func TestTextMarshalerID(t *testing.T) {
+	conn := newConn(t)
+	devices := sqjdb.NewTable[Device](t.Name())
+	ensure.Nil(t, devices.Migrate(conn))
+	id := uuid.New()
+	_, err := devices.Insert(conn, &Device{ID: id, Name: "phone"})
+	ensure.Nil(t, err)
+	doc, err := devices.Get(conn, id)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, doc.Name, "phone")
+	byID, err := devices.GetMany(conn, id)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, byID[id].Name, "phone")
+}
+
+type Post struct {
+	ID       string `json:",omitempty"`
+	TenantID string `json:",omitempty"`
+	Slug     string `json:",omitempty"`
+	Title    string `json:",omitempty"`
+}
+
+func TestCompositeKey(t *testing.T) {
+	conn := newConn(t)
+	posts := sqjdb.NewTable[Post](t.Name(), sqjdb.CompositeKey("TenantID", "Slug"))
+	ensure.Nil(t, posts.Migrate(conn))
+	_, err := posts.Insert(conn, &Post{TenantID: "a", Slug: "hello", Title: "A"})
+	ensure.Nil(t, err)
+	_, err = posts.Insert(conn, &Post{TenantID: "b", Slug: "hello", Title: "B"})
+	ensure.Nil(t, err)
+	_, err = posts.Insert(conn, &Post{TenantID: "a", Slug: "hello"})
+	ensure.NotNil(t, err)
+	byKey, err := posts.ByKey("b", "hello")
+	ensure.Nil(t, err)
+	doc, err := posts.One(conn, byKey)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, doc.Title, "B")
+	drift, err := posts.VerifyIndexes(conn)
+	ensure.Nil(t, err)
+	ensure.True(t, drift.Empty())
+}
+
+func TestByKeyMismatch(t *testing.T) {
+	conn := newConn(t)
+	posts := sqjdb.NewTable[Post](t.Name(), sqjdb.CompositeKey("TenantID", "Slug"))
+	ensure.Nil(t, posts.Migrate(conn))
+	_, err := posts.ByKey("a")
+	ensure.NotNil(t, err)
+	ensure.StringContains(t, err.Error(), "expects 2 key parts but got 1")
+}
+
+type Droid struct {
+	ID    string `json:",omitempty"`
+	Model string `json:",omitempty"`
+	sets  int
+}
+
+func (d *Droid) GetID() string   { return d.ID }
+func (d *Droid) SetID(id string) { d.ID = id; d.sets++ }
+
+func TestIDSetter(t *testing.T) {
+	conn := newConn(t)
+	droids := sqjdb.NewTable[Droid](t.Name(), sqjdb.IDGenerator(func() string { return "r2d2" }))
+	ensure.Nil(t, droids.Migrate(conn))
+	in := &Droid{Model: "astromech"}
+	doc, err := droids.Insert(conn, in)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, doc.ID, "r2d2")
+	ensure.DeepEqual(t, doc.sets, 1)
+	ensure.DeepEqual(t, in.ID, "")
+	fetched, err := droids.Get(conn, "r2d2")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, fetched.Model, "astromech")
+}
+
+type Address struct {
+	City string `json:"city"`
+}
+
+type Tagged struct {
+	ID      string             `json:"id,omitempty"`
+	Name    string             `json:"name,omitempty"`
+	Version int64              `json:"version,omitempty"`
+	Home    *Address           `json:"home,omitempty"`
+	Bases   map[string]Address `json:"bases,omitempty"`
+}
+
+func TestFieldName(t *testing.T) {
+	tagged := sqjdb.NewTable[Tagged](t.Name())
+	ensure.DeepEqual(t, tagged.FieldName("Name"), "name")
+	ensure.DeepEqual(t, tagged.FieldName("Home.City"), "home.city")
+	ensure.DeepEqual(t, tagged.FieldName("Bases.Hoth.City"), "bases.Hoth.city")
+	ensure.DeepEqual(t, tagged.FieldName("name"), "name")
+	ensure.DeepEqual(t, tagged.FieldName("Missing.City"), "Missing.City")
+	ensure.DeepEqual(t, tagged.FieldName("/Home/City"), "/Home/City")
+}
+
+func TestFieldNameQueries(t *testing.T) {
+	conn := newConn(t)
+	tagged := sqjdb.NewTable[Tagged](t.Name(),
+		sqjdb.Versioned("Version"),
+		sqjdb.Indexes(sqjdb.IndexSpec{Fields: []string{"Home.City"}}))
+	ensure.Nil(t, tagged.Migrate(conn))
+	doc, err := tagged.Insert(conn, &Tagged{Name: "luke", Home: &Address{City: "tatooine"}})
+	ensure.Nil(t, err)
+	_, err = tagged.Insert(conn, &Tagged{Name: "leia", Home: &Address{City: "alderaan"}})
+	ensure.Nil(t, err)
+
+	found, err := tagged.One(conn, tagged.Where("Name").Eq("luke").SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, found.ID, doc.ID)
+	docs, err := tagged.All(conn, tagged.OrderBy("Home.City", sqjdb.Asc))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docs[0].Name, "leia")
+	ensure.True(t, usesIndex(t, conn, &tagged, t.Name()+"_Home_City",
+		tagged.Where("Home.City").Eq("tatooine").SQL()))
+
+	_, err = tagged.Patch(conn, &Tagged{Name: "skywalker", Version: doc.Version},
+		tagged.ByID(doc.ID))
+	ensure.Nil(t, err)
+	found, err = tagged.Get(conn, doc.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, found.Version, int64(2))
+}
@@ -0,0 +1,176 @@
+package sqjdb
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// IndexSpec describes an index on document fields.
+type IndexSpec struct {
+	// Name of the index. It defaults to the table name and fields joined by
+	// underscores.
+	Name string
+	// Fields are the document fields to index, in order.
+	Fields []string
+	// Unique makes the index enforce unique values.
+	Unique bool
+	// Where is an optional SQL expression that makes this a partial index.
+	Where string
+	// Collate is an optional collation for the fields, such as NoCase. Queries
+	// must use the same collation, for example with Field.Collate, for the
+	// index to be used.
+	Collate string
+}
+
+func (t *Table[T]) indexName(spec IndexSpec) string {
+	if spec.Name != "" {
+		return spec.Name
+	}
+	_, base := splitName(t.Name)
+	// Nested field paths contain characters not allowed in names.
+	return base + "_" + sanitizeName(strings.Join(spec.Fields, "_"))
+}
+
+// sanitizeName replaces the characters not allowed in unquoted SQL names with
+// underscores.
+func sanitizeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// indexDef returns the index definition following "create index", without the
+// schema.
+func (t *Table[T]) indexDef(spec IndexSpec) string {
+	_, base := splitName(t.Name)
+	var def strings.Builder
+	def.WriteString(t.indexName(spec))
+	def.WriteString(" on ")
+	def.WriteString(base)
+	def.WriteString(" (")
+	for i, field := range spec.Fields {
+		if i > 0 {
+			def.WriteString(", ")
+		}
+		if spec.Collate == "" {
+			def.WriteString(fieldExpr(t.FieldName(field)))
+		} else {
+			// Without parentheses the collation applies to the field name.
+			def.WriteString("(" + fieldExpr(t.FieldName(field)) + ") collate " + spec.Collate)
+		}
+	}
+	def.WriteRune(')')
+	if spec.Where != "" {
+		def.WriteString(" where ")
+		def.WriteString(spec.Where)
+	}
+	return def.String()
+}
+
+func (t *Table[T]) indexDDL(spec IndexSpec) string {
+	prefix, _ := splitName(t.Name)
+	if spec.Unique {
+		return "create unique index if not exists " + prefix + t.indexDef(spec)
+	}
+	return "create index if not exists " + prefix + t.indexDef(spec)
+}
+
+// indexSchemaSQL returns the SQL SQLite stores in its schema for the index.
+func (t *Table[T]) indexSchemaSQL(spec IndexSpec) string {
+	if spec.Unique {
+		return "CREATE UNIQUE INDEX " + t.indexDef(spec)
+	}
+	return "CREATE INDEX " + t.indexDef(spec)
+}
+
+// CreateIndex creates the described index if it does not exist.
+func (t *Table[T]) CreateIndex(conn *sqlite.Conn, spec IndexSpec) error {
+	if len(spec.Fields) == 0 {
+		return fmt.Errorf("sqjdb: index on %q has no fields", t.Name)
+	}
+	ddl := t.indexDDL(spec)
+	if err := sqlitex.ExecuteTransient(conn, ddl, nil); err != nil {
+		return fmt.Errorf("sqjdb: creating index %q: %w", t.indexName(spec), err)
+	}
+	return nil
+}
+
+// Indexes declares indexes that Migrate creates, and VerifyIndexes checks.
+func Indexes(specs ...IndexSpec) Option {
+	return func(o *options) {
+		o.indexes = append(o.indexes, specs...)
+	}
+}
+
+func (t *Table[T]) migrateIndexes(conn *sqlite.Conn) error {
+	for _, spec := range t.opts.indexes {
+		if err := t.CreateIndex(conn, spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IndexDrift describes differences between the declared and actual indexes.
+type IndexDrift struct {
+	// Missing are declared indexes that do not exist.
+	Missing []string
+	// Changed are declared indexes that exist with a different definition.
+	Changed []string
+	// Extra are indexes that exist but are not declared.
+	Extra []string
+}
+
+// Empty reports if there is no drift.
+func (d IndexDrift) Empty() bool {
+	return len(d.Missing) == 0 && len(d.Changed) == 0 && len(d.Extra) == 0
+}
+
+// VerifyIndexes compares the indexes declared with the Indexes option against
+// those that exist on the table. The standard ID index and the indexes created
+// by Promote are ignored.
+func (t *Table[T]) VerifyIndexes(conn *sqlite.Conn) (IndexDrift, error) {
+	prefix, base := splitName(t.Name)
+	actual := map[string]string{}
+	err := sqlitex.Execute(conn,
+		"select name, sql from "+prefix+"sqlite_schema where type = 'index' and tbl_name = ? and sql is not null",
+		&sqlitex.ExecOptions{
+			Args: []any{base},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				actual[stmt.ColumnText(0)] = stmt.ColumnText(1)
+				return nil
+			},
+		})
+	if err != nil {
+		return IndexDrift{}, fmt.Errorf("sqjdb: listing indexes on %q: %w", t.Name, err)
+	}
+	delete(actual, base+"_ID")
+	for _, field := range t.opts.promoted {
+		delete(actual, t.promotedIndexName(field))
+	}
+	var drift IndexDrift
+	for _, spec := range t.opts.indexes {
+		name := t.indexName(spec)
+		sql, ok := actual[name]
+		switch {
+		case !ok:
+			drift.Missing = append(drift.Missing, name)
+		case sql != t.indexSchemaSQL(spec):
+			drift.Changed = append(drift.Changed, name)
+		}
+		delete(actual, name)
+	}
+	for name := range actual {
+		drift.Extra = append(drift.Extra, name)
+	}
+	slices.Sort(drift.Extra)
+	return drift, nil
+}
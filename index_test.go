@@ -0,0 +1,83 @@
+package sqjdb_test
+
+import (
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+)
+
+// usesIndex reports if the query plan for the query uses the named index.
+func usesIndex[T any](t *testing.T, conn *sqlite.Conn, table *sqjdb.Table[T], index string, sqls ...sqjdb.SQL) bool {
+	plan, err := table.Explain(conn, sqls...)
+	ensure.Nil(t, err)
+	return sqjdb.UsesIndex(plan, index)
+}
+
+func TestCreateIndex(t *testing.T) {
+	conn := newConn(t)
+	spec := sqjdb.IndexSpec{Fields: []string{"Age", "Name"}}
+	ensure.Nil(t, jedis.CreateIndex(conn, spec))
+	ensure.Nil(t, jedis.CreateIndex(conn, spec))
+	ensure.True(t, usesIndex(t, conn, &jedis, "jedis_Age_Name",
+		sqjdb.Where("Age").Eq(42).And(sqjdb.Where("Name").Eq("luke")).SQL()))
+}
+
+func TestCreateIndexPartialUnique(t *testing.T) {
+	conn := newConn(t)
+	ensure.Nil(t, jedis.CreateIndex(conn, sqjdb.IndexSpec{
+		Name:   "jedis_young_name",
+		Fields: []string{"Name"},
+		Unique: true,
+		Where:  "data->>'Age' < 100",
+	}))
+	_, err := jedis.Insert(conn, &Jedi{Name: yoda.Name, Age: 10})
+	ensure.Nil(t, err)
+	_, err = jedis.Insert(conn, &Jedi{Name: luke.Name, Age: 10})
+	ensure.NotNil(t, err)
+}
+
+func TestCreateIndexNoFields(t *testing.T) {
+	conn := newConn(t)
+	ensure.NotNil(t, jedis.CreateIndex(conn, sqjdb.IndexSpec{}))
+}
+
+func TestIndexesOption(t *testing.T) {
+	conn := newConn(t)
+	byName := sqjdb.IndexSpec{Fields: []string{"Name"}}
+	byAge := sqjdb.IndexSpec{Fields: []string{"Age"}}
+	indexed := sqjdb.NewTable[Jedi]("jedis", sqjdb.Indexes(byName, byAge))
+
+	drift, err := indexed.VerifyIndexes(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, drift.Missing, []string{"jedis_Name", "jedis_Age"})
+
+	ensure.Nil(t, indexed.Migrate(conn))
+	drift, err = indexed.VerifyIndexes(conn)
+	ensure.Nil(t, err)
+	ensure.True(t, drift.Empty())
+
+	changed := sqjdb.NewTable[Jedi]("jedis",
+		sqjdb.Indexes(sqjdb.IndexSpec{Fields: []string{"Name"}, Unique: true}))
+	drift, err = changed.VerifyIndexes(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, drift, sqjdb.IndexDrift{
+		Changed: []string{"jedis_Name"},
+		Extra:   []string{"jedis_Age"},
+	})
+}
+
+func TestCreateIndexCollate(t *testing.T) {
+	conn := newConn(t)
+	ensure.Nil(t, jedis.CreateIndex(conn, sqjdb.IndexSpec{
+		Fields:  []string{"Name"},
+		Collate: sqjdb.NoCase,
+	}))
+	cond := sqjdb.Where("Name").Collate(sqjdb.NoCase).Eq("YODA")
+	ensure.True(t, usesIndex(t, conn, &jedis, "jedis_Name", cond.SQL()))
+	docs, err := jedis.All(conn, cond.SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 1)
+	ensure.DeepEqual(t, docs[0].ID, yoda.ID)
+}
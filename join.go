@@ -0,0 +1,104 @@
+package sqjdb
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// Joined is a pair of documents returned by Join and LeftJoin.
+type Joined[L, R any] struct {
+	Left  *L
+	Right *R
+}
+
+// Qualified starts a condition on the named document field of this table,
+// qualified with the table name so it is unambiguous in joins.
+func (t *Table[T]) Qualified(name string) Field {
+	_, base := splitName(t.Name)
+	return Field{expr: base + "." + t.fieldSQL(name)}
+}
+
+// Join returns the pairs of documents from the two tables where leftField of
+// the left document equals rightField of the right document, per the given
+// query. Since both tables have a data column, conditions and ordering should
+// use Table.Qualified, for example orders.Qualified("Total").OrderBy(Desc).
+// The tables must have different names. Documents are upgraded per the
+// SchemaVersion of their table.
+func Join[L, R any](conn *sqlite.Conn, left *Table[L], right *Table[R], leftField, rightField string, sqls ...SQL) ([]Joined[L, R], error) {
+	return join(conn, "join", left, right, leftField, rightField, sqls)
+}
+
+// LeftJoin is like Join, but includes left documents without a matching right
+// document, with a nil Right.
+func LeftJoin[L, R any](conn *sqlite.Conn, left *Table[L], right *Table[R], leftField, rightField string, sqls ...SQL) ([]Joined[L, R], error) {
+	return join(conn, "left join", left, right, leftField, rightField, sqls)
+}
+
+func join[L, R any](conn *sqlite.Conn, kind string, left *Table[L], right *Table[R], leftField, rightField string, sqls []SQL) (_ []Joined[L, R], err error) {
+	_, leftBase := splitName(left.Name)
+	_, rightBase := splitName(right.Name)
+	if leftBase == rightBase {
+		return nil, fmt.Errorf("sqjdb: can not join %q to itself", left.Name)
+	}
+	var query strings.Builder
+	query.WriteString("select json(" + leftBase + ".data), json(" + rightBase + ".data) from")
+	on := left.Qualified(leftField).expr + " = " + right.Qualified(rightField).expr
+	sqls = slices.Concat(
+		[]SQL{left.from(), {Query: kind}, right.from(), {Query: "on " + on}},
+		sqls,
+	)
+	addSQLQuery(&query, sqls)
+	sp := left.trace(conn, "join", query.String(), sqls)
+	defer func() { err = sp.finish(err) }()
+	stmt, err := prepareSQL(conn, query.String(), sqls)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseStmt(stmt)
+	var pairs []Joined[L, R]
+	var buf []byte
+	var leftPending, rightPending []upgradedDoc
+	for {
+		rowReturned, err := stmt.Step()
+		if err != nil {
+			return nil, err
+		}
+		if !rowReturned {
+			break
+		}
+		sp.addRows(1)
+		var pair Joined[L, R]
+		pair.Left = new(L)
+		upgraded, err := left.decode(columnBytes(stmt, 0, &buf), pair.Left)
+		if err != nil {
+			return nil, err
+		}
+		if upgraded != nil {
+			leftPending = append(leftPending, *upgraded)
+		}
+		if stmt.ColumnType(1) != sqlite.TypeNull {
+			pair.Right = new(R)
+			upgraded, err := right.decode(columnBytes(stmt, 1, &buf), pair.Right)
+			if err != nil {
+				return nil, err
+			}
+			if upgraded != nil {
+				rightPending = append(rightPending, *upgraded)
+			}
+		}
+		pairs = append(pairs, pair)
+	}
+	if len(leftPending) > 0 || len(rightPending) > 0 {
+		stmt.Reset()
+		if err := left.writeBack(conn, leftPending); err != nil {
+			return nil, err
+		}
+		if err := right.writeBack(conn, rightPending); err != nil {
+			return nil, err
+		}
+	}
+	return pairs, nil
+}
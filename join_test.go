@@ -0,0 +1,78 @@
+package sqjdb_test
+
+import (
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+)
+
+type Customer struct {
+	ID   string `json:",omitempty"`
+	Name string `json:",omitempty"`
+}
+
+type Order struct {
+	ID         string `json:",omitempty"`
+	CustomerID string `json:",omitempty"`
+	Total      int    `json:",omitempty"`
+}
+
+func newOrders(t *testing.T) (*sqlite.Conn, sqjdb.Table[Customer], sqjdb.Table[Order]) {
+	conn := newConn(t)
+	customers := sqjdb.NewTable[Customer](t.Name() + "_customers")
+	orders := sqjdb.NewTable[Order](t.Name()+"_orders", sqjdb.SoftDelete("DeletedAt"))
+	ensure.Nil(t, customers.Migrate(conn))
+	ensure.Nil(t, orders.Migrate(conn))
+	for _, c := range []*Customer{{ID: "c1", Name: "han"}, {ID: "c2", Name: "chewie"}} {
+		_, err := customers.Insert(conn, c)
+		ensure.Nil(t, err)
+	}
+	for _, o := range []*Order{
+		{ID: "o1", CustomerID: "c1", Total: 10},
+		{ID: "o2", CustomerID: "c1", Total: 20},
+		{ID: "o3", CustomerID: "c1", Total: 30},
+	} {
+		_, err := orders.Insert(conn, o)
+		ensure.Nil(t, err)
+	}
+	_, err := orders.Delete(conn, orders.ByID("o3"))
+	ensure.Nil(t, err)
+	return conn, customers, orders
+}
+
+func TestJoin(t *testing.T) {
+	conn, customers, orders := newOrders(t)
+	pairs, err := sqjdb.Join(conn, &orders, &customers, "CustomerID", "ID",
+		orders.Qualified("Total").Gt(15).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(pairs), 1)
+	ensure.DeepEqual(t, pairs[0].Left.ID, "o2")
+	ensure.DeepEqual(t, pairs[0].Right.Name, "han")
+}
+
+func TestLeftJoin(t *testing.T) {
+	conn, customers, orders := newOrders(t)
+	pairs, err := sqjdb.LeftJoin(conn, &customers, &orders, "ID", "CustomerID",
+		customers.Qualified("ID").OrderBy(sqjdb.Asc))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(pairs), 3)
+	ensure.DeepEqual(t, pairs[2].Left.Name, "chewie")
+	ensure.True(t, pairs[2].Right == nil)
+}
+
+func TestJoinUpgrades(t *testing.T) {
+	conn, _, orders := newOrders(t)
+	v1 := sqjdb.NewTable[ContactV1](t.Name() + "_contacts")
+	ensure.Nil(t, v1.Migrate(conn))
+	_, err := v1.Insert(conn, &ContactV1{ID: "c1", Name: "Han Solo"})
+	ensure.Nil(t, err)
+
+	contacts := sqjdb.NewTable[Contact](v1.Name, sqjdb.SchemaVersion("SchemaVersion", splitName))
+	pairs, err := sqjdb.Join(conn, &orders, &contacts, "CustomerID", "ID",
+		orders.Qualified("ID").OrderBy(sqjdb.Asc))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(pairs), 2)
+	ensure.DeepEqual(t, pairs[0].Right, &Contact{ID: "c1", First: "Han", Last: "Solo", SchemaVersion: 2})
+}
@@ -0,0 +1,164 @@
+package sqjdb
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// ExportJSONL writes the documents per the given query to w as newline
+// delimited JSON, one document per line, streaming them from the database.
+func (t *Table[T]) ExportJSONL(conn *sqlite.Conn, w io.Writer, sqls ...SQL) (err error) {
+	var query strings.Builder
+	query.WriteString("select json(data) from")
+	sqls = slices.Concat([]SQL{t.from()}, sqls)
+	addSQLQuery(&query, sqls)
+	sp := t.trace(conn, "export_jsonl", query.String(), sqls)
+	defer func() { err = sp.finish(err) }()
+	stmt, err := prepareSQL(conn, query.String(), sqls)
+	if err != nil {
+		return err
+	}
+	defer releaseStmt(stmt)
+	bw := bufio.NewWriter(w)
+	var buf []byte
+	for {
+		rowReturned, err := stmt.Step()
+		if err != nil {
+			return err
+		}
+		if !rowReturned {
+			break
+		}
+		sp.addRows(1)
+		bw.Write(columnBytes(stmt, 0, &buf))
+		if err := bw.WriteByte('\n'); err != nil {
+			return fmt.Errorf("sqjdb: exporting %q: %w", t.Name, err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("sqjdb: exporting %q: %w", t.Name, err)
+	}
+	return nil
+}
+
+// Conflict is the strategy used by ImportJSONL for documents whose ID already
+// exists.
+type Conflict int
+
+const (
+	// ConflictFail fails the import with a UniqueViolationError.
+	ConflictFail Conflict = iota
+	// ConflictSkip keeps the existing document.
+	ConflictSkip
+	// ConflictReplace replaces the existing document, like Upsert.
+	ConflictReplace
+	// ConflictUpsert merges the imported document into the existing one as a
+	// JSON Merge Patch, like Patch.
+	ConflictUpsert
+)
+
+// ImportOptions configures ImportJSONL.
+type ImportOptions struct {
+	// BatchSize is the number of documents imported per transaction. The
+	// default is 1000.
+	BatchSize int
+	// Conflict is the strategy for documents whose ID already exists.
+	Conflict Conflict
+}
+
+// conflictSQL returns the on conflict clause implementing the strategy.
+func (t *Table[T]) conflictSQL(c Conflict) SQL {
+	target := "on conflict (" + fieldExpr(t.opts.idKey) + ") "
+	var sql SQL
+	switch c {
+	case ConflictFail:
+		return SQL{}
+	case ConflictSkip:
+		return SQL{Query: target + "do nothing"}
+	case ConflictReplace:
+		sql = t.assignData(SQL{Query: "excluded.data"})
+	case ConflictUpsert:
+		sql = t.assignData(SQL{Query: "jsonb_patch(data, excluded.data)"})
+	}
+	sql.Query = target + "do update " + sql.Query
+	if t.opts.tenant != "" {
+		// Documents belonging to another tenant are not changed.
+		cond := Where(t.FieldName(t.opts.tenant)).Eq(t.opts.tenantID)
+		sql.Query += " where " + cond.Expr
+		sql.Args = append(sql.Args, cond.Args...)
+	}
+	return sql
+}
+
+// ImportJSONL imports newline delimited JSON documents from r, as written by
+// ExportJSONL, and returns the number of documents imported or changed. On
+// error, it is the number in the batches imported before the failure. The
+// documents are inserted like InsertRaw, so hooks and validation are skipped.
+// Each batch is imported within a savepoint, so a failure rolls back the
+// current batch, but not the ones before it unless the connection is in a
+// transaction.
+func (t *Table[T]) ImportJSONL(conn *sqlite.Conn, r io.Reader, opts ImportOptions) (int, error) {
+	dec := json.NewDecoder(r)
+	return t.importDocs(conn, func() (json.RawMessage, error) {
+		var doc json.RawMessage
+		if err := dec.Decode(&doc); err != nil {
+			return nil, err
+		}
+		return doc, nil
+	}, opts)
+}
+
+// importDocs imports the documents returned by next until it returns io.EOF.
+func (t *Table[T]) importDocs(conn *sqlite.Conn, next func() (json.RawMessage, error), opts ImportOptions) (int, error) {
+	batchSize := opts.BatchSize
+	if batchSize < 1 {
+		batchSize = 1000
+	}
+	conflict := t.conflictSQL(opts.Conflict)
+	var n, line int
+	for done := false; !done; {
+		var batch int
+		err := func() (err error) {
+			defer settleNotifications(conn)
+			defer sqlitex.Save(conn)(&err)
+			for range batchSize {
+				doc, err := next()
+				if err != nil {
+					if errors.Is(err, io.EOF) {
+						done = true
+						return nil
+					}
+					return fmt.Errorf("sqjdb: importing %q: document %d: %w", t.Name, line+1, err)
+				}
+				line++
+				stored, err := t.insertRaw(conn, doc, conflict)
+				if err != nil {
+					return fmt.Errorf("sqjdb: importing %q: document %d: %w", t.Name, line, err)
+				}
+				if stored == nil && opts.Conflict != ConflictSkip {
+					return &UniqueViolationError{
+						Field: t.opts.idKey,
+						Err:   fmt.Errorf("sqjdb: document %d in %q belongs to another tenant", line, t.Name),
+					}
+				}
+				if stored != nil {
+					batch++
+				}
+			}
+			return nil
+		}()
+		if err != nil {
+			return n, err
+		}
+		n += batch
+	}
+	return n, nil
+}
@@ -0,0 +1,73 @@
+package sqjdb_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestExportImportJSONL(t *testing.T) {
+	conn := newConn(t)
+	var buf bytes.Buffer
+	ensure.Nil(t, jedis.ExportJSONL(conn, &buf, sqjdb.OrderBy("Name", sqjdb.Asc)))
+	ensure.DeepEqual(t, strings.Count(buf.String(), "\n"), 3)
+
+	restored := sqjdb.NewTable[Jedi](t.Name())
+	ensure.Nil(t, restored.Migrate(conn))
+	n, err := restored.ImportJSONL(conn, &buf, sqjdb.ImportOptions{BatchSize: 2})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 3)
+	docs, err := restored.All(conn, sqjdb.OrderBy("Name", sqjdb.Asc))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docs, []*Jedi{&leia, &luke, &yoda})
+}
+
+func TestImportJSONLConflicts(t *testing.T) {
+	conn := newConn(t)
+	input := `{"ID":"` + yoda.ID + `","Name":"master yoda"}` + "\n" + `{"Name":"grogu"}` + "\n"
+
+	_, err := jedis.ImportJSONL(conn, strings.NewReader(input), sqjdb.ImportOptions{})
+	var uve *sqjdb.UniqueViolationError
+	ensure.True(t, errors.As(err, &uve))
+
+	n, err := jedis.ImportJSONL(conn, strings.NewReader(input), sqjdb.ImportOptions{Conflict: sqjdb.ConflictSkip})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+	doc, err := jedis.Get(conn, yoda.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, doc, &yoda)
+
+	_, err = jedis.ImportJSONL(conn, strings.NewReader(input), sqjdb.ImportOptions{Conflict: sqjdb.ConflictUpsert})
+	ensure.Nil(t, err)
+	doc, err = jedis.Get(conn, yoda.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, doc, &Jedi{ID: yoda.ID, Name: "master yoda", Age: yoda.Age})
+
+	_, err = jedis.ImportJSONL(conn, strings.NewReader(input), sqjdb.ImportOptions{Conflict: sqjdb.ConflictReplace})
+	ensure.Nil(t, err)
+	doc, err = jedis.Get(conn, yoda.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, doc, &Jedi{ID: yoda.ID, Name: "master yoda"})
+
+	count, err := jedis.Count(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, count, 6)
+}
+
+func TestImportJSONLCodec(t *testing.T) {
+	conn := newConn(t)
+	compressed := sqjdb.NewTable[Jedi](t.Name(), sqjdb.UseCodec(sqjdb.Gzip(0)))
+	ensure.Nil(t, compressed.Migrate(conn))
+	_, err := compressed.Insert(conn, &yoda)
+	ensure.Nil(t, err)
+	input := `{"ID":"` + yoda.ID + `","Name":"master yoda"}`
+	_, err = compressed.ImportJSONL(conn, strings.NewReader(input), sqjdb.ImportOptions{Conflict: sqjdb.ConflictUpsert})
+	ensure.Nil(t, err)
+	doc, err := compressed.Get(conn, yoda.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, doc, &Jedi{ID: yoda.ID, Name: "master yoda", Age: yoda.Age})
+}
@@ -0,0 +1,192 @@
+package sqjdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// JSONPatchOp is a single RFC 6902 JSON Patch operation. All the operations,
+// add, remove, replace, move, copy and test, are supported.
+type JSONPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// jsonPointerPath converts a JSON Pointer into a SQLite JSON path. Segments
+// made up only of digits are treated as array indexes, and "-" refers to the
+// end of an array.
+func jsonPointerPath(pointer string) (string, error) {
+	if pointer == "" || pointer[0] != '/' {
+		return "", fmt.Errorf("sqjdb: invalid JSON pointer %q", pointer)
+	}
+	var path strings.Builder
+	path.WriteRune('$')
+	for _, seg := range strings.Split(pointer[1:], "/") {
+		seg = strings.ReplaceAll(seg, "~1", "/")
+		seg = strings.ReplaceAll(seg, "~0", "~")
+		switch {
+		case seg == "-":
+			path.WriteString("[#]")
+		case seg != "" && strings.Trim(seg, "0123456789") == "":
+			path.WriteString("[" + seg + "]")
+		case strings.ContainsRune(seg, '"'):
+			return "", fmt.Errorf("sqjdb: unsupported JSON pointer %q", pointer)
+		default:
+			path.WriteString(`."` + seg + `"`)
+		}
+	}
+	return path.String(), nil
+}
+
+// jsonPatchStep applies a single operation to the document expression. The
+// document is bound once as d in a subquery, so operations can refer to it
+// more than once without repeating the expression, and the statement grows
+// linearly with the number of operations. If check is not empty and does not
+// hold, the statement fails with the given message, which is smuggled out as
+// an invalid JSON path since SQLite has no other way to raise an error.
+func jsonPatchStep(doc, op, check SQL, msg string) SQL {
+	result := op
+	if check.Query != "" {
+		result = SQL{
+			Query: "case when " + check.Query + " then " + op.Query +
+				" else jsonb_extract(d, ?) end",
+			Args: slices.Concat(check.Args, op.Args, []any{msg}),
+		}
+	}
+	return SQL{
+		Query: "(select " + result.Query + " from (select " + doc.Query + " as d))",
+		Args:  slices.Concat(result.Args, doc.Args),
+	}
+}
+
+// jsonPatchAdd applies an add operation to the document expression. The parent
+// of the path must exist. Adding at an array index inserts the value before the
+// element at that index, shifting the later elements, by rebuilding the array
+// in order with the value slotted in between.
+func jsonPatchAdd(doc SQL, op JSONPatchOp, path string) (SQL, error) {
+	i := strings.LastIndexByte(op.Path, '/')
+	parent, last := "$", op.Path[i+1:]
+	if i > 0 {
+		var err error
+		if parent, err = jsonPointerPath(op.Path[:i]); err != nil {
+			return SQL{}, err
+		}
+		doc = jsonPatchStep(doc, SQL{Query: "d"}, SQL{
+			Query: "json_type(d, ?) in ('object', 'array')",
+			Args:  []any{parent},
+		}, fmt.Sprintf("sqjdb: JSON Patch add to missing path %s", op.Path[:i]))
+	}
+	set := SQL{
+		Query: "jsonb_set(d, ?, json(?))",
+		Args:  []any{path, string(op.Value)},
+	}
+	if last == "" || strings.Trim(last, "0123456789") != "" {
+		return jsonPatchStep(doc, set, SQL{}, ""), nil
+	}
+	index, err := strconv.Atoi(last)
+	if err != nil {
+		return SQL{}, fmt.Errorf("sqjdb: invalid JSON pointer %q", op.Path)
+	}
+	// json_quote keeps the JSON text of nested objects and arrays as is, and
+	// json(v) parses it back once the rows lose their JSON subtype.
+	return jsonPatchStep(doc, SQL{
+		Query: "case when json_type(d, ?) = 'array' then jsonb_set(d, ?, (" +
+			"select jsonb_group_array(json(v) order by o) from (" +
+			"select json_quote(value) as v, key * 2 as o from json_each(d, ?) " +
+			"union all select json(?), ?)) else " + set.Query + " end",
+		Args: slices.Concat(
+			[]any{parent, parent, parent, string(op.Value), index*2 - 1},
+			set.Args,
+		),
+	}, SQL{
+		Query: "json_type(d, ?) <> 'array' or json_array_length(d, ?) >= ?",
+		Args:  []any{parent, parent, index},
+	}, fmt.Sprintf("sqjdb: JSON Patch add at out of range index %s", op.Path)), nil
+}
+
+// jsonPatchExpr translates the operations into a single SQL expression that
+// transforms the data column.
+func jsonPatchExpr(ops []JSONPatchOp) (SQL, error) {
+	expr := SQL{Query: "data"}
+	for _, op := range ops {
+		path, err := jsonPointerPath(op.Path)
+		if err != nil {
+			return SQL{}, err
+		}
+		var from string
+		if op.Op == "move" || op.Op == "copy" {
+			if from, err = jsonPointerPath(op.From); err != nil {
+				return SQL{}, err
+			}
+		}
+		exists := func(path string) SQL {
+			return SQL{Query: "json_type(d, ?) is not null", Args: []any{path}}
+		}
+		missing := fmt.Sprintf("sqjdb: JSON Patch %s of missing path %s", op.Op, op.Path)
+		switch op.Op {
+		default:
+			return SQL{}, fmt.Errorf("sqjdb: unsupported JSON Patch op %q", op.Op)
+		case "add":
+			expr, err = jsonPatchAdd(expr, op, path)
+			if err != nil {
+				return SQL{}, err
+			}
+		case "replace":
+			expr = jsonPatchStep(expr, SQL{
+				Query: "jsonb_replace(d, ?, json(?))",
+				Args:  []any{path, string(op.Value)},
+			}, exists(path), missing)
+		case "remove":
+			expr = jsonPatchStep(expr, SQL{
+				Query: "jsonb_remove(d, ?)",
+				Args:  []any{path},
+			}, exists(path), missing)
+		case "copy":
+			missing = fmt.Sprintf("sqjdb: JSON Patch copy from missing path %s", op.From)
+			expr = jsonPatchStep(expr, SQL{
+				Query: "jsonb_set(d, ?, jsonb_extract(d, ?))",
+				Args:  []any{path, from},
+			}, exists(from), missing)
+		case "move":
+			missing = fmt.Sprintf("sqjdb: JSON Patch move from missing path %s", op.From)
+			expr = jsonPatchStep(expr, SQL{
+				Query: "jsonb_set(jsonb_remove(d, ?), ?, jsonb_extract(d, ?))",
+				Args:  []any{from, path, from},
+			}, exists(from), missing)
+		case "test":
+			var value bytes.Buffer
+			if err := json.Compact(&value, op.Value); err != nil {
+				return SQL{}, fmt.Errorf("sqjdb: invalid JSON Patch test value: %w", err)
+			}
+			expr = jsonPatchStep(expr, SQL{Query: "d"}, SQL{
+				Query: "d -> ? = ?",
+				Args:  []any{path, value.String()},
+			}, fmt.Sprintf("sqjdb: JSON Patch test failed at %s", op.Path))
+		}
+	}
+	return expr, nil
+}
+
+// JSONPatch applies the RFC 6902 JSON Patch operations per the given query.
+// The operations are translated to jsonb_set, jsonb_replace and jsonb_remove
+// calls and applied in a single statement, so if any operation fails for any
+// document, including a failed test, an add to a missing parent or an out of
+// range array index, or a replace, remove, copy or move of a missing path,
+// none are updated. Note that test compares the minified JSON text, so objects
+// only match with their keys in the same order. It returns the number of
+// documents updated.
+func (t *Table[T]) JSONPatch(conn *sqlite.Conn, ops []JSONPatchOp, sqls ...SQL) (int, error) {
+	expr, err := jsonPatchExpr(ops)
+	if err != nil {
+		return 0, err
+	}
+	return t.patch(conn, expr, sqls)
+}
@@ -0,0 +1,186 @@
+package sqjdb_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+type Padawan struct {
+	ID      string         `json:",omitempty"`
+	Name    string         `json:",omitempty"`
+	Master  map[string]any `json:",omitempty"`
+	Former  map[string]any `json:",omitempty"`
+	Sabers  []string       `json:",omitempty"`
+	Planets []string       `json:",omitempty"`
+}
+
+func TestJSONPatch(t *testing.T) {
+	conn := newConn(t)
+	padawans := sqjdb.NewTable[Padawan](t.Name())
+	ensure.Nil(t, padawans.Migrate(conn))
+	p, err := padawans.Insert(conn, &Padawan{
+		Name:   "ahsoka",
+		Master: map[string]any{"Name": "anakin"},
+		Sabers: []string{"green"},
+	})
+	ensure.Nil(t, err)
+
+	var ops []sqjdb.JSONPatchOp
+	ensure.Nil(t, json.Unmarshal([]byte(`[
+		{"op": "replace", "path": "/Name", "value": "fulcrum"},
+		{"op": "add", "path": "/Sabers/-", "value": "white"},
+		{"op": "copy", "from": "/Sabers", "path": "/Planets"},
+		{"op": "move", "from": "/Master", "path": "/Former"},
+		{"op": "remove", "path": "/Planets/0"}
+	]`), &ops))
+	n, err := padawans.JSONPatch(conn, ops, sqjdb.ByID(p.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+
+	after, err := padawans.Get(conn, p.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, after, &Padawan{
+		ID:      p.ID,
+		Name:    "fulcrum",
+		Former:  map[string]any{"Name": "anakin"},
+		Sabers:  []string{"green", "white"},
+		Planets: []string{"white"},
+	})
+}
+
+func TestJSONPatchUnsupportedOp(t *testing.T) {
+	conn := newConn(t)
+	_, err := jedis.JSONPatch(conn, []sqjdb.JSONPatchOp{{Op: "frob", Path: "/Name"}})
+	ensure.NotNil(t, err)
+}
+
+func TestJSONPatchTest(t *testing.T) {
+	conn := newConn(t)
+	padawans := sqjdb.NewTable[Padawan](t.Name())
+	ensure.Nil(t, padawans.Migrate(conn))
+	p, err := padawans.Insert(conn, &Padawan{Name: "ahsoka", Sabers: []string{"green"}})
+	ensure.Nil(t, err)
+
+	patch := func(value string) error {
+		_, err := padawans.JSONPatch(conn, []sqjdb.JSONPatchOp{
+			{Op: "test", Path: "/Sabers", Value: json.RawMessage(value)},
+			{Op: "replace", Path: "/Name", Value: json.RawMessage(`"fulcrum"`)},
+		}, sqjdb.ByID(p.ID))
+		return err
+	}
+	err = patch(`["blue"]`)
+	ensure.NotNil(t, err)
+	ensure.StringContains(t, err.Error(), "JSON Patch test failed at /Sabers")
+	after, err := padawans.Get(conn, p.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, after.Name, "ahsoka")
+
+	ensure.Nil(t, patch(`[ "green" ]`))
+	after, err = padawans.Get(conn, p.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, after.Name, "fulcrum")
+}
+
+func TestJSONPatchMissingPath(t *testing.T) {
+	conn := newConn(t)
+	padawans := sqjdb.NewTable[Padawan](t.Name())
+	ensure.Nil(t, padawans.Migrate(conn))
+	p, err := padawans.Insert(conn, &Padawan{Name: "ahsoka"})
+	ensure.Nil(t, err)
+
+	for _, op := range []sqjdb.JSONPatchOp{
+		{Op: "replace", Path: "/Master", Value: json.RawMessage(`{}`)},
+		{Op: "remove", Path: "/Master"},
+		{Op: "copy", From: "/Master", Path: "/Former"},
+		{Op: "move", From: "/Master", Path: "/Former"},
+	} {
+		_, err := padawans.JSONPatch(conn, []sqjdb.JSONPatchOp{
+			{Op: "add", Path: "/Name", Value: json.RawMessage(`"fulcrum"`)},
+			op,
+		}, sqjdb.ByID(p.ID))
+		ensure.NotNil(t, err)
+		ensure.StringContains(t, err.Error(), "missing path /Master")
+	}
+	after, err := padawans.Get(conn, p.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, after, &Padawan{ID: p.ID, Name: "ahsoka"})
+}
+
+func TestJSONPatchManyOps(t *testing.T) {
+	conn := newConn(t)
+	padawans := sqjdb.NewTable[Padawan](t.Name())
+	ensure.Nil(t, padawans.Migrate(conn))
+	p, err := padawans.Insert(conn, &Padawan{Name: "ahsoka"})
+	ensure.Nil(t, err)
+
+	// Each op refers to the document more than once, which must not make the
+	// statement grow exponentially.
+	var ops []sqjdb.JSONPatchOp
+	for range 100 {
+		ops = append(ops,
+			sqjdb.JSONPatchOp{Op: "copy", From: "/Name", Path: "/Former"},
+			sqjdb.JSONPatchOp{Op: "move", From: "/Former", Path: "/Name"})
+	}
+	n, err := padawans.JSONPatch(conn, ops, sqjdb.ByID(p.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+	after, err := padawans.Get(conn, p.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, after, &Padawan{ID: p.ID, Name: "ahsoka"})
+}
+
+func TestJSONPatchAddInsert(t *testing.T) {
+	conn := newConn(t)
+	padawans := sqjdb.NewTable[Padawan](t.Name())
+	ensure.Nil(t, padawans.Migrate(conn))
+	p, err := padawans.Insert(conn, &Padawan{
+		Name:   "ahsoka",
+		Master: map[string]any{"Students": []any{map[string]any{"Name": "anakin"}}},
+		Sabers: []string{"green", "blue"},
+	})
+	ensure.Nil(t, err)
+
+	var ops []sqjdb.JSONPatchOp
+	ensure.Nil(t, json.Unmarshal([]byte(`[
+		{"op": "add", "path": "/Sabers/0", "value": "white"},
+		{"op": "add", "path": "/Sabers/2", "value": "red"},
+		{"op": "add", "path": "/Sabers/4", "value": "yellow"},
+		{"op": "add", "path": "/Master/Students/0", "value": {"Name": "obi-wan"}}
+	]`), &ops))
+	_, err = padawans.JSONPatch(conn, ops, sqjdb.ByID(p.ID))
+	ensure.Nil(t, err)
+	after, err := padawans.Get(conn, p.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, after.Sabers, []string{"white", "green", "red", "blue", "yellow"})
+	ensure.DeepEqual(t, after.Master, map[string]any{"Students": []any{
+		map[string]any{"Name": "obi-wan"},
+		map[string]any{"Name": "anakin"},
+	}})
+
+	_, err = padawans.JSONPatch(conn, []sqjdb.JSONPatchOp{
+		{Op: "add", Path: "/Sabers/9", Value: json.RawMessage(`"purple"`)},
+	}, sqjdb.ByID(p.ID))
+	ensure.NotNil(t, err)
+	ensure.StringContains(t, err.Error(), "out of range index /Sabers/9")
+}
+
+func TestJSONPatchAddMissingParent(t *testing.T) {
+	conn := newConn(t)
+	padawans := sqjdb.NewTable[Padawan](t.Name())
+	ensure.Nil(t, padawans.Migrate(conn))
+	p, err := padawans.Insert(conn, &Padawan{Name: "ahsoka"})
+	ensure.Nil(t, err)
+
+	_, err = padawans.JSONPatch(conn, []sqjdb.JSONPatchOp{
+		{Op: "add", Path: "/Name", Value: json.RawMessage(`"fulcrum"`)},
+		{Op: "add", Path: "/Master/Name", Value: json.RawMessage(`"anakin"`)},
+	}, sqjdb.ByID(p.ID))
+	ensure.NotNil(t, err)
+	ensure.StringContains(t, err.Error(), "add to missing path /Master")
+	after, err := padawans.Get(conn, p.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, after, &Padawan{ID: p.ID, Name: "ahsoka"})
+}
@@ -0,0 +1,72 @@
+package sqjdb
+
+import (
+	"time"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// KVEntry is a value stored in a KV.
+type KVEntry[T any] struct {
+	Key   string `sqjdb:"id"`
+	Value T
+	// Expires is when the entry expires, or nil if it does not.
+	Expires *time.Time `json:",omitempty"`
+}
+
+// KV is a typed key value store in a document table. Entries set with a TTL
+// are hidden once they expire, and removed by Purge.
+type KV[T any] struct {
+	Entries Table[KVEntry[T]]
+}
+
+// NewKV creates a KV stored in the named table.
+func NewKV[T any](name string) KV[T] {
+	return KV[T]{Entries: NewTable[KVEntry[T]](name, Expires("Expires"))}
+}
+
+// Migrate creates the table for the store.
+func (kv *KV[T]) Migrate(conn *sqlite.Conn) error {
+	return kv.Entries.Migrate(conn)
+}
+
+// Get returns the value for the key. It returns ErrNoDoc if the key is not set
+// or has expired.
+func (kv *KV[T]) Get(conn *sqlite.Conn, key string) (*T, error) {
+	entry, err := kv.Entries.Get(conn, key)
+	if err != nil {
+		return nil, err
+	}
+	return &entry.Value, nil
+}
+
+// Set sets the value for the key, replacing any existing value. A positive ttl
+// makes the entry expire after it.
+func (kv *KV[T]) Set(conn *sqlite.Conn, key string, value *T, ttl time.Duration) error {
+	entry := &KVEntry[T]{Key: key, Value: *value}
+	if ttl > 0 {
+		expires := time.Now().Add(ttl).UTC()
+		entry.Expires = &expires
+	}
+	_, err := kv.Entries.Upsert(conn, entry)
+	return err
+}
+
+// Delete deletes the key, and reports whether it was set.
+func (kv *KV[T]) Delete(conn *sqlite.Conn, key string) (bool, error) {
+	n, err := kv.Entries.Delete(conn, kv.Entries.ByID(key))
+	return n > 0, err
+}
+
+// Scan returns the entries with keys starting with prefix, ordered by key. The
+// queries are added after the prefix condition, and can be used to paginate
+// with Limit.
+func (kv *KV[T]) Scan(conn *sqlite.Conn, prefix string, sqls ...SQL) ([]*KVEntry[T], error) {
+	cond := prefixRange(fieldExpr(kv.Entries.opts.idKey), prefix)
+	return kv.Entries.All(conn, append([]SQL{cond.SQL(), OrderBy(kv.Entries.opts.idKey, Asc)}, sqls...)...)
+}
+
+// Purge removes expired entries, and returns the number removed.
+func (kv *KV[T]) Purge(conn *sqlite.Conn) (int, error) {
+	return kv.Entries.PurgeExpired(conn)
+}
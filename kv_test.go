@@ -0,0 +1,74 @@
+package sqjdb_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestKV(t *testing.T) {
+	conn := newConn(t)
+	kv := sqjdb.NewKV[Jedi](t.Name())
+	ensure.Nil(t, kv.Migrate(conn))
+
+	_, err := kv.Get(conn, "jedi/luke")
+	ensure.True(t, errors.Is(err, sqjdb.ErrNoDoc))
+	ensure.Nil(t, kv.Set(conn, "jedi/luke", &Jedi{Name: "luke"}, 0))
+	ensure.Nil(t, kv.Set(conn, "jedi/luke", &Jedi{Name: "luke", Age: 42}, 0))
+	ensure.Nil(t, kv.Set(conn, "jedi/yoda", &Jedi{Name: "yoda"}, 0))
+	ensure.Nil(t, kv.Set(conn, "sith/vader", &Jedi{Name: "vader"}, 0))
+	v, err := kv.Get(conn, "jedi/luke")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, v, &Jedi{Name: "luke", Age: 42})
+
+	entries, err := kv.Scan(conn, "jedi/")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(entries), 2)
+	ensure.DeepEqual(t, entries[0].Key, "jedi/luke")
+	ensure.DeepEqual(t, entries[1].Value.Name, "yoda")
+	entries, err = kv.Scan(conn, "", sqjdb.Limit(1))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(entries), 1)
+
+	deleted, err := kv.Delete(conn, "jedi/yoda")
+	ensure.Nil(t, err)
+	ensure.True(t, deleted)
+	deleted, err = kv.Delete(conn, "jedi/yoda")
+	ensure.Nil(t, err)
+	ensure.False(t, deleted)
+}
+
+func TestKVTTL(t *testing.T) {
+	conn := newConn(t)
+	kv := sqjdb.NewKV[Jedi](t.Name())
+	ensure.Nil(t, kv.Migrate(conn))
+	ensure.Nil(t, kv.Set(conn, "short", &Jedi{Name: "short"}, time.Millisecond))
+	ensure.Nil(t, kv.Set(conn, "long", &Jedi{Name: "long"}, time.Hour))
+	time.Sleep(10 * time.Millisecond)
+
+	_, err := kv.Get(conn, "short")
+	ensure.True(t, errors.Is(err, sqjdb.ErrNoDoc))
+	_, err = kv.Get(conn, "long")
+	ensure.Nil(t, err)
+	n, err := kv.Purge(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+}
+
+func TestKVSetExpired(t *testing.T) {
+	conn := newConn(t)
+	kv := sqjdb.NewKV[Jedi](t.Name())
+	ensure.Nil(t, kv.Migrate(conn))
+	ensure.Nil(t, kv.Set(conn, "key", &Jedi{Name: "short"}, time.Millisecond))
+	time.Sleep(10 * time.Millisecond)
+	ensure.Nil(t, kv.Set(conn, "key", &Jedi{Name: "again"}, 0))
+	v, err := kv.Get(conn, "key")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, v.Name, "again")
+	n, err := kv.Purge(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 0)
+}
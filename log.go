@@ -0,0 +1,87 @@
+package sqjdb
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+)
+
+// LogOptions configures Logged.
+type LogOptions struct {
+	// Level is the level statements are logged at. Failed statements are
+	// logged at slog.LevelError. The zero value is slog.LevelInfo.
+	Level slog.Level
+	// Redact returns the arguments to log for the statement, which can hide
+	// sensitive values such as the documents being written. If nil, the
+	// arguments are logged as is. Use RedactArgs to omit them.
+	Redact func(s Statement) []any
+}
+
+// RedactArgs is a LogOptions.Redact function that omits all arguments.
+func RedactArgs(Statement) []any { return nil }
+
+// Logged logs the statements run by the table, with their SQL, arguments,
+// duration, and error if they fail. If logger is nil, slog.Default is used at
+// the time each statement is logged, so slog.SetDefault configures the logger
+// for all such tables.
+func Logged(logger *slog.Logger, opts LogOptions) Option {
+	if logger == nil {
+		logger = slog.New(defaultHandler{})
+	}
+	return func(o *options) {
+		o.logger = logger
+		o.logOptions = opts
+	}
+}
+
+// defaultHandler forwards to the handler of slog.Default.
+type defaultHandler struct{}
+
+func (defaultHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return slog.Default().Handler().Enabled(ctx, level)
+}
+
+func (defaultHandler) Handle(ctx context.Context, r slog.Record) error {
+	return slog.Default().Handler().Handle(ctx, r)
+}
+
+func (defaultHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return slog.Default().Handler().WithAttrs(attrs)
+}
+
+func (defaultHandler) WithGroup(name string) slog.Handler {
+	return slog.Default().Handler().WithGroup(name)
+}
+
+func (o *options) logStatement(ctx context.Context, s Statement, r StatementResult) {
+	level := o.logOptions.Level
+	if r.Err != nil {
+		level = slog.LevelError
+	}
+	if !o.logger.Enabled(ctx, level) {
+		return
+	}
+	args := s.Args
+	if o.logOptions.Redact != nil {
+		args = o.logOptions.Redact(s)
+	}
+	// Documents are bound as JSON bytes, which are easier to read as text.
+	args = slices.Clone(args)
+	for i, arg := range args {
+		if b, ok := arg.([]byte); ok {
+			args[i] = string(b)
+		}
+	}
+	attrs := []slog.Attr{
+		slog.String("table", s.Table),
+		slog.String("op", s.Op),
+		slog.String("query", s.Query),
+		slog.Any("args", args),
+		slog.Duration("duration", r.Duration),
+		slog.Int("rows", r.Rows),
+	}
+	if r.Err != nil {
+		attrs = append(attrs, slog.Any("error", r.Err))
+	}
+	o.logger.LogAttrs(ctx, level, "sqjdb: statement", attrs...)
+}
@@ -0,0 +1,54 @@
+package sqjdb_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func newLogged(t *testing.T, opts sqjdb.LogOptions) (sqjdb.Table[Jedi], *bytes.Buffer) {
+	var buf bytes.Buffer
+	handler := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	return sqjdb.NewTable[Jedi]("jedis", sqjdb.Logged(slog.New(handler), opts)), &buf
+}
+
+func TestLogged(t *testing.T) {
+	conn := newConn(t)
+	logged, buf := newLogged(t, sqjdb.LogOptions{})
+	_, err := logged.Insert(conn, &Jedi{ID: "grogu", Name: "grogu"})
+	ensure.Nil(t, err)
+	_, err = logged.Count(conn, sqjdb.Where("Age").Gt(40).SQL())
+	ensure.Nil(t, err)
+	_, err = logged.Insert(conn, &Jedi{ID: "grogu"})
+	ensure.NotNil(t, err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	ensure.DeepEqual(t, len(lines), 3)
+	ensure.StringContains(t, lines[0], `level=INFO msg="sqjdb: statement" table=jedis op=insert`)
+	ensure.StringContains(t, lines[0], `args="[{\"ID\":\"grogu\",\"Name\":\"grogu\"}]" rows=1`)
+	ensure.StringContains(t, lines[1], `op=count query="select count(*) from jedis where `)
+	ensure.StringContains(t, lines[1], `args=[40] rows=1`)
+	ensure.StringContains(t, lines[2], `level=ERROR`)
+	ensure.StringContains(t, lines[2], `error="sqjdb: unique violation on field`)
+}
+
+func TestLoggedRedact(t *testing.T) {
+	conn := newConn(t)
+	logged, buf := newLogged(t, sqjdb.LogOptions{Level: slog.LevelDebug, Redact: sqjdb.RedactArgs})
+	_, err := logged.Insert(conn, &Jedi{Name: "grogu"})
+	ensure.Nil(t, err)
+	ensure.StringContains(t, buf.String(), `level=DEBUG`)
+	ensure.StringContains(t, buf.String(), `args=[] rows=1`)
+	ensure.StringDoesNotContain(t, buf.String(), "grogu")
+}
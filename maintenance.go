@@ -0,0 +1,53 @@
+package sqjdb
+
+import (
+	"fmt"
+	"strconv"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// Optimize runs PRAGMA optimize, which analyzes the tables whose statistics are
+// likely out of date. It is cheap, and SQLite recommends running it when
+// closing long lived connections, or every few hours in long running
+// processes.
+func Optimize(conn *sqlite.Conn) error {
+	return maintain(conn, "optimize", "pragma optimize")
+}
+
+// Analyze gathers statistics about all tables and indexes for the query
+// planner. It reads every index, so prefer Optimize unless the data has changed
+// shape significantly, for example after a bulk import.
+func Analyze(conn *sqlite.Conn) error {
+	return maintain(conn, "analyze", "analyze")
+}
+
+// Vacuum rebuilds the database file, returning free pages to the file system
+// and defragmenting it. It needs up to twice the size of the database in free
+// disk space, and blocks writers for the duration, so run it rarely, such as
+// from a nightly job, and only after deleting a large part of the data. It can
+// not be run within a transaction.
+func Vacuum(conn *sqlite.Conn) error {
+	return maintain(conn, "vacuum", "vacuum")
+}
+
+// IncrementalVacuum frees up to pages unused pages from the end of the database
+// file, or all of them if pages is not positive. Unlike Vacuum it is cheap
+// enough to run periodically or on shutdown, but it only has an effect on
+// databases using Pragma("auto_vacuum", "incremental"). Since Open enables WAL
+// mode first, the setting only takes effect after the next Vacuum.
+func IncrementalVacuum(conn *sqlite.Conn, pages int) error {
+	q := "pragma incremental_vacuum"
+	if pages > 0 {
+		q += "(" + strconv.Itoa(pages) + ")"
+	}
+	return maintain(conn, "incremental vacuum", q)
+}
+
+func maintain(conn *sqlite.Conn, name, query string) error {
+	if err := sqlitex.ExecuteTransient(conn, query, nil); err != nil {
+		return fmt.Errorf("sqjdb: %s: %w", name, err)
+	}
+	return nil
+}
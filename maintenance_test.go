@@ -0,0 +1,46 @@
+package sqjdb_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestMaintenance(t *testing.T) {
+	conn, err := sqjdb.Open(filepath.Join(t.TempDir(), "db"),
+		sqjdb.Pragma("auto_vacuum", "incremental"))
+	ensure.Nil(t, err)
+	defer conn.Close()
+	ensure.Nil(t, jedis.Migrate(conn))
+	ensure.Nil(t, sqjdb.Vacuum(conn))
+	mode, err := sqjdb.QueryScalar[int](conn, "pragma auto_vacuum")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, mode, 2)
+	for range 100 {
+		_, err := jedis.Insert(conn, &Jedi{Name: strings.Repeat("x", 1000)})
+		ensure.Nil(t, err)
+	}
+	ensure.Nil(t, sqjdb.Analyze(conn))
+	stats, err := sqjdb.QueryScalar[int](conn, "select count(*) from sqlite_stat1")
+	ensure.Nil(t, err)
+	ensure.True(t, stats > 0)
+	ensure.Nil(t, sqjdb.Optimize(conn))
+
+	_, err = jedis.Delete(conn)
+	ensure.Nil(t, err)
+	free, err := sqjdb.QueryScalar[int](conn, "pragma freelist_count")
+	ensure.Nil(t, err)
+	ensure.True(t, free > 2)
+	ensure.Nil(t, sqjdb.IncrementalVacuum(conn, 5))
+	left, err := sqjdb.QueryScalar[int](conn, "pragma freelist_count")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, left, free-5)
+	ensure.Nil(t, sqjdb.IncrementalVacuum(conn, 0))
+	left, err = sqjdb.QueryScalar[int](conn, "pragma freelist_count")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, left, 0)
+	ensure.Nil(t, sqjdb.Vacuum(conn))
+}
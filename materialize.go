@@ -0,0 +1,161 @@
+package sqjdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// Materialized is a table holding the aggregates of a table grouped by some
+// fields, like GroupBy, which triggers keep up to date as the documents change.
+// Each write recomputes the aggregates of the groups it affects, so the group
+// fields should be indexed. Scopes such as SoftDelete do not apply, so deleted
+// documents are included.
+type Materialized[R any] struct {
+	Name   string
+	source string
+	fields []string
+	aggs   []Aggregate
+}
+
+// Materialize creates a Materialized table with the given name, grouping the
+// documents in the table on the fields and computing the aggregates. Each group
+// is decoded into R as it is by GroupBy.
+func Materialize[R, T any](t *Table[T], name string, fields []string, aggs ...Aggregate) Materialized[R] {
+	return Materialized[R]{Name: name, source: t.Name, fields: fields, aggs: aggs}
+}
+
+// groupSelect returns the query computing the key and the document for the
+// groups, limited to those of the given row in a trigger if it is not empty.
+func (m *Materialized[R]) groupSelect(source, row string) string {
+	keys := make([]string, len(m.fields))
+	object := make([]string, 0, len(m.fields)+len(m.aggs))
+	conds := make([]string, len(m.fields))
+	for i, field := range m.fields {
+		keys[i] = fieldExpr(field)
+		object = append(object, quoteString(field)+", json("+fieldJSONExpr(field)+")")
+		conds[i] = fieldExpr(field) + " is " + row + "." + fieldExpr(field)
+	}
+	for _, agg := range m.aggs {
+		expr := "*"
+		if agg.Field != "" {
+			expr = fieldExpr(agg.Field)
+		}
+		object = append(object, quoteString(agg.As)+", "+agg.Func+"("+expr+")")
+	}
+	group := strings.Join(keys, ", ")
+	q := "select json_array(" + group + "), jsonb_object(" + strings.Join(object, ", ") +
+		") from " + source
+	if row != "" {
+		q += " where " + strings.Join(conds, " and ")
+	}
+	return q + " group by " + group
+}
+
+// refresh returns the trigger statements recomputing the group of the row.
+func (m *Materialized[R]) refresh(mBase, base, row string) string {
+	keys := make([]string, len(m.fields))
+	for i, field := range m.fields {
+		keys[i] = row + "." + fieldExpr(field)
+	}
+	return " delete from " + mBase + " where key = json_array(" + strings.Join(keys, ", ") + ");" +
+		" insert into " + mBase + " (key, data) " + m.groupSelect(base, row) + ";"
+}
+
+// Migrate creates the table and the triggers maintaining it, and computes the
+// aggregates if the table did not exist.
+func (m *Materialized[R]) Migrate(conn *sqlite.Conn) (err error) {
+	if len(m.fields) == 0 {
+		return fmt.Errorf("sqjdb: no fields to group %q by", m.source)
+	}
+	prefix, base := splitName(m.source)
+	_, mBase := splitName(m.Name)
+	var exists bool
+	err = sqlitex.Execute(conn, "select 1 from "+prefix+"sqlite_schema where name = ?",
+		&sqlitex.ExecOptions{
+			Args: []any{mBase},
+			ResultFunc: func(*sqlite.Stmt) error {
+				exists = true
+				return nil
+			},
+		})
+	if err != nil {
+		return fmt.Errorf("sqjdb: checking for table %q: %w", m.Name, err)
+	}
+	if exists {
+		return nil
+	}
+	qs := []string{
+		"create table " + m.Name + " (key text primary key, data blob) without rowid",
+		"insert into " + m.Name + " (key, data) " + m.groupSelect(m.source, ""),
+		// Triggers can only refer to unqualified tables in their own schema.
+		"create trigger " + m.Name + "_insert after insert on " + base + " begin" +
+			m.refresh(mBase, base, "new") + " end",
+		"create trigger " + m.Name + "_update after update on " + base + " begin" +
+			m.refresh(mBase, base, "old") + m.refresh(mBase, base, "new") + " end",
+		"create trigger " + m.Name + "_delete after delete on " + base + " begin" +
+			m.refresh(mBase, base, "old") + " end",
+	}
+	defer sqlitex.Save(conn)(&err)
+	for _, q := range qs {
+		if err = sqlitex.ExecuteTransient(conn, q, nil); err != nil {
+			return fmt.Errorf("sqjdb: creating materialized table %q: %w", m.Name, err)
+		}
+	}
+	return nil
+}
+
+// Rebuild recomputes the aggregates of all groups from scratch, which is only
+// needed if the documents were changed while the triggers did not exist.
+func (m *Materialized[R]) Rebuild(conn *sqlite.Conn) (err error) {
+	defer sqlitex.Save(conn)(&err)
+	if err = sqlitex.Execute(conn, "delete from "+m.Name, nil); err != nil {
+		return fmt.Errorf("sqjdb: rebuilding %q: %w", m.Name, err)
+	}
+	q := "insert into " + m.Name + " (key, data) " + m.groupSelect(m.source, "")
+	if err = sqlitex.Execute(conn, q, nil); err != nil {
+		return fmt.Errorf("sqjdb: rebuilding %q: %w", m.Name, err)
+	}
+	return nil
+}
+
+// All returns the groups per the given query, ordered by the group fields. The
+// query can refer to the group fields and aggregates by name, for example using
+// Where.
+func (m *Materialized[R]) All(conn *sqlite.Conn, sqls ...SQL) ([]*R, error) {
+	order := make([]string, len(m.fields))
+	for i, field := range m.fields {
+		order[i] = fieldExpr(field)
+	}
+	var query strings.Builder
+	query.WriteString("select json(data) from " + m.Name)
+	sqls = slices.Concat(sqls, []SQL{{Query: "order by " + strings.Join(order, ", ")}})
+	addSQLQuery(&query, sqls)
+	stmt, err := prepareSQL(conn, query.String(), sqls)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseStmt(stmt)
+	var groups []*R
+	var buf []byte
+	for {
+		rowReturned, err := stmt.Step()
+		if err != nil {
+			return nil, err
+		}
+		if !rowReturned {
+			break
+		}
+		jsonS := columnBytes(stmt, 0, &buf)
+		v := new(R)
+		if err := json.Unmarshal(jsonS, v); err != nil {
+			return nil, fmt.Errorf("sqjdb: invalid json from db: %w\n%s", err, jsonS)
+		}
+		groups = append(groups, v)
+	}
+	return groups, nil
+}
@@ -0,0 +1,52 @@
+package sqjdb_test
+
+import (
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+type AgeStats struct {
+	Age    int
+	Count  int
+	Oldest string
+}
+
+func TestMaterialize(t *testing.T) {
+	conn := newConn(t)
+	byAge := sqjdb.Materialize[AgeStats](&jedis, "jedis_by_age", []string{"Age"},
+		sqjdb.CountOf("Count"), sqjdb.MaxOf("Oldest", "Name"))
+	ensure.Nil(t, byAge.Migrate(conn))
+	ensure.Nil(t, byAge.Migrate(conn))
+	stats, err := byAge.All(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, stats, []*AgeStats{
+		{Age: 42, Count: 2, Oldest: "luke"},
+		{Age: 980, Count: 1, Oldest: "yoda"},
+	})
+
+	// Triggers keep the groups up to date.
+	_, err = jedis.Insert(conn, &Jedi{Name: "mace", Age: 42})
+	ensure.Nil(t, err)
+	_, err = jedis.Patch(conn, &Jedi{Age: 19}, sqjdb.ByID(luke.ID))
+	ensure.Nil(t, err)
+	_, err = jedis.Delete(conn, sqjdb.ByID(yoda.ID))
+	ensure.Nil(t, err)
+	expected := []*AgeStats{
+		{Age: 19, Count: 1, Oldest: "luke"},
+		{Age: 42, Count: 2, Oldest: "mace"},
+	}
+	stats, err = byAge.All(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, stats, expected)
+
+	stats, err = byAge.All(conn, sqjdb.Where("Count").Gt(1).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, stats, expected[1:])
+
+	ensure.Nil(t, byAge.Rebuild(conn))
+	stats, err = byAge.All(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, stats, expected)
+}
@@ -0,0 +1,116 @@
+package sqjdb
+
+import (
+	"errors"
+	"expvar"
+	"sync"
+	"time"
+)
+
+// Metrics receives measurements from tables using Metered, and can be
+// implemented to export them to a monitoring system such as Prometheus.
+type Metrics interface {
+	// ObserveStatement is called after each statement run by the table.
+	ObserveStatement(s Statement, r StatementResult)
+	// ObserveBusyRetry is called when a PoolTable using WithRetry retries an
+	// operation because the database is busy or locked.
+	ObserveBusyRetry(table string)
+}
+
+// Metered reports the statements run by the table, and the retries of its
+// PoolTable, to the Metrics. It can be combined with Traced.
+func Metered(m Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+// latencyBuckets are the upper bounds of the ExpvarMetrics latency histogram.
+var latencyBuckets = []time.Duration{
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+}
+
+// ExpvarMetrics is Metrics published with expvar. Statements are counted by
+// "table.op" in the ops, rows and errors maps, and their latency is counted in
+// the buckets of a histogram per "table.op". Busy retries are counted by table.
+// ErrNoDoc is not counted as an error.
+type ExpvarMetrics struct {
+	mu          sync.Mutex // guards creating histograms
+	ops         *expvar.Map
+	rows        *expvar.Map
+	errors      *expvar.Map
+	latency     *expvar.Map
+	busyRetries *expvar.Map
+}
+
+// NewExpvarMetrics publishes the metrics as an expvar.Map with the given name.
+// Like expvar.Publish, it panics if the name is already in use.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	m := &ExpvarMetrics{
+		ops:         new(expvar.Map),
+		rows:        new(expvar.Map),
+		errors:      new(expvar.Map),
+		latency:     new(expvar.Map),
+		busyRetries: new(expvar.Map),
+	}
+	root := expvar.NewMap(name)
+	root.Set("ops", m.ops)
+	root.Set("rows", m.rows)
+	root.Set("errors", m.errors)
+	root.Set("latency", m.latency)
+	root.Set("busy_retries", m.busyRetries)
+	return m
+}
+
+// ObserveStatement implements Metrics.
+func (m *ExpvarMetrics) ObserveStatement(s Statement, r StatementResult) {
+	key := s.Table + "." + s.Op
+	m.ops.Add(key, 1)
+	m.rows.Add(key, int64(r.Rows))
+	if r.Err != nil && !errors.Is(r.Err, ErrNoDoc) {
+		m.errors.Add(key, 1)
+	}
+	bucket := "inf"
+	for _, le := range latencyBuckets {
+		if r.Duration <= le {
+			bucket = "le_" + le.String()
+			break
+		}
+	}
+	m.histogram(key).Add(bucket, 1)
+}
+
+func (m *ExpvarMetrics) histogram(key string) *expvar.Map {
+	if h, ok := m.latency.Get(key).(*expvar.Map); ok {
+		return h
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if h, ok := m.latency.Get(key).(*expvar.Map); ok {
+		return h
+	}
+	h := new(expvar.Map)
+	m.latency.Set(key, h)
+	return h
+}
+
+// ObserveBusyRetry implements Metrics.
+func (m *ExpvarMetrics) ObserveBusyRetry(table string) {
+	m.busyRetries.Add(table, 1)
+}
+
+// observeRetries wraps fn to report the calls after the first as busy retries.
+func observeRetries(m Metrics, table string, fn func() error) func() error {
+	attempt := 0
+	return func() error {
+		attempt++
+		if attempt > 1 {
+			m.ObserveBusyRetry(table)
+		}
+		return fn()
+	}
+}
@@ -0,0 +1,78 @@
+package sqjdb_test
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+func TestExpvarMetrics(t *testing.T) {
+	pjedis := newPool(t)
+	metered := sqjdb.NewTable[Jedi]("jedis", sqjdb.Metered(sqjdb.NewExpvarMetrics(t.Name())))
+	pmetered := metered.WithPool(pjedis.Pool)
+	ctx := context.Background()
+	_, err := pmetered.All(ctx)
+	ensure.Nil(t, err)
+	_, err = pmetered.One(ctx, sqjdb.ByID("missing"))
+	ensure.True(t, err != nil)
+	_, err = pmetered.Insert(ctx, &Jedi{ID: yoda.ID})
+	ensure.True(t, err != nil)
+
+	var published struct {
+		Ops     map[string]int
+		Rows    map[string]int
+		Errors  map[string]int
+		Latency map[string]map[string]int
+	}
+	ensure.Nil(t, json.Unmarshal([]byte(expvar.Get(t.Name()).String()), &published))
+	ensure.DeepEqual(t, published.Ops, map[string]int{"jedis.all": 1, "jedis.one": 1, "jedis.insert": 1})
+	ensure.DeepEqual(t, published.Rows, map[string]int{"jedis.all": 3, "jedis.one": 0, "jedis.insert": 0})
+	ensure.DeepEqual(t, published.Errors, map[string]int{"jedis.insert": 1})
+	total := 0
+	for _, count := range published.Latency["jedis.all"] {
+		total += count
+	}
+	ensure.DeepEqual(t, total, 1)
+}
+
+type busyRetries map[string]int
+
+func (busyRetries) ObserveStatement(sqjdb.Statement, sqjdb.StatementResult) {}
+
+func (b busyRetries) ObserveBusyRetry(table string) { b[table]++ }
+
+func TestMetricsBusyRetries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	pool, err := sqlitex.NewPool(path, sqlitex.PoolOptions{PoolSize: 1})
+	ensure.Nil(t, err)
+	t.Cleanup(func() { pool.Close() })
+	other, err := sqlite.OpenConn(path)
+	ensure.Nil(t, err)
+	t.Cleanup(func() { other.Close() })
+	ensure.Nil(t, jedis.Migrate(other))
+	ensure.Nil(t, sqlitex.Execute(other, "begin immediate", nil))
+
+	retries := busyRetries{}
+	metered := sqjdb.NewTable[Jedi]("jedis", sqjdb.Metered(retries))
+	pmetered := metered.WithPool(pool).WithRetry(sqjdb.RetryPolicy{Attempts: 5, Backoff: time.Millisecond})
+	attempts := 0
+	err = pmetered.Do(context.Background(), func(conn *sqlite.Conn) error {
+		conn.SetBusyTimeout(0)
+		attempts++
+		if attempts == 3 {
+			ensure.Nil(t, sqlitex.Execute(other, "commit", nil))
+		}
+		_, err := metered.Insert(conn, &Jedi{Name: "din"})
+		return err
+	})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, retries, busyRetries{"jedis": 2})
+}
@@ -0,0 +1,191 @@
+package sqjdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// ErrUnknownType is returned by MixedTable for documents whose type is not
+// registered.
+var ErrUnknownType = errors.New("sqjdb: unknown document type")
+
+// MixedTable stores documents of several Go types in one table, told apart by
+// a string discriminator field. Register each type with RegisterType, after
+// which documents are read back as their concrete type behind the common
+// interface I. The registered types should share the ID field.
+type MixedTable[I any] struct {
+	Name    string
+	field   string
+	opts    []Option
+	base    Table[json.RawMessage]
+	decode  map[string]func([]byte) (I, error)
+	writers map[reflect.Type]mixedWriter[I]
+}
+
+type mixedWriter[I any] struct {
+	insert func(*sqlite.Conn, I) (I, error)
+	upsert func(*sqlite.Conn, I) (I, error)
+}
+
+// NewMixedTable creates a MixedTable storing the discriminator in the named
+// field, which is indexed. The options apply to the tables of every registered
+// type.
+func NewMixedTable[I any](name, field string, opts ...Option) *MixedTable[I] {
+	opts = append(opts, Indexes(IndexSpec{Fields: []string{field}}))
+	return &MixedTable[I]{
+		Name:    name,
+		field:   field,
+		opts:    opts,
+		base:    NewTable[json.RawMessage](name, opts...),
+		decode:  map[string]func([]byte) (I, error){},
+		writers: map[reflect.Type]mixedWriter[I]{},
+	}
+}
+
+// RegisterType registers documents of type *T under the discriminator kind,
+// and returns a Table restricted to them. Documents written through the
+// returned table have the discriminator set, so T need not have the field. It
+// panics if *T does not implement I, or if the kind is already registered.
+func RegisterType[T, I any](m *MixedTable[I], kind string) *Table[T] {
+	if _, ok := any(new(T)).(I); !ok {
+		panic(fmt.Sprintf("sqjdb: %T does not implement %v", new(T), reflect.TypeFor[I]()))
+	}
+	if _, ok := m.decode[kind]; ok {
+		panic(fmt.Sprintf("sqjdb: type %q already registered in %q", kind, m.Name))
+	}
+	t := NewTable[T](m.Name, append(m.opts, func(o *options) {
+		o.kindField = m.field
+		o.kind = kind
+	})...)
+	m.decode[kind] = func(jsonS []byte) (I, error) {
+		v := new(T)
+		if _, err := t.decode(jsonS, v); err != nil {
+			var zero I
+			return zero, err
+		}
+		return any(v).(I), nil
+	}
+	m.writers[reflect.TypeFor[*T]()] = mixedWriter[I]{
+		insert: func(conn *sqlite.Conn, doc I) (I, error) {
+			v, err := t.Insert(conn, any(doc).(*T))
+			if err != nil {
+				var zero I
+				return zero, err
+			}
+			return any(v).(I), nil
+		},
+		upsert: func(conn *sqlite.Conn, doc I) (I, error) {
+			v, err := t.Upsert(conn, any(doc).(*T))
+			if err != nil {
+				var zero I
+				return zero, err
+			}
+			return any(v).(I), nil
+		},
+	}
+	return &t
+}
+
+// Migrate creates the table and its indexes.
+func (m *MixedTable[I]) Migrate(conn *sqlite.Conn) error {
+	return m.base.Migrate(conn)
+}
+
+func (m *MixedTable[I]) writer(doc I) (mixedWriter[I], error) {
+	w, ok := m.writers[reflect.TypeOf(doc)]
+	if !ok {
+		return w, fmt.Errorf("%w: %T in %q", ErrUnknownType, doc, m.Name)
+	}
+	return w, nil
+}
+
+// Insert inserts the document, which must be a pointer to a registered type.
+func (m *MixedTable[I]) Insert(conn *sqlite.Conn, doc I) (I, error) {
+	w, err := m.writer(doc)
+	if err != nil {
+		var zero I
+		return zero, err
+	}
+	return w.insert(conn, doc)
+}
+
+// Upsert inserts or replaces the document, which must be a pointer to a
+// registered type. Documents of another type with the same ID are not
+// replaced.
+func (m *MixedTable[I]) Upsert(conn *sqlite.Conn, doc I) (I, error) {
+	w, err := m.writer(doc)
+	if err != nil {
+		var zero I
+		return zero, err
+	}
+	return w.upsert(conn, doc)
+}
+
+// decodeDoc decodes the document into the type registered for its
+// discriminator.
+func (m *MixedTable[I]) decodeDoc(jsonS []byte) (I, error) {
+	var zero I
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(jsonS, &fields); err != nil {
+		return zero, fmt.Errorf("sqjdb: invalid json from db: %w\n%s", err, jsonS)
+	}
+	var kind string
+	if raw, ok := fields[m.field]; ok {
+		if err := json.Unmarshal(raw, &kind); err != nil {
+			return zero, fmt.Errorf("sqjdb: invalid %s in %q: %w", m.field, m.Name, err)
+		}
+	}
+	decode, ok := m.decode[kind]
+	if !ok {
+		return zero, fmt.Errorf("%w: %q in %q", ErrUnknownType, kind, m.Name)
+	}
+	return decode(jsonS)
+}
+
+// One returns a single document per the given query. It returns the error
+// ErrNoDoc if no document is found.
+func (m *MixedTable[I]) One(conn *sqlite.Conn, sqls ...SQL) (I, error) {
+	raw, err := m.base.One(conn, sqls...)
+	if err != nil {
+		var zero I
+		return zero, err
+	}
+	return m.decodeDoc(*raw)
+}
+
+// Get returns the document with the given ID. It returns the error ErrNoDoc if
+// no document is found.
+func (m *MixedTable[I]) Get(conn *sqlite.Conn, id any) (I, error) {
+	return m.One(conn, m.base.ByID(id))
+}
+
+// All returns the documents per the given query, of any registered type.
+func (m *MixedTable[I]) All(conn *sqlite.Conn, sqls ...SQL) ([]I, error) {
+	raws, err := m.base.All(conn, sqls...)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]I, 0, len(raws))
+	for _, raw := range raws {
+		doc, err := m.decodeDoc(*raw)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// Count returns the number of documents per the given query.
+func (m *MixedTable[I]) Count(conn *sqlite.Conn, sqls ...SQL) (int, error) {
+	return m.base.Count(conn, sqls...)
+}
+
+// Delete deletes the documents per the given query, of any type.
+func (m *MixedTable[I]) Delete(conn *sqlite.Conn, sqls ...SQL) (int, error) {
+	return m.base.Delete(conn, sqls...)
+}
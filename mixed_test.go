@@ -0,0 +1,91 @@
+package sqjdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+type Vehicle interface {
+	Wheels() int
+}
+
+type Car struct {
+	ID    string
+	Model string
+}
+
+func (*Car) Wheels() int { return 4 }
+
+type Bike struct {
+	ID    string
+	Gears int
+}
+
+func (*Bike) Wheels() int { return 2 }
+
+func TestMixedTable(t *testing.T) {
+	conn := newConn(t)
+	vehicles := sqjdb.NewMixedTable[Vehicle](t.Name(), "Type")
+	cars := sqjdb.RegisterType[Car](vehicles, "car")
+	bikes := sqjdb.RegisterType[Bike](vehicles, "bike")
+	ensure.Nil(t, vehicles.Migrate(conn))
+
+	car, err := vehicles.Insert(conn, &Car{Model: "t"})
+	ensure.Nil(t, err)
+	_, err = bikes.Insert(conn, &Bike{Gears: 21})
+	ensure.Nil(t, err)
+
+	all, err := vehicles.All(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(all), 2)
+	wheels := 0
+	for _, v := range all {
+		wheels += v.Wheels()
+	}
+	ensure.DeepEqual(t, wheels, 6)
+
+	got, err := vehicles.Get(conn, car.(*Car).ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, got, car)
+
+	onlyCars, err := cars.All(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(onlyCars), 1)
+	_, err = cars.Get(conn, car.(*Car).ID)
+	ensure.Nil(t, err)
+	_, err = bikes.Get(conn, car.(*Car).ID)
+	ensure.True(t, errors.Is(err, sqjdb.ErrNoDoc))
+
+	n, err := vehicles.Count(conn, sqjdb.Where("Type").Eq("bike").SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+
+	_, err = bikes.Upsert(conn, &Bike{ID: car.(*Car).ID})
+	var uve *sqjdb.UniqueViolationError
+	ensure.True(t, errors.As(err, &uve))
+}
+
+type Truck struct {
+	ID string
+}
+
+func (*Truck) Wheels() int { return 18 }
+
+func TestMixedTableUnknownType(t *testing.T) {
+	conn := newConn(t)
+	vehicles := sqjdb.NewMixedTable[Vehicle](t.Name(), "Type")
+	sqjdb.RegisterType[Car](vehicles, "car")
+	ensure.Nil(t, vehicles.Migrate(conn))
+	_, err := vehicles.Insert(conn, &Truck{})
+	ensure.True(t, errors.Is(err, sqjdb.ErrUnknownType))
+
+	other := sqjdb.NewMixedTable[Vehicle](t.Name(), "Type")
+	sqjdb.RegisterType[Truck](other, "truck")
+	_, err = other.Insert(conn, &Truck{})
+	ensure.Nil(t, err)
+	_, err = vehicles.All(conn)
+	ensure.True(t, errors.Is(err, sqjdb.ErrUnknownType))
+}
@@ -0,0 +1,348 @@
+package sqjdb
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"zombiezen.com/go/sqlite"
+)
+
+// ImportMongo imports documents from a mongoexport JSON dump, either one
+// document per line or a JSON array, or from a mongodump BSON file, and returns
+// the number of documents imported or changed, like ImportJSONL. The format is
+// detected from the first byte.
+//
+// The _id field is mapped to the ID field of the table. Extended JSON and BSON
+// values are converted to plain JSON: object IDs and UUIDs become strings,
+// dates become RFC 3339 strings, binary data becomes base64 strings, and
+// numbers become JSON numbers. Other BSON types, such as regular expressions
+// and decimals, are not supported.
+func (t *Table[T]) ImportMongo(conn *sqlite.Conn, r io.Reader, opts ImportOptions) (int, error) {
+	br := bufio.NewReader(r)
+	var first byte
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return 0, nil
+			}
+			return 0, fmt.Errorf("sqjdb: importing %q: %w", t.Name, err)
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			first = b
+			break
+		}
+	}
+	if err := br.UnreadByte(); err != nil {
+		return 0, fmt.Errorf("sqjdb: importing %q: %w", t.Name, err)
+	}
+	var next func() (any, error)
+	switch first {
+	case '{', '[':
+		dec := json.NewDecoder(br)
+		dec.UseNumber()
+		if first == '[' {
+			if _, err := dec.Token(); err != nil {
+				return 0, fmt.Errorf("sqjdb: importing %q: %w", t.Name, err)
+			}
+		}
+		next = func() (any, error) {
+			if first == '[' && !dec.More() {
+				return nil, io.EOF
+			}
+			var v any
+			if err := dec.Decode(&v); err != nil {
+				return nil, err
+			}
+			return fromExtendedJSON(v)
+		}
+	default:
+		next = func() (any, error) {
+			return readBSON(br)
+		}
+	}
+	return t.importDocs(conn, func() (json.RawMessage, error) {
+		v, err := next()
+		if err != nil {
+			return nil, err
+		}
+		doc, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("document is a %T, not an object", v)
+		}
+		if id, ok := doc["_id"]; ok {
+			delete(doc, "_id")
+			doc[t.opts.idKey] = id
+		}
+		return json.Marshal(doc)
+	}, opts)
+}
+
+// fromExtendedJSON converts the MongoDB Extended JSON wrappers in v, in either
+// the canonical or relaxed format, to plain values.
+func fromExtendedJSON(v any) (any, error) {
+	switch v := v.(type) {
+	case []any:
+		for i, e := range v {
+			c, err := fromExtendedJSON(e)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = c
+		}
+		return v, nil
+	case map[string]any:
+		if len(v) == 1 || len(v) == 2 {
+			if c, ok, err := fromExtendedWrapper(v); ok || err != nil {
+				return c, err
+			}
+		}
+		for k, e := range v {
+			c, err := fromExtendedJSON(e)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = c
+		}
+		return v, nil
+	}
+	return v, nil
+}
+
+// fromExtendedWrapper converts a single Extended JSON wrapper such as
+// {"$oid": "..."}. It returns false if m is not a known wrapper.
+func fromExtendedWrapper(m map[string]any) (any, bool, error) {
+	if len(m) == 2 {
+		if _, ok := m["$type"]; ok {
+			if _, ok := m["$binary"]; ok {
+				return extendedBinary(m)
+			}
+		}
+		return nil, false, nil
+	}
+	for k, e := range m {
+		switch k {
+		case "$oid", "$uuid", "$symbol":
+			return e, true, nil
+		case "$numberInt", "$numberLong", "$numberDouble", "$numberDecimal":
+			s, _ := e.(string)
+			if _, err := strconv.ParseFloat(s, 64); err != nil {
+				// Infinity and NaN can not be represented in JSON.
+				return nil, true, fmt.Errorf("unsupported %s value %q", k, s)
+			}
+			return json.Number(s), true, nil
+		case "$date":
+			return extendedDate(e)
+		case "$binary":
+			return extendedBinary(m)
+		}
+	}
+	return nil, false, nil
+}
+
+func extendedDate(e any) (any, bool, error) {
+	switch e := e.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, e)
+		if err != nil {
+			return nil, true, fmt.Errorf("invalid $date: %w", err)
+		}
+		return t.UTC(), true, nil
+	case json.Number:
+		ms, err := e.Int64()
+		if err != nil {
+			return nil, true, fmt.Errorf("invalid $date: %w", err)
+		}
+		return time.UnixMilli(ms).UTC(), true, nil
+	case map[string]any:
+		s, _ := e["$numberLong"].(string)
+		ms, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, true, fmt.Errorf("invalid $date: %w", err)
+		}
+		return time.UnixMilli(ms).UTC(), true, nil
+	}
+	return nil, true, fmt.Errorf("invalid $date %v", e)
+}
+
+func extendedBinary(m map[string]any) (any, bool, error) {
+	// The canonical format nests the fields, while the legacy format uses a
+	// sibling $type field.
+	var data, subType string
+	switch b := m["$binary"].(type) {
+	case map[string]any:
+		data, _ = b["base64"].(string)
+		subType, _ = b["subType"].(string)
+	case string:
+		data = b
+		subType, _ = m["$type"].(string)
+	}
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid $binary: %w", err)
+	}
+	st, err := strconv.ParseUint(subType, 16, 8)
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid $binary subType %q", subType)
+	}
+	return bsonBinary(byte(st), raw), true, nil
+}
+
+// bsonBinary converts binary data with the given subtype, returning UUIDs as
+// strings and other data as bytes, which encode to base64 strings.
+func bsonBinary(subType byte, data []byte) any {
+	if (subType == 3 || subType == 4) && len(data) == 16 {
+		return uuid.UUID(data).String()
+	}
+	return data
+}
+
+// readBSON reads the next BSON document from r, returning io.EOF if there are
+// no more.
+func readBSON(r io.Reader) (any, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.LittleEndian.Uint32(size[:])
+	if n < 5 || n > 16<<20 {
+		return nil, fmt.Errorf("invalid BSON document size %d", n)
+	}
+	buf := make([]byte, n)
+	copy(buf, size[:])
+	if _, err := io.ReadFull(r, buf[4:]); err != nil {
+		return nil, fmt.Errorf("reading BSON document: %w", io.ErrUnexpectedEOF)
+	}
+	doc, _, err := parseBSON(buf, false)
+	return doc, err
+}
+
+var errBSONTruncated = errors.New("truncated BSON document")
+
+// parseBSON parses the BSON document at the start of b, returning it and its
+// size. Arrays are BSON documents keyed by index.
+func parseBSON(b []byte, array bool) (any, int, error) {
+	if len(b) < 5 {
+		return nil, 0, errBSONTruncated
+	}
+	n := int(binary.LittleEndian.Uint32(b))
+	if n < 5 || n > len(b) || b[n-1] != 0 {
+		return nil, 0, errBSONTruncated
+	}
+	doc := map[string]any{}
+	var elems []any
+	body := b[4 : n-1]
+	for len(body) > 0 {
+		typ := body[0]
+		end := 1
+		for end < len(body) && body[end] != 0 {
+			end++
+		}
+		if end == len(body) {
+			return nil, 0, errBSONTruncated
+		}
+		key := string(body[1:end])
+		v, size, err := parseBSONValue(typ, body[end+1:])
+		if err != nil {
+			return nil, 0, fmt.Errorf("field %q: %w", key, err)
+		}
+		if array {
+			elems = append(elems, v)
+		} else {
+			doc[key] = v
+		}
+		body = body[end+1+size:]
+	}
+	if array {
+		if elems == nil {
+			elems = []any{}
+		}
+		return elems, n, nil
+	}
+	return doc, n, nil
+}
+
+// parseBSONValue parses the value of the given BSON type at the start of b,
+// returning it and its size.
+func parseBSONValue(typ byte, b []byte) (any, int, error) {
+	fixed := func(n int) error {
+		if len(b) < n {
+			return errBSONTruncated
+		}
+		return nil
+	}
+	switch typ {
+	case 0x01: // double
+		if err := fixed(8); err != nil {
+			return nil, 0, err
+		}
+		f := math.Float64frombits(binary.LittleEndian.Uint64(b))
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, 0, fmt.Errorf("unsupported double value %v", f)
+		}
+		return f, 8, nil
+	case 0x02, 0x0D, 0x0E: // string, JavaScript code, symbol
+		if err := fixed(4); err != nil {
+			return nil, 0, err
+		}
+		n := int(binary.LittleEndian.Uint32(b))
+		if n < 1 || 4+n > len(b) {
+			return nil, 0, errBSONTruncated
+		}
+		return string(b[4 : 4+n-1]), 4 + n, nil
+	case 0x03, 0x04: // document, array
+		return parseBSON(b, typ == 0x04)
+	case 0x05: // binary
+		if err := fixed(5); err != nil {
+			return nil, 0, err
+		}
+		n := int(binary.LittleEndian.Uint32(b))
+		if n < 0 || 5+n > len(b) {
+			return nil, 0, errBSONTruncated
+		}
+		data := b[5 : 5+n]
+		if b[4] == 2 && n >= 4 {
+			// The old binary subtype repeats the length.
+			data = data[4:]
+		}
+		return bsonBinary(b[4], data), 5 + n, nil
+	case 0x06, 0x0A: // undefined, null
+		return nil, 0, nil
+	case 0x07: // ObjectId
+		if err := fixed(12); err != nil {
+			return nil, 0, err
+		}
+		return hex.EncodeToString(b[:12]), 12, nil
+	case 0x08: // boolean
+		if err := fixed(1); err != nil {
+			return nil, 0, err
+		}
+		return b[0] != 0, 1, nil
+	case 0x09: // UTC datetime
+		if err := fixed(8); err != nil {
+			return nil, 0, err
+		}
+		return time.UnixMilli(int64(binary.LittleEndian.Uint64(b))).UTC(), 8, nil
+	case 0x10: // int32
+		if err := fixed(4); err != nil {
+			return nil, 0, err
+		}
+		return int32(binary.LittleEndian.Uint32(b)), 4, nil
+	case 0x12: // int64
+		if err := fixed(8); err != nil {
+			return nil, 0, err
+		}
+		return int64(binary.LittleEndian.Uint64(b)), 8, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported BSON type 0x%02x", typ)
+}
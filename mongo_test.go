@@ -0,0 +1,123 @@
+package sqjdb_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+)
+
+type Starship struct {
+	ID       string `json:",omitempty"`
+	Name     string
+	Built    time.Time
+	Crew     int64
+	Weapons  []string
+	Registry string
+}
+
+func newStarships(t *testing.T) (*sqlite.Conn, *sqjdb.Table[Starship]) {
+	conn := newConn(t)
+	starships := sqjdb.NewTable[Starship](t.Name())
+	ensure.Nil(t, starships.Migrate(conn))
+	return conn, &starships
+}
+
+func allStarships(t *testing.T, conn *sqlite.Conn, starships *sqjdb.Table[Starship]) []*Starship {
+	docs, err := starships.All(conn, sqjdb.OrderBy("Name", sqjdb.Asc))
+	ensure.Nil(t, err)
+	return docs
+}
+
+var mongoStarships = []*Starship{
+	{
+		ID:       "5f1d7a3b9c1e4a2b3c4d5e6f",
+		Name:     "falcon",
+		Built:    time.Date(1977, 5, 25, 0, 0, 0, 0, time.UTC),
+		Crew:     4,
+		Weapons:  []string{"laser"},
+		Registry: "b5f1bdc2-8a9e-4c7f-9d3e-2a1b0c9d8e7f",
+	},
+	{
+		ID:      "5f1d7a3b9c1e4a2b3c4d5e70",
+		Name:    "tie",
+		Built:   time.Date(1977, 5, 25, 0, 0, 0, 0, time.UTC),
+		Crew:    1,
+		Weapons: []string{},
+	},
+}
+
+func TestImportMongoJSON(t *testing.T) {
+	conn, starships := newStarships(t)
+	input := `{"_id":{"$oid":"5f1d7a3b9c1e4a2b3c4d5e6f"},"Name":"falcon","Built":{"$date":"1977-05-25T00:00:00.000Z"},"Crew":{"$numberLong":"4"},"Weapons":["laser"],"Registry":{"$binary":{"base64":"tfG9woqeTH+dPiobDJ2Ofw==","subType":"04"}}}
+{"_id":{"$oid":"5f1d7a3b9c1e4a2b3c4d5e70"},"Name":"tie","Built":{"$date":{"$numberLong":"233366400000"}},"Crew":{"$numberInt":"1"},"Weapons":[]}
+`
+	n, err := starships.ImportMongo(conn, strings.NewReader(input), sqjdb.ImportOptions{})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 2)
+	ensure.DeepEqual(t, allStarships(t, conn, starships), mongoStarships)
+}
+
+func TestImportMongoJSONArray(t *testing.T) {
+	conn, starships := newStarships(t)
+	input := `[{"_id":{"$oid":"5f1d7a3b9c1e4a2b3c4d5e70"},"Name":"tie","Built":{"$date":"1977-05-25T00:00:00Z"},"Crew":1,"Weapons":[]}]`
+	n, err := starships.ImportMongo(conn, strings.NewReader(input), sqjdb.ImportOptions{})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+	ensure.DeepEqual(t, allStarships(t, conn, starships), mongoStarships[1:])
+}
+
+// bsonDoc encodes a BSON document from type, key and value bytes.
+func bsonDoc(elems ...[]byte) []byte {
+	body := bytes.Join(elems, nil)
+	doc := binary.LittleEndian.AppendUint32(nil, uint32(len(body)+5))
+	return append(append(doc, body...), 0)
+}
+
+func bsonElem(typ byte, key string, value []byte) []byte {
+	return append(append([]byte{typ}, key+"\x00"...), value...)
+}
+
+func bsonString(s string) []byte {
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(len(s)+1)), s+"\x00"...)
+}
+
+func TestImportMongoBSON(t *testing.T) {
+	conn, starships := newStarships(t)
+	built := binary.LittleEndian.AppendUint64(nil, uint64(mongoStarships[0].Built.UnixMilli()))
+	registry := append(binary.LittleEndian.AppendUint32(nil, 16), 4)
+	registry = append(registry, 0xb5, 0xf1, 0xbd, 0xc2, 0x8a, 0x9e, 0x4c, 0x7f,
+		0x9d, 0x3e, 0x2a, 0x1b, 0x0c, 0x9d, 0x8e, 0x7f)
+	var input []byte
+	input = append(input, bsonDoc(
+		bsonElem(0x07, "_id", []byte{0x5f, 0x1d, 0x7a, 0x3b, 0x9c, 0x1e, 0x4a, 0x2b, 0x3c, 0x4d, 0x5e, 0x6f}),
+		bsonElem(0x02, "Name", bsonString("falcon")),
+		bsonElem(0x09, "Built", built),
+		bsonElem(0x12, "Crew", binary.LittleEndian.AppendUint64(nil, 4)),
+		bsonElem(0x04, "Weapons", bsonDoc(bsonElem(0x02, "0", bsonString("laser")))),
+		bsonElem(0x05, "Registry", registry),
+	)...)
+	input = append(input, bsonDoc(
+		bsonElem(0x07, "_id", []byte{0x5f, 0x1d, 0x7a, 0x3b, 0x9c, 0x1e, 0x4a, 0x2b, 0x3c, 0x4d, 0x5e, 0x70}),
+		bsonElem(0x02, "Name", bsonString("tie")),
+		bsonElem(0x09, "Built", built),
+		bsonElem(0x10, "Crew", binary.LittleEndian.AppendUint32(nil, 1)),
+		bsonElem(0x04, "Weapons", bsonDoc()),
+	)...)
+	n, err := starships.ImportMongo(conn, bytes.NewReader(input), sqjdb.ImportOptions{})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 2)
+	ensure.DeepEqual(t, allStarships(t, conn, starships), mongoStarships)
+}
+
+func TestImportMongoBSONUnsupported(t *testing.T) {
+	conn, starships := newStarships(t)
+	input := bsonDoc(bsonElem(0x13, "Price", make([]byte, 16)))
+	_, err := starships.ImportMongo(conn, bytes.NewReader(input), sqjdb.ImportOptions{})
+	ensure.StringContains(t, err.Error(), "unsupported BSON type 0x13")
+}
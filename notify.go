@@ -0,0 +1,170 @@
+package sqjdb
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// Notifier dispatches the writes made to a table by this process to
+// subscribers, without polling. Writes made within a transaction are
+// dispatched once it commits, and dropped if it rolls back. Writes within
+// transactions not made with WithTx or Savepoint are only dispatched with the
+// next statement run on the connection, or when it is closed with Close. Use
+// Table.Notifier to get one, or TrackChanges and Watch to see writes from
+// other processes.
+type Notifier[T any] struct {
+	mu   sync.Mutex
+	next int
+	subs []subscriber[T]
+}
+
+type subscriber[T any] struct {
+	id int
+	fn func(HookEvent[T])
+}
+
+// Notifier returns the Notifier for the table, which dispatches all writes
+// made through the table, with the events after hooks would see. Writes that
+// do not run hooks, such as PatchFields and InsertRaw, are dispatched as patches
+// and inserts. It should be called before the table is used.
+func (t *Table[T]) Notifier() *Notifier[T] {
+	if t.hooks == nil {
+		t.hooks = &hooks[T]{}
+	}
+	if t.hooks.notifier == nil {
+		t.hooks.notifier = &Notifier[T]{}
+	}
+	return t.hooks.notifier
+}
+
+// Subscribe calls fn with every write until the returned function is called.
+// It is called synchronously on the goroutine that made the write, after it is
+// committed, so it should return quickly.
+func (n *Notifier[T]) Subscribe(fn func(HookEvent[T])) (cancel func()) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	id := n.next
+	n.next++
+	n.subs = append(n.subs, subscriber[T]{id: id, fn: fn})
+	return sync.OnceFunc(func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		n.subs = slices.DeleteFunc(n.subs, func(s subscriber[T]) bool { return s.id == id })
+	})
+}
+
+// Chan returns a channel receiving every write until the returned function is
+// called. Writers block while the channel is full, so it should be drained
+// promptly, or buffered with the given size. The channel is not closed.
+func (n *Notifier[T]) Chan(size int) (<-chan HookEvent[T], func()) {
+	ch := make(chan HookEvent[T], size)
+	done := make(chan struct{})
+	unsubscribe := n.Subscribe(func(e HookEvent[T]) {
+		select {
+		case ch <- e:
+		case <-done:
+		}
+	})
+	return ch, sync.OnceFunc(func() {
+		unsubscribe()
+		close(done)
+	})
+}
+
+func (n *Notifier[T]) dispatch(e HookEvent[T]) {
+	n.mu.Lock()
+	subs := slices.Clone(n.subs)
+	n.mu.Unlock()
+	for _, s := range subs {
+		s.fn(e)
+	}
+}
+
+// notify dispatches the event to the Notifier of the table once the write
+// commits, if there is one and the write changed any documents.
+func (t *Table[T]) notify(conn *sqlite.Conn, e HookEvent[T]) error {
+	if t.hooks == nil || t.hooks.notifier == nil || e.N == 0 {
+		return nil
+	}
+	n := t.hooks.notifier
+	return notify(conn, func() { n.dispatch(e) })
+}
+
+// pendingNotifications holds the notifications for writes made within the
+// current transaction of each connection, indexed by their row in the
+// notificationsTable. The entry of a connection is removed once its
+// transaction ends and the notifications are flushed, which happens at the
+// latest with the next statement or with Close.
+var pendingNotifications sync.Map // map[*sqlite.Conn]*[]func()
+
+// notificationsTable has a row for each pending notification. Since it is
+// written in the same transaction as the documents, rolling back a transaction
+// or savepoint also removes the rows of the notifications made within it, even
+// if it was not started by WithTx or Savepoint.
+const notificationsTable = "temp.sqjdb_notifications"
+
+// notify dispatches the notification now if the connection is not within a
+// transaction, or once the transaction commits otherwise.
+func notify(conn *sqlite.Conn, fn func()) error {
+	if conn.AutocommitEnabled() {
+		flushNotifications(conn)
+		fn()
+		return nil
+	}
+	pending, _ := pendingNotifications.LoadOrStore(conn, &[]func(){})
+	p := pending.(*[]func())
+	err := sqlitex.Execute(conn,
+		"create table if not exists "+notificationsTable+" (seq integer primary key)", nil)
+	if err == nil {
+		err = sqlitex.Execute(conn, "insert into "+notificationsTable+" (seq) values (?)",
+			&sqlitex.ExecOptions{Args: []any{len(*p)}})
+	}
+	if err != nil {
+		return fmt.Errorf("sqjdb: recording notification: %w", err)
+	}
+	*p = append(*p, fn)
+	return nil
+}
+
+// settleNotifications must be deferred before starting a transaction or
+// savepoint. Once the outermost transaction ends, it dispatches the pending
+// notifications that were not rolled back.
+func settleNotifications(conn *sqlite.Conn) {
+	if conn.AutocommitEnabled() {
+		flushNotifications(conn)
+	}
+}
+
+// flushNotifications dispatches the pending notifications whose rows survived
+// the transaction, and drops the rest. It must be called outside of a
+// transaction. Notifications are dropped if their rows can not be read.
+func flushNotifications(conn *sqlite.Conn) {
+	pending, ok := pendingNotifications.LoadAndDelete(conn)
+	if !ok {
+		return
+	}
+	fns := *pending.(*[]func())
+	var committed []func()
+	err := sqlitex.Execute(conn, "select seq from "+notificationsTable+" order by seq",
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				if seq := stmt.ColumnInt(0); seq < len(fns) {
+					committed = append(committed, fns[seq])
+				}
+				return nil
+			},
+		})
+	if err != nil {
+		return
+	}
+	if err := sqlitex.Execute(conn, "delete from "+notificationsTable, nil); err != nil {
+		return
+	}
+	for _, fn := range committed {
+		fn()
+	}
+}
@@ -0,0 +1,187 @@
+package sqjdb_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+func TestNotifier(t *testing.T) {
+	conn := newConn(t)
+	notified := sqjdb.NewTable[Jedi](t.Name())
+	ensure.Nil(t, notified.Migrate(conn))
+	var events []sqjdb.HookEvent[Jedi]
+	cancel := notified.Notifier().Subscribe(func(e sqjdb.HookEvent[Jedi]) {
+		events = append(events, e)
+	})
+
+	doc, err := notified.Insert(conn, &Jedi{Name: "anakin"})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(events), 1)
+	ensure.DeepEqual(t, events[0].Op, sqjdb.HookInsert)
+	ensure.DeepEqual(t, events[0].Doc, doc)
+
+	// Writes within a transaction are dispatched once it commits.
+	err = sqjdb.WithTx(conn, func(conn *sqlite.Conn) error {
+		_, err := notified.Patch(conn, &Jedi{Name: "vader"}, sqjdb.ByID(doc.ID))
+		ensure.Nil(t, err)
+		err = sqjdb.Savepoint(conn, func(conn *sqlite.Conn) error {
+			_, err := notified.Insert(conn, &Jedi{Name: "rolled back"})
+			ensure.Nil(t, err)
+			return errors.New("rollback")
+		})
+		ensure.NotNil(t, err)
+		_, err = notified.InsertMany(conn, []*Jedi{{Name: "obiwan"}})
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, len(events), 1)
+		return nil
+	})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(events), 3)
+	ensure.DeepEqual(t, events[1].Op, sqjdb.HookPatch)
+	ensure.DeepEqual(t, events[1].N, 1)
+	ensure.DeepEqual(t, events[2].Doc.Name, "obiwan")
+
+	// Writes in transactions that roll back are dropped.
+	err = sqjdb.WithTx(conn, func(conn *sqlite.Conn) error {
+		_, err := notified.Delete(conn, sqjdb.ByID(doc.ID))
+		ensure.Nil(t, err)
+		return errors.New("rollback")
+	})
+	ensure.NotNil(t, err)
+	ensure.DeepEqual(t, len(events), 3)
+
+	func() {
+		defer func() { ensure.DeepEqual(t, recover(), "nope") }()
+		sqjdb.WithTx(conn, func(conn *sqlite.Conn) error {
+			_, err := notified.Insert(conn, &Jedi{Name: "panic"})
+			ensure.Nil(t, err)
+			panic("nope")
+		})
+	}()
+	ensure.DeepEqual(t, len(events), 3)
+
+	// Writes that change nothing are not dispatched.
+	_, err = notified.Delete(conn, sqjdb.ByID("missing"))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(events), 3)
+
+	ch, stop := notified.Notifier().Chan(1)
+	_, err = notified.Delete(conn, sqjdb.ByID(doc.ID))
+	ensure.Nil(t, err)
+	e := <-ch
+	ensure.DeepEqual(t, e.Op, sqjdb.HookDelete)
+	ensure.DeepEqual(t, len(events), 4)
+	stop()
+	cancel()
+	_, err = notified.Insert(conn, &Jedi{Name: "ahsoka"})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(events), 4)
+	ensure.DeepEqual(t, len(ch), 0)
+}
+
+func TestNotifierUnhookedWrites(t *testing.T) {
+	conn := newConn(t)
+	notified := sqjdb.NewTable[SoftJedi](t.Name(), sqjdb.SoftDelete("DeletedAt"))
+	ensure.Nil(t, notified.Migrate(conn))
+	var ops []sqjdb.HookOp
+	notified.Notifier().Subscribe(func(e sqjdb.HookEvent[SoftJedi]) {
+		ops = append(ops, e.Op)
+	})
+
+	raw, err := notified.InsertRaw(conn, json.RawMessage(`{"Name":"anakin"}`))
+	ensure.Nil(t, err)
+	var doc SoftJedi
+	ensure.Nil(t, json.Unmarshal(raw, &doc))
+	_, err = notified.PatchFields(conn, map[string]any{"Name": "vader"}, sqjdb.ByID(doc.ID))
+	ensure.Nil(t, err)
+	_, err = notified.JSONPatch(conn, []sqjdb.JSONPatchOp{
+		{Op: "replace", Path: "/Name", Value: json.RawMessage(`"anakin"`)},
+	}, sqjdb.ByID(doc.ID))
+	ensure.Nil(t, err)
+	_, err = notified.UpdateReturning(conn, &SoftJedi{Name: "vader"}, sqjdb.ByID(doc.ID))
+	ensure.Nil(t, err)
+	_, err = notified.Delete(conn, sqjdb.ByID(doc.ID))
+	ensure.Nil(t, err)
+	_, err = notified.Restore(conn, sqjdb.ByID(doc.ID))
+	ensure.Nil(t, err)
+	_, err = notified.ImportJSONL(conn, strings.NewReader(`{"Name":"obiwan"}`+"\n"), sqjdb.ImportOptions{})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, ops, []sqjdb.HookOp{
+		sqjdb.HookInsert, sqjdb.HookPatch, sqjdb.HookPatch, sqjdb.HookPatch,
+		sqjdb.HookDelete, sqjdb.HookPatch, sqjdb.HookInsert,
+	})
+
+	// Writes that change nothing are not dispatched.
+	_, err = notified.PatchFields(conn, map[string]any{"Name": "vader"}, sqjdb.ByID("missing"))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(ops), 7)
+}
+
+func TestNotifierSaveRollback(t *testing.T) {
+	conn := newConn(t)
+	notified := sqjdb.NewTable[Jedi](t.Name())
+	ensure.Nil(t, notified.Migrate(conn))
+	var names []string
+	notified.Notifier().Subscribe(func(e sqjdb.HookEvent[Jedi]) {
+		names = append(names, e.Doc.Name)
+	})
+
+	// Transactions made with sqlitex.Save instead of WithTx drop the writes
+	// they roll back, including in nested savepoints.
+	err := func() (err error) {
+		defer sqlitex.Save(conn)(&err)
+		_, err = notified.Insert(conn, &Jedi{Name: "rolled back"})
+		ensure.Nil(t, err)
+		return errors.New("rollback")
+	}()
+	ensure.NotNil(t, err)
+	err = func() (err error) {
+		defer sqlitex.Save(conn)(&err)
+		_, err = notified.Insert(conn, &Jedi{Name: "anakin"})
+		ensure.Nil(t, err)
+		ensure.NotNil(t, func() (err error) {
+			defer sqlitex.Save(conn)(&err)
+			_, err = notified.Insert(conn, &Jedi{Name: "nested"})
+			ensure.Nil(t, err)
+			return errors.New("rollback")
+		}())
+		return nil
+	}()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(names), 0)
+
+	// The committed ones are dispatched with the next statement.
+	_, err = notified.Count(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, names, []string{"anakin"})
+	_, err = notified.Insert(conn, &Jedi{Name: "obiwan"})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, names, []string{"anakin", "obiwan"})
+}
+
+func TestNotifierClose(t *testing.T) {
+	conn := newConn(t)
+	notified := sqjdb.NewTable[Jedi](t.Name())
+	ensure.Nil(t, notified.Migrate(conn))
+	var names []string
+	notified.Notifier().Subscribe(func(e sqjdb.HookEvent[Jedi]) {
+		names = append(names, e.Doc.Name)
+	})
+
+	err := func() (err error) {
+		defer sqlitex.Save(conn)(&err)
+		_, err = notified.Insert(conn, &Jedi{Name: "anakin"})
+		return err
+	}()
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(names), 0)
+	ensure.Nil(t, sqjdb.Close(conn))
+	ensure.DeepEqual(t, names, []string{"anakin"})
+}
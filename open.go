@@ -0,0 +1,109 @@
+package sqjdb
+
+import (
+	"fmt"
+	"time"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// OpenOption configures Open.
+type OpenOption func(*openOptions)
+
+type openOptions struct {
+	flags       sqlite.OpenFlags
+	busyTimeout time.Duration
+	pragmas     [][2]string
+}
+
+// defaultPragmas are the pragmas applied by Open, in order.
+var defaultPragmas = [][2]string{
+	{"journal_mode", "wal"},
+	{"synchronous", "normal"},
+	{"foreign_keys", "on"},
+	{"temp_store", "memory"},
+}
+
+// BusyTimeout sets how long Open connections wait for locks held by other
+// connections. The default is 5 seconds.
+func BusyTimeout(d time.Duration) OpenOption {
+	return func(o *openOptions) {
+		o.busyTimeout = d
+	}
+}
+
+// Pragma sets the pragma when opening connections, overriding the default if
+// there is one. The name and value are not escaped.
+func Pragma(name, value string) OpenOption {
+	return func(o *openOptions) {
+		for i, p := range o.pragmas {
+			if p[0] == name {
+				o.pragmas[i][1] = value
+				return
+			}
+		}
+		o.pragmas = append(o.pragmas, [2]string{name, value})
+	}
+}
+
+// ReadOnly opens connections that can not write to the database.
+func ReadOnly() OpenOption {
+	return func(o *openOptions) {
+		o.flags = o.flags&^(sqlite.OpenReadWrite|sqlite.OpenCreate) | sqlite.OpenReadOnly
+	}
+}
+
+func newOpenOptions(opts []OpenOption) *openOptions {
+	o := &openOptions{
+		flags:       sqlite.OpenReadWrite | sqlite.OpenCreate | sqlite.OpenURI | sqlite.OpenWAL,
+		busyTimeout: 5 * time.Second,
+		pragmas:     append([][2]string(nil), defaultPragmas...),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// prepareConn applies the options to a newly opened connection.
+func (o *openOptions) prepareConn(conn *sqlite.Conn) error {
+	conn.SetBusyTimeout(o.busyTimeout)
+	for _, p := range o.pragmas {
+		q := "pragma " + p[0] + " = " + p[1]
+		if err := sqlitex.ExecuteTransient(conn, q, nil); err != nil {
+			return fmt.Errorf("sqjdb: setting pragma %s: %w", p[0], err)
+		}
+	}
+	return RegisterFunctions(conn)
+}
+
+// Open opens a connection to the database at path, which may be a URI, with
+// recommended settings: WAL journaling, synchronous=NORMAL, foreign keys
+// enforced, temporary storage in memory and a busy timeout. The SQL functions
+// from RegisterFunctions are also registered.
+func Open(path string, opts ...OpenOption) (*sqlite.Conn, error) {
+	o := newOpenOptions(opts)
+	conn, err := sqlite.OpenConn(path, o.flags)
+	if err != nil {
+		return nil, fmt.Errorf("sqjdb: opening %q: %w", path, err)
+	}
+	if err := o.prepareConn(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Close closes the connection. Notifications of writes committed within a
+// transaction not made with WithTx or Savepoint, which are otherwise pending
+// until the connection is used again, are dispatched first, and those of an
+// open transaction are dropped.
+func Close(conn *sqlite.Conn) error {
+	if conn.AutocommitEnabled() {
+		flushNotifications(conn)
+	} else {
+		pendingNotifications.Delete(conn)
+	}
+	return conn.Close()
+}
@@ -0,0 +1,47 @@
+package sqjdb_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+func pragma(t *testing.T, conn *sqlite.Conn, name string) string {
+	v, err := sqlitex.ResultText(conn.Prep("pragma " + name))
+	ensure.Nil(t, err)
+	return v
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	conn, err := sqjdb.Open(path, sqjdb.Pragma("synchronous", "full"))
+	ensure.Nil(t, err)
+	defer conn.Close()
+	ensure.DeepEqual(t, pragma(t, conn, "journal_mode"), "wal")
+	ensure.DeepEqual(t, pragma(t, conn, "foreign_keys"), "1")
+	ensure.DeepEqual(t, pragma(t, conn, "synchronous"), "2")
+	ensure.Nil(t, jedis.Migrate(conn))
+	_, err = jedis.Insert(conn, &Jedi{Name: "grogu"})
+	ensure.Nil(t, err)
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	conn, err := sqjdb.Open(path)
+	ensure.Nil(t, err)
+	defer conn.Close()
+	ensure.Nil(t, jedis.Migrate(conn))
+
+	reader, err := sqjdb.Open(path, sqjdb.ReadOnly())
+	ensure.Nil(t, err)
+	defer reader.Close()
+	n, err := jedis.Count(reader)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 0)
+	_, err = jedis.Insert(reader, &Jedi{Name: "grogu"})
+	ensure.NotNil(t, err)
+}
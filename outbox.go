@@ -0,0 +1,138 @@
+package sqjdb
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// Outbox stores events to publish, so they can be enqueued in the same
+// transaction as the changes they describe, and published reliably by a
+// consumer afterwards. Events are delivered at least once, in the order they
+// were enqueued, unless their lease expires while being processed.
+type Outbox[E any] struct {
+	Name string
+}
+
+// NewOutbox creates a new Outbox stored in the named table.
+func NewOutbox[E any](name string) Outbox[E] {
+	return Outbox[E]{Name: name}
+}
+
+// OutboxEvent is an event leased from an Outbox.
+type OutboxEvent[E any] struct {
+	// Seq identifies the event, and increases with every enqueued event.
+	Seq int64
+	// Attempts is the number of times the event has been leased, including
+	// this one.
+	Attempts int
+	Event    *E
+}
+
+// Migrate creates the table for the outbox.
+func (o *Outbox[E]) Migrate(conn *sqlite.Conn) error {
+	qCreate := "create table if not exists " + o.Name +
+		" (seq integer primary key autoincrement, data blob not null," +
+		" attempts integer not null default 0, lease_until real not null default 0)"
+	if err := sqlitex.ExecuteTransient(conn, qCreate, nil); err != nil {
+		return fmt.Errorf("sqjdb: creating table %q: %w", o.Name, err)
+	}
+	return nil
+}
+
+// Enqueue adds the events to the outbox. Call it within the transaction making
+// the changes, using WithTx, so the events are stored if and only if the
+// changes are.
+func (o *Outbox[E]) Enqueue(conn *sqlite.Conn, events ...*E) error {
+	query := "insert into " + o.Name + " (data) values (jsonb(" + jsonParam + "))"
+	for _, event := range events {
+		jsonS, release, err := marshal(event)
+		if err != nil {
+			return err
+		}
+		err = sqlitex.Execute(conn, query, &sqlitex.ExecOptions{Args: []any{jsonS}})
+		release()
+		if err != nil {
+			return fmt.Errorf("sqjdb: enqueuing event in %q: %w", o.Name, err)
+		}
+	}
+	return nil
+}
+
+// Lease returns up to limit of the oldest events that are not leased, and
+// leases them for the given duration. Events that are not acknowledged with Ack
+// before the lease expires are returned again by a later Lease.
+func (o *Outbox[E]) Lease(conn *sqlite.Conn, limit int, lease time.Duration) ([]OutboxEvent[E], error) {
+	query := "update " + o.Name + " set attempts = attempts + 1," +
+		" lease_until = unixepoch('now', 'subsec') + ?" +
+		" where seq in (select seq from " + o.Name +
+		" where lease_until <= unixepoch('now', 'subsec') order by seq limit ?)" +
+		" returning seq, attempts, json(data)"
+	var events []OutboxEvent[E]
+	err := sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
+		Args: []any{lease.Seconds(), limit},
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			event := new(E)
+			jsonS := []byte(stmt.ColumnText(2))
+			if err := json.Unmarshal(jsonS, event); err != nil {
+				return fmt.Errorf("sqjdb: invalid json from db: %w\n%s", err, jsonS)
+			}
+			events = append(events, OutboxEvent[E]{
+				Seq:      stmt.ColumnInt64(0),
+				Attempts: stmt.ColumnInt(1),
+				Event:    event,
+			})
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sqjdb: leasing events from %q: %w", o.Name, err)
+	}
+	slices.SortFunc(events, func(a, b OutboxEvent[E]) int {
+		return cmp.Compare(a.Seq, b.Seq)
+	})
+	return events, nil
+}
+
+// Ack removes the processed events from the outbox.
+func (o *Outbox[E]) Ack(conn *sqlite.Conn, seqs ...int64) error {
+	query := "delete from " + o.Name + " where seq = ?"
+	return WithTx(conn, func(conn *sqlite.Conn) error {
+		for _, seq := range seqs {
+			if err := sqlitex.Execute(conn, query, &sqlitex.ExecOptions{Args: []any{seq}}); err != nil {
+				return fmt.Errorf("sqjdb: acknowledging event %d in %q: %w", seq, o.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Process leases up to limit events, calls fn with each of them in order, and
+// acknowledges those for which it returns nil. The others are retried once
+// their lease expires, and their errors are returned joined. It returns the
+// number of events acknowledged, which is 0 once the outbox is drained.
+func (o *Outbox[E]) Process(conn *sqlite.Conn, limit int, lease time.Duration, fn func(OutboxEvent[E]) error) (int, error) {
+	events, err := o.Lease(conn, limit, lease)
+	if err != nil {
+		return 0, err
+	}
+	var done []int64
+	var errs []error
+	for _, event := range events {
+		if err := fn(event); err != nil {
+			errs = append(errs, fmt.Errorf("sqjdb: processing event %d from %q: %w", event.Seq, o.Name, err))
+			continue
+		}
+		done = append(done, event.Seq)
+	}
+	if err := o.Ack(conn, done...); err != nil {
+		return 0, err
+	}
+	return len(done), errors.Join(errs...)
+}
@@ -0,0 +1,66 @@
+package sqjdb_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+)
+
+type JediEvent struct {
+	Type string
+	ID   string
+}
+
+func TestOutbox(t *testing.T) {
+	conn := newConn(t)
+	outbox := sqjdb.NewOutbox[JediEvent]("jedi_events")
+	ensure.Nil(t, outbox.Migrate(conn))
+	ensure.Nil(t, outbox.Migrate(conn))
+
+	// Events are only stored if the transaction commits.
+	var doc *Jedi
+	err := sqjdb.WithTx(conn, func(conn *sqlite.Conn) (err error) {
+		if doc, err = jedis.Insert(conn, &Jedi{Name: "grogu"}); err != nil {
+			return err
+		}
+		return outbox.Enqueue(conn, &JediEvent{Type: "created", ID: doc.ID})
+	})
+	ensure.Nil(t, err)
+	err = sqjdb.WithTx(conn, func(conn *sqlite.Conn) error {
+		ensure.Nil(t, outbox.Enqueue(conn, &JediEvent{Type: "created", ID: "din"}))
+		return errors.New("rollback")
+	})
+	ensure.NotNil(t, err)
+	ensure.Nil(t, outbox.Enqueue(conn, &JediEvent{Type: "deleted", ID: yoda.ID}))
+
+	events, err := outbox.Lease(conn, 1, time.Minute)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, events, []sqjdb.OutboxEvent[JediEvent]{
+		{Seq: 1, Attempts: 1, Event: &JediEvent{Type: "created", ID: doc.ID}},
+	})
+
+	// Leased events are skipped until their lease expires.
+	var seen []JediEvent
+	n, err := outbox.Process(conn, 10, 0, func(e sqjdb.OutboxEvent[JediEvent]) error {
+		seen = append(seen, *e.Event)
+		return errors.New("unavailable")
+	})
+	ensure.StringContains(t, err.Error(), "unavailable")
+	ensure.DeepEqual(t, n, 0)
+	ensure.DeepEqual(t, seen, []JediEvent{{Type: "deleted", ID: yoda.ID}})
+
+	ensure.Nil(t, outbox.Ack(conn, events[0].Seq))
+	n, err = outbox.Process(conn, 10, time.Minute, func(e sqjdb.OutboxEvent[JediEvent]) error {
+		ensure.DeepEqual(t, e.Attempts, 2)
+		return nil
+	})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+	events, err = outbox.Lease(conn, 10, time.Minute)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(events), 0)
+}
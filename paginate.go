@@ -0,0 +1,81 @@
+package sqjdb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// ErrInvalidCursor indicates a cursor given to Paginate was not one it returned.
+var ErrInvalidCursor = errors.New("sqjdb: invalid cursor")
+
+func encodeCursor(id any) (string, error) {
+	jsonS, err := json.Marshal(id)
+	if err != nil {
+		return "", fmt.Errorf("sqjdb: failed to json.Marshal: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(jsonS), nil
+}
+
+func decodeCursor(cursor string) (any, error) {
+	jsonS, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidCursor, cursor, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(jsonS))
+	dec.UseNumber()
+	var id any
+	if err := dec.Decode(&id); err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidCursor, cursor, err)
+	}
+	switch id := id.(type) {
+	case string:
+		return id, nil
+	case json.Number:
+		if i, err := id.Int64(); err == nil {
+			return i, nil
+		}
+		return id.Float64()
+	}
+	return nil, fmt.Errorf("%w %q", ErrInvalidCursor, cursor)
+}
+
+// Paginate returns a page of up to limit documents ordered by ID, along with an
+// opaque cursor for the next page. Pass an empty cursor for the first page. The
+// returned cursor is empty when there are no more documents. Since generated
+// IDs are ULIDs, pages are in insertion order. The filters further restrict the
+// documents returned.
+func (t *Table[T]) Paginate(conn *sqlite.Conn, cursor string, limit int, filters ...Cond) ([]*T, string, error) {
+	if limit < 1 {
+		return nil, "", fmt.Errorf("sqjdb: invalid page limit %d", limit)
+	}
+	cond := Where(t.opts.idKey).IsNotNull()
+	if cursor != "" {
+		afterID, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		cond = Where(t.opts.idKey).Gt(afterID)
+	}
+	if len(filters) > 0 {
+		cond = cond.And(filters...)
+	}
+	// Fetch one extra document to know if there is a next page.
+	docs, err := t.All(conn, cond.SQL(), OrderBy(t.opts.idKey, Asc), Limit(limit+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(docs) <= limit {
+		return docs, "", nil
+	}
+	docs = docs[:limit]
+	next, err := encodeCursor(t.docID(docs[limit-1]))
+	if err != nil {
+		return nil, "", err
+	}
+	return docs, next, nil
+}
@@ -0,0 +1,46 @@
+package sqjdb_test
+
+import (
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestPaginate(t *testing.T) {
+	conn := newConn(t)
+	var ids []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		docs, next, err := jedis.Paginate(conn, cursor, 2)
+		ensure.Nil(t, err)
+		for _, doc := range docs {
+			ids = append(ids, doc.ID)
+		}
+		if next == "" {
+			ensure.DeepEqual(t, pages, 1)
+			break
+		}
+		cursor = next
+	}
+	ensure.DeepEqual(t, ids, []string{yoda.ID, luke.ID, leia.ID})
+}
+
+func TestPaginateFilters(t *testing.T) {
+	conn := newConn(t)
+	docs, next, err := jedis.Paginate(conn, "", 1, sqjdb.Where("Age").Eq(42))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 1)
+	ensure.DeepEqual(t, docs[0].ID, luke.ID)
+	docs, next, err = jedis.Paginate(conn, next, 1, sqjdb.Where("Age").Eq(42))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 1)
+	ensure.DeepEqual(t, docs[0].ID, leia.ID)
+	ensure.DeepEqual(t, next, "")
+}
+
+func TestPaginateInvalidCursor(t *testing.T) {
+	conn := newConn(t)
+	_, _, err := jedis.Paginate(conn, "!", 1)
+	ensure.NotNil(t, err)
+}
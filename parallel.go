@@ -0,0 +1,95 @@
+package sqjdb
+
+import (
+	"sync"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// ParallelDecode makes All and Iter decode documents using the given number of
+// goroutines, while the statement is stepped by the calling goroutine.
+// Documents are still returned in order. Decoding dominates the cost of
+// reading large documents, but for small ones the coordination costs more than
+// it saves. Upgraders and the WarnUnknownFields function may be called
+// concurrently.
+func ParallelDecode(workers int) Option {
+	return func(o *options) {
+		o.decodeWorkers = workers
+	}
+}
+
+type decodeJob[T any] struct {
+	jsonS  []byte
+	result chan decoded[T]
+}
+
+type decoded[T any] struct {
+	doc      *T
+	upgraded *upgradedDoc
+	err      error
+}
+
+// stepParallel steps the statement to the end, decoding the documents using
+// the ParallelDecode workers, and calls fn with each in order until it returns
+// false. Upgraded documents are added to pending if it is not nil.
+func (t *Table[T]) stepParallel(stmt *sqlite.Stmt, pending *[]upgradedDoc, fn func(*T) bool) error {
+	workers := t.opts.decodeWorkers
+	jobs := make(chan decodeJob[T])
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				var r decoded[T]
+				r.doc = new(T)
+				r.upgraded, r.err = t.decode(job.jsonS, r.doc)
+				job.result <- r
+			}
+		}()
+	}
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	// Results are consumed in order, allowing a window of documents to be
+	// decoded ahead of the oldest one.
+	var inFlight []chan decoded[T]
+	next := func() (bool, error) {
+		r := <-inFlight[0]
+		inFlight = inFlight[1:]
+		if r.err != nil {
+			return false, r.err
+		}
+		if r.upgraded != nil && pending != nil {
+			*pending = append(*pending, *r.upgraded)
+		}
+		return fn(r.doc), nil
+	}
+	for {
+		rowReturned, err := stmt.Step()
+		if err != nil {
+			return err
+		}
+		if !rowReturned {
+			break
+		}
+		// Workers hold on to the document, so it can not use a shared buffer.
+		jsonS := columnBytes(stmt, 0, new([]byte))
+		result := make(chan decoded[T], 1)
+		jobs <- decodeJob[T]{jsonS: jsonS, result: result}
+		inFlight = append(inFlight, result)
+		if len(inFlight) >= 2*workers {
+			if ok, err := next(); err != nil || !ok {
+				return err
+			}
+		}
+	}
+	for len(inFlight) > 0 {
+		if ok, err := next(); err != nil || !ok {
+			return err
+		}
+	}
+	return nil
+}
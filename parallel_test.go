@@ -0,0 +1,44 @@
+package sqjdb_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestParallelDecode(t *testing.T) {
+	conn := newConn(t)
+	parallel := sqjdb.NewTable[Jedi](t.Name(), sqjdb.ParallelDecode(3))
+	ensure.Nil(t, parallel.Migrate(conn))
+	var want []*Jedi
+	for i := range 50 {
+		doc, err := parallel.Insert(conn, &Jedi{ID: fmt.Sprintf("%03d", i), Age: i + 1})
+		ensure.Nil(t, err)
+		want = append(want, doc)
+	}
+	docs, err := parallel.All(conn, sqjdb.OrderBy("ID", sqjdb.Asc))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docs, want)
+
+	var iterated []*Jedi
+	for doc, err := range parallel.Iter(conn, sqjdb.OrderBy("ID", sqjdb.Asc)) {
+		ensure.Nil(t, err)
+		iterated = append(iterated, doc)
+		if len(iterated) == 10 {
+			break
+		}
+	}
+	ensure.DeepEqual(t, iterated, want[:10])
+}
+
+func TestParallelDecodeError(t *testing.T) {
+	conn := newConn(t)
+	strict := sqjdb.NewTable[JediName]("jedis", sqjdb.DisallowUnknownFields(), sqjdb.ParallelDecode(2))
+	_, err := strict.All(conn)
+	ensure.NotNil(t, err)
+	for _, err := range strict.Iter(conn) {
+		ensure.NotNil(t, err)
+	}
+}
@@ -0,0 +1,339 @@
+package sqjdb
+
+import (
+	"context"
+	"encoding/json"
+	"iter"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// PoolTable provides the Table API backed by a sqlitex.Pool. Each method takes
+// a connection from the pool, runs the operation, and puts it back. Use
+// Table.WithPool to create one.
+type PoolTable[T any] struct {
+	Table *Table[T]
+	Pool  *sqlitex.Pool
+	// readers is used for reads if set, see Table.WithDB.
+	readers *sqlitex.Pool
+	retry   *RetryPolicy
+}
+
+// WithPool returns a PoolTable that uses the given pool for connections.
+func (t *Table[T]) WithPool(pool *sqlitex.Pool) *PoolTable[T] {
+	return &PoolTable[T]{Table: t, Pool: pool}
+}
+
+// WithRetry returns a copy of the PoolTable that retries operations that fail
+// because the database is busy or locked, per the policy.
+func (p *PoolTable[T]) WithRetry(policy RetryPolicy) *PoolTable[T] {
+	r := *p
+	r.retry = &policy
+	return &r
+}
+
+// Do takes a connection from the pool, calls fn with it, and puts it back. It
+// can be used to run multiple operations on the same connection, for example
+// within a transaction. When using WithRetry, fn is called again if it fails
+// because the database is busy or locked.
+func (p *PoolTable[T]) Do(ctx context.Context, fn func(conn *sqlite.Conn) error) error {
+	return p.do(ctx, p.Pool, fn)
+}
+
+// Read is like Do, but takes a connection from the read pool when using
+// WithDB. The connection is read only, so fn must not write.
+func (p *PoolTable[T]) Read(ctx context.Context, fn func(conn *sqlite.Conn) error) error {
+	// Writing back upgraded documents needs a writable connection.
+	if p.readers == nil || p.Table.opts.writeBack {
+		return p.Do(ctx, fn)
+	}
+	return p.do(ctx, p.readers, fn)
+}
+
+func (p *PoolTable[T]) do(ctx context.Context, pool *sqlitex.Pool, fn func(conn *sqlite.Conn) error) error {
+	take := func() error {
+		conn, err := pool.Take(ctx)
+		if err != nil {
+			return err
+		}
+		defer pool.Put(conn)
+		if p.Table.opts.tracer != nil {
+			defer withConnContext(conn, ctx)()
+		}
+		return fn(conn)
+	}
+	if p.retry != nil {
+		if m := p.Table.opts.metrics; m != nil {
+			take = observeRetries(m, p.Table.Name, take)
+		}
+		return busyError(Retry(ctx, *p.retry, take))
+	}
+	return busyError(take())
+}
+
+// Migrate is the pooled version of Table.Migrate.
+func (p *PoolTable[T]) Migrate(ctx context.Context) error {
+	return p.Do(ctx, p.Table.Migrate)
+}
+
+// Insert is the pooled version of Table.Insert.
+func (p *PoolTable[T]) Insert(ctx context.Context, doc *T) (*T, error) {
+	var v *T
+	err := p.Do(ctx, func(conn *sqlite.Conn) (err error) {
+		v, err = p.Table.Insert(conn, doc)
+		return err
+	})
+	return v, err
+}
+
+// One is the pooled version of Table.One.
+func (p *PoolTable[T]) One(ctx context.Context, sqls ...SQL) (*T, error) {
+	var v *T
+	err := p.Read(ctx, func(conn *sqlite.Conn) (err error) {
+		v, err = p.Table.One(conn, sqls...)
+		return err
+	})
+	return v, err
+}
+
+// All is the pooled version of Table.All.
+func (p *PoolTable[T]) All(ctx context.Context, sqls ...SQL) ([]*T, error) {
+	var docs []*T
+	err := p.Read(ctx, func(conn *sqlite.Conn) (err error) {
+		docs, err = p.Table.All(conn, sqls...)
+		return err
+	})
+	return docs, err
+}
+
+// AllValues is the pooled version of Table.AllValues.
+func (p *PoolTable[T]) AllValues(ctx context.Context, sqls ...SQL) ([]T, error) {
+	var docs []T
+	err := p.Read(ctx, func(conn *sqlite.Conn) (err error) {
+		docs, err = p.Table.AllValues(conn, sqls...)
+		return err
+	})
+	return docs, err
+}
+
+// Count is the pooled version of Table.Count.
+func (p *PoolTable[T]) Count(ctx context.Context, sqls ...SQL) (int, error) {
+	var n int
+	err := p.Read(ctx, func(conn *sqlite.Conn) (err error) {
+		n, err = p.Table.Count(conn, sqls...)
+		return err
+	})
+	return n, err
+}
+
+// Delete is the pooled version of Table.Delete.
+func (p *PoolTable[T]) Delete(ctx context.Context, sqls ...SQL) (int, error) {
+	var n int
+	err := p.Do(ctx, func(conn *sqlite.Conn) (err error) {
+		n, err = p.Table.Delete(conn, sqls...)
+		return err
+	})
+	return n, err
+}
+
+// Patch is the pooled version of Table.Patch.
+func (p *PoolTable[T]) Patch(ctx context.Context, doc *T, sqls ...SQL) (int, error) {
+	var n int
+	err := p.Do(ctx, func(conn *sqlite.Conn) (err error) {
+		n, err = p.Table.Patch(conn, doc, sqls...)
+		return err
+	})
+	return n, err
+}
+
+// Replace is the pooled version of Table.Replace.
+func (p *PoolTable[T]) Replace(ctx context.Context, doc *T, sqls ...SQL) (int, error) {
+	var n int
+	err := p.Do(ctx, func(conn *sqlite.Conn) (err error) {
+		n, err = p.Table.Replace(conn, doc, sqls...)
+		return err
+	})
+	return n, err
+}
+
+// Upsert is the pooled version of Table.Upsert.
+func (p *PoolTable[T]) Upsert(ctx context.Context, doc *T) (*T, error) {
+	var v *T
+	err := p.Do(ctx, func(conn *sqlite.Conn) (err error) {
+		v, err = p.Table.Upsert(conn, doc)
+		return err
+	})
+	return v, err
+}
+
+// InsertMany is the pooled version of Table.InsertMany.
+func (p *PoolTable[T]) InsertMany(ctx context.Context, docs []*T) ([]*T, error) {
+	var v []*T
+	err := p.Do(ctx, func(conn *sqlite.Conn) (err error) {
+		v, err = p.Table.InsertMany(conn, docs)
+		return err
+	})
+	return v, err
+}
+
+// Iter is the pooled version of Table.Iter. The connection is held until the
+// iteration ends.
+func (p *PoolTable[T]) Iter(ctx context.Context, sqls ...SQL) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		pool := p.Pool
+		if p.readers != nil {
+			pool = p.readers
+		}
+		conn, err := pool.Take(ctx)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer pool.Put(conn)
+		for v, err := range p.Table.Iter(conn, sqls...) {
+			if !yield(v, err) {
+				return
+			}
+		}
+	}
+}
+
+// Get is the pooled version of Table.Get.
+func (p *PoolTable[T]) Get(ctx context.Context, id any) (*T, error) {
+	var v *T
+	err := p.Read(ctx, func(conn *sqlite.Conn) (err error) {
+		v, err = p.Table.Get(conn, id)
+		return err
+	})
+	return v, err
+}
+
+// GetMany is the pooled version of Table.GetMany.
+func (p *PoolTable[T]) GetMany(ctx context.Context, ids ...any) (map[any]*T, error) {
+	var v map[any]*T
+	err := p.Read(ctx, func(conn *sqlite.Conn) (err error) {
+		v, err = p.Table.GetMany(conn, ids...)
+		return err
+	})
+	return v, err
+}
+
+// GetOrCreate is the pooled version of Table.GetOrCreate.
+func (p *PoolTable[T]) GetOrCreate(ctx context.Context, filter SQL, defaults *T) (*T, bool, error) {
+	var v *T
+	var created bool
+	err := p.Do(ctx, func(conn *sqlite.Conn) (err error) {
+		v, created, err = p.Table.GetOrCreate(conn, filter, defaults)
+		return err
+	})
+	return v, created, err
+}
+
+// UpdateReturning is the pooled version of Table.UpdateReturning.
+func (p *PoolTable[T]) UpdateReturning(ctx context.Context, doc *T, sqls ...SQL) ([]*T, error) {
+	var v []*T
+	err := p.Do(ctx, func(conn *sqlite.Conn) (err error) {
+		v, err = p.Table.UpdateReturning(conn, doc, sqls...)
+		return err
+	})
+	return v, err
+}
+
+// PatchFields is the pooled version of Table.PatchFields.
+func (p *PoolTable[T]) PatchFields(ctx context.Context, fields map[string]any, sqls ...SQL) (int, error) {
+	var n int
+	err := p.Do(ctx, func(conn *sqlite.Conn) (err error) {
+		n, err = p.Table.PatchFields(conn, fields, sqls...)
+		return err
+	})
+	return n, err
+}
+
+// JSONPatch is the pooled version of Table.JSONPatch.
+func (p *PoolTable[T]) JSONPatch(ctx context.Context, ops []JSONPatchOp, sqls ...SQL) (int, error) {
+	var n int
+	err := p.Do(ctx, func(conn *sqlite.Conn) (err error) {
+		n, err = p.Table.JSONPatch(conn, ops, sqls...)
+		return err
+	})
+	return n, err
+}
+
+// AllIncludingDeleted is the pooled version of Table.AllIncludingDeleted.
+func (p *PoolTable[T]) AllIncludingDeleted(ctx context.Context, sqls ...SQL) ([]*T, error) {
+	var docs []*T
+	err := p.Read(ctx, func(conn *sqlite.Conn) (err error) {
+		docs, err = p.Table.AllIncludingDeleted(conn, sqls...)
+		return err
+	})
+	return docs, err
+}
+
+// Restore is the pooled version of Table.Restore.
+func (p *PoolTable[T]) Restore(ctx context.Context, sqls ...SQL) (int, error) {
+	var n int
+	err := p.Do(ctx, func(conn *sqlite.Conn) (err error) {
+		n, err = p.Table.Restore(conn, sqls...)
+		return err
+	})
+	return n, err
+}
+
+// Purge is the pooled version of Table.Purge.
+func (p *PoolTable[T]) Purge(ctx context.Context, sqls ...SQL) (int, error) {
+	var n int
+	err := p.Do(ctx, func(conn *sqlite.Conn) (err error) {
+		n, err = p.Table.Purge(conn, sqls...)
+		return err
+	})
+	return n, err
+}
+
+// Watch is the pooled version of Table.Watch. A connection is only held while
+// polling.
+func (p *PoolTable[T]) Watch(ctx context.Context, sqls ...SQL) iter.Seq2[Change[T], error] {
+	return p.Table.watch(ctx, func(fn func(*sqlite.Conn) error) error {
+		return p.Read(ctx, fn)
+	}, sqls)
+}
+
+// OneRaw is the pooled version of Table.OneRaw.
+func (p *PoolTable[T]) OneRaw(ctx context.Context, sqls ...SQL) (json.RawMessage, error) {
+	var v json.RawMessage
+	err := p.Read(ctx, func(conn *sqlite.Conn) (err error) {
+		v, err = p.Table.OneRaw(conn, sqls...)
+		return err
+	})
+	return v, err
+}
+
+// AllRaw is the pooled version of Table.AllRaw.
+func (p *PoolTable[T]) AllRaw(ctx context.Context, sqls ...SQL) ([]json.RawMessage, error) {
+	var docs []json.RawMessage
+	err := p.Read(ctx, func(conn *sqlite.Conn) (err error) {
+		docs, err = p.Table.AllRaw(conn, sqls...)
+		return err
+	})
+	return docs, err
+}
+
+// InsertRaw is the pooled version of Table.InsertRaw.
+func (p *PoolTable[T]) InsertRaw(ctx context.Context, doc json.RawMessage) (json.RawMessage, error) {
+	var v json.RawMessage
+	err := p.Do(ctx, func(conn *sqlite.Conn) (err error) {
+		v, err = p.Table.InsertRaw(conn, doc)
+		return err
+	})
+	return v, err
+}
+
+// Select is the pooled version of Table.Select.
+func (p *PoolTable[T]) Select(ctx context.Context, fields []string, sqls ...SQL) ([]*T, error) {
+	var docs []*T
+	err := p.Read(ctx, func(conn *sqlite.Conn) (err error) {
+		docs, err = p.Table.Select(conn, fields, sqls...)
+		return err
+	})
+	return docs, err
+}
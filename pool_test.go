@@ -0,0 +1,69 @@
+package sqjdb_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+func newPool(t *testing.T) *sqjdb.PoolTable[Jedi] {
+	pool, err := sqlitex.NewPool(
+		fmt.Sprintf("file:%s.db?mode=memory&cache=shared", t.Name()),
+		sqlitex.PoolOptions{PoolSize: 2},
+	)
+	ensure.Nil(t, err)
+	t.Cleanup(func() { pool.Close() })
+	pjedis := jedis.WithPool(pool)
+	ctx := context.Background()
+	ensure.Nil(t, pjedis.Migrate(ctx))
+	for _, jedi := range []*Jedi{&yoda, &luke, &leia} {
+		_, err := pjedis.Insert(ctx, jedi)
+		ensure.Nil(t, err)
+	}
+	return pjedis
+}
+
+func TestPoolOne(t *testing.T) {
+	pjedis := newPool(t)
+	ctx := context.Background()
+	// More calls than the pool size ensures connections are returned.
+	for range 5 {
+		yodaFetched, err := pjedis.One(ctx, sqjdb.ByID(yoda.ID))
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, yodaFetched.Name, yoda.Name)
+	}
+}
+
+func TestPoolAllAndDelete(t *testing.T) {
+	pjedis := newPool(t)
+	ctx := context.Background()
+	n, err := pjedis.Delete(ctx, sqjdb.ByID(luke.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+	rows42, err := pjedis.All(ctx, byAge(luke.Age))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(rows42), 1)
+}
+
+func TestPoolIter(t *testing.T) {
+	pjedis := newPool(t)
+	ctx := context.Background()
+	for range 3 {
+		for _, err := range pjedis.Iter(ctx) {
+			ensure.Nil(t, err)
+			break
+		}
+	}
+}
+
+func TestPoolWithRetry(t *testing.T) {
+	pjedis := newPool(t).WithRetry(sqjdb.DefaultRetryPolicy)
+	ctx := context.Background()
+	n, err := pjedis.Count(ctx)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 3)
+}
@@ -0,0 +1,66 @@
+package sqjdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// Select is like All, but only fetches the named fields of the documents,
+// leaving the other fields of T zero. It avoids shipping and decoding large
+// documents when only a few fields are needed.
+func (t *Table[T]) Select(conn *sqlite.Conn, fields []string, sqls ...SQL) ([]*T, error) {
+	return SelectAs[T](conn, t, fields, sqls...)
+}
+
+// SelectAs is like Table.Select, but decodes the fields into R, which is
+// usually a smaller struct than the documents of the table.
+func SelectAs[R, T any](conn *sqlite.Conn, t *Table[T], fields []string, sqls ...SQL) (_ []*R, err error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("sqjdb: no fields to select from %q", t.Name)
+	}
+	var query strings.Builder
+	query.WriteString("select json_object(")
+	args := make([]any, 0, len(fields))
+	for i, field := range fields {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		// json() keeps nested objects and arrays as JSON rather than strings.
+		key := t.FieldName(field)
+		query.WriteString("?, json(" + fieldJSONExpr(key) + ")")
+		args = append(args, key)
+	}
+	query.WriteString(") from")
+	sqls = slices.Concat([]SQL{{Args: args}, t.from()}, sqls)
+	addSQLQuery(&query, sqls)
+	sp := t.trace(conn, "select", query.String(), sqls)
+	defer func() { err = sp.finish(err) }()
+	stmt, err := prepareSQL(conn, query.String(), sqls)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseStmt(stmt)
+	var docs []*R
+	var buf []byte
+	for {
+		rowReturned, err := stmt.Step()
+		if err != nil {
+			return nil, err
+		}
+		if !rowReturned {
+			break
+		}
+		sp.addRows(1)
+		jsonS := columnBytes(stmt, 0, &buf)
+		v := new(R)
+		if err := json.Unmarshal(jsonS, v); err != nil {
+			return nil, fmt.Errorf("sqjdb: invalid json from db: %w\n%s", err, jsonS)
+		}
+		docs = append(docs, v)
+	}
+	return docs, nil
+}
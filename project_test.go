@@ -0,0 +1,70 @@
+package sqjdb_test
+
+import (
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+)
+
+type Citizen struct {
+	ID       string `json:",omitempty"`
+	FullName string `json:"full_name,omitempty"`
+	Planet   string `json:"planet,omitempty"`
+}
+
+// newCitizens creates a table of citizens whose fields all have renamed JSON
+// keys.
+func newCitizens(t *testing.T, conn *sqlite.Conn) *sqjdb.Table[Citizen] {
+	citizens := sqjdb.NewTable[Citizen](t.Name())
+	ensure.Nil(t, citizens.Migrate(conn))
+	for _, c := range []*Citizen{
+		{FullName: "Han Solo", Planet: "Corellia"},
+		{FullName: "Wedge Antilles", Planet: "Corellia"},
+		{FullName: "Lando Calrissian", Planet: "Socorro"},
+	} {
+		_, err := citizens.Insert(conn, c)
+		ensure.Nil(t, err)
+	}
+	return &citizens
+}
+
+func TestSelect(t *testing.T) {
+	conn := newConn(t)
+	docs, err := jedis.Select(conn, []string{"ID", "Name"}, sqjdb.ByID(yoda.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docs, []*Jedi{{ID: yoda.ID, Name: yoda.Name}})
+}
+
+func TestSelectAs(t *testing.T) {
+	conn := newConn(t)
+	type name struct {
+		Name string
+	}
+	docs, err := sqjdb.SelectAs[name](conn, &jedis, []string{"Name"},
+		sqjdb.OrderBy("Name", sqjdb.Asc))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docs, []*name{{leia.Name}, {luke.Name}, {yoda.Name}})
+}
+
+func TestSelectRenamedField(t *testing.T) {
+	conn := newConn(t)
+	citizens := newCitizens(t, conn)
+	docs, err := citizens.Select(conn, []string{"FullName"}, sqjdb.OrderBy("full_name", sqjdb.Asc))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docs, []*Citizen{
+		{FullName: "Han Solo"}, {FullName: "Lando Calrissian"}, {FullName: "Wedge Antilles"},
+	})
+}
+
+func TestSelectNested(t *testing.T) {
+	conn := newConn(t)
+	passages := sqjdb.NewTable[Passage](t.Name())
+	ensure.Nil(t, passages.Migrate(conn))
+	doc, err := passages.Insert(conn, &Passage{Text: "north", Embedding: []float64{0, 1}})
+	ensure.Nil(t, err)
+	docs, err := passages.Select(conn, []string{"Embedding", "Missing"})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docs, []*Passage{{Embedding: doc.Embedding}})
+}
@@ -0,0 +1,91 @@
+package sqjdb
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// Promote maintains a generated column with an index for each of the named
+// fields. Migrate adds the columns, named after the fields, and their indexes.
+// Conditions and ordering built with Table.Where and Table.OrderBy target the
+// columns instead of extracting the field from the document. SQLite only
+// allows adding virtual generated columns to existing tables, so the values
+// are computed on read, but the indexes store them.
+func Promote(fields ...string) Option {
+	return func(o *options) {
+		o.promoted = append(o.promoted, fields...)
+	}
+}
+
+// columnName returns the quoted column name for a promoted field.
+func columnName(field string) string {
+	return `"` + strings.ReplaceAll(field, `"`, `""`) + `"`
+}
+
+// fieldSQL returns the SQL expression for the named field, which is the column
+// for promoted fields.
+func (t *Table[T]) fieldSQL(name string) string {
+	if slices.Contains(t.opts.promoted, name) {
+		return columnName(name)
+	}
+	return fieldExpr(t.FieldName(name))
+}
+
+// promotedIndexName returns the name of the index on the generated column of
+// the promoted field, without the schema.
+func (t *Table[T]) promotedIndexName(field string) string {
+	return t.indexName(IndexSpec{Fields: []string{"col", field}})
+}
+
+func (t *Table[T]) migratePromoted(conn *sqlite.Conn) error {
+	prefix, base := splitName(t.Name)
+	schema := strings.TrimSuffix(prefix, ".")
+	if schema == "" {
+		schema = "main"
+	}
+	columns := map[string]bool{}
+	err := sqlitex.Execute(conn, "select name from pragma_table_xinfo(?, ?)",
+		&sqlitex.ExecOptions{
+			Args: []any{base, schema},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				columns[stmt.ColumnText(0)] = true
+				return nil
+			},
+		})
+	if err != nil {
+		return fmt.Errorf("sqjdb: listing columns of %q: %w", t.Name, err)
+	}
+	for _, field := range t.opts.promoted {
+		col := columnName(field)
+		if !columns[field] {
+			qAdd := "alter table " + t.Name + " add column " + col +
+				" generated always as (" + fieldExpr(t.FieldName(field)) + ") virtual"
+			if err := sqlitex.ExecuteTransient(conn, qAdd, nil); err != nil {
+				return fmt.Errorf("sqjdb: adding column for %q on %q: %w", field, t.Name, err)
+			}
+		}
+		qIndex := "create index if not exists " + prefix + t.promotedIndexName(field) +
+			" on " + base + " (" + col + ")"
+		if err := sqlitex.ExecuteTransient(conn, qIndex, nil); err != nil {
+			return fmt.Errorf("sqjdb: creating index for %q on %q: %w", field, t.Name, err)
+		}
+	}
+	return nil
+}
+
+// Where starts a condition on the named document field, targeting the
+// generated column if the field is promoted. Go field names are resolved to
+// their JSON keys with FieldName.
+func (t *Table[T]) Where(name string) Field {
+	return Field{expr: t.fieldSQL(name)}
+}
+
+// OrderBy generates an order by clause on the named document field, targeting
+// the generated column if the field is promoted.
+func (t *Table[T]) OrderBy(name string, dir Direction) SQL {
+	return SQL{Query: "order by " + t.fieldSQL(name) + " " + string(dir)}
+}
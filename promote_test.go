@@ -0,0 +1,57 @@
+package sqjdb_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestPromote(t *testing.T) {
+	conn := newConn(t)
+	promoted := sqjdb.NewTable[Jedi]("jedis", sqjdb.Promote("Age"))
+	ensure.Nil(t, promoted.Migrate(conn))
+	ensure.Nil(t, promoted.Migrate(conn))
+
+	cond := promoted.Where("Age").Eq(42)
+	ensure.DeepEqual(t, cond.Expr, `"Age" = ?`)
+	ensure.True(t, usesIndex(t, conn, &promoted, "jedis_col_Age", cond.SQL()))
+	docs, err := promoted.All(conn, cond.SQL(), promoted.OrderBy("Age", sqjdb.Asc))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 2)
+
+	// Other fields are still extracted from the document.
+	ensure.DeepEqual(t, promoted.Where("Name").Eq("yoda").Expr, sqjdb.Where("Name").Eq("yoda").Expr)
+}
+
+func TestPromoteScoped(t *testing.T) {
+	conn := newConn(t)
+	promoted := sqjdb.NewTable[SoftJedi](t.Name(), sqjdb.Promote("Name"), sqjdb.SoftDelete("DeletedAt"))
+	ensure.Nil(t, promoted.Migrate(conn))
+	doc, err := promoted.Insert(conn, &SoftJedi{Name: "a"})
+	ensure.Nil(t, err)
+	found, err := promoted.One(conn, promoted.Where("Name").Eq("a").SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, found.ID, doc.ID)
+}
+
+func TestPromoteNestedAttached(t *testing.T) {
+	conn := newConn(t)
+	file := fmt.Sprintf("file:%s_archive.db?mode=memory&cache=shared", t.Name())
+	ensure.Nil(t, sqjdb.Attach(conn, file, "archive"))
+	padawans := sqjdb.NewTable[Padawan]("archive.padawans", sqjdb.Promote("Master.Name"))
+	ensure.Nil(t, padawans.Migrate(conn))
+	ensure.Nil(t, padawans.Migrate(conn))
+
+	_, err := padawans.Insert(conn, &Padawan{Name: "ahsoka", Master: map[string]any{"Name": "anakin"}})
+	ensure.Nil(t, err)
+	cond := padawans.Where("Master.Name").Eq("anakin")
+	ensure.True(t, usesIndex(t, conn, &padawans, "padawans_col_Master_Name", cond.SQL()))
+	found, err := padawans.One(conn, cond.SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, found.Name, "ahsoka")
+	drift, err := padawans.VerifyIndexes(conn)
+	ensure.Nil(t, err)
+	ensure.True(t, drift.Empty())
+}
@@ -0,0 +1,336 @@
+package sqjdb
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// fieldExpr returns the SQL expression to extract the named document field.
+// Nested fields are named by a dotted path such as "Address.City", a JSON
+// pointer such as "/Address/City", or a JSON path such as "$.Address.City".
+func fieldExpr(name string) string {
+	return "data->>" + quoteString(fieldLabel(name))
+}
+
+// fieldJSONExpr returns the SQL expression to extract the named document field
+// as JSON.
+func fieldJSONExpr(name string) string {
+	return "data->" + quoteString(fieldLabel(name))
+}
+
+// fieldLabel returns the right hand side of the -> and ->> operators for the
+// named field. Top level fields use their name as is, which keeps existing
+// index expressions unchanged.
+func fieldLabel(name string) string {
+	if isNestedField(name) {
+		return fieldPath(name)
+	}
+	return name
+}
+
+// isNestedField reports whether the field name is a path rather than a top
+// level field.
+func isNestedField(name string) bool {
+	return strings.HasPrefix(name, "$") || strings.HasPrefix(name, "/") ||
+		strings.Contains(name, ".")
+}
+
+// pathKeyEscaper escapes keys for the quoted labels of SQLite JSON paths, which
+// end at the first double quote and decode JSON escapes.
+var pathKeyEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\u0022`)
+
+// fieldPath returns the SQLite JSON path to the named document field.
+func fieldPath(name string) string {
+	if strings.HasPrefix(name, "$") {
+		return name
+	}
+	var segments []string
+	if rest, ok := strings.CutPrefix(name, "/"); ok {
+		segments = strings.Split(rest, "/")
+		for i, s := range segments {
+			segments[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(s)
+		}
+	} else {
+		segments = strings.Split(name, ".")
+	}
+	var path strings.Builder
+	path.WriteRune('$')
+	for _, s := range segments {
+		path.WriteString(`."` + pathKeyEscaper.Replace(s) + `"`)
+	}
+	return path.String()
+}
+
+// quoteName quotes the table name for use in SQL.
+func quoteName(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteString quotes the string as an SQL literal.
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// Field is a document field used to build conditions. Use Where to create one.
+type Field struct {
+	expr string
+}
+
+// Where starts a condition on the named document field. Nested fields are named
+// by a dotted path such as "Address.City", or a JSON pointer such as
+// "/Address/City".
+func Where(name string) Field {
+	return Field{expr: fieldExpr(name)}
+}
+
+// NoCase is the built-in SQLite collation that ignores ASCII case.
+const NoCase = "nocase"
+
+// Collate returns the field compared using the named collation, such as
+// NoCase, in conditions.
+func (f Field) Collate(name string) Field {
+	return Field{expr: "(" + f.expr + ") collate " + name}
+}
+
+func (f Field) op(op string, v any) Cond {
+	return Cond{Expr: f.expr + " " + op + " ?", Args: []any{v}}
+}
+
+// Eq matches documents where the field is equal to v.
+func (f Field) Eq(v any) Cond { return f.op("=", v) }
+
+// Ne matches documents where the field is not equal to v.
+func (f Field) Ne(v any) Cond { return f.op("!=", v) }
+
+// Gt matches documents where the field is greater than v.
+func (f Field) Gt(v any) Cond { return f.op(">", v) }
+
+// Gte matches documents where the field is greater than or equal to v.
+func (f Field) Gte(v any) Cond { return f.op(">=", v) }
+
+// Lt matches documents where the field is less than v.
+func (f Field) Lt(v any) Cond { return f.op("<", v) }
+
+// Lte matches documents where the field is less than or equal to v.
+func (f Field) Lte(v any) Cond { return f.op("<=", v) }
+
+// Like matches documents where the field matches the LIKE pattern.
+func (f Field) Like(pattern string) Cond { return f.op("like", pattern) }
+
+// timeOp compares the time in the field to t as instants, rather than as text,
+// so times with different offsets or precision compare correctly. SQLite
+// compares them with millisecond precision, and can not use an index on the
+// field to do so.
+func (f Field) timeOp(op string, t time.Time) Cond {
+	return Cond{
+		Expr: "unixepoch(" + f.expr + ", 'subsec') " + op + " unixepoch(?, 'subsec')",
+		Args: []any{t},
+	}
+}
+
+// Before matches documents where the time in the field is before t.
+func (f Field) Before(t time.Time) Cond { return f.timeOp("<", t) }
+
+// After matches documents where the time in the field is after t.
+func (f Field) After(t time.Time) Cond { return f.timeOp(">", t) }
+
+// Between matches documents where the time in the field is between start and
+// end, inclusive.
+func (f Field) Between(start, end time.Time) Cond {
+	return Cond{
+		Expr: "unixepoch(" + f.expr + ", 'subsec') between unixepoch(?, 'subsec') and unixepoch(?, 'subsec')",
+		Args: []any{start, end},
+	}
+}
+
+// IsNull matches documents where the field is null or missing.
+func (f Field) IsNull() Cond { return Cond{Expr: f.expr + " is null"} }
+
+// IsNotNull matches documents where the field is present and not null.
+func (f Field) IsNotNull() Cond { return Cond{Expr: f.expr + " is not null"} }
+
+// Cond is a composable boolean SQL expression. Use SQL to turn it into a where
+// clause.
+type Cond struct {
+	Expr string
+	Args []any
+}
+
+func (c Cond) join(op string, others []Cond) Cond {
+	var expr strings.Builder
+	expr.WriteRune('(')
+	expr.WriteString(c.Expr)
+	args := slices.Clone(c.Args)
+	for _, o := range others {
+		expr.WriteString(") " + op + " (")
+		expr.WriteString(o.Expr)
+		args = append(args, o.Args...)
+	}
+	expr.WriteRune(')')
+	return Cond{Expr: expr.String(), Args: args}
+}
+
+// And returns a condition that matches when all the conditions match.
+func (c Cond) And(others ...Cond) Cond { return c.join("and", others) }
+
+// Or returns a condition that matches when any of the conditions match.
+func (c Cond) Or(others ...Cond) Cond { return c.join("or", others) }
+
+// Not returns a condition that matches when c does not.
+func (c Cond) Not() Cond {
+	return Cond{Expr: "not (" + c.Expr + ")", Args: c.Args}
+}
+
+// SQL returns the condition as a where clause.
+func (c Cond) SQL() SQL {
+	return SQL{Query: "where " + c.Expr, Args: c.Args}
+}
+
+// Direction is the sort order used by OrderBy.
+type Direction string
+
+// Sort directions for OrderBy.
+const (
+	Asc  Direction = "asc"
+	Desc Direction = "desc"
+)
+
+// OrderBy generates an order by clause on the named document field.
+func OrderBy(name string, dir Direction) SQL {
+	return SQL{Query: "order by " + fieldExpr(name) + " " + string(dir)}
+}
+
+// OrderBy generates an order by clause on the field.
+func (f Field) OrderBy(dir Direction) SQL {
+	return SQL{Query: "order by " + f.expr + " " + string(dir)}
+}
+
+// Limit generates a limit clause. It should not be used with One, which
+// includes its own limit.
+func Limit(n int) SQL {
+	return SQL{Query: "limit ?", Args: []any{n}}
+}
+
+// Offset generates an offset clause. SQLite requires it to follow a Limit.
+func Offset(n int) SQL {
+	return SQL{Query: "offset ?", Args: []any{n}}
+}
+
+// In matches documents where the named field is one of the given values. Each
+// value is bound to its own placeholder. An empty list matches no documents.
+func In[V any](name string, values ...V) Cond {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return Where(name).In(args...)
+}
+
+// In matches documents where the field is one of the given values. Each value
+// is bound to its own placeholder. An empty list matches no documents.
+func (f Field) In(values ...any) Cond {
+	var expr strings.Builder
+	expr.WriteString(f.expr)
+	expr.WriteString(" in (")
+	for i := range values {
+		if i > 0 {
+			expr.WriteRune(',')
+		}
+		expr.WriteRune('?')
+	}
+	expr.WriteRune(')')
+	return Cond{Expr: expr.String(), Args: values}
+}
+
+// eachValue returns the SQL expression matching documents with an element in
+// the named array field satisfying the condition on value.
+func eachValue(name, cond string) string {
+	return "exists (select 1 from json_each(" + fieldJSONExpr(name) + ") where " + cond + ")"
+}
+
+// Contains matches documents where the named array field has an element equal
+// to v.
+func Contains(name string, v any) Cond {
+	return Cond{Expr: eachValue(name, "value = ?"), Args: []any{v}}
+}
+
+// AnyOf matches documents where the named array field has an element equal to
+// one of the given values. An empty list matches no documents.
+func AnyOf[V any](name string, values ...V) Cond {
+	var expr strings.Builder
+	expr.WriteString("value in (")
+	args := make([]any, len(values))
+	for i, v := range values {
+		if i > 0 {
+			expr.WriteRune(',')
+		}
+		expr.WriteRune('?')
+		args[i] = v
+	}
+	expr.WriteRune(')')
+	return Cond{Expr: eachValue(name, expr.String()), Args: args}
+}
+
+// AllOf matches documents where the named array field has an element equal to
+// each of the given values. An empty list matches all documents.
+func AllOf[V any](name string, values ...V) Cond {
+	if len(values) == 0 {
+		return Cond{Expr: "1"}
+	}
+	conds := make([]Cond, len(values))
+	for i, v := range values {
+		conds[i] = Contains(name, v)
+	}
+	return conds[0].And(conds[1:]...)
+}
+
+// ArrayLen matches documents where the named array field has n elements. A
+// missing field has no elements.
+func ArrayLen(name string, n int) Cond {
+	return Cond{
+		Expr: "coalesce(json_array_length(" + fieldJSONExpr(name) + "), 0) = ?",
+		Args: []any{n},
+	}
+}
+
+// TypedField is a document field holding values of type V, so conditions on it
+// are checked at compile time. The cmd/sqjdbgen tool generates them for the
+// fields of document types.
+type TypedField[V any] struct {
+	// Name is the JSON key of the field, or a path to a nested field.
+	Name string
+}
+
+// Where starts an untyped condition on the field.
+func (f TypedField[V]) Where() Field { return Where(f.Name) }
+
+// Eq matches documents where the field is equal to v.
+func (f TypedField[V]) Eq(v V) Cond { return f.Where().Eq(v) }
+
+// Ne matches documents where the field is not equal to v.
+func (f TypedField[V]) Ne(v V) Cond { return f.Where().Ne(v) }
+
+// Gt matches documents where the field is greater than v.
+func (f TypedField[V]) Gt(v V) Cond { return f.Where().Gt(v) }
+
+// Gte matches documents where the field is greater than or equal to v.
+func (f TypedField[V]) Gte(v V) Cond { return f.Where().Gte(v) }
+
+// Lt matches documents where the field is less than v.
+func (f TypedField[V]) Lt(v V) Cond { return f.Where().Lt(v) }
+
+// Lte matches documents where the field is less than or equal to v.
+func (f TypedField[V]) Lte(v V) Cond { return f.Where().Lte(v) }
+
+// In matches documents where the field is one of the given values.
+func (f TypedField[V]) In(values ...V) Cond { return In(f.Name, values...) }
+
+// IsNull matches documents where the field is null or missing.
+func (f TypedField[V]) IsNull() Cond { return f.Where().IsNull() }
+
+// IsNotNull matches documents where the field is present and not null.
+func (f TypedField[V]) IsNotNull() Cond { return f.Where().IsNotNull() }
+
+// OrderBy generates an order by clause on the field.
+func (f TypedField[V]) OrderBy(dir Direction) SQL { return OrderBy(f.Name, dir) }
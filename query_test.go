@@ -0,0 +1,229 @@
+package sqjdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+)
+
+func TestWhereAnd(t *testing.T) {
+	conn := newConn(t)
+	docs, err := jedis.All(conn,
+		sqjdb.Where("Age").Gt(40).And(sqjdb.Where("Name").Eq(luke.Name)).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 1)
+	ensure.DeepEqual(t, docs[0].ID, luke.ID)
+}
+
+func TestWhereOr(t *testing.T) {
+	conn := newConn(t)
+	docs, err := jedis.All(conn,
+		sqjdb.Where("Name").Eq(yoda.Name).Or(sqjdb.Where("Name").Eq(leia.Name)).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 2)
+}
+
+func TestWhereNot(t *testing.T) {
+	conn := newConn(t)
+	docs, err := jedis.All(conn, sqjdb.Where("Age").Lte(42).Not().SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 1)
+	ensure.DeepEqual(t, docs[0].ID, yoda.ID)
+}
+
+func TestWhereIsNull(t *testing.T) {
+	conn := newConn(t)
+	docs, err := jedis.All(conn, sqjdb.Where("Missing").IsNull().SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 3)
+}
+
+func TestOrderByLimitOffset(t *testing.T) {
+	conn := newConn(t)
+	docs, err := jedis.All(conn,
+		sqjdb.OrderBy("Name", sqjdb.Desc), sqjdb.Limit(2), sqjdb.Offset(1))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 2)
+	ensure.DeepEqual(t, docs[0].Name, luke.Name)
+	ensure.DeepEqual(t, docs[1].Name, leia.Name)
+}
+
+func TestOrderByAsc(t *testing.T) {
+	conn := newConn(t)
+	doc, err := jedis.One(conn, sqjdb.OrderBy("Age", sqjdb.Asc))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, doc.Age, 42)
+}
+
+func TestIn(t *testing.T) {
+	conn := newConn(t)
+	ids := []string{yoda.ID, leia.ID}
+	docs, err := jedis.All(conn, sqjdb.In("ID", ids...).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 2)
+}
+
+func TestInEmpty(t *testing.T) {
+	conn := newConn(t)
+	docs, err := jedis.All(conn, sqjdb.In[string]("ID").SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 0)
+}
+
+func TestInComposes(t *testing.T) {
+	conn := newConn(t)
+	docs, err := jedis.All(conn,
+		sqjdb.In("Age", 42, 980).And(sqjdb.Where("Name").Ne(yoda.Name)).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 2)
+}
+
+func TestWhereTime(t *testing.T) {
+	conn, starships := newStarships(t)
+	built := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, name := range []string{"falcon", "x-wing", "tie"} {
+		// Offsets differ from the query times, so text comparison would fail.
+		at := built.Add(time.Duration(i) * time.Hour).In(time.FixedZone("", 5*3600))
+		_, err := starships.Insert(conn, &Starship{Name: name, Built: at})
+		ensure.Nil(t, err)
+	}
+	names := func(cond sqjdb.Cond) []string {
+		docs, err := starships.All(conn, cond.SQL(), sqjdb.OrderBy("Name", sqjdb.Asc))
+		ensure.Nil(t, err)
+		var names []string
+		for _, doc := range docs {
+			names = append(names, doc.Name)
+		}
+		return names
+	}
+	ensure.DeepEqual(t, names(sqjdb.Where("Built").Before(built.Add(time.Hour))), []string{"falcon"})
+	ensure.DeepEqual(t, names(sqjdb.Where("Built").After(built)), []string{"tie", "x-wing"})
+	ensure.DeepEqual(t, names(sqjdb.Where("Built").Between(built.Add(time.Hour), built.Add(2*time.Hour))), []string{"tie", "x-wing"})
+
+	// Bound times match stored times with the same offset.
+	stored := built.In(time.FixedZone("", 5*3600))
+	ensure.DeepEqual(t, names(sqjdb.Where("Built").Eq(stored)), []string{"falcon"})
+}
+
+func newPadawans(t *testing.T) (*sqlite.Conn, sqjdb.Table[Padawan]) {
+	conn := newConn(t)
+	padawans := sqjdb.NewTable[Padawan](t.Name())
+	ensure.Nil(t, padawans.Migrate(conn))
+	for _, p := range []*Padawan{
+		{Name: "ahsoka", Sabers: []string{"green", "white"}},
+		{Name: "kanan", Sabers: []string{"blue"}},
+		{Name: "grogu"},
+	} {
+		_, err := padawans.Insert(conn, p)
+		ensure.Nil(t, err)
+	}
+	return conn, padawans
+}
+
+func padawanNames(t *testing.T, conn *sqlite.Conn, padawans *sqjdb.Table[Padawan], c sqjdb.Cond) []string {
+	docs, err := padawans.All(conn, c.SQL(), sqjdb.OrderBy("Name", sqjdb.Asc))
+	ensure.Nil(t, err)
+	var names []string
+	for _, doc := range docs {
+		names = append(names, doc.Name)
+	}
+	return names
+}
+
+func TestContains(t *testing.T) {
+	conn, padawans := newPadawans(t)
+	ensure.DeepEqual(t, padawanNames(t, conn, &padawans, sqjdb.Contains("Sabers", "white")),
+		[]string{"ahsoka"})
+	ensure.DeepEqual(t, padawanNames(t, conn, &padawans, sqjdb.Contains("Sabers", "red")),
+		[]string(nil))
+}
+
+func TestAnyOf(t *testing.T) {
+	conn, padawans := newPadawans(t)
+	ensure.DeepEqual(t, padawanNames(t, conn, &padawans, sqjdb.AnyOf("Sabers", "blue", "white")),
+		[]string{"ahsoka", "kanan"})
+	ensure.DeepEqual(t, padawanNames(t, conn, &padawans, sqjdb.AnyOf[string]("Sabers")),
+		[]string(nil))
+}
+
+func TestAllOf(t *testing.T) {
+	conn, padawans := newPadawans(t)
+	ensure.DeepEqual(t, padawanNames(t, conn, &padawans, sqjdb.AllOf("Sabers", "green", "white")),
+		[]string{"ahsoka"})
+	ensure.DeepEqual(t, padawanNames(t, conn, &padawans, sqjdb.AllOf("Sabers", "green", "blue")),
+		[]string(nil))
+	ensure.DeepEqual(t, len(padawanNames(t, conn, &padawans, sqjdb.AllOf[string]("Sabers"))), 3)
+}
+
+func TestArrayLen(t *testing.T) {
+	conn, padawans := newPadawans(t)
+	ensure.DeepEqual(t, padawanNames(t, conn, &padawans, sqjdb.ArrayLen("Sabers", 0)),
+		[]string{"grogu"})
+	ensure.DeepEqual(t, padawanNames(t, conn, &padawans, sqjdb.ArrayLen("Sabers", 1)),
+		[]string{"kanan"})
+}
+
+func TestWhereNested(t *testing.T) {
+	conn := newConn(t)
+	padawans := sqjdb.NewTable[Padawan](t.Name(),
+		sqjdb.Indexes(sqjdb.IndexSpec{Fields: []string{"Master.Name"}}))
+	ensure.Nil(t, padawans.Migrate(conn))
+	for _, p := range []*Padawan{
+		{Name: "ahsoka", Master: map[string]any{"Name": "anakin"}},
+		{Name: "anakin", Master: map[string]any{"Name": "obi-wan"}},
+		{Name: "grogu"},
+	} {
+		_, err := padawans.Insert(conn, p)
+		ensure.Nil(t, err)
+	}
+	for _, name := range []string{"Master.Name", "/Master/Name", "$.Master.Name"} {
+		ensure.DeepEqual(t, padawanNames(t, conn, &padawans, sqjdb.Where(name).Eq("anakin")),
+			[]string{"ahsoka"})
+	}
+	docs, err := padawans.All(conn, sqjdb.Where("Master.Name").IsNotNull().SQL(),
+		sqjdb.OrderBy("Master.Name", sqjdb.Desc))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docs[0].Name, "anakin")
+	ensure.True(t, usesIndex(t, conn, &padawans, t.Name()+"_Master_Name",
+		sqjdb.Where("Master.Name").Eq("anakin").SQL()))
+}
+
+func TestTypedField(t *testing.T) {
+	conn := newConn(t)
+	age := sqjdb.TypedField[int]{Name: "Age"}
+	name := sqjdb.TypedField[string]{Name: "Name"}
+	docs, err := jedis.All(conn, age.Gt(40).And(name.In(luke.Name, yoda.Name)).SQL(),
+		name.OrderBy(sqjdb.Desc))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 2)
+	ensure.DeepEqual(t, docs[0].ID, yoda.ID)
+}
+
+func TestWhereQuotedKey(t *testing.T) {
+	conn := newConn(t)
+	padawans := sqjdb.NewTable[Padawan](t.Name())
+	ensure.Nil(t, padawans.Migrate(conn))
+	_, err := padawans.Insert(conn, &Padawan{Name: "ahsoka", Master: map[string]any{
+		`a"b`: "quote",
+		`a\b`: "backslash",
+		"a":   map[string]any{"b": "nested"},
+	}})
+	ensure.Nil(t, err)
+
+	for path, value := range map[string]string{
+		`/Master/a"b`: "quote",
+		`/Master/a\b`: "backslash",
+		"/Master/a/b": "nested",
+	} {
+		n, err := padawans.Count(conn, sqjdb.Where(path).Eq(value).SQL())
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, n, 1, path)
+	}
+	// The quote does not end the key early and select the nested field.
+	n, err := padawans.Count(conn, sqjdb.Where(`/Master/a"."b`).Eq("nested").SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 0)
+}
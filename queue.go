@@ -0,0 +1,169 @@
+package sqjdb
+
+import (
+	"errors"
+	"time"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// Job states.
+const (
+	JobReady = "ready"
+	JobDead  = "dead"
+)
+
+// ErrLeaseLost is returned when acknowledging a job that has since been
+// claimed again, because its lease expired.
+var ErrLeaseLost = errors.New("sqjdb: job lease lost")
+
+// Job is a message in a Queue.
+type Job[T any] struct {
+	ID      string `json:",omitempty"`
+	Payload T
+	// State is JobReady, or JobDead once the job has used up its attempts.
+	State string
+	// Attempts is the number of times the job has been claimed.
+	Attempts int
+	// Due is the unix time in milliseconds after which the job can be claimed.
+	// Claiming a job moves it forward by the lease, so it becomes available
+	// again if it is not acknowledged in time.
+	Due int64
+	// LastError is the error from the last Nack.
+	LastError string `json:",omitempty"`
+	// Lease identifies the last claim of the job, and is checked by Ack, Nack
+	// and Requeue.
+	Lease string `json:",omitempty"`
+}
+
+// Queue is a durable job queue stored in a document table. Jobs are claimed
+// oldest due first, and are delivered at least once.
+type Queue[T any] struct {
+	Jobs   Table[Job[T]]
+	policy RetryPolicy
+}
+
+// NewQueue creates a Queue stored in the named table. The policy controls how
+// many times a job is attempted before it is moved to the dead letters, and
+// how long Nack delays the next attempt.
+func NewQueue[T any](name string, policy RetryPolicy) Queue[T] {
+	return Queue[T]{
+		Jobs: NewTable[Job[T]](name, Indexes(IndexSpec{
+			Fields: []string{"State", "Due"},
+		})),
+		policy: policy,
+	}
+}
+
+// Migrate creates the table for the queue.
+func (q *Queue[T]) Migrate(conn *sqlite.Conn) error {
+	return q.Jobs.Migrate(conn)
+}
+
+// Enqueue adds a job with the payload to the queue, which can be claimed after
+// the delay. Calling it within a transaction with WithTx only makes the job
+// visible once the transaction commits.
+func (q *Queue[T]) Enqueue(conn *sqlite.Conn, payload *T, delay time.Duration) (*Job[T], error) {
+	return q.Jobs.Insert(conn, &Job[T]{
+		Payload: *payload,
+		State:   JobReady,
+		Due:     time.Now().Add(delay).UnixMilli(),
+	})
+}
+
+// Claim returns the ready job that has been due the longest, and leases it for
+// the given duration, after which it can be claimed again. Jobs whose lease
+// expired on their last attempt are moved to the dead letters instead. It
+// returns ErrNoDoc if no jobs are due.
+func (q *Queue[T]) Claim(conn *sqlite.Conn, lease time.Duration) (job *Job[T], err error) {
+	now := time.Now()
+	err = WithTx(conn, func(conn *sqlite.Conn) error {
+		_, err := q.Jobs.PatchFields(conn, map[string]any{
+			"State":     JobDead,
+			"LastError": "sqjdb: lease expired on the last attempt",
+		}, Where("State").Eq(JobReady).And(
+			Where("Due").Lte(now.UnixMilli()),
+			Where("Attempts").Gte(q.maxAttempts()),
+		).SQL())
+		if err != nil {
+			return err
+		}
+		expr := SQL{
+			Query: "jsonb_set(data, '$.Attempts', " + fieldExpr("Attempts") + " + 1, '$.Due', ?, '$.Lease', ?)",
+			Args:  []any{now.Add(lease).UnixMilli(), ULID()},
+		}
+		jobs, err := q.Jobs.updateReturning(conn, expr, []SQL{{
+			Query: "where rowid = (select rowid from " + q.Jobs.Name +
+				" where " + fieldExpr("State") + " = ? and " + fieldExpr("Due") + " <= ?" +
+				" order by " + fieldExpr("Due") + " limit 1)",
+			Args: []any{JobReady, now.UnixMilli()},
+		}})
+		if err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			return ErrNoDoc
+		}
+		job = jobs[0]
+		return nil
+	})
+	return job, err
+}
+
+// maxAttempts is the number of times a job is claimed before it is dead.
+func (q *Queue[T]) maxAttempts() int {
+	return max(q.policy.Attempts, 1)
+}
+
+// leased selects the job as of its last claim.
+func (q *Queue[T]) leased(job *Job[T]) SQL {
+	return Where(q.Jobs.IDKey()).Eq(job.ID).And(Where("Lease").Eq(job.Lease)).SQL()
+}
+
+// Ack removes the completed job from the queue. It returns ErrLeaseLost if the
+// job has been claimed again.
+func (q *Queue[T]) Ack(conn *sqlite.Conn, job *Job[T]) error {
+	n, err := q.Jobs.Delete(conn, q.leased(job))
+	if err == nil && n == 0 {
+		err = ErrLeaseLost
+	}
+	return err
+}
+
+// Nack records the failure of the claimed job. It is retried after the backoff
+// of the RetryPolicy, or moved to the dead letters if it has used up its
+// attempts. It returns ErrLeaseLost if the job has been claimed again.
+func (q *Queue[T]) Nack(conn *sqlite.Conn, job *Job[T], cause error) error {
+	fields := map[string]any{"LastError": cause.Error()}
+	if job.Attempts >= q.maxAttempts() {
+		fields["State"] = JobDead
+	} else {
+		fields["Due"] = time.Now().Add(q.policy.delay(job.Attempts)).UnixMilli()
+	}
+	n, err := q.Jobs.PatchFields(conn, fields, q.leased(job))
+	if err == nil && n == 0 {
+		err = ErrLeaseLost
+	}
+	return err
+}
+
+// DeadLetters returns the jobs that used up their attempts, followed by the
+// given clauses, such as OrderBy and Limit.
+func (q *Queue[T]) DeadLetters(conn *sqlite.Conn, sqls ...SQL) ([]*Job[T], error) {
+	return q.Jobs.All(conn, append([]SQL{Where("State").Eq(JobDead).SQL()}, sqls...)...)
+}
+
+// Requeue makes the dead job ready to be claimed again, with its attempts
+// reset. It returns ErrLeaseLost if the job has been requeued and claimed
+// since.
+func (q *Queue[T]) Requeue(conn *sqlite.Conn, job *Job[T]) error {
+	n, err := q.Jobs.PatchFields(conn, map[string]any{
+		"State":    JobReady,
+		"Attempts": 0,
+		"Due":      time.Now().UnixMilli(),
+	}, q.leased(job))
+	if err == nil && n == 0 {
+		err = ErrLeaseLost
+	}
+	return err
+}
@@ -0,0 +1,134 @@
+package sqjdb_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+type Email struct {
+	To string
+}
+
+func TestQueue(t *testing.T) {
+	conn := newConn(t)
+	queue := sqjdb.NewQueue[Email]("emails", sqjdb.RetryPolicy{Attempts: 2})
+	ensure.Nil(t, queue.Migrate(conn))
+	ensure.True(t, usesIndex(t, conn, &queue.Jobs, "emails_State_Due",
+		sqjdb.Where("State").Eq(sqjdb.JobReady).And(sqjdb.Where("Due").Lte(0)).SQL()))
+
+	first, err := queue.Enqueue(conn, &Email{To: "yoda"}, 0)
+	ensure.Nil(t, err)
+	_, err = queue.Enqueue(conn, &Email{To: "luke"}, time.Hour)
+	ensure.Nil(t, err)
+	second, err := queue.Enqueue(conn, &Email{To: "leia"}, 0)
+	ensure.Nil(t, err)
+
+	job, err := queue.Claim(conn, time.Hour)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, job.ID, first.ID)
+	ensure.DeepEqual(t, job.Attempts, 1)
+	ensure.DeepEqual(t, job.Payload, Email{To: "yoda"})
+	ensure.Nil(t, queue.Ack(conn, job))
+
+	// A job whose lease expires can be claimed again.
+	job, err = queue.Claim(conn, 0)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, job.ID, second.ID)
+	job, err = queue.Claim(conn, time.Hour)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, job.ID, second.ID)
+	ensure.DeepEqual(t, job.Attempts, 2)
+	_, err = queue.Claim(conn, time.Hour)
+	ensure.True(t, errors.Is(err, sqjdb.ErrNoDoc))
+
+	// Failing the last attempt moves the job to the dead letters.
+	ensure.Nil(t, queue.Nack(conn, job, errors.New("bounced")))
+	dead, err := queue.DeadLetters(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(dead), 1)
+	ensure.DeepEqual(t, dead[0].LastError, "bounced")
+	_, err = queue.Claim(conn, time.Hour)
+	ensure.True(t, errors.Is(err, sqjdb.ErrNoDoc))
+
+	ensure.Nil(t, queue.Requeue(conn, dead[0]))
+	job, err = queue.Claim(conn, time.Hour)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, job.ID, second.ID)
+	ensure.DeepEqual(t, job.Attempts, 1)
+	ensure.DeepEqual(t, job.State, sqjdb.JobReady)
+}
+
+func TestQueueNackBackoff(t *testing.T) {
+	conn := newConn(t)
+	queue := sqjdb.NewQueue[Email]("emails", sqjdb.RetryPolicy{
+		Attempts:   5,
+		Backoff:    time.Minute,
+		MaxBackoff: 3 * time.Minute,
+	})
+	ensure.Nil(t, queue.Migrate(conn))
+	_, err := queue.Enqueue(conn, &Email{To: "yoda"}, 0)
+	ensure.Nil(t, err)
+	for _, expected := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		job, err := queue.Claim(conn, time.Hour)
+		ensure.Nil(t, err)
+		start := time.Now()
+		ensure.Nil(t, queue.Nack(conn, job, errors.New("failed")))
+		job, err = queue.Jobs.Get(conn, job.ID)
+		ensure.Nil(t, err)
+		delay := time.UnixMilli(job.Due).Sub(start)
+		ensure.True(t, delay > expected-time.Second && delay <= expected+time.Second, delay)
+		// Make the job due again.
+		_, err = queue.Jobs.PatchFields(conn, map[string]any{"Due": 0}, sqjdb.ByID(job.ID))
+		ensure.Nil(t, err)
+	}
+}
+
+func TestQueueLease(t *testing.T) {
+	conn := newConn(t)
+	queue := sqjdb.NewQueue[Email]("emails", sqjdb.RetryPolicy{Attempts: 3})
+	ensure.Nil(t, queue.Migrate(conn))
+	_, err := queue.Enqueue(conn, &Email{To: "yoda"}, 0)
+	ensure.Nil(t, err)
+
+	// The first lease expires and the job is claimed again, so only the second
+	// claim can acknowledge it.
+	stale, err := queue.Claim(conn, 0)
+	ensure.Nil(t, err)
+	job, err := queue.Claim(conn, time.Hour)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, job.ID, stale.ID)
+	ensure.NotDeepEqual(t, job.Lease, stale.Lease)
+	ensure.True(t, errors.Is(queue.Nack(conn, stale, errors.New("failed")), sqjdb.ErrLeaseLost))
+	ensure.True(t, errors.Is(queue.Ack(conn, stale), sqjdb.ErrLeaseLost))
+	ensure.Nil(t, queue.Ack(conn, job))
+	ensure.True(t, errors.Is(queue.Ack(conn, job), sqjdb.ErrLeaseLost))
+}
+
+func TestQueueLeaseExpiredOnLastAttempt(t *testing.T) {
+	conn := newConn(t)
+	queue := sqjdb.NewQueue[Email]("emails", sqjdb.RetryPolicy{Attempts: 1})
+	ensure.Nil(t, queue.Migrate(conn))
+	_, err := queue.Enqueue(conn, &Email{To: "yoda"}, 0)
+	ensure.Nil(t, err)
+
+	job, err := queue.Claim(conn, 0)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, job.Attempts, 1)
+	// The worker never acknowledged the job, which has no attempts left.
+	_, err = queue.Claim(conn, time.Hour)
+	ensure.True(t, errors.Is(err, sqjdb.ErrNoDoc))
+	dead, err := queue.DeadLetters(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(dead), 1)
+	ensure.DeepEqual(t, dead[0].Attempts, 1)
+	ensure.StringContains(t, dead[0].LastError, "lease expired")
+
+	ensure.Nil(t, queue.Requeue(conn, dead[0]))
+	_, err = queue.Claim(conn, time.Hour)
+	ensure.Nil(t, err)
+	ensure.True(t, errors.Is(queue.Requeue(conn, dead[0]), sqjdb.ErrLeaseLost))
+}
@@ -0,0 +1,136 @@
+package sqjdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// OneRaw is like One, but returns the document as stored, without decoding it.
+// SchemaVersion upgrades are not applied.
+func (t *Table[T]) OneRaw(conn *sqlite.Conn, sqls ...SQL) (json.RawMessage, error) {
+	docs, err := t.allRaw(conn, slices.Concat([]SQL{t.from()}, sqls, []SQL{{Query: "limit 1"}}))
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, ErrNoDoc
+	}
+	return docs[0], nil
+}
+
+// AllRaw is like All, but returns the documents as stored, without decoding
+// them. SchemaVersion upgrades are not applied.
+func (t *Table[T]) AllRaw(conn *sqlite.Conn, sqls ...SQL) ([]json.RawMessage, error) {
+	return t.allRaw(conn, slices.Concat([]SQL{t.from()}, sqls))
+}
+
+func (t *Table[T]) allRaw(conn *sqlite.Conn, sqls []SQL) (docs []json.RawMessage, err error) {
+	var query strings.Builder
+	query.WriteString("select json(data) from")
+	addSQLQuery(&query, sqls)
+	sp := t.trace(conn, "all", query.String(), sqls)
+	defer func() {
+		sp.addRows(len(docs))
+		err = sp.finish(err)
+	}()
+	stmt, err := prepareSQL(conn, query.String(), sqls)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseStmt(stmt)
+	for {
+		rowReturned, err := stmt.Step()
+		if err != nil {
+			return nil, err
+		}
+		if !rowReturned {
+			break
+		}
+		docs = append(docs, json.RawMessage(stmt.ColumnText(0)))
+	}
+	return docs, nil
+}
+
+// InsertRaw inserts a JSON document without decoding it, and returns the
+// document as stored. A missing string ID is generated, and the fields managed
+// by the table, such as the version and tenant, are set. Hooks and validation
+// are skipped, since they work on decoded documents.
+func (t *Table[T]) InsertRaw(conn *sqlite.Conn, doc json.RawMessage) (json.RawMessage, error) {
+	return t.insertRaw(conn, doc, SQL{})
+}
+
+// insertRaw is InsertRaw with an optional on conflict clause. It returns nil if
+// the clause skipped the document.
+func (t *Table[T]) insertRaw(conn *sqlite.Conn, doc json.RawMessage, conflict SQL) (_ json.RawMessage, err error) {
+	// jsonb_insert only sets missing fields, while jsonb_set overwrites them.
+	expr := SQL{Query: "jsonb(?)", Args: []any{string(doc)}}
+	set := func(fn, field string, value any) {
+		expr = SQL{
+			Query: fn + "(" + expr.Query + ", ?, ?)",
+			Args:  append(expr.Args, fieldPath(t.FieldName(field)), value),
+		}
+	}
+	var zero T
+	if f, ok := reflect.TypeOf(zero).FieldByName(t.opts.idField); ok && f.Type.Kind() == reflect.String {
+		set("jsonb_insert", t.opts.idKey, t.newID())
+	}
+	if t.opts.tenant != "" {
+		set("jsonb_set", t.opts.tenant, t.opts.tenantID)
+	}
+	if t.opts.version != "" {
+		set("jsonb_insert", t.opts.version, 1)
+	}
+	if t.opts.schemaVersion != "" {
+		set("jsonb_insert", t.opts.schemaVersion, t.currentSchemaVersion())
+	}
+	column := "data"
+	if t.opts.codec != nil {
+		column = "raw"
+		expr = SQL{
+			Query: codecFunc(t.Name, "encode") + "(json(" + expr.Query + "))",
+			Args:  expr.Args,
+		}
+	}
+	sqls := []SQL{
+		{Query: "insert into " + t.Name + " (" + column + ") values (" + expr.Query + ")", Args: expr.Args},
+		conflict,
+		{Query: "returning json(data)"},
+	}
+	var query strings.Builder
+	addSQLQuery(&query, sqls)
+	sp := t.trace(conn, "insert", query.String(), sqls)
+	defer func() { err = sp.finish(err) }()
+	stmt, err := prepareSQL(conn, query.String(), sqls)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseStmt(stmt)
+	rowReturned, err := stmt.Step()
+	if err != nil {
+		return nil, t.writeError(fmt.Errorf("sqjdb: inserting document in %q: %w", t.Name, err))
+	}
+	if !rowReturned {
+		return nil, nil
+	}
+	sp.addRows(1)
+	stored := json.RawMessage(stmt.ColumnText(0))
+	// The insert is only complete once the statement is reset.
+	if err := stmt.Reset(); err != nil {
+		return nil, t.writeError(fmt.Errorf("sqjdb: inserting document in %q: %w", t.Name, err))
+	}
+	if t.hooks != nil && t.hooks.notifier != nil {
+		doc := new(T)
+		if _, err := t.decode(stored, doc); err != nil {
+			return nil, err
+		}
+		if err := t.notify(conn, HookEvent[T]{Op: HookInsert, Doc: doc, N: 1}); err != nil {
+			return nil, err
+		}
+	}
+	return stored, nil
+}
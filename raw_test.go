@@ -0,0 +1,46 @@
+package sqjdb_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestOneRaw(t *testing.T) {
+	conn := newConn(t)
+	raw, err := jedis.OneRaw(conn, sqjdb.ByID(yoda.ID))
+	ensure.Nil(t, err)
+	var doc Jedi
+	ensure.Nil(t, json.Unmarshal(raw, &doc))
+	ensure.DeepEqual(t, doc, yoda)
+	_, err = jedis.OneRaw(conn, sqjdb.ByID("missing"))
+	ensure.DeepEqual(t, err, sqjdb.ErrNoDoc)
+}
+
+func TestAllRaw(t *testing.T) {
+	conn := newConn(t)
+	docs, err := jedis.AllRaw(conn, sqjdb.Where("Age").Eq(42).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 2)
+}
+
+func TestInsertRaw(t *testing.T) {
+	conn := newConn(t)
+	versioned := sqjdb.NewTable[Doc](t.Name(), sqjdb.Versioned("Version"))
+	ensure.Nil(t, versioned.Migrate(conn))
+	raw, err := versioned.InsertRaw(conn, json.RawMessage(`{"Name":"raw","Extra":true}`))
+	ensure.Nil(t, err)
+	var stored map[string]any
+	ensure.Nil(t, json.Unmarshal(raw, &stored))
+	ensure.NotDeepEqual(t, stored["ID"], nil)
+	ensure.DeepEqual(t, stored["Version"], float64(1))
+	ensure.DeepEqual(t, stored["Extra"], true)
+
+	raw, err = versioned.InsertRaw(conn, json.RawMessage(`{"ID":"given"}`))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, string(raw), `{"ID":"given","Version":1}`)
+	_, err = versioned.InsertRaw(conn, json.RawMessage(`{"ID":"given"}`))
+	ensure.NotNil(t, err)
+}
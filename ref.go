@@ -0,0 +1,63 @@
+package sqjdb
+
+import (
+	"encoding/json"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// Ref is a reference to a document in another table. Only the ID is stored,
+// as a JSON string, and Populate loads the referenced documents. It implements
+// encoding.TextMarshaler, so it can be used in conditions.
+type Ref[T any] struct {
+	ID string
+	// Doc is the referenced document, set by Populate.
+	Doc *T
+}
+
+// RefTo returns a reference to the document with the given ID.
+func RefTo[T any](id string) Ref[T] {
+	return Ref[T]{ID: id}
+}
+
+func (r Ref[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.ID)
+}
+
+func (r *Ref[T]) UnmarshalJSON(data []byte) error {
+	r.Doc = nil
+	return json.Unmarshal(data, &r.ID)
+}
+
+func (r Ref[T]) MarshalText() ([]byte, error) {
+	return []byte(r.ID), nil
+}
+
+// Populate loads the documents referenced by docs from the table in a single
+// query, and sets them on the references. The ref function returns the
+// reference to populate for a document. References to missing documents are
+// left with a nil Doc.
+func Populate[D, T any](conn *sqlite.Conn, t *Table[T], docs []*D, ref func(*D) *Ref[T]) error {
+	var ids []any
+	seen := map[string]bool{}
+	for _, doc := range docs {
+		if id := ref(doc).ID; id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	byID, err := t.GetMany(conn, ids...)
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		r := ref(doc)
+		if r.ID != "" {
+			r.Doc = byID[r.ID]
+		}
+	}
+	return nil
+}
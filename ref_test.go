@@ -0,0 +1,45 @@
+package sqjdb_test
+
+import (
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+type Mission struct {
+	ID      string
+	Pilot   sqjdb.Ref[Jedi]
+	Copilot sqjdb.Ref[Jedi]
+}
+
+func TestPopulate(t *testing.T) {
+	conn := newConn(t)
+	missions := sqjdb.NewTable[Mission](t.Name())
+	ensure.Nil(t, missions.Migrate(conn))
+	for _, m := range []*Mission{
+		{Pilot: sqjdb.RefTo[Jedi](luke.ID), Copilot: sqjdb.RefTo[Jedi](leia.ID)},
+		{Pilot: sqjdb.RefTo[Jedi](luke.ID), Copilot: sqjdb.RefTo[Jedi]("missing")},
+		{Pilot: sqjdb.RefTo[Jedi](yoda.ID)},
+	} {
+		_, err := missions.Insert(conn, m)
+		ensure.Nil(t, err)
+	}
+	docs, err := missions.All(conn, sqjdb.Where("Pilot").Eq(sqjdb.RefTo[Jedi](luke.ID)).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 2)
+
+	docs, err = missions.All(conn)
+	ensure.Nil(t, err)
+	ensure.Nil(t, sqjdb.Populate(conn, &jedis, docs, func(m *Mission) *sqjdb.Ref[Jedi] {
+		return &m.Pilot
+	}))
+	ensure.Nil(t, sqjdb.Populate(conn, &jedis, docs, func(m *Mission) *sqjdb.Ref[Jedi] {
+		return &m.Copilot
+	}))
+	ensure.DeepEqual(t, *docs[0].Pilot.Doc, luke)
+	ensure.DeepEqual(t, *docs[0].Copilot.Doc, leia)
+	ensure.DeepEqual(t, docs[1].Pilot.Doc, docs[0].Pilot.Doc)
+	ensure.True(t, docs[1].Copilot.Doc == nil)
+	ensure.DeepEqual(t, *docs[2].Pilot.Doc, yoda)
+}
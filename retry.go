@@ -0,0 +1,71 @@
+package sqjdb
+
+import (
+	"context"
+	"time"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// RetryPolicy configures retrying operations that fail because the database is
+// busy or locked by another connection.
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts, including the first.
+	Attempts int
+	// Backoff is the delay before the first retry, and is doubled for each
+	// subsequent retry.
+	Backoff time.Duration
+	// MaxBackoff limits the delay between retries, if set.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is a reasonable RetryPolicy for multi-process writers.
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:   5,
+	Backoff:    10 * time.Millisecond,
+	MaxBackoff: time.Second,
+}
+
+// IsBusy reports whether the error is a transient SQLITE_BUSY or SQLITE_LOCKED
+// error, in which case the operation can be retried.
+func IsBusy(err error) bool {
+	code := sqlite.ErrCode(err).ToPrimary()
+	return code == sqlite.ResultBusy || code == sqlite.ResultLocked
+}
+
+// Retry calls fn until it returns an error for which IsBusy is false, or the
+// policy runs out of attempts, or the context is done. fn must be safe to
+// repeat, so multi-step operations should use WithTx within it.
+func Retry(ctx context.Context, policy RetryPolicy, fn func() error) error {
+	backoff := policy.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !IsBusy(err) || attempt >= policy.Attempts {
+			return err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+		if policy.MaxBackoff > 0 {
+			backoff = min(backoff, policy.MaxBackoff)
+		}
+	}
+}
+
+// delay returns the backoff before the next attempt after the given number of
+// failed attempts.
+func (p RetryPolicy) delay(attempts int) time.Duration {
+	backoff := p.Backoff
+	for range attempts - 1 {
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return backoff
+}
@@ -0,0 +1,82 @@
+package sqjdb_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// newLockedConns returns two connections to a file database, the second of
+// which holds the write lock. The first does not wait for locks.
+func newLockedConns(t *testing.T) (*sqlite.Conn, *sqlite.Conn) {
+	path := filepath.Join(t.TempDir(), "db")
+	conn, err := sqlite.OpenConn(path)
+	ensure.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+	conn.SetBusyTimeout(0)
+	ensure.Nil(t, jedis.Migrate(conn))
+	other, err := sqlite.OpenConn(path)
+	ensure.Nil(t, err)
+	t.Cleanup(func() { other.Close() })
+	ensure.Nil(t, sqlitex.Execute(other, "begin immediate", nil))
+	return conn, other
+}
+
+func TestRetry(t *testing.T) {
+	conn, other := newLockedConns(t)
+	_, err := jedis.Insert(other, &Jedi{Name: "grogu"})
+	ensure.Nil(t, err)
+
+	_, err = jedis.Insert(conn, &Jedi{Name: "din"})
+	ensure.True(t, sqjdb.IsBusy(err), err)
+
+	attempts := 0
+	policy := sqjdb.RetryPolicy{Attempts: 100, Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+	err = sqjdb.Retry(context.Background(), policy, func() error {
+		attempts++
+		if attempts == 3 {
+			ensure.Nil(t, sqlitex.Execute(other, "commit", nil))
+		}
+		_, err := jedis.Insert(conn, &Jedi{Name: "din"})
+		return err
+	})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, attempts, 3)
+	n, err := jedis.Count(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 2)
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	conn, other := newLockedConns(t)
+	_, err := jedis.Insert(other, &Jedi{Name: "grogu"})
+	ensure.Nil(t, err)
+
+	attempts := 0
+	policy := sqjdb.RetryPolicy{Attempts: 3, Backoff: time.Millisecond}
+	err = sqjdb.Retry(context.Background(), policy, func() error {
+		attempts++
+		_, err := jedis.Insert(conn, &Jedi{Name: "din"})
+		return err
+	})
+	ensure.True(t, sqjdb.IsBusy(err), err)
+	ensure.DeepEqual(t, attempts, 3)
+}
+
+func TestRetryOtherError(t *testing.T) {
+	errNope := errors.New("nope")
+	attempts := 0
+	err := sqjdb.Retry(context.Background(), sqjdb.DefaultRetryPolicy, func() error {
+		attempts++
+		return errNope
+	})
+	ensure.DeepEqual(t, err, errNope)
+	ensure.DeepEqual(t, attempts, 1)
+}
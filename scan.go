@@ -0,0 +1,149 @@
+package sqjdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// Query runs the query with the arguments bound with Bind, and decodes each row
+// into R. A single column containing a JSON object or array, such as
+// json(data), is decoded as is. Otherwise a struct R is decoded from the
+// columns by name, following the json tags, and any other R is decoded from
+// the single column.
+func Query[R any](conn *sqlite.Conn, query string, args ...any) ([]R, error) {
+	stmt, err := prepareSQL(conn, query, []SQL{{Args: args}})
+	if err != nil {
+		return nil, err
+	}
+	defer releaseStmt(stmt)
+	isStruct := reflect.TypeFor[R]().Kind() == reflect.Struct
+	var rows []R
+	for {
+		rowReturned, err := stmt.Step()
+		if err != nil {
+			return nil, err
+		}
+		if !rowReturned {
+			break
+		}
+		var jsonS []byte
+		if stmt.ColumnCount() == 1 && (!isStruct || isJSONContainer(stmt, 0)) {
+			jsonS = columnJSON(stmt, 0)
+		} else {
+			jsonS = append(jsonS, '{')
+			for i := range stmt.ColumnCount() {
+				if i > 0 {
+					jsonS = append(jsonS, ',')
+				}
+				jsonS = strconv.AppendQuote(jsonS, stmt.ColumnName(i))
+				jsonS = append(jsonS, ':')
+				jsonS = append(jsonS, columnJSON(stmt, i)...)
+			}
+			jsonS = append(jsonS, '}')
+		}
+		var v R
+		if err := json.Unmarshal(jsonS, &v); err != nil {
+			return nil, fmt.Errorf("sqjdb: decoding row: %w\n%s", err, jsonS)
+		}
+		rows = append(rows, v)
+	}
+	return rows, nil
+}
+
+// QueryScalar runs the query with the arguments bound with Bind, and decodes
+// the first column of the first row into V. It returns ErrNoDoc if there are
+// no rows. A NULL value decodes to the zero value, so use a pointer V to tell
+// it apart. Text is decoded as is into strings, and as JSON otherwise, like a
+// single column in Query.
+func QueryScalar[V any](conn *sqlite.Conn, query string, args ...any) (V, error) {
+	var v V
+	stmt, err := prepareSQL(conn, query, []SQL{{Args: args}})
+	if err != nil {
+		return v, err
+	}
+	defer releaseStmt(stmt)
+	rowReturned, err := stmt.Step()
+	if err != nil {
+		return v, err
+	}
+	if !rowReturned {
+		return v, ErrNoDoc
+	}
+	switch p := any(&v).(type) {
+	case *string:
+		*p = stmt.ColumnText(0)
+	case *int64:
+		*p = stmt.ColumnInt64(0)
+	case *int:
+		*p = stmt.ColumnInt(0)
+	case *float64:
+		*p = stmt.ColumnFloat(0)
+	case *bool:
+		*p = stmt.ColumnBool(0)
+	default:
+		jsonS := columnJSON(stmt, 0)
+		if err := json.Unmarshal(jsonS, p); err != nil {
+			return v, fmt.Errorf("sqjdb: decoding value: %w\n%s", err, jsonS)
+		}
+	}
+	return v, nil
+}
+
+// isJSONContainer reports if the column is text containing a JSON object or
+// array.
+func isJSONContainer(stmt *sqlite.Stmt, i int) bool {
+	if stmt.ColumnType(i) != sqlite.TypeText {
+		return false
+	}
+	text := bytes.TrimSpace([]byte(stmt.ColumnText(i)))
+	return len(text) > 0 && (text[0] == '{' || text[0] == '[') && json.Valid(text)
+}
+
+// columnValue returns the column value as the Go type of its storage class,
+// int64, float64, string, []byte or nil, so it binds back as the same type.
+func columnValue(stmt *sqlite.Stmt, i int) any {
+	switch stmt.ColumnType(i) {
+	case sqlite.TypeInteger:
+		return stmt.ColumnInt64(i)
+	case sqlite.TypeFloat:
+		return stmt.ColumnFloat(i)
+	case sqlite.TypeText:
+		return stmt.ColumnText(i)
+	case sqlite.TypeBlob:
+		blob := make([]byte, stmt.ColumnLen(i))
+		stmt.ColumnBytes(i, blob)
+		return blob
+	default:
+		return nil
+	}
+}
+
+// columnJSON encodes the column value as JSON. Text containing a JSON object
+// or array is included as is.
+func columnJSON(stmt *sqlite.Stmt, i int) []byte {
+	switch stmt.ColumnType(i) {
+	case sqlite.TypeInteger:
+		return strconv.AppendInt(nil, stmt.ColumnInt64(i), 10)
+	case sqlite.TypeFloat:
+		jsonS, _ := json.Marshal(stmt.ColumnFloat(i))
+		return jsonS
+	case sqlite.TypeText:
+		if isJSONContainer(stmt, i) {
+			return []byte(stmt.ColumnText(i))
+		}
+		jsonS, _ := json.Marshal(stmt.ColumnText(i))
+		return jsonS
+	case sqlite.TypeBlob:
+		blob := make([]byte, stmt.ColumnLen(i))
+		stmt.ColumnBytes(i, blob)
+		jsonS, _ := json.Marshal(blob)
+		return jsonS
+	default:
+		return []byte("null")
+	}
+}
@@ -0,0 +1,68 @@
+package sqjdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestQueryDocuments(t *testing.T) {
+	conn := newConn(t)
+	docs, err := sqjdb.Query[Jedi](conn,
+		"select json(data) from jedis where data->>'Age' = ? order by data->>'Name'", 42)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docs, []Jedi{leia, luke})
+}
+
+func TestQueryScalar(t *testing.T) {
+	conn := newConn(t)
+	names, err := sqjdb.Query[string](conn, "select data->>'Name' from jedis order by 1")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, names, []string{"leia", "luke", "yoda"})
+	counts, err := sqjdb.Query[int](conn, "select count(*) from jedis")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, counts, []int{3})
+}
+
+func TestQueryColumns(t *testing.T) {
+	conn := newConn(t)
+	type row struct {
+		Name  string `json:"name"`
+		Age   int
+		Score *float64
+	}
+	rows, err := sqjdb.Query[row](conn,
+		"select data->>'Name' as name, data->>'Age' as age, null as score from jedis where data->>'ID' = ?",
+		yoda.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, rows, []row{{Name: yoda.Name, Age: yoda.Age}})
+	single, err := sqjdb.Query[row](conn, "select count(*) as age from jedis")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, single, []row{{Age: 3}})
+}
+
+func TestQueryScalarValue(t *testing.T) {
+	conn := newConn(t)
+	count, err := sqjdb.QueryScalar[int](conn, "select count(*) from jedis where data->>'Age' = ?", 42)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, count, 2)
+	name, err := sqjdb.QueryScalar[string](conn, "select max(data->>'Name') from jedis")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, name, "yoda")
+	doc, err := sqjdb.QueryScalar[string](conn, "select json(data) from jedis where data->>'Name' = ?", "yoda")
+	ensure.Nil(t, err)
+	ensure.StringContains(t, doc, `"Name":"yoda"`)
+	jedi, err := sqjdb.QueryScalar[Jedi](conn, "select json(data) from jedis where data->>'Name' = ?", "yoda")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, jedi, yoda)
+	fk, err := sqjdb.QueryScalar[bool](conn, "pragma foreign_keys")
+	ensure.Nil(t, err)
+	ensure.False(t, fk)
+	oldest, err := sqjdb.QueryScalar[*int](conn, "select max(data->>'Age') from jedis where data->>'Age' > 1000")
+	ensure.Nil(t, err)
+	ensure.True(t, oldest == nil)
+	_, err = sqjdb.QueryScalar[int](conn, "select 1 where 0")
+	ensure.True(t, errors.Is(err, sqjdb.ErrNoDoc))
+}
@@ -0,0 +1,220 @@
+package sqjdb
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Search compiles user-facing search queries, such as
+//
+//	age>40 name:luke* tag:jedi -rank:master "light saber"
+//
+// into conditions. Terms are separated by spaces, and must all match. A term
+// is key:value for equality, or a prefix match if the value ends in *, or uses
+// one of the >, >=, < and <= comparisons. Values are numbers, true or false
+// unless quoted, and a leading - negates the term. Words without a key match
+// any of the Text fields.
+//
+// Equality, prefix and comparison terms on regular fields can use indexes.
+// Prefix terms are case sensitive, since they compile to a range of strings,
+// while words are matched case insensitively anywhere in the Text fields,
+// which scans them.
+type Search struct {
+	// Fields maps the keys allowed in queries, which are matched case
+	// insensitively, to document fields. Other keys are rejected.
+	Fields map[string]string
+	// Arrays lists the document fields that are arrays, which match if any
+	// element does.
+	Arrays []string
+	// Text lists the document fields matched by words without a key. Words are
+	// rejected if it is empty.
+	Text []string
+}
+
+// searchTerm is a parsed term of a search query.
+type searchTerm struct {
+	negate bool
+	key    string
+	op     string
+	value  string
+	quoted bool
+}
+
+// Parse compiles the query into a condition. An empty query matches all
+// documents.
+func (s *Search) Parse(query string) (Cond, error) {
+	terms, err := parseSearch(query)
+	if err != nil {
+		return Cond{}, err
+	}
+	conds := make([]Cond, 0, len(terms))
+	for _, term := range terms {
+		cond, err := s.term(term)
+		if err != nil {
+			return Cond{}, err
+		}
+		if term.negate {
+			// Documents missing the field match negated terms.
+			cond = Cond{Expr: "not coalesce(" + cond.Expr + ", 0)", Args: cond.Args}
+		}
+		conds = append(conds, cond)
+	}
+	return allOf(conds), nil
+}
+
+// term compiles a single term.
+func (s *Search) term(term searchTerm) (Cond, error) {
+	if term.key == "" {
+		if len(s.Text) == 0 {
+			return Cond{}, fmt.Errorf("sqjdb: search term %q has no field", term.value)
+		}
+		pattern := "%" + escapeLike(term.value) + "%"
+		conds := make([]Cond, len(s.Text))
+		for i, name := range s.Text {
+			f := Where(name)
+			conds[i] = Cond{Expr: f.expr + ` like ? escape '\'`, Args: []any{pattern}}
+		}
+		return conds[0].Or(conds[1:]...), nil
+	}
+	var name string
+	for key, field := range s.Fields {
+		if strings.EqualFold(key, term.key) {
+			name = field
+			break
+		}
+	}
+	if name == "" {
+		return Cond{}, fmt.Errorf("sqjdb: unknown search field %q", term.key)
+	}
+	value := searchValue(term)
+	prefix, isPrefix := "", false
+	if v, ok := value.(string); ok && term.op == ":" && !term.quoted && strings.HasSuffix(v, "*") {
+		prefix, isPrefix = strings.TrimSuffix(v, "*"), true
+	}
+	if slices.Contains(s.Arrays, name) {
+		switch {
+		case isPrefix:
+			r := prefixRange("value", prefix)
+			return Cond{Expr: eachValue(name, r.Expr), Args: r.Args}, nil
+		case term.op == ":":
+			return Contains(name, value), nil
+		}
+		return Cond{Expr: eachValue(name, "value "+term.op+" ?"), Args: []any{value}}, nil
+	}
+	f := Where(name)
+	switch {
+	case isPrefix:
+		return prefixRange(f.expr, prefix), nil
+	case term.op == ":":
+		return f.Eq(value), nil
+	}
+	return f.op(term.op, value), nil
+}
+
+// searchValue returns the value of the term, parsing unquoted numbers and
+// booleans.
+func searchValue(term searchTerm) any {
+	if term.quoted {
+		return term.value
+	}
+	if n, err := strconv.ParseInt(term.value, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(term.value, 64); err == nil {
+		return f
+	}
+	switch term.value {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return term.value
+}
+
+// parseSearch splits the query into terms.
+func parseSearch(query string) ([]searchTerm, error) {
+	var terms []searchTerm
+	i := 0
+	for {
+		for i < len(query) && (query[i] == ' ' || query[i] == '\t') {
+			i++
+		}
+		if i == len(query) {
+			return terms, nil
+		}
+		var term searchTerm
+		if query[i] == '-' {
+			term.negate = true
+			i++
+		}
+		if i < len(query) && query[i] != '"' {
+			end := i
+			for end < len(query) && !strings.ContainsRune(" \t:<>\"", rune(query[end])) {
+				end++
+			}
+			if end < len(query) && (query[end] == ':' || query[end] == '<' || query[end] == '>') {
+				if end == i {
+					return nil, fmt.Errorf("sqjdb: invalid search at %d: missing field", i)
+				}
+				term.key = query[i:end]
+				term.op = query[end : end+1]
+				i = end + 1
+				if term.op != ":" && i < len(query) && query[i] == '=' {
+					term.op += "="
+					i++
+				}
+			}
+		}
+		value, quoted, next, err := searchWord(query, i)
+		if err != nil {
+			return nil, err
+		}
+		if value == "" && !quoted {
+			return nil, fmt.Errorf("sqjdb: invalid search at %d: missing value", i)
+		}
+		term.value, term.quoted, i = value, quoted, next
+		terms = append(terms, term)
+	}
+}
+
+// searchWord reads a word or a quoted phrase starting at i, and returns it
+// along with the position following it.
+func searchWord(query string, i int) (string, bool, int, error) {
+	if i < len(query) && query[i] == '"' {
+		end := strings.IndexByte(query[i+1:], '"')
+		if end < 0 {
+			return "", false, 0, fmt.Errorf("sqjdb: invalid search at %d: unterminated quote", i)
+		}
+		return query[i+1 : i+1+end], true, i + end + 2, nil
+	}
+	end := i
+	for end < len(query) && query[end] != ' ' && query[end] != '\t' {
+		end++
+	}
+	return query[i:end], false, end, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s, using \ as the escape character.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// prefixRange matches strings in expr starting with prefix, as a range so an
+// index on expr can be used.
+func prefixRange(expr, prefix string) Cond {
+	b := []byte(prefix)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 0xff {
+			b[i]++
+			return Cond{
+				Expr: expr + " >= ? and " + expr + " < ?",
+				Args: []any{prefix, string(b[:i+1])},
+			}
+		}
+	}
+	// Numbers sort before strings.
+	return Cond{Expr: expr + " >= ?", Args: []any{prefix}}
+}
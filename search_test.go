@@ -0,0 +1,75 @@
+package sqjdb_test
+
+import (
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestSearch(t *testing.T) {
+	conn := newConn(t)
+	search := sqjdb.Search{
+		Fields: map[string]string{"name": "Name", "age": "Age"},
+		Text:   []string{"Name"},
+	}
+	cases := []struct {
+		query string
+		ids   []string
+	}{
+		{``, []string{leia.ID, luke.ID, yoda.ID}},
+		{`age>40 name:l*`, []string{leia.ID, luke.ID}},
+		{`Age>=980`, []string{yoda.ID}},
+		{`age<=42 -name:leia`, []string{luke.ID}},
+		{`name:"luke"`, []string{luke.ID}},
+		{`name:lu`, nil},
+		{`OD`, []string{yoda.ID}},
+	}
+	for _, c := range cases {
+		cond, err := search.Parse(c.query)
+		ensure.Nil(t, err, c.query)
+		docs, err := jedis.All(conn, cond.SQL(), sqjdb.OrderBy("Name", sqjdb.Asc))
+		ensure.Nil(t, err, c.query)
+		var ids []string
+		for _, doc := range docs {
+			ids = append(ids, doc.ID)
+		}
+		ensure.DeepEqual(t, ids, c.ids, c.query)
+	}
+}
+
+func TestSearchArrays(t *testing.T) {
+	conn, padawans := newPadawans(t)
+	search := sqjdb.Search{
+		Fields: map[string]string{"saber": "Sabers", "name": "Name"},
+		Arrays: []string{"Sabers"},
+	}
+	for query, names := range map[string][]string{
+		`saber:white`:         {"ahsoka"},
+		`saber:b*`:            {"kanan"},
+		`-saber:blue`:         {"ahsoka", "grogu"},
+		`saber:green name:a*`: {"ahsoka"},
+	} {
+		cond, err := search.Parse(query)
+		ensure.Nil(t, err, query)
+		ensure.DeepEqual(t, padawanNames(t, conn, &padawans, cond), names, query)
+	}
+}
+
+func TestSearchIndexed(t *testing.T) {
+	conn := newConn(t)
+	indexed := sqjdb.NewTable[Jedi](t.Name(), sqjdb.Indexes(sqjdb.IndexSpec{Fields: []string{"Name"}}))
+	ensure.Nil(t, indexed.Migrate(conn))
+	search := sqjdb.Search{Fields: map[string]string{"name": "Name"}}
+	cond, err := search.Parse(`name:lu*`)
+	ensure.Nil(t, err)
+	ensure.True(t, usesIndex(t, conn, &indexed, t.Name()+"_Name", cond.SQL()))
+}
+
+func TestSearchInvalid(t *testing.T) {
+	search := sqjdb.Search{Fields: map[string]string{"name": "Name"}}
+	for _, query := range []string{`luke`, `rank:x`, `name:`, `:luke`, `name:"luke`, `-`} {
+		_, err := search.Parse(query)
+		ensure.NotNil(t, err, query)
+	}
+}
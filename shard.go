@@ -0,0 +1,132 @@
+package sqjdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sync"
+
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// Shards spreads the documents of a table across multiple databases, one pool
+// per database, routing each document by a hash of its ID. Operations on a
+// single document go to its shard, while All, Count and Delete fan out to all
+// shards concurrently. Use Table.WithShards to create one. The number and order
+// of pools must not change once documents are stored.
+type Shards[T any] struct {
+	Table *Table[T]
+	Pools []*sqlitex.Pool
+}
+
+// WithShards returns Shards that spread documents across the given pools.
+func (t *Table[T]) WithShards(pools ...*sqlitex.Pool) *Shards[T] {
+	return &Shards[T]{Table: t, Pools: pools}
+}
+
+// Shard returns the PoolTable for the shard containing the document with the
+// given ID. It can be used for operations not directly provided by Shards.
+func (s *Shards[T]) Shard(id any) *PoolTable[T] {
+	h := fnv.New32a()
+	fmt.Fprint(h, id)
+	return s.Table.WithPool(s.Pools[h.Sum32()%uint32(len(s.Pools))])
+}
+
+// fanOut calls fn on every shard concurrently, returning the results in shard
+// order.
+func fanOut[T, R any](s *Shards[T], fn func(p *PoolTable[T]) (R, error)) ([]R, error) {
+	results := make([]R, len(s.Pools))
+	errs := make([]error, len(s.Pools))
+	var wg sync.WaitGroup
+	for i, pool := range s.Pools {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = fn(s.Table.WithPool(pool))
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Migrate runs Table.Migrate on every shard.
+func (s *Shards[T]) Migrate(ctx context.Context) error {
+	_, err := fanOut(s, func(p *PoolTable[T]) (struct{}, error) {
+		return struct{}{}, p.Migrate(ctx)
+	})
+	return err
+}
+
+// Insert inserts the document into its shard. IDs are generated as they are
+// for Table.Insert, before the shard is chosen.
+func (s *Shards[T]) Insert(ctx context.Context, doc *T) (*T, error) {
+	doc, err := s.Table.withID(doc)
+	if err != nil {
+		return nil, err
+	}
+	return s.Shard(s.Table.docID(doc)).Insert(ctx, doc)
+}
+
+// Upsert upserts the document into its shard.
+func (s *Shards[T]) Upsert(ctx context.Context, doc *T) (*T, error) {
+	doc, err := s.Table.withID(doc)
+	if err != nil {
+		return nil, err
+	}
+	return s.Shard(s.Table.docID(doc)).Upsert(ctx, doc)
+}
+
+// Get returns the document with the given ID from its shard.
+func (s *Shards[T]) Get(ctx context.Context, id any) (*T, error) {
+	return s.Shard(id).Get(ctx, id)
+}
+
+// All returns all documents per the given query from every shard. The query
+// is applied to each shard separately, so ordering and limits apply per shard
+// and the results are concatenated in shard order.
+func (s *Shards[T]) All(ctx context.Context, sqls ...SQL) ([]*T, error) {
+	docs, err := fanOut(s, func(p *PoolTable[T]) ([]*T, error) {
+		return p.All(ctx, sqls...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return slices.Concat(docs...), nil
+}
+
+// Count returns the number of documents per the given query across every
+// shard.
+func (s *Shards[T]) Count(ctx context.Context, sqls ...SQL) (int, error) {
+	counts, err := fanOut(s, func(p *PoolTable[T]) (int, error) {
+		return p.Count(ctx, sqls...)
+	})
+	if err != nil {
+		return 0, err
+	}
+	var total int
+	for _, n := range counts {
+		total += n
+	}
+	return total, nil
+}
+
+// Delete deletes documents per the given query from every shard. It returns
+// the total number of documents deleted.
+func (s *Shards[T]) Delete(ctx context.Context, sqls ...SQL) (int, error) {
+	counts, err := fanOut(s, func(p *PoolTable[T]) (int, error) {
+		return p.Delete(ctx, sqls...)
+	})
+	if err != nil {
+		return 0, err
+	}
+	var total int
+	for _, n := range counts {
+		total += n
+	}
+	return total, nil
+}
@@ -0,0 +1,61 @@
+package sqjdb_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+func newShards(t *testing.T, n int) *sqjdb.Shards[Jedi] {
+	var pools []*sqlitex.Pool
+	for i := range n {
+		pool, err := sqlitex.NewPool(
+			fmt.Sprintf("file:%s_%d.db?mode=memory&cache=shared", t.Name(), i),
+			sqlitex.PoolOptions{PoolSize: 2},
+		)
+		ensure.Nil(t, err)
+		t.Cleanup(func() { pool.Close() })
+		pools = append(pools, pool)
+	}
+	shards := jedis.WithShards(pools...)
+	ensure.Nil(t, shards.Migrate(context.Background()))
+	return shards
+}
+
+func TestShards(t *testing.T) {
+	shards := newShards(t, 3)
+	ctx := context.Background()
+	var ids []string
+	for i := range 20 {
+		doc, err := shards.Insert(ctx, &Jedi{Name: fmt.Sprint("jedi", i), Age: i + 1})
+		ensure.Nil(t, err)
+		ids = append(ids, doc.ID)
+	}
+	for _, id := range ids {
+		doc, err := shards.Get(ctx, id)
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, doc.ID, id)
+	}
+	// Every shard should hold some of the documents.
+	for _, pool := range shards.Pools {
+		n, err := jedis.WithPool(pool).Count(ctx)
+		ensure.Nil(t, err)
+		ensure.True(t, n > 0)
+	}
+	docs, err := shards.All(ctx)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 20)
+	n, err := shards.Count(ctx, sqjdb.Where("Age").Lt(11).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 10)
+	n, err = shards.Delete(ctx, sqjdb.Where("Age").Gte(16).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 5)
+	n, err = shards.Count(ctx)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 15)
+}
@@ -0,0 +1,55 @@
+package sqjdb
+
+import (
+	"log/slog"
+	"time"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// SlowQuery describes a statement that took longer than the SlowQueries
+// threshold.
+type SlowQuery struct {
+	Statement Statement
+	Result    StatementResult
+	// Plan is the EXPLAIN QUERY PLAN output for the statement, one step per
+	// line, indented by depth. It is empty if the plan could not be captured.
+	Plan string
+}
+
+// SlowQueries calls fn with the statements run by the table that take at least
+// threshold. If fn is nil, they are logged with slog.Default at the warning
+// level. Capturing the query plan runs the statement again with EXPLAIN QUERY
+// PLAN, which is cheap, but only happens for slow statements.
+func SlowQueries(threshold time.Duration, fn func(SlowQuery)) Option {
+	if fn == nil {
+		fn = logSlowQuery
+	}
+	return func(o *options) {
+		o.slowThreshold = threshold
+		o.slowQuery = fn
+	}
+}
+
+func logSlowQuery(q SlowQuery) {
+	slog.Warn("sqjdb: slow query",
+		slog.String("table", q.Statement.Table),
+		slog.String("op", q.Statement.Op),
+		slog.String("query", q.Statement.Query),
+		slog.Duration("duration", q.Result.Duration),
+		slog.Int("rows", q.Result.Rows),
+		slog.String("plan", q.Plan),
+	)
+}
+
+// observeSlow reports the statement if it was slow.
+func (o *options) observeSlow(conn *sqlite.Conn, s Statement, r StatementResult) {
+	if r.Duration < o.slowThreshold {
+		return
+	}
+	q := SlowQuery{Statement: s, Result: r}
+	if plan, err := explain(conn, s.Query, []SQL{{Args: s.Args}}); err == nil {
+		q.Plan = plan.String()
+	}
+	o.slowQuery(q)
+}
@@ -0,0 +1,34 @@
+package sqjdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestSlowQueries(t *testing.T) {
+	conn := newConn(t)
+	var slow []sqjdb.SlowQuery
+	watched := sqjdb.NewTable[Jedi]("jedis", sqjdb.SlowQueries(0, func(q sqjdb.SlowQuery) {
+		slow = append(slow, q)
+	}))
+	_, err := watched.One(conn, watched.ByID(yoda.ID))
+	ensure.Nil(t, err)
+	_, err = watched.All(conn, sqjdb.Where("Age").Eq(42).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(slow), 2)
+	ensure.DeepEqual(t, slow[0].Statement.Op, "one")
+	ensure.DeepEqual(t, slow[0].Result.Rows, 1)
+	ensure.StringContains(t, slow[0].Plan, "USING INDEX jedis_ID ")
+	ensure.DeepEqual(t, slow[1].Plan, "SCAN jedis\n")
+
+	slow = nil
+	fast := sqjdb.NewTable[Jedi]("jedis", sqjdb.SlowQueries(time.Hour, func(q sqjdb.SlowQuery) {
+		slow = append(slow, q)
+	}))
+	_, err = fast.All(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(slow), 0)
+}
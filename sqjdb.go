@@ -2,8 +2,9 @@
 // in a SQLite Database.
 //
 // It has various opinions about how you go about doing this:
-//  1. Documents contain an "ID" field of type string. You can manage it, or it
-//     will be filled in for you with ULIDs.
+//  1. Documents contain an "ID" field, or another field named with the IDField
+//     option or tagged `sqjdb:"id"`. You can manage it, or if it is a string
+//     it will be filled in for you with ULIDs.
 //  2. Tables store the JSON document in a column named "data". It's JSONB.
 //  3. SQL is is only lightly hidden from you.
 //  4. Make indexes on your document fields. The standard migrations, if you use
@@ -11,14 +12,19 @@
 package sqjdb
 
 import (
+	"database/sql/driver"
+	"encoding"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"iter"
+	"log/slog"
+	"math"
 	"reflect"
 	"slices"
 	"strings"
+	"time"
 
-	"github.com/oklog/ulid/v2"
 	"zombiezen.com/go/sqlite"
 	"zombiezen.com/go/sqlite/sqlitex"
 )
@@ -26,20 +32,68 @@ import (
 // ErrNoDoc indicates the requested query did not match a document.
 var ErrNoDoc = errors.New("sqjson: no document")
 
+// ErrConflict indicates the stored version of a document did not match the
+// version of the update. It is only returned by Versioned tables.
+var ErrConflict = errors.New("sqjdb: version conflict")
+
+// Binder is implemented by types that bind themselves to placeholders, and is
+// preferred by Bind over its other rules.
+type Binder interface {
+	BindSQLite(stmt *sqlite.Stmt, i int) error
+}
+
 // Bind is used internally to Bind placeholders. It is available as a public API
-// for when you are querying the database directly.
+// for when you are querying the database directly. Besides the basic types, it
+// binds Binder, driver.Valuer and encoding.TextMarshaler values, pointers by
+// what they point to, named types by their underlying type, and structs, maps
+// and slices as JSON text.
 func Bind(stmt *sqlite.Stmt, i int, v any) error {
+	if b, ok := v.(Binder); ok {
+		return b.BindSQLite(stmt, i)
+	}
 	switch v := v.(type) {
 	default:
-		return fmt.Errorf("sqjdb: unexpected value %v of type %T", v, v)
+		// Nil pointers would panic when calling value receiver methods.
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+			stmt.BindNull(i)
+			return nil
+		}
+		if valuer, ok := v.(driver.Valuer); ok {
+			value, err := valuer.Value()
+			if err != nil {
+				return fmt.Errorf("sqjdb: failed to get Value of %v of type %T: %w", v, v, err)
+			}
+			return Bind(stmt, i, value)
+		}
+		if m, ok := v.(encoding.TextMarshaler); ok {
+			text, err := m.MarshalText()
+			if err != nil {
+				return fmt.Errorf("sqjdb: failed to MarshalText value %v of type %T: %w", v, v, err)
+			}
+			stmt.BindText(i, string(text))
+			return nil
+		}
+		return bindReflect(stmt, i, v)
 	case int:
 		stmt.BindInt64(i, int64(v))
+	case int8:
+		stmt.BindInt64(i, int64(v))
 	case int16:
 		stmt.BindInt64(i, int64(v))
 	case int32:
 		stmt.BindInt64(i, int64(v))
 	case int64:
 		stmt.BindInt64(i, int64(v))
+	case uint:
+		return bindUint(stmt, i, uint64(v))
+	case uint8:
+		stmt.BindInt64(i, int64(v))
+	case uint16:
+		stmt.BindInt64(i, int64(v))
+	case uint32:
+		stmt.BindInt64(i, int64(v))
+	case uint64:
+		return bindUint(stmt, i, v)
 	case bool:
 		stmt.BindBool(i, v)
 	case []byte:
@@ -52,7 +106,63 @@ func Bind(stmt *sqlite.Stmt, i int, v any) error {
 		stmt.BindNull(i)
 	case string:
 		stmt.BindText(i, v)
+	case time.Time:
+		// The same format as JSON, so times compare equal to stored ones.
+		stmt.BindText(i, v.Format(time.RFC3339Nano))
+	}
+	return nil
+}
+
+// bindUint binds unsigned integers, which SQLite can only store up to the
+// maximum signed 64-bit integer.
+func bindUint(stmt *sqlite.Stmt, i int, v uint64) error {
+	if v > math.MaxInt64 {
+		return fmt.Errorf("sqjdb: unsigned value %d overflows int64", v)
+	}
+	stmt.BindInt64(i, int64(v))
+	return nil
+}
+
+// bindReflect binds pointers and named types, such as a custom ID type.
+func bindReflect(stmt *sqlite.Stmt, i int, v any) error {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer:
+		return Bind(stmt, i, rv.Elem().Interface())
+	case reflect.String:
+		stmt.BindText(i, rv.String())
+		return nil
+	case reflect.Bool:
+		stmt.BindBool(i, rv.Bool())
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		stmt.BindInt64(i, rv.Int())
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return bindUint(stmt, i, rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		stmt.BindFloat(i, rv.Float())
+		return nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			stmt.BindBytes(i, rv.Bytes())
+			return nil
+		}
+		return bindJSON(stmt, i, v)
+	case reflect.Struct, reflect.Map, reflect.Array:
+		return bindJSON(stmt, i, v)
+	}
+	return fmt.Errorf("sqjdb: unexpected value %v of type %T", v, v)
+}
+
+// bindJSON binds the value as JSON text, which compares equal to the same
+// value extracted from a document with the -> operator.
+func bindJSON(stmt *sqlite.Stmt, i int, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("sqjdb: failed to marshal value %v of type %T: %w", v, v, err)
 	}
+	stmt.BindText(i, string(b))
 	return nil
 }
 
@@ -63,7 +173,7 @@ type SQL struct {
 }
 
 // ByID generates a where clause to select a document by ID.
-func ByID(id string) SQL {
+func ByID(id any) SQL {
 	return SQL{Query: "where data->>'ID' = ?", Args: []any{id}}
 }
 
@@ -72,61 +182,474 @@ func ByID(id string) SQL {
 type Table[T any] struct {
 	Name    string
 	qInsert string
+	qUpsert string
+	opts    options
+	hooks   *hooks[T]
+}
+
+type options struct {
+	version        string
+	softDelete     string
+	expires        string
+	history        bool
+	changes        bool
+	changelog      bool
+	embedding      string
+	geoLat         string
+	geoLon         string
+	indexes        []IndexSpec
+	promoted       []string
+	newID          func() string
+	idField        string
+	idKey          string
+	key            []string
+	tenant         string
+	tenantID       string
+	kindField      string
+	kind           string
+	schemaVersion  string
+	upgraders      []Upgrader
+	writeBack      bool
+	codec          Codec
+	strict         bool
+	onUnknownField func(table string, err error)
+	presize        bool
+	decodeWorkers  int
+	unscoped       bool
+	tracer         Tracer
+	metrics        Metrics
+	logger         *slog.Logger
+	logOptions     LogOptions
+	slowThreshold  time.Duration
+	slowQuery      func(SlowQuery)
+	view           *SQL
+}
+
+// Option configures a Table.
+type Option func(*options)
+
+// Versioned enables optimistic concurrency using the named integer field.
+// Inserted documents start at version 1, and every update increments it.
+// Patch and Replace only apply when the version in the given document matches
+// the stored version, and return ErrConflict otherwise. Upsert does not check
+// versions.
+func Versioned(field string) Option {
+	return func(o *options) {
+		o.version = field
+	}
+}
+
+// SoftDelete makes Delete set the named field to the current time instead of
+// removing documents. Queries and updates exclude deleted documents, and
+// Restore, Purge and AllIncludingDeleted manage them.
+func SoftDelete(field string) Option {
+	return func(o *options) {
+		o.softDelete = field
+	}
+}
+
+// Expires excludes documents from queries and updates once the time in the
+// named field has passed. Documents without the field never expire. Expired
+// documents remain stored until PurgeExpired is called.
+func Expires(field string) Option {
+	return func(o *options) {
+		o.expires = field
+	}
 }
 
 // NewTable creates a new Table.
-func NewTable[T any](name string) Table[T] {
+func NewTable[T any](name string, opts ...Option) Table[T] {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	resolveID[T](&o)
+	data := "jsonb(" + jsonParam + ")"
+	if o.kindField != "" {
+		data = "jsonb_set(" + data + ", " + quoteString(fieldPath(o.kindField)) + ", " +
+			quoteString(o.kind) + ")"
+	}
+	column := "data"
+	if o.codec != nil {
+		column = "raw"
+		data = codecFunc(name, "encode") + "(json(" + data + "))"
+	}
+	qInsert := "insert into " + name + " (" + column + ") values (" + data + ")"
+	qUpsert := qInsert + " on conflict (" + fieldExpr(o.idKey) + ") do update set " +
+		column + " = excluded." + column
+	if o.kindField != "" {
+		// Only replace documents of the same type.
+		qUpsert += " where " + fieldExpr(o.kindField) + " = excluded." + fieldExpr(o.kindField)
+	}
 	return Table[T]{
 		Name:    name,
-		qInsert: "insert into " + name + " (data) values (jsonb(?))",
+		opts:    o,
+		hooks:   &hooks[T]{},
+		qInsert: qInsert,
+		qUpsert: qUpsert,
 	}
 }
 
+// notExpired matches documents where the named time field is missing or in the
+// future.
+func notExpired(field string) Cond {
+	f := fieldExpr(field)
+	return Cond{
+		Expr: f + " is null or unixepoch(" + f + ", 'subsec') > unixepoch('now', 'subsec')",
+	}
+}
+
+// scopes returns the conditions that restrict every query on the table.
+func (t *Table[T]) scopes() []Cond {
+	var scopes []Cond
+	// The tenant scope applies even to unscoped queries.
+	if t.opts.tenant != "" {
+		scopes = append(scopes, Where(t.FieldName(t.opts.tenant)).Eq(t.opts.tenantID))
+	}
+	if t.opts.kindField != "" {
+		scopes = append(scopes, Where(t.opts.kindField).Eq(t.opts.kind))
+	}
+	if t.opts.unscoped {
+		return scopes
+	}
+	if t.opts.softDelete != "" {
+		scopes = append(scopes, Where(t.FieldName(t.opts.softDelete)).IsNull())
+	}
+	if t.opts.expires != "" {
+		scopes = append(scopes, notExpired(t.FieldName(t.opts.expires)))
+	}
+	return scopes
+}
+
+// scopedSelect returns a query selecting the rowid and all columns of the
+// documents within the scopes.
+func (t *Table[T]) scopedSelect() SQL {
+	sel := SQL{Query: "select rowid, * from " + t.Name}
+	if t.opts.view != nil {
+		// Views have no rowid.
+		sel = SQL{Query: "select * from (" + t.opts.view.Query + ")", Args: t.opts.view.Args}
+	}
+	scopes := t.scopes()
+	if len(scopes) == 0 {
+		return sel
+	}
+	cond := scopes[0].And(scopes[1:]...)
+	return SQL{
+		Query: sel.Query + " where " + cond.Expr,
+		Args:  slices.Concat(sel.Args, cond.Args),
+	}
+}
+
+// splitName splits a possibly schema qualified name into the schema prefix,
+// including the trailing dot, and the unqualified name.
+func splitName(name string) (prefix, base string) {
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		return name[:i+1], name[i+1:]
+	}
+	return "", name
+}
+
+// from returns the source of documents for queries, which is the table itself
+// or a subquery applying the scopes.
+func (t *Table[T]) from() SQL {
+	if len(t.scopes()) == 0 && t.opts.view == nil {
+		return SQL{Query: t.Name}
+	}
+	sel := t.scopedSelect()
+	_, base := splitName(t.Name)
+	return SQL{Query: "(" + sel.Query + ") as " + base, Args: sel.Args}
+}
+
+// target restricts the query used by updates and deletes to the scopes.
+func (t *Table[T]) target(sqls []SQL) []SQL {
+	if len(t.scopes()) == 0 {
+		return sqls
+	}
+	return slices.Concat(
+		[]SQL{{Query: "where rowid in (select rowid from"}, t.from()},
+		sqls,
+		[]SQL{{Query: ")"}},
+	)
+}
+
+// unscoped returns a copy of the table without scopes.
+func (t *Table[T]) unscoped() *Table[T] {
+	u := *t
+	u.opts.unscoped = true
+	u.opts.softDelete = ""
+	return &u
+}
+
 // Migrate runs the standard migrations, including creating the table if
 // necessary. They are idempotent and should probably be run on application
 // startup.
 func (t *Table[T]) Migrate(conn *sqlite.Conn) error {
-	qCreate := "create table if not exists " + t.Name + " (data blob)"
+	if err := t.writable(); err != nil {
+		return err
+	}
+	if err := t.RegisterFunctions(conn); err != nil {
+		return err
+	}
+	qCreate := t.createTableSQL()
 	if err := sqlitex.ExecuteTransient(conn, qCreate, nil); err != nil {
 		return fmt.Errorf("sqjdb: creating table %q: %w", t.Name, err)
 	}
+	_, base := splitName(t.Name)
 	qIndexID := "create unique index if not exists " + t.Name +
-		"_ID on " + t.Name + " (data->>'ID')"
+		"_ID on " + base + " (" + fieldExpr(t.opts.idKey) + ")"
 	if err := sqlitex.ExecuteTransient(conn, qIndexID, nil); err != nil {
 		return fmt.Errorf("sqjdb: creating ID index on %q: %w", t.Name, err)
 	}
+	if err := t.migrateIndexes(conn); err != nil {
+		return err
+	}
+	if err := t.migratePromoted(conn); err != nil {
+		return err
+	}
+	if t.opts.history {
+		if err := t.migrateHistory(conn); err != nil {
+			return err
+		}
+	}
+	if t.opts.changes {
+		if err := t.migrateChanges(conn); err != nil {
+			return err
+		}
+	}
+	if t.opts.changelog {
+		if err := t.migrateChangelog(conn); err != nil {
+			return err
+		}
+	}
+	if t.opts.geoLat != "" {
+		if err := t.migrateGeo(conn); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// Insert a new document. If the document contains a non-empty ID, it will be
-// returned as is. If the ID is empty, a shallow clone of the document will be
-// returned with a generated ID set.
-func (t *Table[T]) Insert(conn *sqlite.Conn, doc *T) (*T, error) {
-	reflectV := reflect.Indirect(reflect.ValueOf(doc))
-	vID := reflectV.FieldByName("ID")
+// withID returns the document as is if it contains a non-empty ID, or a
+// shallow clone with a generated ID set.
+func (t *Table[T]) withID(doc *T) (*T, error) {
+	if s, ok := any(doc).(IDSetter); ok {
+		if s.GetID() != "" {
+			return doc, nil
+		}
+		docCopy := *doc
+		doc = &docCopy
+		any(doc).(IDSetter).SetID(t.newID())
+		return doc, nil
+	}
+	vID := docField(doc, t.opts.idField)
 	if !vID.IsValid() {
-		return nil, fmt.Errorf("sqjdb: expected type %T to contain an ID field of type string", doc)
+		return nil, fmt.Errorf("sqjdb: expected type %T to contain an %s field",
+			doc, t.opts.idField)
 	}
 	if vID.IsZero() {
+		if vID.Kind() != reflect.String {
+			return nil, fmt.Errorf("sqjdb: document of type %T is missing its %s, which is only generated for string IDs",
+				doc, t.opts.idField)
+		}
 		docCopy := *doc
 		doc = &docCopy
-		reflect.Indirect(reflect.ValueOf(doc)).FieldByName("ID").SetString(ulid.Make().String())
+		docField(doc, t.opts.idField).SetString(t.newID())
+	}
+	return doc, nil
+}
+
+// docID returns the ID of a document known to contain an ID field.
+func (t *Table[T]) docID(doc *T) any {
+	if s, ok := any(doc).(IDSetter); ok {
+		return s.GetID()
+	}
+	return docField(doc, t.opts.idField).Interface()
+}
+
+// docVersion returns the value of the version field of the document.
+func (t *Table[T]) docVersion(doc *T) (int64, error) {
+	v := docField(doc, t.opts.version)
+	if !v.IsValid() || !v.CanInt() {
+		return 0, fmt.Errorf("sqjdb: expected type %T to contain a %s field of type int",
+			doc, t.opts.version)
+	}
+	return v.Int(), nil
+}
+
+// prepareDoc fills in the fields managed by the table, returning a shallow
+// clone of the document if any are changed, and validates it.
+func (t *Table[T]) prepareDoc(doc *T) (*T, error) {
+	doc, err := t.withID(doc)
+	if err != nil {
+		return nil, err
+	}
+	if t.opts.tenant != "" {
+		if doc, err = t.withTenant(doc); err != nil {
+			return nil, err
+		}
+	}
+	if t.opts.schemaVersion != "" {
+		if doc, err = t.withSchemaVersion(doc); err != nil {
+			return nil, err
+		}
+	}
+	if t.opts.version != "" {
+		version, err := t.docVersion(doc)
+		if err != nil {
+			return nil, err
+		}
+		if version == 0 {
+			docCopy := *doc
+			doc = &docCopy
+			docField(doc, t.opts.version).SetInt(1)
+		}
+	}
+	if err := t.validate(doc, HookInsert); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// insert runs the insert hooks around insertDoc.
+func (t *Table[T]) insert(conn *sqlite.Conn, q string, doc *T) (*T, error) {
+	e := &HookEvent[T]{Op: HookInsert, Doc: doc}
+	err := t.runHooks(conn, e, func() error {
+		doc, err := t.insertDoc(conn, q, e.Doc)
+		if err != nil {
+			return err
+		}
+		// The upsert does nothing if the document belongs to another tenant, or
+		// is of another type.
+		if q == t.qUpsert && (t.opts.tenant != "" || t.opts.kindField != "") && conn.Changes() == 0 {
+			return &UniqueViolationError{
+				Field: t.opts.idKey,
+				Err: fmt.Errorf("sqjdb: document %v in %q belongs to another tenant or type",
+					t.docID(doc), t.Name),
+			}
+		}
+		e.Doc, e.N = doc, 1
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e.Doc, nil
+}
+
+func (t *Table[T]) insertDoc(conn *sqlite.Conn, q string, doc *T) (_ *T, err error) {
+	if err := t.writable(); err != nil {
+		return nil, err
 	}
-	jsonS, err := json.Marshal(doc)
+	doc, err = t.prepareDoc(doc)
 	if err != nil {
-		return nil, fmt.Errorf("sqjdb: failed to json.Marshal: %w", err)
+		return nil, err
 	}
-	stmt, err := conn.Prepare(t.qInsert)
+	jsonS, release, err := marshal(doc)
 	if err != nil {
-		return nil, fmt.Errorf("sqjdb: failed to prepare %q: %w", t.qInsert, err)
+		return nil, err
+	}
+	defer release()
+	sqls := []SQL{{Args: []any{jsonS}}}
+	sp := t.trace(conn, "insert", q, sqls)
+	defer func() { err = sp.finish(err) }()
+	stmt, err := prepareSQL(conn, q, sqls)
+	if err != nil {
+		return nil, err
 	}
-	stmt.BindText(1, string(jsonS))
+	defer releaseStmt(stmt)
 	if _, err := stmt.Step(); err != nil {
-		return nil, fmt.Errorf("sqjdb: inserting document in %q: %w", t.Name, err)
+		return nil, t.writeError(fmt.Errorf("sqjdb: inserting document in %q: %w", t.Name, err))
 	}
+	sp.addRows(1)
 	return doc, nil
 }
 
+// Insert a new document. If the document contains a non-empty ID, it will be
+// returned as is. If the ID is empty, a shallow clone of the document will be
+// returned with a generated ID set.
+func (t *Table[T]) Insert(conn *sqlite.Conn, doc *T) (*T, error) {
+	return t.insert(conn, t.qInsert, doc)
+}
+
+// InsertMany inserts the documents in a single transaction, reusing one
+// prepared statement. IDs are handled as they are for Insert, and the returned
+// slice contains the documents in the same order. If any insert fails, none of
+// the documents are inserted.
+func (t *Table[T]) InsertMany(conn *sqlite.Conn, docs []*T) (_ []*T, err error) {
+	if err := t.writable(); err != nil {
+		return nil, err
+	}
+	defer settleNotifications(conn)
+	defer sqlitex.Save(conn)(&err)
+	sp := t.trace(conn, "insert_many", t.qInsert, nil)
+	// Only errors from the statement are wrapped, not those from hooks.
+	defer func() { sp.finish(err) }()
+	stmt, err := prepareSQL(conn, t.qInsert, nil)
+	if err != nil {
+		return nil, sp.wrap(err)
+	}
+	defer releaseStmt(stmt)
+	inserted := make([]*T, len(docs))
+	for i, doc := range docs {
+		e := &HookEvent[T]{Op: HookInsert, Doc: doc}
+		err := t.runHooks(conn, e, func() error {
+			doc, err := t.prepareDoc(e.Doc)
+			if err != nil {
+				return err
+			}
+			jsonS, release, err := marshal(doc)
+			if err != nil {
+				return err
+			}
+			defer release()
+			if err := Bind(stmt, 1, jsonS); err != nil {
+				return err
+			}
+			if _, err := stmt.Step(); err != nil {
+				return sp.wrap(t.writeError(fmt.Errorf("sqjdb: inserting document in %q: %w", t.Name, err)))
+			}
+			if err := stmt.Reset(); err != nil {
+				return sp.wrap(fmt.Errorf("sqjdb: inserting document in %q: %w", t.Name, err))
+			}
+			e.Doc, e.N = doc, 1
+			sp.addRows(1)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		inserted[i] = e.Doc
+	}
+	return inserted, nil
+}
+
+// GetOrCreate returns the document matching the filter, or inserts the
+// defaults if no document matches. The lookup and insert happen within a
+// transaction. The returned bool reports whether the document was created.
+func (t *Table[T]) GetOrCreate(conn *sqlite.Conn, filter SQL, defaults *T) (_ *T, created bool, err error) {
+	defer settleNotifications(conn)
+	defer sqlitex.Save(conn)(&err)
+	doc, err := t.One(conn, filter)
+	if err == nil {
+		return doc, false, nil
+	}
+	if !errors.Is(err, ErrNoDoc) {
+		return nil, false, err
+	}
+	doc, err = t.Insert(conn, defaults)
+	if err != nil {
+		return nil, false, err
+	}
+	return doc, true, nil
+}
+
+// Upsert inserts a new document, or replaces the existing document with the
+// same ID. It relies on the unique ID index created by Migrate. IDs are
+// generated as they are for Insert.
+func (t *Table[T]) Upsert(conn *sqlite.Conn, doc *T) (*T, error) {
+	return t.insert(conn, t.qUpsert, doc)
+}
+
 func addSQLQuery(query *strings.Builder, sqls []SQL) {
 	for _, part := range sqls {
 		query.WriteRune(' ')
@@ -147,64 +670,222 @@ func bindSQLQuery(stmt *sqlite.Stmt, sqls []SQL) error {
 	return nil
 }
 
-func (t *Table[T]) stepOne(stmt *sqlite.Stmt) (*T, error) {
-	rowReturned, err := stmt.Step()
+// prepareSQL returns the prepared statement for the query with the SQL
+// arguments bound. Statements are cached by the connection, so repeated
+// queries are only compiled once. Callers should releaseStmt when done.
+func prepareSQL(conn *sqlite.Conn, query string, sqls []SQL) (*sqlite.Stmt, error) {
+	// Notifications of a transaction not made with WithTx are pending until
+	// the connection is used again.
+	if conn.AutocommitEnabled() {
+		flushNotifications(conn)
+	}
+	stmt, err := conn.Prepare(query)
 	if err != nil {
+		return nil, fmt.Errorf("sqjdb: failed to prepare %q: %w", query, err)
+	}
+	if err := bindSQLQuery(stmt, sqls); err != nil {
+		releaseStmt(stmt)
 		return nil, err
 	}
-	if !rowReturned {
-		return nil, nil
+	return stmt, nil
+}
+
+// releaseStmt resets the cached statement, so it does not keep the read
+// transaction open if it was not stepped to completion, and clears the
+// bindings, so it does not hold on to the arguments.
+func releaseStmt(stmt *sqlite.Stmt) {
+	stmt.Reset()
+	stmt.ClearBindings()
+}
+
+// columnBytes reads the column into buf, growing it if needed, and returns the
+// bytes read. Unlike converting ColumnText to bytes, it copies the value once,
+// and reusing buf across rows avoids allocating for each one. The returned
+// bytes are only valid until buf is reused.
+func columnBytes(stmt *sqlite.Stmt, col int, buf *[]byte) []byte {
+	n := stmt.ColumnLen(col)
+	if cap(*buf) < n {
+		*buf = make([]byte, n)
 	}
-	jsonS := stmt.ColumnText(0)
+	b := (*buf)[:n]
+	stmt.ColumnBytes(col, b)
+	return b
+}
+
+// stepOne decodes the next document, or returns nil if there are no more.
+func (t *Table[T]) stepOne(stmt *sqlite.Stmt, buf *[]byte, pending *[]upgradedDoc) (*T, error) {
 	v := new(T)
-	if err := json.Unmarshal([]byte(jsonS), v); err != nil {
-		return nil, fmt.Errorf("sqjdb: invalid json from db: %w\n%s", err, jsonS)
+	rowReturned, err := t.stepInto(stmt, buf, pending, v)
+	if err != nil || !rowReturned {
+		return nil, err
 	}
 	return v, nil
 }
 
+// stepInto decodes the next document into v and reports whether there was
+// one. The document is read into buf. Upgraded documents are added to pending
+// if it is not nil.
+func (t *Table[T]) stepInto(stmt *sqlite.Stmt, buf *[]byte, pending *[]upgradedDoc, v *T) (bool, error) {
+	rowReturned, err := stmt.Step()
+	if err != nil || !rowReturned {
+		return false, err
+	}
+	upgraded, err := t.decode(columnBytes(stmt, 0, buf), v)
+	if err != nil {
+		return false, err
+	}
+	if upgraded != nil && pending != nil {
+		*pending = append(*pending, *upgraded)
+	}
+	return true, nil
+}
+
+// decode decodes the document into v, upgrading it for SchemaVersion tables.
+// It returns the upgraded document if it should be written back.
+func (t *Table[T]) decode(jsonS []byte, v *T) (*upgradedDoc, error) {
+	var from int64
+	if t.opts.schemaVersion != "" {
+		upgraded, version, err := t.upgrade(jsonS)
+		if err != nil {
+			return nil, err
+		}
+		if upgraded != nil {
+			jsonS, from = upgraded, version
+		}
+	}
+	if err := t.unmarshal(jsonS, v); err != nil {
+		return nil, fmt.Errorf("sqjdb: invalid json from db: %w\n%s", err, jsonS)
+	}
+	if from == 0 || !t.opts.writeBack {
+		return nil, nil
+	}
+	return &upgradedDoc{id: t.docID(v), from: from, jsonS: jsonS}, nil
+}
+
 // One returns a single document per the given query. It returns the error
 // ErrNoDoc if no document is found.
-func (t *Table[T]) One(conn *sqlite.Conn, sqls ...SQL) (*T, error) {
+func (t *Table[T]) One(conn *sqlite.Conn, sqls ...SQL) (_ *T, err error) {
 	var query strings.Builder
-	query.WriteString("select json(data) from ")
-	query.WriteString(t.Name)
+	query.WriteString("select json(data) from")
+	sqls = slices.Concat([]SQL{t.from()}, sqls)
 	addSQLQuery(&query, sqls)
 	query.WriteString(" limit 1")
-	stmt, err := conn.Prepare(query.String())
+	sp := t.trace(conn, "one", query.String(), sqls)
+	defer func() { err = sp.finish(err) }()
+	stmt, err := prepareSQL(conn, query.String(), sqls)
 	if err != nil {
-		return nil, fmt.Errorf("sqjdb: failed to prepare: %q: %w", query.String(), err)
-	}
-	if err := bindSQLQuery(stmt, sqls); err != nil {
 		return nil, err
 	}
-	v, err := t.stepOne(stmt)
+	// The statement is left mid-row when a document is found, which would
+	// otherwise hold open the read transaction.
+	defer releaseStmt(stmt)
+	var buf []byte
+	var pending []upgradedDoc
+	v, err := t.stepOne(stmt, &buf, &pending)
 	if err != nil {
 		return nil, err
 	}
 	if v == nil {
 		return nil, ErrNoDoc
 	}
+	sp.addRows(1)
+	if len(pending) > 0 {
+		stmt.Reset()
+		if err := t.writeBack(conn, pending); err != nil {
+			return nil, err
+		}
+	}
 	return v, nil
 }
 
+// Get returns the document with the given ID. It returns the error ErrNoDoc if
+// no document is found.
+func (t *Table[T]) Get(conn *sqlite.Conn, id any) (*T, error) {
+	return t.One(conn, t.ByID(id))
+}
+
+// GetMany fetches the documents with the given IDs in a single query. The
+// result is keyed by ID, and IDs without a matching document map to nil. The
+// IDs should be of the same type as the ID field for the keys to match.
+func (t *Table[T]) GetMany(conn *sqlite.Conn, ids ...any) (map[any]*T, error) {
+	docs, err := t.All(conn, In(t.opts.idKey, ids...).SQL())
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[any]*T, len(ids))
+	for _, id := range ids {
+		byID[id] = nil
+	}
+	for _, doc := range docs {
+		byID[t.docID(doc)] = doc
+	}
+	return byID, nil
+}
+
+// Count returns the number of documents per the given query.
+func (t *Table[T]) Count(conn *sqlite.Conn, sqls ...SQL) (_ int, err error) {
+	var query strings.Builder
+	query.WriteString("select count(*) from")
+	sqls = slices.Concat([]SQL{t.from()}, sqls)
+	addSQLQuery(&query, sqls)
+	sp := t.trace(conn, "count", query.String(), sqls)
+	defer func() { err = sp.finish(err) }()
+	stmt, err := prepareSQL(conn, query.String(), sqls)
+	if err != nil {
+		return 0, err
+	}
+	defer releaseStmt(stmt)
+	if _, err := stmt.Step(); err != nil {
+		return 0, err
+	}
+	sp.addRows(1)
+	return stmt.ColumnInt(0), nil
+}
+
 // All returns all documents per the given query. It returns an empty slice with
 // no error if no documents match.
 func (t *Table[T]) All(conn *sqlite.Conn, sqls ...SQL) ([]*T, error) {
+	return t.all(conn, slices.Concat([]SQL{t.from()}, sqls))
+}
+
+// all returns all documents per the given query, where the first part is the
+// source of documents.
+func (t *Table[T]) all(conn *sqlite.Conn, sqls []SQL) (docs []*T, err error) {
+	n, err := t.presize(conn, sqls)
+	if err != nil {
+		return nil, err
+	}
 	var query strings.Builder
-	query.WriteString("select json(data) from ")
-	query.WriteString(t.Name)
+	query.WriteString("select json(data) from")
 	addSQLQuery(&query, sqls)
-	stmt, err := conn.Prepare(query.String())
+	sp := t.trace(conn, "all", query.String(), sqls)
+	defer func() {
+		sp.addRows(len(docs))
+		err = sp.finish(err)
+	}()
+	stmt, err := prepareSQL(conn, query.String(), sqls)
 	if err != nil {
-		return nil, fmt.Errorf("sqjdb: failed to prepare: %q: %w", query.String(), err)
-	}
-	if err := bindSQLQuery(stmt, sqls); err != nil {
 		return nil, err
 	}
-	var docs []*T
+	defer releaseStmt(stmt)
+	docs = make([]*T, 0, n)
+	var pending []upgradedDoc
+	if t.opts.decodeWorkers > 0 {
+		err := t.stepParallel(stmt, &pending, func(v *T) bool {
+			docs = append(docs, v)
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := t.writeBack(conn, pending); err != nil {
+			return nil, err
+		}
+		return docs, nil
+	}
+	var buf []byte
 	for {
-		v, err := t.stepOne(stmt)
+		v, err := t.stepOne(stmt, &buf, &pending)
 		if err != nil {
 			return nil, err
 		}
@@ -213,57 +894,341 @@ func (t *Table[T]) All(conn *sqlite.Conn, sqls ...SQL) ([]*T, error) {
 		}
 		docs = append(docs, v)
 	}
+	if err := t.writeBack(conn, pending); err != nil {
+		return nil, err
+	}
 	return docs, nil
 }
 
-// Delete one or more documents per the given query.
-func (t *Table[T]) Delete(conn *sqlite.Conn, sqls ...SQL) error {
+// Iter returns an iterator over the documents per the given query. Unlike All,
+// documents are decoded one at a time as the iteration proceeds. Iteration
+// stops after the first error is yielded. The connection must not be used for
+// the same query while iterating.
+func (t *Table[T]) Iter(conn *sqlite.Conn, sqls ...SQL) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		var query strings.Builder
+		query.WriteString("select json(data) from")
+		sqls := slices.Concat([]SQL{t.from()}, sqls)
+		addSQLQuery(&query, sqls)
+		sp := t.trace(conn, "iter", query.String(), sqls)
+		var err error
+		defer func() { sp.finish(err) }()
+		stmt, err := prepareSQL(conn, query.String(), sqls)
+		if err != nil {
+			yield(nil, sp.wrap(err))
+			return
+		}
+		// Breaking out of the loop leaves the statement mid-row.
+		defer releaseStmt(stmt)
+		if t.opts.decodeWorkers > 0 {
+			stopped := false
+			err = t.stepParallel(stmt, nil, func(v *T) bool {
+				sp.addRows(1)
+				stopped = !yield(v, nil)
+				return !stopped
+			})
+			if err != nil && !stopped {
+				yield(nil, sp.wrap(err))
+			}
+			return
+		}
+		var buf []byte
+		for {
+			var v *T
+			v, err = t.stepOne(stmt, &buf, nil)
+			if err != nil {
+				yield(nil, sp.wrap(err))
+				return
+			}
+			if v == nil {
+				return
+			}
+			sp.addRows(1)
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Delete one or more documents per the given query. It returns the number of
+// documents deleted. For SoftDelete tables the documents are marked as deleted
+// instead of being removed.
+func (t *Table[T]) Delete(conn *sqlite.Conn, sqls ...SQL) (int, error) {
+	e := &HookEvent[T]{Op: HookDelete, SQLs: sqls}
+	err := t.runHooks(conn, e, func() (err error) {
+		e.N, err = t.delete(conn, e.SQLs)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return e.N, nil
+}
+
+func (t *Table[T]) delete(conn *sqlite.Conn, sqls []SQL) (_ int, err error) {
+	if err := t.writable(); err != nil {
+		return 0, err
+	}
+	if t.opts.softDelete != "" {
+		expr := SQL{
+			Query: "jsonb_set(data, ?, strftime('%Y-%m-%dT%H:%M:%fZ'))",
+			Args:  []any{fieldPath(t.FieldName(t.opts.softDelete))},
+		}
+		return t.update(conn, expr, sqls)
+	}
 	var query strings.Builder
 	query.WriteString("delete from ")
 	query.WriteString(t.Name)
+	sqls = t.target(sqls)
 	addSQLQuery(&query, sqls)
-	stmt, err := conn.Prepare(query.String())
+	sp := t.trace(conn, "delete", query.String(), sqls)
+	defer func() { err = sp.finish(err) }()
+	stmt, err := prepareSQL(conn, query.String(), sqls)
 	if err != nil {
-		return fmt.Errorf("sqjdb: failed to prepare %q: %w", query.String(), err)
-	}
-	if err := bindSQLQuery(stmt, sqls); err != nil {
-		return err
+		return 0, err
 	}
+	defer releaseStmt(stmt)
 	if _, err := stmt.Step(); err != nil {
-		return fmt.Errorf("sqjdb: failed to delete: %w", err)
+		return 0, t.writeError(fmt.Errorf("sqjdb: failed to delete: %w", err))
 	}
-	return nil
+	sp.addRows(conn.Changes())
+	return conn.Changes(), nil
 }
 
-func (t *Table[T]) patchOrReplace(partQ string, conn *sqlite.Conn, doc *T, sqls []SQL) error {
+// setData returns the set clause to update the data column to the given
+// expression, incrementing the version for Versioned tables.
+func (t *Table[T]) setData(expr SQL) SQL {
+	if t.opts.tenant != "" {
+		// Updates can not move documents to another tenant.
+		expr = SQL{
+			Query: "jsonb_set(" + expr.Query + ", ?, ?)",
+			Args:  slices.Concat(expr.Args, []any{fieldPath(t.FieldName(t.opts.tenant)), t.opts.tenantID}),
+		}
+	}
+	if t.opts.kindField != "" {
+		// Updates can not change the type of documents.
+		expr = SQL{
+			Query: "jsonb_set(" + expr.Query + ", ?, ?)",
+			Args:  slices.Concat(expr.Args, []any{fieldPath(t.opts.kindField), t.opts.kind}),
+		}
+	}
+	if t.opts.version != "" {
+		expr = SQL{
+			Query: "jsonb_set(" + expr.Query + ", ?, coalesce(" +
+				fieldExpr(t.FieldName(t.opts.version)) + ", 0) + 1)",
+			Args: slices.Concat(expr.Args, []any{fieldPath(t.FieldName(t.opts.version))}),
+		}
+	}
+	return t.assignData(expr)
+}
+
+// update runs an update statement setting the data column to the given
+// expression per the given query and returns the number of documents updated.
+func (t *Table[T]) update(conn *sqlite.Conn, expr SQL, sqls []SQL) (_ int, err error) {
+	if err := t.writable(); err != nil {
+		return 0, err
+	}
 	var query strings.Builder
 	query.WriteString("update ")
 	query.WriteString(t.Name)
-	jsonS, err := json.Marshal(doc)
+	sqls = slices.Concat([]SQL{t.setData(expr)}, t.target(sqls))
+	addSQLQuery(&query, sqls)
+	sp := t.trace(conn, "update", query.String(), sqls)
+	defer func() { err = sp.finish(err) }()
+	stmt, err := prepareSQL(conn, query.String(), sqls)
+	if err != nil {
+		return 0, err
+	}
+	defer releaseStmt(stmt)
+	if _, err := stmt.Step(); err != nil {
+		return 0, t.writeError(fmt.Errorf("sqjdb: failed to execute %q: %w", query.String(), err))
+	}
+	sp.addRows(conn.Changes())
+	return conn.Changes(), nil
+}
+
+// patch runs update for writes that do not run hooks, and notifies the
+// Notifier of the table of them as patches.
+func (t *Table[T]) patch(conn *sqlite.Conn, expr SQL, sqls []SQL) (int, error) {
+	n, err := t.update(conn, expr, sqls)
 	if err != nil {
-		return fmt.Errorf("sqjdb: failed to json.Marshal: %w", err)
+		return 0, err
 	}
-	sqls = slices.Concat([]SQL{{Query: partQ, Args: []any{jsonS}}}, sqls)
+	return n, t.notify(conn, HookEvent[T]{Op: HookPatch, SQLs: sqls, N: n})
+}
+
+// updateReturning runs an update statement setting the data column to the
+// given expression per the given query and returns the updated documents. It
+// does not run hooks, but notifies the Notifier of the table as a patch.
+func (t *Table[T]) updateReturning(conn *sqlite.Conn, expr SQL, sqls []SQL) (docs []*T, err error) {
+	if err := t.writable(); err != nil {
+		return nil, err
+	}
+	e := HookEvent[T]{Op: HookPatch, SQLs: sqls}
+	var query strings.Builder
+	query.WriteString("update ")
+	query.WriteString(t.Name)
+	sqls = slices.Concat([]SQL{t.setData(expr)}, t.target(sqls))
 	addSQLQuery(&query, sqls)
-	stmt, err := conn.Prepare(query.String())
+	query.WriteString(" returning json(data)")
+	sp := t.trace(conn, "update", query.String(), sqls)
+	defer func() {
+		sp.addRows(len(docs))
+		err = sp.finish(err)
+	}()
+	stmt, err := prepareSQL(conn, query.String(), sqls)
 	if err != nil {
-		return fmt.Errorf("sqjdb: failed to prepare %q: %w", query.String(), err)
+		return nil, err
 	}
-	if err := bindSQLQuery(stmt, sqls); err != nil {
+	defer releaseStmt(stmt)
+	var buf []byte
+	for {
+		v, err := t.stepOne(stmt, &buf, nil)
+		if err != nil {
+			return nil, t.writeError(fmt.Errorf("sqjdb: failed to execute %q: %w", query.String(), err))
+		}
+		if v == nil {
+			break
+		}
+		docs = append(docs, v)
+	}
+	e.N = len(docs)
+	return docs, t.notify(conn, e)
+}
+
+// updateVersioned runs update only on documents matching the given version. If
+// no documents are updated but some match the query, it returns ErrConflict.
+func (t *Table[T]) updateVersioned(conn *sqlite.Conn, expr SQL, version int64, sqls []SQL) (_ int, err error) {
+	defer settleNotifications(conn)
+	defer sqlitex.Save(conn)(&err)
+	target := slices.Concat(
+		[]SQL{{Query: "where rowid in (select rowid from " + t.Name}},
+		sqls,
+		[]SQL{{Query: ") and " + fieldExpr(t.FieldName(t.opts.version)) + " = ?", Args: []any{version}}},
+	)
+	n, err := t.update(conn, expr, target)
+	if err != nil || n > 0 {
+		return n, err
+	}
+	if _, err := t.One(conn, sqls...); err != nil {
+		if errors.Is(err, ErrNoDoc) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return 0, ErrConflict
+}
+
+func (t *Table[T]) patchOrReplace(exprQ string, conn *sqlite.Conn, doc *T, sqls []SQL) (int, error) {
+	jsonS, release, err := marshal(doc)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	expr := SQL{Query: exprQ, Args: []any{jsonS}}
+	if t.opts.version != "" {
+		version, err := t.docVersion(doc)
+		if err != nil {
+			return 0, err
+		}
+		return t.updateVersioned(conn, expr, version, sqls)
+	}
+	return t.update(conn, expr, sqls)
+}
+
+// Patch applies the given update using jsonb_patch per the given query. It
+// returns the number of documents updated.
+func (t *Table[T]) Patch(conn *sqlite.Conn, doc *T, sqls ...SQL) (int, error) {
+	e := &HookEvent[T]{Op: HookPatch, Doc: doc, SQLs: sqls}
+	err := t.runHooks(conn, e, func() (err error) {
+		if err := t.validate(e.Doc, HookPatch); err != nil {
+			return err
+		}
+		e.N, err = t.patchOrReplace("jsonb_patch(data, "+jsonParam+")", conn, e.Doc, e.SQLs)
 		return err
+	})
+	if err != nil {
+		return 0, err
 	}
-	if _, err := stmt.Step(); err != nil {
-		return fmt.Errorf("sqjdb: failed to execute %q: %w", query.String(), err)
+	return e.N, nil
+}
+
+// Replace replaces the document(s) per the given query. It returns the number
+// of documents replaced.
+func (t *Table[T]) Replace(conn *sqlite.Conn, doc *T, sqls ...SQL) (int, error) {
+	e := &HookEvent[T]{Op: HookReplace, Doc: doc, SQLs: sqls}
+	err := t.runHooks(conn, e, func() (err error) {
+		if err := t.validate(e.Doc, HookReplace); err != nil {
+			return err
+		}
+		e.N, err = t.patchOrReplace("jsonb("+jsonParam+")", conn, e.Doc, e.SQLs)
+		return err
+	})
+	if err != nil {
+		return 0, err
 	}
-	return nil
+	return e.N, nil
+}
+
+// UpdateReturning applies the given update using jsonb_patch per the given
+// query, like Patch, but returns the updated documents in the same round trip.
+func (t *Table[T]) UpdateReturning(conn *sqlite.Conn, doc *T, sqls ...SQL) ([]*T, error) {
+	if err := t.validate(doc, HookPatch); err != nil {
+		return nil, err
+	}
+	jsonS, release, err := marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	expr := SQL{Query: "jsonb_patch(data, " + jsonParam + ")", Args: []any{jsonS}}
+	return t.updateReturning(conn, expr, sqls)
 }
 
-// Patch applies the given update using jsonb_patch per the given query.
-func (t *Table[T]) Patch(conn *sqlite.Conn, doc *T, sqls ...SQL) error {
-	return t.patchOrReplace("set data = jsonb_patch(data, ?)", conn, doc, sqls)
+// PatchFields applies the given field values using jsonb_patch per the given
+// query. Unlike Patch, only the fields present in the map are updated,
+// regardless of json tags or zero values. Following JSON Merge Patch, a nil
+// value removes the field. It returns the number of documents updated.
+func (t *Table[T]) PatchFields(conn *sqlite.Conn, fields map[string]any, sqls ...SQL) (int, error) {
+	jsonS, release, err := marshal(fields)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	return t.patch(conn, SQL{Query: "jsonb_patch(data, " + jsonParam + ")", Args: []any{jsonS}}, sqls)
+}
+
+// AllIncludingDeleted is like All, but includes documents deleted from a
+// SoftDelete table and expired documents from an Expires table.
+func (t *Table[T]) AllIncludingDeleted(conn *sqlite.Conn, sqls ...SQL) ([]*T, error) {
+	return t.unscoped().All(conn, sqls...)
+}
+
+// Restore undeletes documents from a SoftDelete table per the given query. It
+// returns the number of documents restored.
+func (t *Table[T]) Restore(conn *sqlite.Conn, sqls ...SQL) (int, error) {
+	if t.opts.softDelete == "" {
+		return 0, fmt.Errorf("sqjdb: table %q does not use SoftDelete", t.Name)
+	}
+	sqls = slices.Concat([]SQL{{Query: "where rowid in (select rowid from " + t.Name}},
+		sqls,
+		[]SQL{{Query: ") and " + fieldExpr(t.FieldName(t.opts.softDelete)) + " is not null"}})
+	expr := SQL{Query: "jsonb_remove(data, ?)", Args: []any{fieldPath(t.FieldName(t.opts.softDelete))}}
+	return t.unscoped().patch(conn, expr, sqls)
 }
 
-// Replace replaces the document(s) per the given query.
-func (t *Table[T]) Replace(conn *sqlite.Conn, doc *T, sqls ...SQL) error {
-	return t.patchOrReplace("set data = jsonb(?)", conn, doc, sqls)
+// Purge permanently removes documents per the given query, including those
+// deleted from a SoftDelete table. It returns the number of documents removed.
+func (t *Table[T]) Purge(conn *sqlite.Conn, sqls ...SQL) (int, error) {
+	return t.unscoped().Delete(conn, sqls...)
+}
+
+// PurgeExpired permanently removes expired documents from an Expires table. It
+// returns the number of documents removed.
+func (t *Table[T]) PurgeExpired(conn *sqlite.Conn) (int, error) {
+	if t.opts.expires == "" {
+		return 0, fmt.Errorf("sqjdb: table %q does not use Expires", t.Name)
+	}
+	return t.Purge(conn, notExpired(t.FieldName(t.opts.expires)).Not().SQL())
 }
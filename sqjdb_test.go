@@ -1,9 +1,14 @@
 package sqjdb_test
 
 import (
+	"database/sql/driver"
+	"encoding/json"
 	"fmt"
+	"math"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/daaku/ensure"
 	"github.com/daaku/sqjdb"
@@ -68,17 +73,9 @@ func TestIDIsGenerated(t *testing.T) {
 
 func TestMigrateIDIndex(t *testing.T) {
 	conn := newConn(t)
-	stmt := conn.Prep(`explain select data from jedis where data->>'ID' = 'a'`)
-	for {
-		hasRow, err := stmt.Step()
-		ensure.Nil(t, err)
-		if !hasRow {
-			t.Fatal("missing expected opcode")
-		}
-		if stmt.GetText("opcode") == "IdxGT" {
-			break
-		}
-	}
+	plan, err := jedis.Explain(conn, sqjdb.ByID("a"))
+	ensure.Nil(t, err)
+	ensure.True(t, sqjdb.UsesIndex(plan, "jedis_ID"), plan)
 }
 
 func TestOne(t *testing.T) {
@@ -107,8 +104,9 @@ func TestDelete(t *testing.T) {
 	beforeDelete, err := jedis.All(conn, byAge(luke.Age))
 	ensure.Nil(t, err)
 	ensure.DeepEqual(t, len(beforeDelete), 2)
-	err = jedis.Delete(conn, sqjdb.ByID(luke.ID))
+	n, err := jedis.Delete(conn, sqjdb.ByID(luke.ID))
 	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
 	afterDelete, err := jedis.All(conn, byAge(luke.Age))
 	ensure.Nil(t, err)
 	ensure.DeepEqual(t, len(afterDelete), 1)
@@ -120,8 +118,9 @@ func TestPatch(t *testing.T) {
 	ensure.Nil(t, err)
 	ensure.DeepEqual(t, beforePatch.Name, luke.Name)
 	const darth = "darth"
-	err = jedis.Patch(conn, &Jedi{Name: darth}, sqjdb.ByID(luke.ID))
+	n, err := jedis.Patch(conn, &Jedi{Name: darth}, sqjdb.ByID(luke.ID))
 	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
 	afterPatch, err := jedis.One(conn, sqjdb.ByID(luke.ID))
 	ensure.Nil(t, err)
 	ensure.DeepEqual(t, afterPatch.Name, darth)
@@ -133,10 +132,395 @@ func TestReplace(t *testing.T) {
 	ensure.Nil(t, err)
 	ensure.DeepEqual(t, beforeReplace.Name, luke.Name)
 	const darth = "darth"
-	err = jedis.Replace(conn, &Jedi{ID: luke.ID, Name: darth}, sqjdb.ByID(luke.ID))
+	n, err := jedis.Replace(conn, &Jedi{ID: luke.ID, Name: darth}, sqjdb.ByID(luke.ID))
 	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
 	afterReplace, err := jedis.One(conn, sqjdb.ByID(luke.ID))
 	ensure.Nil(t, err)
 	ensure.DeepEqual(t, afterReplace.Name, darth)
 	ensure.DeepEqual(t, afterReplace.Age, 0)
 }
+
+func TestUpsert(t *testing.T) {
+	conn := newConn(t)
+	const darth = "darth"
+	_, err := jedis.Upsert(conn, &Jedi{ID: luke.ID, Name: darth})
+	ensure.Nil(t, err)
+	afterUpsert, err := jedis.One(conn, sqjdb.ByID(luke.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, afterUpsert.Name, darth)
+	ensure.DeepEqual(t, afterUpsert.Age, 0)
+
+	inserted, err := jedis.Upsert(conn, &Jedi{Name: darth})
+	ensure.Nil(t, err)
+	ensure.NotDeepEqual(t, len(inserted.ID), 0)
+	all, err := jedis.All(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(all), 4)
+}
+
+func TestInsertMany(t *testing.T) {
+	conn := newConn(t)
+	inserted, err := jedis.InsertMany(conn, []*Jedi{
+		{Name: "obi-wan"},
+		{ID: ulid.Make().String(), Name: "anakin"},
+	})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(inserted), 2)
+	ensure.NotDeepEqual(t, len(inserted[0].ID), 0)
+	all, err := jedis.All(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(all), 5)
+}
+
+func TestInsertManyRollsBack(t *testing.T) {
+	conn := newConn(t)
+	_, err := jedis.InsertMany(conn, []*Jedi{
+		{Name: "obi-wan"},
+		{ID: yoda.ID, Name: "duplicate"},
+	})
+	ensure.NotNil(t, err)
+	all, err := jedis.All(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(all), 3)
+}
+
+func TestReplaceMissing(t *testing.T) {
+	conn := newConn(t)
+	n, err := jedis.Replace(conn, &Jedi{Name: "darth"}, sqjdb.ByID("missing"))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 0)
+}
+
+func TestIter(t *testing.T) {
+	conn := newConn(t)
+	var names []string
+	for doc, err := range jedis.Iter(conn, byAge(luke.Age)) {
+		ensure.Nil(t, err)
+		names = append(names, doc.Name)
+	}
+	ensure.DeepEqual(t, len(names), 2)
+}
+
+func TestIterBreak(t *testing.T) {
+	conn := newConn(t)
+	for _, err := range jedis.Iter(conn) {
+		ensure.Nil(t, err)
+		break
+	}
+	ensure.DeepEqual(t, conn.CheckReset(), "")
+}
+
+func TestGet(t *testing.T) {
+	conn := newConn(t)
+	yodaFetched, err := jedis.Get(conn, yoda.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, yodaFetched.Name, yoda.Name)
+	_, err = jedis.Get(conn, "missing")
+	ensure.DeepEqual(t, err, sqjdb.ErrNoDoc)
+}
+
+func TestGetMany(t *testing.T) {
+	conn := newConn(t)
+	byID, err := jedis.GetMany(conn, yoda.ID, "missing", leia.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(byID), 3)
+	ensure.DeepEqual(t, byID[yoda.ID].Name, yoda.Name)
+	ensure.DeepEqual(t, byID[leia.ID].Name, leia.Name)
+	ensure.True(t, byID["missing"] == nil)
+}
+
+func TestGetOrCreate(t *testing.T) {
+	conn := newConn(t)
+	doc, created, err := jedis.GetOrCreate(conn, sqjdb.ByID(yoda.ID), &Jedi{Name: "nope"})
+	ensure.Nil(t, err)
+	ensure.False(t, created)
+	ensure.DeepEqual(t, doc.Name, yoda.Name)
+
+	const darth = "darth"
+	filter := sqjdb.Where("Name").Eq(darth).SQL()
+	doc, created, err = jedis.GetOrCreate(conn, filter, &Jedi{Name: darth})
+	ensure.Nil(t, err)
+	ensure.True(t, created)
+	ensure.NotDeepEqual(t, len(doc.ID), 0)
+	again, created, err := jedis.GetOrCreate(conn, filter, &Jedi{Name: darth})
+	ensure.Nil(t, err)
+	ensure.False(t, created)
+	ensure.DeepEqual(t, again.ID, doc.ID)
+}
+
+func TestUpdateReturning(t *testing.T) {
+	conn := newConn(t)
+	const darth = "darth"
+	docs, err := jedis.UpdateReturning(conn, &Jedi{Name: darth}, sqjdb.ByID(luke.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 1)
+	ensure.DeepEqual(t, docs[0].ID, luke.ID)
+	ensure.DeepEqual(t, docs[0].Name, darth)
+	ensure.DeepEqual(t, docs[0].Age, luke.Age)
+}
+
+func TestPatchFields(t *testing.T) {
+	conn := newConn(t)
+	n, err := jedis.PatchFields(conn, map[string]any{"Name": "darth", "Age": nil},
+		sqjdb.ByID(luke.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+	afterPatch, err := jedis.Get(conn, luke.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, afterPatch.Name, "darth")
+	ensure.DeepEqual(t, afterPatch.Age, 0)
+}
+
+type Doc struct {
+	ID      string `json:",omitempty"`
+	Name    string `json:",omitempty"`
+	Version int    `json:",omitempty"`
+}
+
+func TestVersioned(t *testing.T) {
+	conn := newConn(t)
+	docs := sqjdb.NewTable[Doc](t.Name(), sqjdb.Versioned("Version"))
+	ensure.Nil(t, docs.Migrate(conn))
+	doc, err := docs.Insert(conn, &Doc{Name: "a"})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, doc.Version, 1)
+
+	n, err := docs.Replace(conn, &Doc{ID: doc.ID, Name: "b", Version: 1}, sqjdb.ByID(doc.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+	fetched, err := docs.Get(conn, doc.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, fetched.Version, 2)
+
+	_, err = docs.Patch(conn, &Doc{Name: "stale", Version: 1}, sqjdb.ByID(doc.ID))
+	ensure.DeepEqual(t, err, sqjdb.ErrConflict)
+	n, err = docs.Patch(conn, &Doc{Name: "c", Version: 2}, sqjdb.ByID(doc.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+	fetched, err = docs.Get(conn, doc.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, fetched.Name, "c")
+	ensure.DeepEqual(t, fetched.Version, 3)
+
+	n, err = docs.Patch(conn, &Doc{Name: "c", Version: 2}, sqjdb.ByID("missing"))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 0)
+}
+
+type SoftJedi struct {
+	ID        string     `json:",omitempty"`
+	Name      string     `json:",omitempty"`
+	DeletedAt *time.Time `json:",omitempty"`
+}
+
+func TestSoftDelete(t *testing.T) {
+	conn := newConn(t)
+	soft := sqjdb.NewTable[SoftJedi](t.Name(), sqjdb.SoftDelete("DeletedAt"))
+	ensure.Nil(t, soft.Migrate(conn))
+	a, err := soft.Insert(conn, &SoftJedi{Name: "a"})
+	ensure.Nil(t, err)
+	_, err = soft.Insert(conn, &SoftJedi{Name: "b"})
+	ensure.Nil(t, err)
+
+	n, err := soft.Delete(conn, sqjdb.ByID(a.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+	n, err = soft.Delete(conn, sqjdb.ByID(a.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 0)
+	_, err = soft.Get(conn, a.ID)
+	ensure.DeepEqual(t, err, sqjdb.ErrNoDoc)
+	all, err := soft.All(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(all), 1)
+	all, err = soft.AllIncludingDeleted(conn, sqjdb.ByID(a.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(all), 1)
+	ensure.NotNil(t, all[0].DeletedAt)
+
+	n, err = soft.Restore(conn, sqjdb.ByID(a.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+	restored, err := soft.Get(conn, a.ID)
+	ensure.Nil(t, err)
+	ensure.True(t, restored.DeletedAt == nil)
+
+	_, err = soft.Delete(conn, sqjdb.ByID(a.ID))
+	ensure.Nil(t, err)
+	n, err = soft.Purge(conn, sqjdb.ByID(a.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+	all, err = soft.AllIncludingDeleted(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(all), 1)
+}
+
+type Session struct {
+	ID        string     `json:",omitempty"`
+	ExpiresAt *time.Time `json:",omitempty"`
+}
+
+func TestExpires(t *testing.T) {
+	conn := newConn(t)
+	sessions := sqjdb.NewTable[Session](t.Name(), sqjdb.Expires("ExpiresAt"))
+	ensure.Nil(t, sessions.Migrate(conn))
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	expired, err := sessions.Insert(conn, &Session{ExpiresAt: &past})
+	ensure.Nil(t, err)
+	live, err := sessions.Insert(conn, &Session{ExpiresAt: &future})
+	ensure.Nil(t, err)
+	_, err = sessions.Insert(conn, &Session{})
+	ensure.Nil(t, err)
+
+	_, err = sessions.Get(conn, expired.ID)
+	ensure.DeepEqual(t, err, sqjdb.ErrNoDoc)
+	_, err = sessions.Get(conn, live.ID)
+	ensure.Nil(t, err)
+	all, err := sessions.All(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(all), 2)
+
+	n, err := sessions.PurgeExpired(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+	all, err = sessions.AllIncludingDeleted(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(all), 2)
+}
+
+func TestCount(t *testing.T) {
+	conn := newConn(t)
+	n, err := jedis.Count(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 3)
+	n, err = jedis.Count(conn, sqjdb.Where("Age").Eq(42).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 2)
+}
+
+type Holocron struct {
+	ID   string          `json:",omitempty"`
+	Data json.RawMessage `json:",omitempty"`
+}
+
+func TestAllRawMessageNotShared(t *testing.T) {
+	conn := newConn(t)
+	holocrons := sqjdb.NewTable[Holocron](t.Name())
+	ensure.Nil(t, holocrons.Migrate(conn))
+	for _, h := range []*Holocron{
+		{ID: "a", Data: json.RawMessage(`{"long":"xxxxxxxxxxxxxxxxxxxxxxxx"}`)},
+		{ID: "b", Data: json.RawMessage(`[1]`)},
+	} {
+		_, err := holocrons.Insert(conn, h)
+		ensure.Nil(t, err)
+	}
+	docs, err := holocrons.All(conn, sqjdb.OrderBy("ID", sqjdb.Asc))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, string(docs[0].Data), `{"long":"xxxxxxxxxxxxxxxxxxxxxxxx"}`)
+	ensure.DeepEqual(t, string(docs[1].Data), `[1]`)
+}
+
+func TestPatchShortJSON(t *testing.T) {
+	conn := newConn(t)
+	// Short JSON text can look like valid JSONB when bound as a blob.
+	n, err := jedis.PatchFields(conn, map[string]any{"A": 12}, jedis.ByID(yoda.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+	raw, err := jedis.OneRaw(conn, jedis.ByID(yoda.ID), sqjdb.SQL{Query: "and data->>'A' = 12"})
+	ensure.Nil(t, err)
+	ensure.True(t, len(raw) > 0)
+}
+
+type jediName string
+
+type upperName string
+
+func (n upperName) Value() (driver.Value, error) { return strings.ToLower(string(n)), nil }
+
+type age uint16
+
+// point binds itself as the distance from the origin.
+type point struct{ X, Y float64 }
+
+func (p point) BindSQLite(stmt *sqlite.Stmt, i int) error {
+	stmt.BindFloat(i, math.Hypot(p.X, p.Y))
+	return nil
+}
+
+// bound returns the value of the placeholder after binding v.
+func bound(t *testing.T, conn *sqlite.Conn, v any) any {
+	stmt, _, err := conn.PrepareTransient("select ?")
+	ensure.Nil(t, err)
+	defer stmt.Finalize()
+	if err := sqjdb.Bind(stmt, 1, v); err != nil {
+		return err
+	}
+	_, err = stmt.Step()
+	ensure.Nil(t, err)
+	switch stmt.ColumnType(0) {
+	case sqlite.TypeInteger:
+		return stmt.ColumnInt64(0)
+	case sqlite.TypeFloat:
+		return stmt.ColumnFloat(0)
+	case sqlite.TypeText:
+		return stmt.ColumnText(0)
+	case sqlite.TypeNull:
+		return nil
+	}
+	return stmt.ColumnType(0)
+}
+
+func TestBind(t *testing.T) {
+	conn := newConn(t)
+	name := jediName("yoda")
+	var missing *jediName
+	cases := []struct {
+		value, bound any
+	}{
+		{int8(-1), int64(-1)},
+		{uint(1), int64(1)},
+		{uint8(2), int64(2)},
+		{uint64(math.MaxInt64), int64(math.MaxInt64)},
+		{age(42), int64(42)},
+		{name, "yoda"},
+		{&name, "yoda"},
+		{missing, nil},
+		{upperName("LUKE"), "luke"},
+		{float32(1.5), 1.5},
+		{[]string{"a", "b"}, `["a","b"]`},
+		{map[string]int{"a": 1}, `{"a":1}`},
+		{Jedi{Name: "rey"}, `{"Name":"rey"}`},
+		{[2]int{1, 2}, `[1,2]`},
+		{point{3, 4}, 5.0},
+		{&point{3, 4}, 5.0},
+	}
+	for _, c := range cases {
+		ensure.DeepEqual(t, bound(t, conn, c.value), c.bound, c.value)
+	}
+	ensure.StringContains(t, bound(t, conn, uint64(math.MaxUint64)).(error).Error(), "overflows int64")
+	ensure.StringContains(t, bound(t, conn, make(chan int)).(error).Error(), "sqjdb: unexpected value")
+}
+
+func TestBindNamedID(t *testing.T) {
+	conn := newConn(t)
+	docs, err := jedis.All(conn, sqjdb.Where("Name").Eq(jediName(luke.Name)).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 1)
+	ensure.DeepEqual(t, docs[0].ID, luke.ID)
+}
+
+func TestBindJSON(t *testing.T) {
+	conn, starships := newStarships(t)
+	_, err := starships.Insert(conn, &Starship{Name: "falcon", Weapons: []string{"laser", "missile"}})
+	ensure.Nil(t, err)
+	_, err = starships.Insert(conn, &Starship{Name: "tie", Weapons: []string{"laser"}})
+	ensure.Nil(t, err)
+	docs, err := starships.All(conn, sqjdb.SQL{
+		Query: "where data->'Weapons' = ?",
+		Args:  []any{[]string{"laser", "missile"}},
+	})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 1)
+	ensure.DeepEqual(t, docs[0].Name, "falcon")
+}
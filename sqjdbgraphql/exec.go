@@ -0,0 +1,625 @@
+package sqjdbgraphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"github.com/daaku/sqjdb"
+)
+
+// orderedMap is a response object, which keeps the fields in the order they
+// were selected.
+type orderedMap []keyValue
+
+type keyValue struct {
+	key string
+	val any
+}
+
+func (m orderedMap) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, kv := range m {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(kv.key)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(kv.val)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(val)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+type executor struct {
+	s    *Schema
+	doc  *document
+	vars map[string]any
+	errs []*Error
+	// fields is the number of fields validated, with fragments expanded.
+	fields int
+}
+
+// execute runs the request, and reports false if it failed before execution.
+// Mutations are rejected if queryOnly is set.
+func (s *Schema) execute(ctx context.Context, req Request, queryOnly bool) (*Response, bool) {
+	fail := func(err error) (*Response, bool) {
+		return &Response{Errors: []*Error{{Message: err.Error()}}}, false
+	}
+	doc, err := parse(req.Query, s.MaxDepth, s.MaxFields)
+	if err != nil {
+		return fail(err)
+	}
+	var op *operation
+	for _, o := range doc.operations {
+		if req.OperationName == "" && len(doc.operations) == 1 || o.name == req.OperationName {
+			op = o
+			break
+		}
+	}
+	if op == nil {
+		if req.OperationName == "" {
+			return fail(fmt.Errorf("operation name required"))
+		}
+		return fail(fmt.Errorf("unknown operation %q", req.OperationName))
+	}
+	root := s.query
+	if op.kind == "mutation" {
+		if queryOnly {
+			return fail(fmt.Errorf("mutations are not allowed in GET requests"))
+		}
+		root = s.mutation
+	}
+	e := &executor{s: s, doc: doc}
+	if err := e.validate(op, root); err != nil {
+		return fail(err)
+	}
+	if e.vars, err = s.coerceVariables(op, req.Variables); err != nil {
+		return fail(err)
+	}
+	data, ok := e.executeSelections(ctx, root, nil, op.selections, nil)
+	res := &Response{Errors: e.errs}
+	if ok {
+		res.Data = data
+	}
+	return res, true
+}
+
+// validate checks the operation selects valid fields with valid arguments.
+// The depth and field limits are checked with fragments expanded, since a
+// small query can spread a fragment many times.
+func (e *executor) validate(op *operation, root *gqlType) error {
+	defined := map[string]bool{}
+	for _, v := range op.vars {
+		if defined[v.name] {
+			return fmt.Errorf("duplicate variable $%s", v.name)
+		}
+		defined[v.name] = true
+	}
+	return e.validateSelections(root, op.selections, defined, map[string]bool{}, 1)
+}
+
+func (e *executor) validateSelections(typ *gqlType, sels []*selection, defined, visiting map[string]bool, depth int) error {
+	if depth > e.s.MaxDepth {
+		return fmt.Errorf("nesting exceeds the maximum depth of %d", e.s.MaxDepth)
+	}
+	// Fields selected with the same response key are merged, so they must be
+	// the same field with the same arguments.
+	keys := map[string]*selection{}
+	for _, sel := range sels {
+		if sel.name != "" {
+			if prev, ok := keys[sel.key()]; ok && (prev.name != sel.name || !reflect.DeepEqual(prev.args, sel.args)) {
+				return fmt.Errorf("fields selected as %q conflict", sel.key())
+			}
+			keys[sel.key()] = sel
+			e.fields++
+			if e.fields > e.s.MaxFields {
+				return fmt.Errorf("query exceeds the maximum of %d fields", e.s.MaxFields)
+			}
+		}
+		for _, d := range sel.directives {
+			if d.name != "skip" && d.name != "include" {
+				return fmt.Errorf("unknown directive @%s", d.name)
+			}
+			if err := checkArgs(d.args, []*field{{name: "if", typ: nonNull(booleanType)}}, defined); err != nil {
+				return fmt.Errorf("directive @%s: %w", d.name, err)
+			}
+		}
+		switch {
+		case sel.name == "__typename":
+			if len(sel.args) > 0 || len(sel.selections) > 0 {
+				return fmt.Errorf("field __typename can not have arguments or a selection")
+			}
+		case sel.name != "":
+			f := typ.field(sel.name)
+			if f == nil {
+				return fmt.Errorf("cannot query field %q on type %q", sel.name, typ.name)
+			}
+			if err := checkArgs(sel.args, f.args, defined); err != nil {
+				return fmt.Errorf("field %q: %w", sel.name, err)
+			}
+			named := f.typ.named()
+			if named.kind == kindObject {
+				if len(sel.selections) == 0 {
+					return fmt.Errorf("field %q of type %s must have a selection", sel.name, f.typ)
+				}
+				if err := e.validateSelections(named, sel.selections, defined, visiting, depth+1); err != nil {
+					return err
+				}
+			} else if len(sel.selections) > 0 {
+				return fmt.Errorf("field %q of type %s can not have a selection", sel.name, f.typ)
+			}
+		case sel.spread != "":
+			frag, ok := e.doc.fragments[sel.spread]
+			if !ok {
+				return fmt.Errorf("unknown fragment %q", sel.spread)
+			}
+			if visiting[frag.name] {
+				return fmt.Errorf("fragment %q spreads itself", frag.name)
+			}
+			if frag.on != typ.name {
+				return fmt.Errorf("fragment %q on %q can not be spread on %q", frag.name, frag.on, typ.name)
+			}
+			visiting[frag.name] = true
+			err := e.validateSelections(typ, frag.selections, defined, visiting, depth)
+			delete(visiting, frag.name)
+			if err != nil {
+				return err
+			}
+		default:
+			if sel.on != "" && sel.on != typ.name {
+				return fmt.Errorf("inline fragment on %q can not be spread on %q", sel.on, typ.name)
+			}
+			if err := e.validateSelections(typ, sel.selections, defined, visiting, depth); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkArgs checks the arguments are defined and required ones are given, and
+// the variables they use are defined.
+func checkArgs(args []*argument, defs []*field, defined map[string]bool) error {
+	for _, a := range args {
+		if !slices.ContainsFunc(defs, func(f *field) bool { return f.name == a.name }) {
+			return fmt.Errorf("unknown argument %q", a.name)
+		}
+		if err := checkVars(a.value, defined); err != nil {
+			return err
+		}
+	}
+	for _, f := range defs {
+		if f.typ.kind == kindNonNull && !slices.ContainsFunc(args, func(a *argument) bool { return a.name == f.name }) {
+			return fmt.Errorf("argument %q is required", f.name)
+		}
+	}
+	return nil
+}
+
+func checkVars(v any, defined map[string]bool) error {
+	switch v := v.(type) {
+	case variable:
+		if !defined[string(v)] {
+			return fmt.Errorf("undefined variable $%s", v)
+		}
+	case []any:
+		for _, e := range v {
+			if err := checkVars(e, defined); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		for _, e := range v {
+			if err := checkVars(e, defined); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// inputType returns the input type for a type reference.
+func (s *Schema) inputType(ref *typeRef) (*gqlType, error) {
+	var typ *gqlType
+	if ref.list != nil {
+		elem, err := s.inputType(ref.list)
+		if err != nil {
+			return nil, err
+		}
+		typ = listOf(elem)
+	} else {
+		typ = s.types[ref.name]
+		if typ == nil {
+			return nil, fmt.Errorf("unknown type %q", ref.name)
+		}
+		if typ.kind == kindObject {
+			return nil, fmt.Errorf("type %q is not an input type", ref.name)
+		}
+	}
+	if ref.nonNull {
+		typ = nonNull(typ)
+	}
+	return typ, nil
+}
+
+func (s *Schema) coerceVariables(op *operation, given map[string]any) (map[string]any, error) {
+	vars := map[string]any{}
+	for _, v := range op.vars {
+		typ, err := s.inputType(v.typ)
+		if err != nil {
+			return nil, fmt.Errorf("variable $%s: %w", v.name, err)
+		}
+		value, ok := given[v.name]
+		if !ok && v.def != nil {
+			value, ok = v.def, true
+		}
+		if !ok {
+			if typ.kind == kindNonNull {
+				return nil, fmt.Errorf("variable $%s of type %s is required", v.name, typ)
+			}
+			continue
+		}
+		c, err := coerceInput(typ, value)
+		if err != nil {
+			return nil, fmt.Errorf("variable $%s: %w", v.name, err)
+		}
+		vars[v.name] = c
+	}
+	return vars, nil
+}
+
+// resolve substitutes the variables in a value, and reports false if it is a
+// variable that was not given.
+func (e *executor) resolve(v any) (any, bool) {
+	switch v := v.(type) {
+	case variable:
+		value, ok := e.vars[string(v)]
+		return value, ok
+	case []any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			list[i], _ = e.resolve(item)
+		}
+		return list, true
+	case map[string]any:
+		obj := make(map[string]any, len(v))
+		for k, item := range v {
+			if value, ok := e.resolve(item); ok {
+				obj[k] = value
+			}
+		}
+		return obj, true
+	}
+	return v, true
+}
+
+// coerceInput checks the value is valid for the input type, and converts it
+// to int64, float64, string, bool, []any or map[string]any.
+func coerceInput(typ *gqlType, v any) (any, error) {
+	if typ.kind == kindNonNull {
+		if v == nil {
+			return nil, fmt.Errorf("expected a non-null %s", typ.ofType)
+		}
+		return coerceInput(typ.ofType, v)
+	}
+	if v == nil {
+		return nil, nil
+	}
+	switch typ.kind {
+	case kindList:
+		items, ok := v.([]any)
+		if !ok {
+			items = []any{v}
+		}
+		list := make([]any, len(items))
+		for i, item := range items {
+			c, err := coerceInput(typ.ofType, item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = c
+		}
+		return list, nil
+	case kindInputObject:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected a %s object", typ.name)
+		}
+		for k := range obj {
+			if typ.field(k) == nil {
+				return nil, fmt.Errorf("unknown field %q of %s", k, typ.name)
+			}
+		}
+		c := make(map[string]any, len(obj))
+		for _, f := range typ.fields {
+			fv, ok := obj[f.name]
+			if !ok {
+				if f.typ.kind == kindNonNull {
+					return nil, fmt.Errorf("field %q of %s is required", f.name, typ.name)
+				}
+				continue
+			}
+			value, err := coerceInput(f.typ, fv)
+			if err != nil {
+				return nil, fmt.Errorf("field %q of %s: %w", f.name, typ.name, err)
+			}
+			c[f.name] = value
+		}
+		return c, nil
+	case kindEnum:
+		var s string
+		switch v := v.(type) {
+		case enumValue:
+			s = string(v)
+		case string:
+			s = v
+		}
+		if !slices.Contains(typ.values, s) {
+			return nil, fmt.Errorf("invalid %s value %v", typ.name, v)
+		}
+		return s, nil
+	}
+	switch n := v.(type) {
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			v = i
+		} else if f, err := n.Float64(); err == nil {
+			v = f
+		}
+	case int:
+		v = int64(n)
+	case int32:
+		v = int64(n)
+	case float64:
+		// Variables decoded without UseNumber have integers as floats.
+		if typ.name == "Int" && n == math.Trunc(n) {
+			v = int64(n)
+		}
+	}
+	switch typ.name {
+	case "Int":
+		if i, ok := v.(int64); ok && i >= math.MinInt32 && i <= math.MaxInt32 {
+			return i, nil
+		}
+	case "Float":
+		switch v := v.(type) {
+		case int64:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		}
+	case "String":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case "Boolean":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case "ID":
+		switch v := v.(type) {
+		case string:
+			return v, nil
+		case int64:
+			return strconv.FormatInt(v, 10), nil
+		}
+	case "JSON":
+		if _, ok := v.(enumValue); !ok {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid %s value %v", typ.name, v)
+}
+
+// include evaluates the skip and include directives.
+func (e *executor) include(dirs []*directive) bool {
+	for _, d := range dirs {
+		for _, a := range d.args {
+			v, _ := e.resolve(a.value)
+			if b, ok := v.(bool); ok && b == (d.name == "skip") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// fieldGroup is the fields selected with the same response key, whose
+// selections are merged.
+type fieldGroup struct {
+	key    string
+	fields []*selection
+}
+
+func (e *executor) collectFields(sels []*selection, groups []*fieldGroup) []*fieldGroup {
+	for _, sel := range sels {
+		if !e.include(sel.directives) {
+			continue
+		}
+		switch {
+		case sel.name != "":
+			i := slices.IndexFunc(groups, func(g *fieldGroup) bool { return g.key == sel.key() })
+			if i < 0 {
+				groups = append(groups, &fieldGroup{key: sel.key(), fields: []*selection{sel}})
+			} else {
+				groups[i].fields = append(groups[i].fields, sel)
+			}
+		case sel.spread != "":
+			groups = e.collectFields(e.doc.fragments[sel.spread].selections, groups)
+		default:
+			groups = e.collectFields(sel.selections, groups)
+		}
+	}
+	return groups
+}
+
+func (e *executor) fieldError(path []any, err error) {
+	e.errs = append(e.errs, &Error{Message: err.Error(), Path: path})
+}
+
+// publicError returns the error of a resolver as sent to clients. Errors from
+// the tables may include SQL and other internals, so only the ones caused by
+// the request have their own message.
+func publicError(err error) error {
+	var input *inputError
+	var validation *sqjdb.ValidationError
+	var unique *sqjdb.UniqueViolationError
+	switch {
+	case errors.As(err, &input):
+		return input
+	case errors.Is(err, sqjdb.ErrInvalidCursor):
+		return errors.New("invalid cursor")
+	case errors.Is(err, sqjdb.ErrNoDoc):
+		return errors.New("document not found")
+	case errors.As(err, &validation):
+		return validation.Err
+	case errors.As(err, &unique):
+		return fmt.Errorf("duplicate value for field %q", unique.Field)
+	case errors.Is(err, sqjdb.ErrConflict):
+		return errors.New("version conflict")
+	}
+	return errors.New("internal error")
+}
+
+// executeSelections returns the selected fields of an object. It reports false
+// if a non-null field is null, in which case the object is null.
+func (e *executor) executeSelections(ctx context.Context, typ *gqlType, obj map[string]any, sels []*selection, path []any) (orderedMap, bool) {
+	groups := e.collectFields(sels, nil)
+	out := make(orderedMap, 0, len(groups))
+	for _, g := range groups {
+		sel := g.fields[0]
+		fieldPath := append(slices.Clip(path), g.key)
+		if sel.name == "__typename" {
+			out = append(out, keyValue{g.key, typ.name})
+			continue
+		}
+		f := typ.field(sel.name)
+		var value any
+		if f.resolve != nil {
+			args, err := e.coerceArgs(f, sel.args)
+			if err == nil {
+				value, err = f.resolve(ctx, args)
+				if err != nil {
+					err = publicError(err)
+				}
+			}
+			if err != nil {
+				e.fieldError(fieldPath, err)
+				if f.typ.kind == kindNonNull {
+					return nil, false
+				}
+				out = append(out, keyValue{g.key, nil})
+				continue
+			}
+		} else {
+			value = obj[f.name]
+		}
+		var sub []*selection
+		for _, s := range g.fields {
+			sub = append(sub, s.selections...)
+		}
+		v, ok := e.complete(ctx, f.typ, value, sub, fieldPath)
+		if !ok {
+			if f.typ.kind == kindNonNull {
+				return nil, false
+			}
+			v = nil
+		}
+		out = append(out, keyValue{g.key, v})
+	}
+	return out, true
+}
+
+func (e *executor) coerceArgs(f *field, args []*argument) (map[string]any, error) {
+	values := map[string]any{}
+	for _, def := range f.args {
+		i := slices.IndexFunc(args, func(a *argument) bool { return a.name == def.name })
+		var v any
+		ok := i >= 0
+		if ok {
+			v, ok = e.resolve(args[i].value)
+		}
+		if !ok {
+			if def.typ.kind == kindNonNull {
+				return nil, fmt.Errorf("argument %q is required", def.name)
+			}
+			continue
+		}
+		c, err := coerceInput(def.typ, v)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: %w", def.name, err)
+		}
+		values[def.name] = c
+	}
+	return values, nil
+}
+
+// complete converts a value to the output type, executing the selections of
+// objects. It reports false if the value is null because of an error, which
+// makes the nearest nullable parent null.
+func (e *executor) complete(ctx context.Context, typ *gqlType, v any, sels []*selection, path []any) (any, bool) {
+	if typ.kind == kindNonNull {
+		c, ok := e.complete(ctx, typ.ofType, v, sels, path)
+		if ok && c == nil {
+			e.fieldError(path, fmt.Errorf("non-null field of type %s is null", typ))
+			ok = false
+		}
+		return c, ok
+	}
+	if v == nil {
+		return nil, true
+	}
+	switch typ.kind {
+	case kindList:
+		items, ok := v.([]any)
+		if !ok {
+			e.fieldError(path, fmt.Errorf("expected a list for %s", typ))
+			return nil, false
+		}
+		list := make([]any, len(items))
+		for i, item := range items {
+			c, ok := e.complete(ctx, typ.ofType, item, sels, append(slices.Clip(path), i))
+			if !ok && typ.ofType.kind == kindNonNull {
+				return nil, false
+			}
+			list[i] = c
+		}
+		return list, true
+	case kindObject:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			e.fieldError(path, fmt.Errorf("expected an object for %s", typ))
+			return nil, false
+		}
+		m, ok := e.executeSelections(ctx, typ, obj, sels, path)
+		if !ok {
+			return nil, false
+		}
+		return m, true
+	}
+	if typ == idType {
+		switch id := v.(type) {
+		case json.Number:
+			return id.String(), true
+		case int64:
+			return strconv.FormatInt(id, 10), true
+		}
+	}
+	return v, true
+}
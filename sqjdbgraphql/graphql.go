@@ -0,0 +1,515 @@
+// Package sqjdbgraphql serves sqjdb tables as a GraphQL API.
+//
+// Register adds queries and mutations for a table, with types derived from its
+// documents by reflection, following their JSON encoding. For a table
+// registered as "jedi" with documents of type Jedi, the schema includes:
+//
+//	type Query {
+//	  jedi(id: ID!): Jedi
+//	  jediList(filter: JediFilter, first: Int, after: String): JediPage!
+//	  jediCount(filter: JediFilter): Int!
+//	}
+//
+//	type Mutation {
+//	  createJedi(input: JediInput!): Jedi!
+//	  updateJedi(id: ID!, input: JediInput!): Jedi
+//	  deleteJedi(id: ID!): Boolean!
+//	}
+//
+// Lists are paginated like Table.Paginate, returning the cursor for the next
+// page. Filters have a field for each scalar document field, testing equality,
+// and fields with the _ne, _gt, _gte, _lt, _lte and _like suffixes for the
+// other comparisons. Updates merge the given fields into the document and
+// replace it, so validation and hooks run as for Table.Replace.
+//
+// Queries, mutations, variables, fragments, the skip and include directives,
+// and introspection are supported. Subscriptions are not. Queries are limited
+// in depth and size with fragments expanded, since they are usually untrusted.
+// Errors from the tables are reported as "internal error", unless caused by
+// the request, like validation errors and unique violations.
+package sqjdbgraphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+)
+
+// Schema is a GraphQL schema over registered tables. It is an http.Handler
+// serving GraphQL requests. Tables must be registered, and the limits changed,
+// before serving requests.
+type Schema struct {
+	// MaxDepth is the deepest nesting of selections, lists and objects allowed
+	// in a query. New sets it to 16.
+	MaxDepth int
+	// MaxFields is the most fields a query can select. New sets it to 256.
+	MaxFields int
+	// MaxBodyBytes is the largest request body read by ServeHTTP. New sets it
+	// to 1 MiB.
+	MaxBodyBytes int64
+
+	types    map[string]*gqlType
+	goTypes  map[string]reflect.Type
+	query    *gqlType
+	mutation *gqlType
+
+	introspection     sync.Once
+	introspectionData map[string]any
+}
+
+// New returns an empty schema.
+func New() *Schema {
+	s := &Schema{
+		MaxDepth:     16,
+		MaxFields:    256,
+		MaxBodyBytes: 1 << 20,
+		types:        map[string]*gqlType{},
+		goTypes:      map[string]reflect.Type{},
+		query:        &gqlType{kind: kindObject, name: "Query"},
+		mutation:     &gqlType{kind: kindObject, name: "Mutation"},
+	}
+	for _, t := range []*gqlType{stringType, intType, floatType, booleanType, idType, jsonType, s.query, s.mutation} {
+		s.addType(t)
+	}
+	s.addMetaTypes()
+	return s
+}
+
+// Register adds the queries and mutations for the table to the schema, using
+// name for the root fields. It panics if the names are already used.
+func Register[T any](s *Schema, name string, t *sqjdb.PoolTable[T]) {
+	typ := reflect.TypeFor[T]()
+	if typ.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("sqjdbgraphql: %v is not a struct", typ))
+	}
+	if !nameRE.MatchString(name) {
+		panic(fmt.Sprintf("sqjdbgraphql: invalid name %q", name))
+	}
+	idKey := t.Table.IDKey()
+	var idField *reflect.StructField
+	for _, f := range docFields(typ) {
+		if f.Name == idKey {
+			idField = &f
+		}
+	}
+	if idField == nil {
+		panic(fmt.Sprintf("sqjdbgraphql: %v has no ID field %q", typ, idKey))
+	}
+	r := &resolver[T]{t: t, idKey: idKey, idKind: idField.Type.Kind(), filters: map[string]filterField{}}
+
+	obj := s.structType(typ, false, idKey)
+	input := s.structType(typ, true, idKey)
+	page := &gqlType{kind: kindObject, name: typ.Name() + "Page", fields: []*field{
+		{name: "items", typ: nonNull(listOf(nonNull(obj)))},
+		{name: "next", typ: stringType, desc: "The cursor for the next page, null on the last page."},
+	}}
+	filter := &gqlType{kind: kindInputObject, name: typ.Name() + "Filter"}
+	for _, f := range obj.fields {
+		var ops []string
+		switch f.typ {
+		case stringType, idType:
+			ops = []string{"", "ne", "gt", "gte", "lt", "lte", "like"}
+		case intType, floatType:
+			ops = []string{"", "ne", "gt", "gte", "lt", "lte"}
+		case booleanType:
+			ops = []string{"", "ne"}
+		}
+		for _, op := range ops {
+			name := f.name
+			if op != "" {
+				name += "_" + op
+			}
+			// Filters compare with the stored IDs, which may be numbers.
+			typ := f.typ
+			if f.typ == idType {
+				typ = stringType
+				if r.numericID() {
+					typ = intType
+				}
+			}
+			if op == "like" && typ != stringType {
+				continue
+			}
+			filter.fields = append(filter.fields, &field{name: name, typ: typ})
+			r.filters[name] = filterField{name: f.name, op: op}
+		}
+	}
+	// The page and filter types are shared by tables with the same documents.
+	if _, ok := s.types[page.name]; !ok {
+		s.addType(page)
+		s.addType(filter)
+	}
+	filter = s.types[filter.name]
+
+	upper := string(unicode.ToUpper(rune(name[0]))) + name[1:]
+	idArg := &field{name: "id", typ: nonNull(idType)}
+	s.addField(s.query, &field{
+		name:    name,
+		typ:     obj,
+		args:    []*field{idArg},
+		resolve: r.get,
+	})
+	s.addField(s.query, &field{
+		name: name + "List",
+		typ:  nonNull(page),
+		args: []*field{
+			{name: "filter", typ: filter},
+			{name: "first", typ: intType, desc: "The page size, 50 by default."},
+			{name: "after", typ: stringType, desc: "The cursor of the page."},
+		},
+		resolve: r.list,
+	})
+	s.addField(s.query, &field{
+		name:    name + "Count",
+		typ:     nonNull(intType),
+		args:    []*field{{name: "filter", typ: filter}},
+		resolve: r.count,
+	})
+	s.addField(s.mutation, &field{
+		name:    "create" + upper,
+		typ:     nonNull(obj),
+		args:    []*field{{name: "input", typ: nonNull(input)}},
+		resolve: r.create,
+	})
+	s.addField(s.mutation, &field{
+		name:    "update" + upper,
+		typ:     obj,
+		args:    []*field{idArg, {name: "input", typ: nonNull(input)}},
+		resolve: r.update,
+		desc:    "Merges the input into the document, returning null if it does not exist.",
+	})
+	s.addField(s.mutation, &field{
+		name:    "delete" + upper,
+		typ:     nonNull(booleanType),
+		args:    []*field{idArg},
+		resolve: r.delete,
+		desc:    "Deletes the document, returning false if it does not exist.",
+	})
+}
+
+// filterField is the document field and comparison of a filter field.
+type filterField struct {
+	name string
+	op   string
+}
+
+type resolver[T any] struct {
+	t       *sqjdb.PoolTable[T]
+	idKey   string
+	idKind  reflect.Kind
+	filters map[string]filterField
+}
+
+// toValue converts a document to its JSON representation.
+func toValue(v any) (any, error) {
+	jsonS, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSON(jsonS)
+}
+
+func decodeJSON(jsonS []byte) (any, error) {
+	dec := json.NewDecoder(strings.NewReader(string(jsonS)))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// fromValue converts the JSON representation of a document to the document.
+func fromValue(v any, doc any) error {
+	jsonS, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(jsonS, doc)
+}
+
+func (r *resolver[T]) numericID() bool {
+	switch r.idKind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// byID returns the query selecting the document with the ID argument.
+func (r *resolver[T]) byID(id any) (sqjdb.SQL, error) {
+	if r.numericID() {
+		n, err := strconv.ParseInt(id.(string), 10, 64)
+		if err != nil {
+			return sqjdb.SQL{}, &inputError{fmt.Sprintf("invalid id %q", id)}
+		}
+		return r.t.Table.ByID(n), nil
+	}
+	return r.t.Table.ByID(id), nil
+}
+
+// conds returns the conditions for a filter argument.
+func (r *resolver[T]) conds(filter any) []sqjdb.Cond {
+	m, _ := filter.(map[string]any)
+	conds := make([]sqjdb.Cond, 0, len(m))
+	for key, v := range m {
+		ff := r.filters[key]
+		f := sqjdb.Where(ff.name)
+		switch ff.op {
+		case "":
+			if v == nil {
+				conds = append(conds, f.IsNull())
+			} else {
+				conds = append(conds, f.Eq(v))
+			}
+		case "ne":
+			if v == nil {
+				conds = append(conds, f.IsNotNull())
+			} else {
+				conds = append(conds, f.Ne(v))
+			}
+		case "gt":
+			conds = append(conds, f.Gt(v))
+		case "gte":
+			conds = append(conds, f.Gte(v))
+		case "lt":
+			conds = append(conds, f.Lt(v))
+		case "lte":
+			conds = append(conds, f.Lte(v))
+		case "like":
+			s, _ := v.(string)
+			conds = append(conds, f.Like(s))
+		}
+	}
+	return conds
+}
+
+func (r *resolver[T]) get(ctx context.Context, args map[string]any) (any, error) {
+	byID, err := r.byID(args["id"])
+	if err != nil {
+		return nil, err
+	}
+	doc, err := r.t.One(ctx, byID)
+	if errors.Is(err, sqjdb.ErrNoDoc) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toValue(doc)
+}
+
+func (r *resolver[T]) list(ctx context.Context, args map[string]any) (any, error) {
+	first := 50
+	if n, ok := args["first"].(int64); ok {
+		first = int(n)
+	}
+	after, _ := args["after"].(string)
+	var docs []*T
+	var next string
+	err := r.t.Read(ctx, func(conn *sqlite.Conn) (err error) {
+		docs, next, err = r.t.Table.Paginate(conn, after, first, r.conds(args["filter"])...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if docs == nil {
+		docs = []*T{}
+	}
+	items, err := toValue(docs)
+	if err != nil {
+		return nil, err
+	}
+	page := map[string]any{"items": items, "next": nil}
+	if next != "" {
+		page["next"] = next
+	}
+	return page, nil
+}
+
+func (r *resolver[T]) count(ctx context.Context, args map[string]any) (any, error) {
+	var sqls []sqjdb.SQL
+	if conds := r.conds(args["filter"]); len(conds) > 0 {
+		sqls = append(sqls, conds[0].And(conds[1:]...).SQL())
+	}
+	n, err := r.t.Count(ctx, sqls...)
+	if err != nil {
+		return nil, err
+	}
+	return int64(n), nil
+}
+
+// input returns the input argument, with the ID converted to the stored type.
+func (r *resolver[T]) input(args map[string]any) (map[string]any, error) {
+	input := args["input"].(map[string]any)
+	if id, ok := input[r.idKey].(string); ok && r.numericID() {
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return nil, &inputError{fmt.Sprintf("invalid id %q", id)}
+		}
+		input[r.idKey] = n
+	}
+	return input, nil
+}
+
+func (r *resolver[T]) create(ctx context.Context, args map[string]any) (any, error) {
+	input, err := r.input(args)
+	if err != nil {
+		return nil, err
+	}
+	doc := new(T)
+	if err := fromValue(input, doc); err != nil {
+		return nil, err
+	}
+	doc, err = r.t.Insert(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	return toValue(doc)
+}
+
+func (r *resolver[T]) update(ctx context.Context, args map[string]any) (any, error) {
+	byID, err := r.byID(args["id"])
+	if err != nil {
+		return nil, err
+	}
+	if id, ok := args["input"].(map[string]any)[r.idKey]; ok && id != args["id"] {
+		return nil, &inputError{"can not change the id"}
+	}
+	input, err := r.input(args)
+	if err != nil {
+		return nil, err
+	}
+	var doc *T
+	err = r.t.Do(ctx, func(conn *sqlite.Conn) error {
+		return sqjdb.WithTx(conn, func(conn *sqlite.Conn) (err error) {
+			doc, err = r.t.Table.One(conn, byID)
+			if err != nil {
+				return err
+			}
+			if err := fromValue(input, doc); err != nil {
+				return err
+			}
+			_, err = r.t.Table.Replace(conn, doc, byID)
+			return err
+		})
+	})
+	if errors.Is(err, sqjdb.ErrNoDoc) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toValue(doc)
+}
+
+func (r *resolver[T]) delete(ctx context.Context, args map[string]any) (any, error) {
+	byID, err := r.byID(args["id"])
+	if err != nil {
+		return nil, err
+	}
+	n, err := r.t.Delete(ctx, byID)
+	if err != nil {
+		return nil, err
+	}
+	return n > 0, nil
+}
+
+// inputError is an invalid argument, whose message is sent to clients.
+type inputError struct {
+	msg string
+}
+
+func (e *inputError) Error() string {
+	return e.msg
+}
+
+// Request is a GraphQL request.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Error is a GraphQL error.
+type Error struct {
+	Message string `json:"message"`
+	// Path is the response path of the field that failed, if any.
+	Path []any `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Response is a GraphQL response.
+type Response struct {
+	Data   any      `json:"data,omitempty"`
+	Errors []*Error `json:"errors,omitempty"`
+}
+
+// ServeHTTP serves GraphQL requests, either as a JSON body in a POST, or as
+// query parameters in a GET, which can only run queries.
+func (s *Schema) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	switch r.Method {
+	case http.MethodPost:
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.MaxBodyBytes))
+		dec.UseNumber()
+		if err := dec.Decode(&req); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeResponse(w, http.StatusRequestEntityTooLarge, &Response{Errors: []*Error{{Message: "request body too large"}}})
+				return
+			}
+			writeResponse(w, http.StatusBadRequest, &Response{Errors: []*Error{{Message: "invalid request: " + err.Error()}}})
+			return
+		}
+	case http.MethodGet:
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			dec := json.NewDecoder(strings.NewReader(v))
+			dec.UseNumber()
+			if err := dec.Decode(&req.Variables); err != nil {
+				writeResponse(w, http.StatusBadRequest, &Response{Errors: []*Error{{Message: "invalid variables: " + err.Error()}}})
+				return
+			}
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeResponse(w, http.StatusMethodNotAllowed, &Response{Errors: []*Error{{Message: "method not allowed"}}})
+		return
+	}
+	res, ok := s.execute(r.Context(), req, r.Method == http.MethodGet)
+	status := http.StatusOK
+	if !ok {
+		status = http.StatusBadRequest
+	}
+	writeResponse(w, status, res)
+}
+
+func writeResponse(w http.ResponseWriter, status int, res *Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
+
+// Execute runs a GraphQL request.
+func (s *Schema) Execute(ctx context.Context, req Request) *Response {
+	res, _ := s.execute(ctx, req, false)
+	return res
+}
@@ -0,0 +1,423 @@
+package sqjdbgraphql_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"github.com/daaku/sqjdb/sqjdbgraphql"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+type Lightsaber struct {
+	Color string `json:",omitempty"`
+}
+
+type Jedi struct {
+	ID     string      `json:",omitempty"`
+	Name   string      `json:",omitempty"`
+	Age    int         `json:",omitempty"`
+	Saber  *Lightsaber `json:",omitempty"`
+	Titles []string    `json:",omitempty"`
+}
+
+func (j *Jedi) Validate() error {
+	if j.Name == "" {
+		return errors.New("name required")
+	}
+	return nil
+}
+
+func newSchema(t *testing.T) *sqjdbgraphql.Schema {
+	pool, err := sqlitex.NewPool(
+		fmt.Sprintf("file:%s.db?mode=memory&cache=shared", t.Name()),
+		sqlitex.PoolOptions{PoolSize: 2},
+	)
+	ensure.Nil(t, err)
+	t.Cleanup(func() { pool.Close() })
+	jedis := sqjdb.NewTable[Jedi]("jedis")
+	pjedis := jedis.WithPool(pool)
+	ensure.Nil(t, pjedis.Migrate(context.Background()))
+	s := sqjdbgraphql.New()
+	sqjdbgraphql.Register(s, "jedi", pjedis)
+	return s
+}
+
+// run executes the query and returns the response as JSON.
+func run(t *testing.T, s *sqjdbgraphql.Schema, query string, vars map[string]any) string {
+	res := s.Execute(context.Background(), sqjdbgraphql.Request{Query: query, Variables: vars})
+	b, err := json.Marshal(res)
+	ensure.Nil(t, err)
+	return string(b)
+}
+
+// data executes the query, which must succeed, and decodes the data into v.
+func data(t *testing.T, s *sqjdbgraphql.Schema, query string, vars map[string]any, v any) {
+	res := s.Execute(context.Background(), sqjdbgraphql.Request{Query: query, Variables: vars})
+	ensure.DeepEqual(t, res.Errors, []*sqjdbgraphql.Error(nil))
+	b, err := json.Marshal(res.Data)
+	ensure.Nil(t, err)
+	ensure.Nil(t, json.Unmarshal(b, v))
+}
+
+func TestCRUD(t *testing.T) {
+	s := newSchema(t)
+	var created struct{ CreateJedi Jedi }
+	data(t, s, `mutation Create($input: JediInput!) { createJedi(input: $input) { ID Name Age Saber { Color } } }`,
+		map[string]any{"input": map[string]any{"Name": "yoda", "Age": 900, "Saber": map[string]any{"Color": "green"}}},
+		&created)
+	yoda := created.CreateJedi
+	ensure.NotDeepEqual(t, yoda.ID, "")
+	ensure.DeepEqual(t, yoda, Jedi{ID: yoda.ID, Name: "yoda", Age: 900, Saber: &Lightsaber{Color: "green"}})
+
+	ensure.DeepEqual(t,
+		run(t, s, `query ($id: ID!) { master: jedi(id: $id) { __typename ...name Saber { Color } } }
+			fragment name on Jedi { Name }`, map[string]any{"id": yoda.ID}),
+		`{"data":{"master":{"__typename":"Jedi","Name":"yoda","Saber":{"Color":"green"}}}}`)
+
+	var updated struct{ UpdateJedi Jedi }
+	data(t, s, fmt.Sprintf(`mutation { updateJedi(id: %q, input: {Age: 980, Titles: "master"}) { ID Name Age Titles } }`, yoda.ID), nil, &updated)
+	ensure.DeepEqual(t, updated.UpdateJedi, Jedi{ID: yoda.ID, Name: "yoda", Age: 980, Titles: []string{"master"}})
+
+	ensure.DeepEqual(t,
+		run(t, s, `mutation ($id: ID!) { updateJedi(id: $id, input: {Name: ""}) { Name } }`, map[string]any{"id": yoda.ID}),
+		`{"data":{"updateJedi":null},"errors":[{"message":"name required","path":["updateJedi"]}]}`)
+	ensure.DeepEqual(t,
+		run(t, s, `mutation ($id: ID!) { updateJedi(id: $id, input: {ID: "other"}) { Name } }`, map[string]any{"id": yoda.ID}),
+		`{"data":{"updateJedi":null},"errors":[{"message":"can not change the id","path":["updateJedi"]}]}`)
+
+	ensure.DeepEqual(t,
+		run(t, s, `mutation ($id: ID!) { a: deleteJedi(id: $id) b: deleteJedi(id: $id) }`, map[string]any{"id": yoda.ID}),
+		`{"data":{"a":true,"b":false}}`)
+	ensure.DeepEqual(t,
+		run(t, s, `query ($id: ID!) { jedi(id: $id) { Name } }`, map[string]any{"id": yoda.ID}),
+		`{"data":{"jedi":null}}`)
+}
+
+func TestList(t *testing.T) {
+	s := newSchema(t)
+	for _, jedi := range []string{`{Name: "yoda", Age: 980}`, `{Name: "luke", Age: 42}`, `{Name: "leia", Age: 42}`} {
+		data(t, s, `mutation { createJedi(input: `+jedi+`) { ID } }`, nil, &struct{}{})
+	}
+
+	var page struct {
+		JediList struct {
+			Items []Jedi
+			Next  string
+		}
+	}
+	data(t, s, `{ jediList(first: 2) { items { Name } next } }`, nil, &page)
+	ensure.DeepEqual(t, page.JediList.Items, []Jedi{{Name: "yoda"}, {Name: "luke"}})
+	after := page.JediList.Next
+	page.JediList.Next = ""
+	data(t, s, `query ($after: String) { jediList(first: 2, after: $after) { items { Name } next } }`,
+		map[string]any{"after": after}, &page)
+	ensure.DeepEqual(t, page.JediList.Items, []Jedi{{Name: "leia"}})
+	ensure.DeepEqual(t, page.JediList.Next, "")
+
+	ensure.DeepEqual(t,
+		run(t, s, `{ jediList(filter: {Age: 42, Name_like: "l%a"}) { items { Name } } jediCount(filter: {Age_gt: 42}) }`, nil),
+		`{"data":{"jediList":{"items":[{"Name":"leia"}]},"jediCount":1}}`)
+	ensure.DeepEqual(t,
+		run(t, s, `query ($skip: Boolean!) { jediCount @skip(if: $skip) all: jediCount @include(if: true) }`, map[string]any{"skip": true}),
+		`{"data":{"all":3}}`)
+}
+
+func TestErrors(t *testing.T) {
+	s := newSchema(t)
+	cases := map[string]string{
+		`{ jedi(id: "a") { Rank } }`:                `cannot query field "Rank" on type "Jedi"`,
+		`{ jedi { Name } }`:                         `field "jedi": argument "id" is required`,
+		`{ jedi(id: "a") }`:                         `field "jedi" of type Jedi must have a selection`,
+		`{ jediCount(filter: {Rank: 1}) }`:          `argument "filter": unknown field "Rank" of JediFilter`,
+		`{ jediCount(filter: {Age: "old"}) }`:       `argument "filter": field "Age" of JediFilter: invalid Int value old`,
+		`query ($id: ID) { jedi(id: $x) { Name } }`: `field "jedi": undefined variable $x`,
+		`subscription { jediCount }`:                `syntax error at offset 0: subscriptions are not supported`,
+		`{ jediList { items { Name } `:              `syntax error at offset 28: expected name`,
+
+		`{ jedi(id: "a") { ...missing } }`:                        `unknown fragment "missing"`,
+		`{ jedi(id: "a") { ...f } } fragment f on Jedi { ...f }`:  `fragment "f" spreads itself`,
+		`{ ...f } fragment f on Jedi { Name }`:                    `fragment "f" on "Jedi" can not be spread on "Query"`,
+		`{ ... on Jedi { Name } }`:                                `inline fragment on "Jedi" can not be spread on "Query"`,
+		`fragment f on Jedi { Name }`:                             `document has no operations`,
+		`{ jediCount(filter: {Name: """x}) }`:                     `syntax error at offset 27: unterminated string`,
+		`{ jediCount(filter: {Name: "x\q"}) }`:                    `syntax error at offset 27: invalid escape 'q'`,
+		`{ jediCount(filter: {Name: "x) }`:                        `syntax error at offset 27: unterminated string`,
+		`{ jediCount(filter: {Age: 1-}) }`:                        `syntax error at offset 27: invalid number`,
+		`{ jediCount(filter: {Age: %}) }`:                         `syntax error at offset 26: unexpected character '%'`,
+		`{}`:                                                      `syntax error at offset 2: empty selection set`,
+		``:                                                        `document has no operations`,
+		`{ jediCount @deprecated }`:                               `unknown directive @deprecated`,
+		`{ jediCount @skip }`:                                     `directive @skip: argument "if" is required`,
+		`query ($a: Int, $a: Int) { jediCount }`:                  `duplicate variable $a`,
+		`query ($f: Jedi) { jediCount }`:                          `variable $f: type "Jedi" is not an input type`,
+		`query ($f: Droid) { jediCount }`:                         `variable $f: unknown type "Droid"`,
+		`query ($id: ID!) { jedi(id: $id) { Name } }`:             `variable $id of type ID! is required`,
+		`{ __typename { Name } }`:                                 `field __typename can not have arguments or a selection`,
+		`{ jediCount { Name } }`:                                  `field "jediCount" of type Int! can not have a selection`,
+		`{ jediCount(first: 1) }`:                                 `field "jediCount": unknown argument "first"`,
+		`{ a: jediCount a: jediList { next } }`:                   `fields selected as "a" conflict`,
+		`{ a: jedi(id: "1") { Name } a: jedi(id: "2") { Name } }`: `fields selected as "a" conflict`,
+		`{ jediCount(filter: {Age: 1.5}) }`:                       `argument "filter": field "Age" of JediFilter: invalid Int value 1.5`,
+		`{ jediCount(filter: {Age: 3000000000}) }`:                `argument "filter": field "Age" of JediFilter: invalid Int value 3000000000`,
+		`{ jediCount(filter: {Name: RED}) }`:                      `argument "filter": field "Name" of JediFilter: invalid String value RED`,
+		`{ jediCount(filter: "yoda") }`:                           `argument "filter": expected a JediFilter object`,
+		`query A { jediCount } query B { jediCount }`:             `operation name required`,
+	}
+	for query, msg := range cases {
+		res := s.Execute(context.Background(), sqjdbgraphql.Request{Query: query})
+		ensure.DeepEqual(t, len(res.Errors), 1, query)
+		ensure.DeepEqual(t, res.Errors[0].Message, msg, query)
+	}
+}
+
+func TestOperationName(t *testing.T) {
+	s := newSchema(t)
+	query := `query A { a: jediCount } query B { b: jediCount }`
+	res := s.Execute(context.Background(), sqjdbgraphql.Request{Query: query, OperationName: "B"})
+	b, err := json.Marshal(res)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, string(b), `{"data":{"b":0}}`)
+	res = s.Execute(context.Background(), sqjdbgraphql.Request{Query: query, OperationName: "C"})
+	ensure.DeepEqual(t, res.Errors[0].Message, `unknown operation "C"`)
+}
+
+func TestValues(t *testing.T) {
+	s := newSchema(t)
+	var created struct{ CreateJedi Jedi }
+	data(t, s, `mutation { createJedi(input: {Name: "Obi-\u0057an \"Ben\"\n", Age: 57, Titles: ["master", "general"]}) { Name Age Titles } }`,
+		nil, &created)
+	ensure.DeepEqual(t, created.CreateJedi, Jedi{Name: "Obi-Wan \"Ben\"\n", Age: 57, Titles: []string{"master", "general"}})
+
+	// Default values are used for missing variables, and given ones override
+	// them.
+	query := `query ($age: Int = 57, $name: String) { jediCount(filter: {Age: $age, Name: $name}) }`
+	ensure.DeepEqual(t, run(t, s, query, nil), `{"data":{"jediCount":1}}`)
+	ensure.DeepEqual(t, run(t, s, query, map[string]any{"age": 58}), `{"data":{"jediCount":0}}`)
+	ensure.DeepEqual(t, run(t, s, query, map[string]any{"age": json.Number("57"), "name": "Obi-Wan"}),
+		`{"data":{"jediCount":0}}`)
+	ensure.DeepEqual(t, run(t, s, query, map[string]any{"age": "old"}),
+		`{"errors":[{"message":"variable $age: invalid Int value old"}]}`)
+	ensure.DeepEqual(t, run(t, s, `{ jediCount(filter: {Name: null}) }`, nil), `{"data":{"jediCount":0}}`)
+	ensure.DeepEqual(t, run(t, s, `{ jediCount(filter: {Name: """
+		Obi-Wan "Ben"
+	"""}) }`, nil), `{"data":{"jediCount":0}}`)
+	ensure.DeepEqual(t, run(t, s, `{ ... @include(if: true) { jediCount(filter: {Age: 57}) } }`, nil),
+		`{"data":{"jediCount":1}}`)
+}
+
+func TestNonNullError(t *testing.T) {
+	s := newSchema(t)
+	res := s.Execute(context.Background(), sqjdbgraphql.Request{
+		Query: `mutation { createJedi(input: {Age: 1}) { Name } }`,
+	})
+	// createJedi is non-null, so the error makes the whole data null.
+	ensure.DeepEqual(t, res.Data, nil)
+	ensure.DeepEqual(t, len(res.Errors), 1)
+	ensure.StringContains(t, res.Errors[0].Message, "name required")
+	ensure.DeepEqual(t, res.Errors[0].Path, []any{"createJedi"})
+}
+
+func TestIntrospection(t *testing.T) {
+	s := newSchema(t)
+	ensure.DeepEqual(t,
+		run(t, s, `{ __schema { queryType { name } mutationType { name } } __type(name: "Jedi") { kind fields { name type { kind name ofType { name } } } } }`, nil),
+		`{"data":{"__schema":{"queryType":{"name":"Query"},"mutationType":{"name":"Mutation"}},"__type":{"kind":"OBJECT","fields":[`+
+			`{"name":"ID","type":{"kind":"SCALAR","name":"ID","ofType":null}},`+
+			`{"name":"Name","type":{"kind":"SCALAR","name":"String","ofType":null}},`+
+			`{"name":"Age","type":{"kind":"SCALAR","name":"Int","ofType":null}},`+
+			`{"name":"Saber","type":{"kind":"OBJECT","name":"Lightsaber","ofType":null}},`+
+			`{"name":"Titles","type":{"kind":"LIST","name":null,"ofType":{"name":"String"}}}]}}}`)
+}
+
+func TestInternalError(t *testing.T) {
+	pool, err := sqlitex.NewPool(
+		fmt.Sprintf("file:%s.db?mode=memory&cache=shared", t.Name()),
+		sqlitex.PoolOptions{PoolSize: 2},
+	)
+	ensure.Nil(t, err)
+	t.Cleanup(func() { pool.Close() })
+	jedis := sqjdb.NewTable[Jedi]("jedis")
+	pjedis := jedis.WithPool(pool)
+	ensure.Nil(t, pjedis.Migrate(context.Background()))
+	s := sqjdbgraphql.New()
+	sqjdbgraphql.Register(s, "jedi", pjedis)
+
+	ensure.DeepEqual(t,
+		run(t, s, `{ jediList(after: "bogus") { next } }`, nil),
+		`{"errors":[{"message":"invalid cursor","path":["jediList"]}]}`)
+
+	// The SQL error is not sent to the client.
+	ensure.Nil(t, pjedis.Do(context.Background(), func(conn *sqlite.Conn) error {
+		return sqlitex.ExecuteTransient(conn, "drop table jedis", nil)
+	}))
+	ensure.DeepEqual(t,
+		run(t, s, `{ jediCount }`, nil),
+		`{"errors":[{"message":"internal error","path":["jediCount"]}]}`)
+}
+
+type Droid struct {
+	ID    int64  `json:",omitempty"`
+	Model string `json:",omitempty"`
+}
+
+func TestNumericID(t *testing.T) {
+	pool, err := sqlitex.NewPool(
+		fmt.Sprintf("file:%s.db?mode=memory&cache=shared", t.Name()),
+		sqlitex.PoolOptions{PoolSize: 2},
+	)
+	ensure.Nil(t, err)
+	t.Cleanup(func() { pool.Close() })
+	droids := sqjdb.NewTable[Droid]("droids")
+	pdroids := droids.WithPool(pool)
+	ensure.Nil(t, pdroids.Migrate(context.Background()))
+	s := sqjdbgraphql.New()
+	sqjdbgraphql.Register(s, "droid", pdroids)
+
+	ensure.DeepEqual(t,
+		run(t, s, `mutation { createDroid(input: {ID: "2", Model: "astromech"}) { ID Model } }`, nil),
+		`{"data":{"createDroid":{"ID":"2","Model":"astromech"}}}`)
+	ensure.DeepEqual(t,
+		run(t, s, `{ droid(id: 2) { Model } droidCount(filter: {ID_gte: 2}) }`, nil),
+		`{"data":{"droid":{"Model":"astromech"},"droidCount":1}}`)
+	ensure.DeepEqual(t,
+		run(t, s, `{ droid(id: "r2") { Model } }`, nil),
+		`{"data":{"droid":null},"errors":[{"message":"invalid id \"r2\"","path":["droid"]}]}`)
+}
+
+func TestSchemaString(t *testing.T) {
+	s := newSchema(t)
+	ensure.StringContains(t, s.String(), "type Query {\n  jedi(id: ID!): Jedi\n")
+	ensure.StringContains(t, s.String(), "input JediFilter {\n  ID: String\n  ID_ne: String\n")
+	ensure.StringContains(t, s.String(),
+		"  \"\"\"Deletes the document, returning false if it does not exist.\"\"\"\n  deleteJedi(id: ID!): Boolean!\n")
+}
+
+func TestLimits(t *testing.T) {
+	s := newSchema(t)
+	s.MaxDepth = 4
+	s.MaxFields = 4
+	ensure.DeepEqual(t, run(t, s, `{ jediList { items { Saber { Color } } } }`, nil),
+		`{"data":{"jediList":{"items":[]}}}`)
+
+	res := s.Execute(context.Background(), sqjdbgraphql.Request{
+		Query: `{ jediList { items { Saber { Color } } } next: jediCount }`,
+	})
+	ensure.StringContains(t, res.Errors[0].Message, "query exceeds the maximum of 4 fields")
+	res = s.Execute(context.Background(), sqjdbgraphql.Request{
+		Query: `{ jediCount(filter: {Age: [[[1]]]}) }`,
+	})
+	ensure.StringContains(t, res.Errors[0].Message, "nesting exceeds the maximum depth of 4")
+	res = s.Execute(context.Background(), sqjdbgraphql.Request{
+		Query: `query ($a: [[[[[Int]]]]]) { jediCount }`,
+	})
+	ensure.StringContains(t, res.Errors[0].Message, "nesting exceeds the maximum depth of 4")
+
+	// Fragments are limited as expanded, since each spread repeats them.
+	res = s.Execute(context.Background(), sqjdbgraphql.Request{
+		Query: `{ jedi(id: "a") { ...b } } fragment a on Jedi { Name } fragment b on Jedi { ...a ...a ...a ...a }`,
+	})
+	ensure.StringContains(t, res.Errors[0].Message, "query exceeds the maximum of 4 fields")
+	res = s.Execute(context.Background(), sqjdbgraphql.Request{
+		Query: `{ jediList { items { ...saber } } } fragment saber on Jedi { Saber { ... { Color } } }`,
+	})
+	ensure.DeepEqual(t, len(res.Errors), 0)
+	s.MaxDepth = 3
+	res = s.Execute(context.Background(), sqjdbgraphql.Request{
+		Query: `{ jediList { items { ...saber } } } fragment saber on Jedi { Saber { Color } }`,
+	})
+	ensure.StringContains(t, res.Errors[0].Message, "nesting exceeds the maximum depth of 3")
+}
+
+func TestDeepNesting(t *testing.T) {
+	// Queries nested deep enough to exhaust the stack fail with the default
+	// limits instead.
+	s := newSchema(t)
+	const n = 1_000_000
+	for _, query := range []string{
+		`{ jediCount(filter: ` + strings.Repeat("[", n) + `) }`,
+		`{ jediCount(filter: ` + strings.Repeat("{a: ", n) + `) }`,
+		strings.Repeat("{ jediCount ", n),
+		`query ($a: ` + strings.Repeat("[", n) + `) { jediCount }`,
+	} {
+		res := s.Execute(context.Background(), sqjdbgraphql.Request{Query: query})
+		ensure.DeepEqual(t, len(res.Errors), 1)
+		ensure.StringContains(t, res.Errors[0].Message, "nesting exceeds the maximum depth of 16")
+	}
+	res := s.Execute(context.Background(), sqjdbgraphql.Request{
+		Query: "{" + strings.Repeat(" jediCount", 1000) + " }",
+	})
+	ensure.StringContains(t, res.Errors[0].Message, "query exceeds the maximum of 256 fields")
+}
+
+func TestServeHTTP(t *testing.T) {
+	srv := httptest.NewServer(newSchema(t))
+	t.Cleanup(srv.Close)
+
+	body := `{"query":"mutation ($name: String) { createJedi(input: {Name: $name}) { Name } }","variables":{"name":"rey"}}`
+	res, err := http.Post(srv.URL, "application/json", bytes.NewBufferString(body))
+	ensure.Nil(t, err)
+	var b bytes.Buffer
+	_, err = b.ReadFrom(res.Body)
+	ensure.Nil(t, err)
+	res.Body.Close()
+	ensure.DeepEqual(t, res.StatusCode, http.StatusOK)
+	ensure.DeepEqual(t, b.String(), `{"data":{"createJedi":{"Name":"rey"}}}`+"\n")
+
+	res, err = http.Get(srv.URL + "?query=" + url.QueryEscape(`{ jediCount }`))
+	ensure.Nil(t, err)
+	b.Reset()
+	_, err = b.ReadFrom(res.Body)
+	ensure.Nil(t, err)
+	res.Body.Close()
+	ensure.DeepEqual(t, b.String(), `{"data":{"jediCount":1}}`+"\n")
+
+	res, err = http.Get(srv.URL + "?query=" + url.QueryEscape(`mutation { deleteJedi(id: "a") }`))
+	ensure.Nil(t, err)
+	res.Body.Close()
+	ensure.DeepEqual(t, res.StatusCode, http.StatusBadRequest)
+
+	req, err := http.NewRequest(http.MethodDelete, srv.URL, nil)
+	ensure.Nil(t, err)
+	res, err = http.DefaultClient.Do(req)
+	ensure.Nil(t, err)
+	res.Body.Close()
+	ensure.DeepEqual(t, res.StatusCode, http.StatusMethodNotAllowed)
+
+	res, err = http.Post(srv.URL, "application/json", bytes.NewBufferString(`{"query":`))
+	ensure.Nil(t, err)
+	res.Body.Close()
+	ensure.DeepEqual(t, res.StatusCode, http.StatusBadRequest)
+}
+
+func TestServeHTTPBodyLimit(t *testing.T) {
+	s := newSchema(t)
+	s.MaxBodyBytes = 64
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	res, err := http.Post(srv.URL, "application/json", bytes.NewBufferString(`{"query":"{ jediCount }"}`))
+	ensure.Nil(t, err)
+	res.Body.Close()
+	ensure.DeepEqual(t, res.StatusCode, http.StatusOK)
+
+	body := `{"query":"{ jediCount }","variables":{"pad":"` + strings.Repeat("x", 64) + `"}}`
+	res, err = http.Post(srv.URL, "application/json", bytes.NewBufferString(body))
+	ensure.Nil(t, err)
+	var b bytes.Buffer
+	_, err = b.ReadFrom(res.Body)
+	ensure.Nil(t, err)
+	res.Body.Close()
+	ensure.DeepEqual(t, res.StatusCode, http.StatusRequestEntityTooLarge)
+	ensure.DeepEqual(t, b.String(), `{"errors":[{"message":"request body too large"}]}`+"\n")
+}
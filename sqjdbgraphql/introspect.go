@@ -0,0 +1,211 @@
+package sqjdbgraphql
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+// addMetaTypes adds the introspection types, and the __schema and __type
+// fields of the query type.
+func (s *Schema) addMetaTypes() {
+	typeKind := &gqlType{kind: kindEnum, name: "__TypeKind", values: []string{
+		"SCALAR", "OBJECT", "INTERFACE", "UNION", "ENUM", "INPUT_OBJECT", "LIST", "NON_NULL",
+	}}
+	directiveLocation := &gqlType{kind: kindEnum, name: "__DirectiveLocation", values: []string{
+		"QUERY", "MUTATION", "SUBSCRIPTION", "FIELD", "FRAGMENT_DEFINITION",
+		"FRAGMENT_SPREAD", "INLINE_FRAGMENT", "VARIABLE_DEFINITION", "SCHEMA",
+		"SCALAR", "OBJECT", "FIELD_DEFINITION", "ARGUMENT_DEFINITION", "INTERFACE",
+		"UNION", "ENUM", "ENUM_VALUE", "INPUT_OBJECT", "INPUT_FIELD_DEFINITION",
+	}}
+	schema := &gqlType{kind: kindObject, name: "__Schema"}
+	typ := &gqlType{kind: kindObject, name: "__Type"}
+	fieldType := &gqlType{kind: kindObject, name: "__Field"}
+	inputValue := &gqlType{kind: kindObject, name: "__InputValue"}
+	enumValueType := &gqlType{kind: kindObject, name: "__EnumValue"}
+	directiveType := &gqlType{kind: kindObject, name: "__Directive"}
+	includeDeprecated := []*field{{name: "includeDeprecated", typ: booleanType}}
+	deprecation := []*field{
+		{name: "isDeprecated", typ: nonNull(booleanType)},
+		{name: "deprecationReason", typ: stringType},
+	}
+	schema.fields = []*field{
+		{name: "description", typ: stringType},
+		{name: "types", typ: nonNull(listOf(nonNull(typ)))},
+		{name: "queryType", typ: nonNull(typ)},
+		{name: "mutationType", typ: typ},
+		{name: "subscriptionType", typ: typ},
+		{name: "directives", typ: nonNull(listOf(nonNull(directiveType)))},
+	}
+	typ.fields = []*field{
+		{name: "kind", typ: nonNull(typeKind)},
+		{name: "name", typ: stringType},
+		{name: "description", typ: stringType},
+		{name: "specifiedByURL", typ: stringType},
+		{name: "fields", typ: listOf(nonNull(fieldType)), args: includeDeprecated},
+		{name: "interfaces", typ: listOf(nonNull(typ))},
+		{name: "possibleTypes", typ: listOf(nonNull(typ))},
+		{name: "enumValues", typ: listOf(nonNull(enumValueType)), args: includeDeprecated},
+		{name: "inputFields", typ: listOf(nonNull(inputValue)), args: includeDeprecated},
+		{name: "ofType", typ: typ},
+		{name: "isOneOf", typ: booleanType},
+	}
+	fieldType.fields = append([]*field{
+		{name: "name", typ: nonNull(stringType)},
+		{name: "description", typ: stringType},
+		{name: "args", typ: nonNull(listOf(nonNull(inputValue))), args: includeDeprecated},
+		{name: "type", typ: nonNull(typ)},
+	}, deprecation...)
+	inputValue.fields = append([]*field{
+		{name: "name", typ: nonNull(stringType)},
+		{name: "description", typ: stringType},
+		{name: "type", typ: nonNull(typ)},
+		{name: "defaultValue", typ: stringType},
+	}, deprecation...)
+	enumValueType.fields = append([]*field{
+		{name: "name", typ: nonNull(stringType)},
+		{name: "description", typ: stringType},
+	}, deprecation...)
+	directiveType.fields = []*field{
+		{name: "name", typ: nonNull(stringType)},
+		{name: "description", typ: stringType},
+		{name: "locations", typ: nonNull(listOf(nonNull(directiveLocation)))},
+		{name: "args", typ: nonNull(listOf(nonNull(inputValue))), args: includeDeprecated},
+		{name: "isRepeatable", typ: nonNull(booleanType)},
+	}
+	for _, t := range []*gqlType{typeKind, directiveLocation, schema, typ, fieldType, inputValue, enumValueType, directiveType} {
+		s.addType(t)
+	}
+	s.addField(s.query, &field{
+		name: "__schema",
+		typ:  nonNull(schema),
+		resolve: func(context.Context, map[string]any) (any, error) {
+			return s.introspect(), nil
+		},
+	})
+	s.addField(s.query, &field{
+		name: "__type",
+		typ:  typ,
+		args: []*field{{name: "name", typ: nonNull(stringType)}},
+		resolve: func(_ context.Context, args map[string]any) (any, error) {
+			for _, t := range s.introspect()["types"].([]any) {
+				if t := t.(map[string]any); t["name"] == args["name"] {
+					return t, nil
+				}
+			}
+			return nil, nil
+		},
+	})
+}
+
+// introspect returns the value of the __schema field, built on first use.
+func (s *Schema) introspect() map[string]any {
+	s.introspection.Do(func() {
+		types := map[*gqlType]map[string]any{}
+		var typeValue func(t *gqlType) map[string]any
+		inputValues := func(fields []*field) []any {
+			values := []any{}
+			for _, f := range fields {
+				values = append(values, map[string]any{
+					"name":              f.name,
+					"description":       description(f.desc),
+					"type":              typeValue(f.typ),
+					"defaultValue":      nil,
+					"isDeprecated":      false,
+					"deprecationReason": nil,
+				})
+			}
+			return values
+		}
+		typeValue = func(t *gqlType) map[string]any {
+			if v, ok := types[t]; ok {
+				return v
+			}
+			v := map[string]any{
+				"kind":        kindNames[t.kind],
+				"name":        nil,
+				"description": description(t.desc),
+				"isOneOf":     false,
+			}
+			types[t] = v
+			if t.name != "" {
+				v["name"] = t.name
+			}
+			switch t.kind {
+			case kindObject:
+				fields := []any{}
+				for _, f := range t.fields {
+					if strings.HasPrefix(f.name, "__") {
+						continue
+					}
+					fields = append(fields, map[string]any{
+						"name":              f.name,
+						"description":       description(f.desc),
+						"args":              inputValues(f.args),
+						"type":              typeValue(f.typ),
+						"isDeprecated":      false,
+						"deprecationReason": nil,
+					})
+				}
+				v["fields"] = fields
+				v["interfaces"] = []any{}
+			case kindInputObject:
+				v["inputFields"] = inputValues(t.fields)
+			case kindEnum:
+				values := []any{}
+				for _, name := range t.values {
+					values = append(values, map[string]any{
+						"name":              name,
+						"description":       nil,
+						"isDeprecated":      false,
+						"deprecationReason": nil,
+					})
+				}
+				v["enumValues"] = values
+			case kindList, kindNonNull:
+				v["ofType"] = typeValue(t.ofType)
+			}
+			return v
+		}
+		var names []string
+		for name, t := range s.types {
+			if t != s.mutation || s.hasMutations() {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+		typeValues := make([]any, len(names))
+		for i, name := range names {
+			typeValues[i] = typeValue(s.types[name])
+		}
+		var directives []any
+		for _, name := range []string{"include", "skip"} {
+			directives = append(directives, map[string]any{
+				"name":         name,
+				"description":  nil,
+				"locations":    []any{"FIELD", "FRAGMENT_SPREAD", "INLINE_FRAGMENT"},
+				"args":         inputValues([]*field{{name: "if", typ: nonNull(booleanType)}}),
+				"isRepeatable": false,
+			})
+		}
+		s.introspectionData = map[string]any{
+			"description":      nil,
+			"types":            typeValues,
+			"queryType":        typeValue(s.query),
+			"mutationType":     nil,
+			"subscriptionType": nil,
+			"directives":       directives,
+		}
+		if s.hasMutations() {
+			s.introspectionData["mutationType"] = typeValue(s.mutation)
+		}
+	})
+	return s.introspectionData
+}
+
+func description(desc string) any {
+	if desc == "" {
+		return nil
+	}
+	return desc
+}
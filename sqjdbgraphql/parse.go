@@ -0,0 +1,581 @@
+package sqjdbgraphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is a parsed GraphQL request document.
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	// kind is "query" or "mutation".
+	kind       string
+	name       string
+	vars       []*varDef
+	selections []*selection
+}
+
+type varDef struct {
+	name string
+	typ  *typeRef
+	// def is the default value, or nil if there is none.
+	def any
+}
+
+// typeRef is a type as written in a variable definition.
+type typeRef struct {
+	name    string
+	list    *typeRef
+	nonNull bool
+}
+
+func (t *typeRef) String() string {
+	s := t.name
+	if t.list != nil {
+		s = "[" + t.list.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+type fragment struct {
+	name       string
+	on         string
+	selections []*selection
+}
+
+// selection is a field if name is set, a fragment spread if spread is set, and
+// an inline fragment otherwise.
+type selection struct {
+	alias      string
+	name       string
+	args       []*argument
+	directives []*directive
+	selections []*selection
+	spread     string
+	// on is the type condition of an inline fragment.
+	on string
+}
+
+// key returns the response key of a field.
+func (s *selection) key() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+type argument struct {
+	name  string
+	value any
+}
+
+type directive struct {
+	name string
+	args []*argument
+}
+
+// Values are parsed as int64, float64, string, bool, nil, enumValue, variable,
+// []any and map[string]any.
+type (
+	variable  string
+	enumValue string
+)
+
+// syntaxError is an error parsing a document.
+type syntaxError struct {
+	pos int
+	msg string
+}
+
+func (e *syntaxError) Error() string {
+	return fmt.Sprintf("syntax error at offset %d: %s", e.pos, e.msg)
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+	pos  int
+}
+
+type parser struct {
+	src string
+	pos int
+	tok token
+	// depth is the current nesting of selection sets, lists, objects and list
+	// types, and fields the number of fields parsed so far.
+	depth, maxDepth   int
+	fields, maxFields int
+}
+
+// parse parses a GraphQL executable document. Nesting deeper than maxDepth,
+// which would exhaust the stack of the recursive descent, and more than
+// maxFields fields are syntax errors. Since fragments can be spread many
+// times, the limits are checked again on the expanded selections by validate.
+func parse(src string, maxDepth, maxFields int) (doc *document, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			se, ok := r.(*syntaxError)
+			if !ok {
+				panic(r)
+			}
+			err = se
+		}
+	}()
+	p := &parser{src: src, maxDepth: maxDepth, maxFields: maxFields}
+	p.next()
+	doc = &document{fragments: map[string]*fragment{}}
+	for p.tok.kind != tokEOF {
+		if p.tok.kind == tokName && p.tok.val == "fragment" {
+			f := p.fragment()
+			if _, ok := doc.fragments[f.name]; ok {
+				return nil, fmt.Errorf("duplicate fragment %q", f.name)
+			}
+			doc.fragments[f.name] = f
+			continue
+		}
+		doc.operations = append(doc.operations, p.operation())
+	}
+	if len(doc.operations) == 0 {
+		return nil, fmt.Errorf("document has no operations")
+	}
+	return doc, nil
+}
+
+func (p *parser) fail(format string, args ...any) {
+	panic(&syntaxError{pos: p.tok.pos, msg: fmt.Sprintf(format, args...)})
+}
+
+// next scans the next token, skipping whitespace, commas and comments.
+func (p *parser) next() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.pos++
+		} else if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' && p.src[p.pos] != '\r' {
+				p.pos++
+			}
+		} else {
+			break
+		}
+	}
+	start := p.pos
+	if p.pos == len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok = token{kind: tokPunct, val: "...", pos: start}
+	case strings.IndexByte("!$():=@[]{}", c) >= 0:
+		p.pos++
+		p.tok = token{kind: tokPunct, val: string(c), pos: start}
+	case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		for p.pos < len(p.src) && isNameChar(p.src[p.pos]) {
+			p.pos++
+		}
+		p.tok = token{kind: tokName, val: p.src[start:p.pos], pos: start}
+	case c == '-' || c >= '0' && c <= '9':
+		p.number()
+	case c == '"':
+		p.string()
+	default:
+		p.tok.pos = start
+		p.fail("unexpected character %q", c)
+	}
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (p *parser) number() {
+	start := p.pos
+	kind := tokInt
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	digits := func() {
+		n := p.pos
+		for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+			p.pos++
+		}
+		if n == p.pos {
+			p.tok.pos = start
+			p.fail("invalid number")
+		}
+	}
+	digits()
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		kind = tokFloat
+		p.pos++
+		digits()
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		kind = tokFloat
+		p.pos++
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		digits()
+	}
+	p.tok = token{kind: kind, val: p.src[start:p.pos], pos: start}
+}
+
+func (p *parser) string() {
+	start := p.pos
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		end := strings.Index(p.src[p.pos+3:], `"""`)
+		if end < 0 {
+			p.tok.pos = start
+			p.fail("unterminated string")
+		}
+		raw := p.src[p.pos+3 : p.pos+3+end]
+		p.pos += 3 + end + 3
+		p.tok = token{kind: tokString, val: blockString(raw), pos: start}
+		return
+	}
+	var b strings.Builder
+	p.pos++
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' || p.src[p.pos] == '\r' {
+			p.tok.pos = start
+			p.fail("unterminated string")
+		}
+		c := p.src[p.pos]
+		if c == '"' {
+			p.pos++
+			break
+		}
+		if c != '\\' {
+			r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+			b.WriteRune(r)
+			p.pos += size
+			continue
+		}
+		if p.pos+1 >= len(p.src) {
+			p.tok.pos = start
+			p.fail("unterminated string")
+		}
+		esc := p.src[p.pos+1]
+		p.pos += 2
+		switch esc {
+		case '"', '\\', '/':
+			b.WriteByte(esc)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if p.pos+4 > len(p.src) {
+				p.tok.pos = start
+				p.fail("invalid unicode escape")
+			}
+			r, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+			if err != nil {
+				p.tok.pos = start
+				p.fail("invalid unicode escape")
+			}
+			b.WriteRune(rune(r))
+			p.pos += 4
+		default:
+			p.tok.pos = start
+			p.fail("invalid escape %q", esc)
+		}
+	}
+	p.tok = token{kind: tokString, val: b.String(), pos: start}
+}
+
+// blockString removes the common indentation and surrounding blank lines of a
+// block string.
+func blockString(raw string) string {
+	raw = strings.ReplaceAll(raw, `\"""`, `"""`)
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = strings.TrimLeft(lines[i], " \t")
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// peek reports if the current token is the given punctuator.
+func (p *parser) peek(punct string) bool {
+	return p.tok.kind == tokPunct && p.tok.val == punct
+}
+
+// skip consumes the current token if it is the given punctuator.
+func (p *parser) skip(punct string) bool {
+	if p.peek(punct) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(punct string) {
+	if !p.skip(punct) {
+		p.fail("expected %q", punct)
+	}
+}
+
+func (p *parser) name() string {
+	if p.tok.kind != tokName {
+		p.fail("expected name")
+	}
+	name := p.tok.val
+	p.next()
+	return name
+}
+
+func (p *parser) operation() *operation {
+	op := &operation{kind: "query"}
+	if p.peek("{") {
+		op.selections = p.selectionSet()
+		return op
+	}
+	switch p.tok.val {
+	case "query", "mutation":
+		op.kind = p.name()
+	case "subscription":
+		p.fail("subscriptions are not supported")
+	default:
+		p.fail("unexpected %q", p.tok.val)
+	}
+	if p.tok.kind == tokName {
+		op.name = p.name()
+	}
+	if p.skip("(") {
+		for !p.skip(")") {
+			p.expect("$")
+			v := &varDef{name: p.name()}
+			p.expect(":")
+			v.typ = p.typeRef()
+			if p.skip("=") {
+				v.def = p.value(true)
+			}
+			op.vars = append(op.vars, v)
+		}
+	}
+	p.directives()
+	op.selections = p.selectionSet()
+	return op
+}
+
+func (p *parser) fragment() *fragment {
+	p.next()
+	f := &fragment{name: p.name()}
+	if f.name == "on" {
+		p.fail("invalid fragment name \"on\"")
+	}
+	if p.name() != "on" {
+		p.fail("expected \"on\"")
+	}
+	f.on = p.name()
+	p.directives()
+	f.selections = p.selectionSet()
+	return f
+}
+
+// enter increases the nesting depth, failing past the limit.
+func (p *parser) enter() {
+	p.depth++
+	if p.depth > p.maxDepth {
+		p.fail("nesting exceeds the maximum depth of %d", p.maxDepth)
+	}
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func (p *parser) typeRef() *typeRef {
+	t := &typeRef{}
+	if p.skip("[") {
+		p.enter()
+		t.list = p.typeRef()
+		p.expect("]")
+		p.leave()
+	} else {
+		t.name = p.name()
+	}
+	t.nonNull = p.skip("!")
+	return t
+}
+
+func (p *parser) selectionSet() []*selection {
+	p.expect("{")
+	p.enter()
+	var sels []*selection
+	for !p.skip("}") {
+		sels = append(sels, p.selection())
+	}
+	if len(sels) == 0 {
+		p.fail("empty selection set")
+	}
+	p.leave()
+	return sels
+}
+
+func (p *parser) selection() *selection {
+	if p.skip("...") {
+		if p.tok.kind == tokName && p.tok.val != "on" {
+			return &selection{spread: p.name(), directives: p.directives()}
+		}
+		s := &selection{}
+		if p.tok.kind == tokName {
+			p.next()
+			s.on = p.name()
+		}
+		s.directives = p.directives()
+		s.selections = p.selectionSet()
+		return s
+	}
+	p.fields++
+	if p.fields > p.maxFields {
+		p.fail("query exceeds the maximum of %d fields", p.maxFields)
+	}
+	s := &selection{name: p.name()}
+	if p.skip(":") {
+		s.alias, s.name = s.name, p.name()
+	}
+	s.args = p.arguments(false)
+	s.directives = p.directives()
+	if p.peek("{") {
+		s.selections = p.selectionSet()
+	}
+	return s
+}
+
+func (p *parser) arguments(constant bool) []*argument {
+	var args []*argument
+	if p.skip("(") {
+		for !p.skip(")") {
+			a := &argument{name: p.name()}
+			p.expect(":")
+			a.value = p.value(constant)
+			args = append(args, a)
+		}
+	}
+	return args
+}
+
+func (p *parser) directives() []*directive {
+	var dirs []*directive
+	for p.skip("@") {
+		dirs = append(dirs, &directive{name: p.name(), args: p.arguments(false)})
+	}
+	return dirs
+}
+
+func (p *parser) value(constant bool) any {
+	tok := p.tok
+	switch tok.kind {
+	case tokInt:
+		p.next()
+		n, err := strconv.ParseInt(tok.val, 10, 64)
+		if err != nil {
+			p.tok = tok
+			p.fail("invalid int %s", tok.val)
+		}
+		return n
+	case tokFloat:
+		p.next()
+		f, err := strconv.ParseFloat(tok.val, 64)
+		if err != nil {
+			p.tok = tok
+			p.fail("invalid float %s", tok.val)
+		}
+		return f
+	case tokString:
+		p.next()
+		return tok.val
+	case tokName:
+		p.next()
+		switch tok.val {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return enumValue(tok.val)
+	}
+	switch {
+	case p.skip("$"):
+		if constant {
+			p.tok = tok
+			p.fail("unexpected variable")
+		}
+		return variable(p.name())
+	case p.skip("["):
+		p.enter()
+		list := []any{}
+		for !p.skip("]") {
+			list = append(list, p.value(constant))
+		}
+		p.leave()
+		return list
+	case p.skip("{"):
+		p.enter()
+		obj := map[string]any{}
+		for !p.skip("}") {
+			name := p.name()
+			p.expect(":")
+			obj[name] = p.value(constant)
+		}
+		p.leave()
+		return obj
+	}
+	p.fail("expected value")
+	return nil
+}
@@ -0,0 +1,288 @@
+package sqjdbgraphql
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+type typeKind int
+
+const (
+	kindScalar typeKind = iota
+	kindObject
+	kindInputObject
+	kindEnum
+	kindList
+	kindNonNull
+)
+
+var kindNames = map[typeKind]string{
+	kindScalar:      "SCALAR",
+	kindObject:      "OBJECT",
+	kindInputObject: "INPUT_OBJECT",
+	kindEnum:        "ENUM",
+	kindList:        "LIST",
+	kindNonNull:     "NON_NULL",
+}
+
+// gqlType is a GraphQL type. Named types have a name, while list and non-null
+// types wrap ofType.
+type gqlType struct {
+	kind   typeKind
+	name   string
+	desc   string
+	fields []*field
+	values []string
+	ofType *gqlType
+}
+
+// field is a field of an object or input object type, or an argument.
+type field struct {
+	name string
+	desc string
+	typ  *gqlType
+	args []*field
+	// resolve returns the value of a root field, and is nil for other fields,
+	// whose value is taken from the parent object.
+	resolve func(ctx context.Context, args map[string]any) (any, error)
+}
+
+func (t *gqlType) field(name string) *field {
+	for _, f := range t.fields {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// named returns the named type wrapped by t.
+func (t *gqlType) named() *gqlType {
+	for t.ofType != nil {
+		t = t.ofType
+	}
+	return t
+}
+
+func (t *gqlType) String() string {
+	switch t.kind {
+	case kindList:
+		return "[" + t.ofType.String() + "]"
+	case kindNonNull:
+		return t.ofType.String() + "!"
+	}
+	return t.name
+}
+
+func listOf(t *gqlType) *gqlType { return &gqlType{kind: kindList, ofType: t} }
+
+func nonNull(t *gqlType) *gqlType { return &gqlType{kind: kindNonNull, ofType: t} }
+
+var (
+	stringType  = &gqlType{kind: kindScalar, name: "String"}
+	intType     = &gqlType{kind: kindScalar, name: "Int"}
+	floatType   = &gqlType{kind: kindScalar, name: "Float"}
+	booleanType = &gqlType{kind: kindScalar, name: "Boolean"}
+	idType      = &gqlType{kind: kindScalar, name: "ID"}
+	jsonType    = &gqlType{kind: kindScalar, name: "JSON", desc: "Any JSON value."}
+)
+
+var nameRE = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+var (
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+)
+
+// implements reports if typ or a pointer to it implements iface.
+func implements(typ, iface reflect.Type) bool {
+	return typ.Implements(iface) || reflect.PointerTo(typ).Implements(iface)
+}
+
+// docFields returns the exported fields of a struct with their json names, in
+// the order they are encoded. Fields whose names are not valid in GraphQL are
+// skipped.
+func docFields(typ reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for _, f := range reflect.VisibleFields(typ) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if !nameRE.MatchString(name) || strings.HasPrefix(name, "__") {
+			continue
+		}
+		f.Name = name
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// goType returns the GraphQL type for a Go type, as encoded to JSON. Structs
+// are registered as object types, or input object types if input is set.
+func (s *Schema) goType(typ reflect.Type, input bool) *gqlType {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if implements(typ, textMarshalerType) {
+		return stringType
+	}
+	if implements(typ, jsonMarshalerType) {
+		return jsonType
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return stringType
+	case reflect.Bool:
+		return booleanType
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return intType
+	case reflect.Float32, reflect.Float64:
+		return floatType
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			// Bytes are encoded as base64 strings.
+			return stringType
+		}
+		return listOf(s.goType(typ.Elem(), input))
+	case reflect.Struct:
+		if typ.Name() == "" {
+			return jsonType
+		}
+		return s.structType(typ, input, "")
+	}
+	return jsonType
+}
+
+// structType returns the object or input object type for a struct, using the
+// ID type for the field named by idKey.
+func (s *Schema) structType(typ reflect.Type, input bool, idKey string) *gqlType {
+	name := typ.Name()
+	kind := kindObject
+	if input {
+		name += "Input"
+		kind = kindInputObject
+	}
+	if t, ok := s.types[name]; ok {
+		if s.goTypes[name] != typ {
+			panic(fmt.Sprintf("sqjdbgraphql: types %v and %v are both named %q", s.goTypes[name], typ, name))
+		}
+		return t
+	}
+	t := &gqlType{kind: kind, name: name}
+	s.addType(t)
+	s.goTypes[name] = typ
+	for _, f := range docFields(typ) {
+		ft := s.goType(f.Type, input)
+		if f.Name == idKey {
+			ft = idType
+		}
+		t.fields = append(t.fields, &field{name: f.Name, typ: ft})
+	}
+	return t
+}
+
+func (s *Schema) addType(t *gqlType) {
+	if _, ok := s.types[t.name]; ok {
+		panic(fmt.Sprintf("sqjdbgraphql: type %q is already registered", t.name))
+	}
+	s.types[t.name] = t
+}
+
+func (s *Schema) addField(t *gqlType, f *field) {
+	if t.field(f.name) != nil {
+		panic(fmt.Sprintf("sqjdbgraphql: field %q is already registered on %s", f.name, t.name))
+	}
+	t.fields = append(t.fields, f)
+}
+
+func (s *Schema) hasMutations() bool {
+	return len(s.mutation.fields) > 0
+}
+
+// String returns the schema in the GraphQL schema definition language.
+func (s *Schema) String() string {
+	var b strings.Builder
+	names := make([]string, 0, len(s.types))
+	for name, t := range s.types {
+		if strings.HasPrefix(name, "__") || t.kind == kindScalar && builtinScalars[name] {
+			continue
+		}
+		if t == s.mutation && !s.hasMutations() {
+			continue
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		t := s.types[name]
+		writeDesc(&b, "", t.desc)
+		switch t.kind {
+		case kindScalar:
+			b.WriteString("scalar " + name + "\n")
+			continue
+		case kindEnum:
+			b.WriteString("enum " + name + " {\n")
+			for _, v := range t.values {
+				b.WriteString("  " + v + "\n")
+			}
+			b.WriteString("}\n")
+			continue
+		case kindObject:
+			b.WriteString("type ")
+		case kindInputObject:
+			b.WriteString("input ")
+		}
+		b.WriteString(name + " {\n")
+		for _, f := range t.fields {
+			if strings.HasPrefix(f.name, "__") {
+				continue
+			}
+			writeDesc(&b, "  ", f.desc)
+			b.WriteString("  " + f.name)
+			if len(f.args) > 0 {
+				b.WriteString("(")
+				for i, a := range f.args {
+					if i > 0 {
+						b.WriteString(", ")
+					}
+					b.WriteString(a.name + ": " + a.typ.String())
+				}
+				b.WriteString(")")
+			}
+			b.WriteString(": " + f.typ.String() + "\n")
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+func writeDesc(b *strings.Builder, indent, desc string) {
+	if desc != "" {
+		b.WriteString(indent + `"""` + desc + `"""` + "\n")
+	}
+}
+
+var builtinScalars = map[string]bool{
+	"String":  true,
+	"Int":     true,
+	"Float":   true,
+	"Boolean": true,
+	"ID":      true,
+}
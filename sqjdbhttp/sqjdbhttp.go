@@ -0,0 +1,395 @@
+// Package sqjdbhttp serves sqjdb tables as REST endpoints.
+package sqjdbhttp
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+)
+
+// Options configures a Handler.
+type Options[T any] struct {
+	// Limit is the page size when listing without a limit parameter. The
+	// default is 50.
+	Limit int
+	// MaxLimit is the largest allowed limit parameter. The default is 1000.
+	MaxLimit int
+	// MaxBodyBytes is the largest request body read when inserting or
+	// patching. Larger bodies are rejected with status 413. The default is
+	// 1 MiB.
+	MaxBodyBytes int64
+	// Validate is called with the request and document before inserting or
+	// patching it, in addition to the validation done by the table. An error
+	// is returned to the client with status 422.
+	Validate func(r *http.Request, op sqjdb.HookOp, doc *T) error
+}
+
+// Page is the response when listing documents.
+type Page[T any] struct {
+	Items []*T `json:"items"`
+	// Next is the cursor for the next page, empty on the last page.
+	Next string `json:"next,omitempty"`
+}
+
+// Handler returns a handler serving the table as REST endpoints, relative to
+// the path it is mounted at, which can be removed with http.StripPrefix:
+//
+//	GET    /      lists documents, see below
+//	POST   /      inserts the document in the body
+//	GET    /{id}  returns the document
+//	PATCH  /{id}  patches the document with the body, like Table.Patch
+//	DELETE /{id}  deletes the document
+//
+// Listing returns a Page ordered by ID, using the cursor and limit query
+// parameters to paginate. Other query parameters filter the documents by
+// field, as field=value for equality, or field[op]=value where op is one of
+// eq, ne, gt, gte, lt, lte or like. Fields are named by their json name, and
+// values are parsed per the type of the field. The filter query parameter
+// takes a JSON sqjdb.Filter for more complex conditions. Filters use the
+// generated columns of promoted fields.
+//
+// Errors are returned as a JSON object with an error message, with status 404
+// for missing documents, 409 for unique violations and version conflicts, and
+// 422 for validation errors.
+func Handler[T any](t *sqjdb.PoolTable[T], opts Options[T]) http.Handler {
+	if opts.Limit < 1 {
+		opts.Limit = 50
+	}
+	if opts.MaxLimit < 1 {
+		opts.MaxLimit = 1000
+	}
+	if opts.MaxBodyBytes < 1 {
+		opts.MaxBodyBytes = 1 << 20
+	}
+	h := &handler[T]{t: t, opts: opts, fields: jsonFields(reflect.TypeFor[T]())}
+	if _, ok := h.fields[t.Table.IDKey()]; !ok {
+		panic(fmt.Sprintf("sqjdbhttp: %v has no ID field %q", reflect.TypeFor[T](), t.Table.IDKey()))
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", h.list)
+	mux.HandleFunc("POST /{$}", h.insert)
+	mux.HandleFunc("GET /{id}", h.get)
+	mux.HandleFunc("PATCH /{id}", h.patch)
+	mux.HandleFunc("DELETE /{id}", h.delete)
+	return mux
+}
+
+type handler[T any] struct {
+	t    *sqjdb.PoolTable[T]
+	opts Options[T]
+	// fields are the document fields by json name.
+	fields map[string]reflect.StructField
+}
+
+// jsonFields returns the exported fields of a struct by json name.
+func jsonFields(typ reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	if typ.Kind() != reflect.Struct {
+		return fields
+	}
+	for _, f := range reflect.VisibleFields(typ) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f
+	}
+	return fields
+}
+
+// parseField parses the string value of the named field per its type.
+func (h *handler[T]) parseField(name, s string) (reflect.Value, error) {
+	f, ok := h.fields[name]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("unknown field %q", name)
+	}
+	v := reflect.New(f.Type)
+	if u, ok := v.Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(s)); err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid value for field %q: %w", name, err)
+		}
+	} else if f.Type.Kind() == reflect.String {
+		v.Elem().SetString(s)
+	} else if err := json.Unmarshal([]byte(s), v.Interface()); err != nil {
+		return reflect.Value{}, fmt.Errorf("invalid value for field %q: %w", name, err)
+	}
+	return v.Elem(), nil
+}
+
+// parseValue parses the string value of the named field into a value that can
+// be bound.
+func (h *handler[T]) parseValue(name, s string) (any, error) {
+	e, err := h.parseField(name, s)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := e.Interface().(encoding.TextMarshaler); ok {
+		return e.Interface(), nil
+	}
+	// Bind only supports the basic types, not named types such as enums.
+	switch e.Kind() {
+	case reflect.String:
+		return e.String(), nil
+	case reflect.Bool:
+		return e.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return e.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(e.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return e.Float(), nil
+	}
+	return nil, fmt.Errorf("can not filter on field %q of type %v", name, e.Type())
+}
+
+// filters returns the conditions given by the query parameters.
+func (h *handler[T]) filters(r *http.Request) ([]sqjdb.Cond, error) {
+	var conds []sqjdb.Cond
+	for key, values := range r.URL.Query() {
+		if key == "cursor" || key == "limit" {
+			continue
+		}
+		if key == "filter" {
+			for _, s := range values {
+				f, err := sqjdb.ParseFilter([]byte(s))
+				if err != nil {
+					return nil, err
+				}
+				cond, err := h.t.Table.Filter(f)
+				if err != nil {
+					return nil, err
+				}
+				conds = append(conds, cond)
+			}
+			continue
+		}
+		name, op := key, "eq"
+		if i := strings.IndexByte(key, '['); i > 0 && strings.HasSuffix(key, "]") {
+			name, op = key[:i], key[i+1:len(key)-1]
+		}
+		for _, s := range values {
+			v, err := h.parseValue(name, s)
+			if err != nil {
+				return nil, err
+			}
+			f := h.t.Table.Where(h.fields[name].Name)
+			var cond sqjdb.Cond
+			switch op {
+			case "eq":
+				cond = f.Eq(v)
+			case "ne":
+				cond = f.Ne(v)
+			case "gt":
+				cond = f.Gt(v)
+			case "gte":
+				cond = f.Gte(v)
+			case "lt":
+				cond = f.Lt(v)
+			case "lte":
+				cond = f.Lte(v)
+			case "like":
+				cond = f.Like(s)
+			default:
+				return nil, fmt.Errorf("unknown operator %q", op)
+			}
+			conds = append(conds, cond)
+		}
+	}
+	return conds, nil
+}
+
+func (h *handler[T]) list(w http.ResponseWriter, r *http.Request) {
+	limit := h.opts.Limit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > h.opts.MaxLimit {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("limit must be between 1 and %d", h.opts.MaxLimit))
+			return
+		}
+		limit = n
+	}
+	filters, err := h.filters(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var page Page[T]
+	err = h.t.Read(r.Context(), func(conn *sqlite.Conn) (err error) {
+		page.Items, page.Next, err = h.t.Table.Paginate(conn, r.URL.Query().Get("cursor"), limit, filters...)
+		return err
+	})
+	if err != nil {
+		writeTableError(w, err)
+		return
+	}
+	if page.Items == nil {
+		page.Items = []*T{}
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// byID returns the query selecting the document with the ID in the path.
+func (h *handler[T]) byID(r *http.Request) (sqjdb.SQL, error) {
+	id, err := h.parseValue(h.t.Table.IDKey(), r.PathValue("id"))
+	if err != nil {
+		return sqjdb.SQL{}, err
+	}
+	return h.t.Table.ByID(id), nil
+}
+
+// decode decodes the request body into a document and validates it. For
+// patches, the ID is set from the path, so it can not be changed.
+func (h *handler[T]) decode(w http.ResponseWriter, r *http.Request, op sqjdb.HookOp) (*T, bool) {
+	doc := new(T)
+	body := http.MaxBytesReader(w, r.Body, h.opts.MaxBodyBytes)
+	if err := json.NewDecoder(body).Decode(doc); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, errors.New("request body too large"))
+			return nil, false
+		}
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid document: %w", err))
+		return nil, false
+	}
+	if op == sqjdb.HookPatch {
+		id, err := h.parseField(h.t.Table.IDKey(), r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return nil, false
+		}
+		docID := reflect.ValueOf(doc).Elem().FieldByIndex(h.fields[h.t.Table.IDKey()].Index)
+		if !docID.IsZero() && !docID.Equal(id) {
+			writeError(w, http.StatusBadRequest, errors.New("can not change the ID"))
+			return nil, false
+		}
+		docID.Set(id)
+	}
+	if h.opts.Validate != nil {
+		if err := h.opts.Validate(r, op, doc); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err)
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+func (h *handler[T]) get(w http.ResponseWriter, r *http.Request) {
+	byID, err := h.byID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	doc, err := h.t.One(r.Context(), byID)
+	if err != nil {
+		writeTableError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, doc)
+}
+
+func (h *handler[T]) insert(w http.ResponseWriter, r *http.Request) {
+	doc, ok := h.decode(w, r, sqjdb.HookInsert)
+	if !ok {
+		return
+	}
+	doc, err := h.t.Insert(r.Context(), doc)
+	if err != nil {
+		writeTableError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, doc)
+}
+
+func (h *handler[T]) patch(w http.ResponseWriter, r *http.Request) {
+	byID, err := h.byID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	doc, ok := h.decode(w, r, sqjdb.HookPatch)
+	if !ok {
+		return
+	}
+	var patched *T
+	err = h.t.Do(r.Context(), func(conn *sqlite.Conn) error {
+		return sqjdb.WithTx(conn, func(conn *sqlite.Conn) error {
+			n, err := h.t.Table.Patch(conn, doc, byID)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return sqjdb.ErrNoDoc
+			}
+			patched, err = h.t.Table.One(conn, byID)
+			return err
+		})
+	})
+	if err != nil {
+		writeTableError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, patched)
+}
+
+func (h *handler[T]) delete(w http.ResponseWriter, r *http.Request) {
+	byID, err := h.byID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	n, err := h.t.Delete(r.Context(), byID)
+	if err != nil {
+		writeTableError(w, err)
+		return
+	}
+	if n == 0 {
+		writeTableError(w, sqjdb.ErrNoDoc)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// writeTableError writes an error returned by the table, hiding the details of
+// unexpected errors from the client.
+func writeTableError(w http.ResponseWriter, err error) {
+	var validation *sqjdb.ValidationError
+	var unique *sqjdb.UniqueViolationError
+	switch {
+	case errors.Is(err, sqjdb.ErrInvalidCursor):
+		writeError(w, http.StatusBadRequest, errors.New("invalid cursor"))
+	case errors.Is(err, sqjdb.ErrNoDoc):
+		writeError(w, http.StatusNotFound, errors.New("document not found"))
+	case errors.As(err, &validation):
+		writeError(w, http.StatusUnprocessableEntity, validation.Err)
+	case errors.As(err, &unique):
+		writeError(w, http.StatusConflict, fmt.Errorf("duplicate value for field %q", unique.Field))
+	case errors.Is(err, sqjdb.ErrConflict):
+		writeError(w, http.StatusConflict, errors.New("version conflict"))
+	default:
+		writeError(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+	}
+}
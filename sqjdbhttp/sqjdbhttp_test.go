@@ -0,0 +1,159 @@
+package sqjdbhttp_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"github.com/daaku/sqjdb/sqjdbhttp"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+type Jedi struct {
+	ID   string `json:",omitempty"`
+	Name string `json:",omitempty"`
+	Age  int    `json:",omitempty"`
+}
+
+func (j *Jedi) Validate() error {
+	if j.Name == "" {
+		return errors.New("name required")
+	}
+	return nil
+}
+
+func newServer(t *testing.T, opts sqjdbhttp.Options[Jedi], tableOpts ...sqjdb.Option) *httptest.Server {
+	pool, err := sqlitex.NewPool(
+		fmt.Sprintf("file:%s.db?mode=memory&cache=shared", t.Name()),
+		sqlitex.PoolOptions{PoolSize: 2},
+	)
+	ensure.Nil(t, err)
+	t.Cleanup(func() { pool.Close() })
+	jedis := sqjdb.NewTable[Jedi]("jedis", tableOpts...)
+	pjedis := jedis.WithPool(pool)
+	ensure.Nil(t, pjedis.Migrate(context.Background()))
+	srv := httptest.NewServer(http.StripPrefix("/jedis", sqjdbhttp.Handler(pjedis, opts)))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// call makes a request and decodes the JSON response into v if it is not nil.
+func call(t *testing.T, srv *httptest.Server, method, path, body string, v any) int {
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	ensure.Nil(t, err)
+	res, err := srv.Client().Do(req)
+	ensure.Nil(t, err)
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	ensure.Nil(t, err)
+	if v != nil {
+		ensure.Nil(t, json.Unmarshal(b, v))
+	}
+	return res.StatusCode
+}
+
+func TestCRUD(t *testing.T) {
+	srv := newServer(t, sqjdbhttp.Options[Jedi]{})
+	var yoda Jedi
+	ensure.DeepEqual(t, call(t, srv, "POST", "/jedis/", `{"Name":"yoda","Age":900}`, &yoda), http.StatusCreated)
+	ensure.NotDeepEqual(t, yoda.ID, "")
+
+	var got Jedi
+	ensure.DeepEqual(t, call(t, srv, "GET", "/jedis/"+yoda.ID, "", &got), http.StatusOK)
+	ensure.DeepEqual(t, got, yoda)
+
+	ensure.DeepEqual(t, call(t, srv, "PATCH", "/jedis/"+yoda.ID, `{"Age":980}`, &got), http.StatusOK)
+	ensure.DeepEqual(t, got, Jedi{ID: yoda.ID, Name: "yoda", Age: 980})
+
+	ensure.DeepEqual(t, call(t, srv, "PATCH", "/jedis/"+yoda.ID, `{"ID":"other"}`, nil), http.StatusBadRequest)
+
+	ensure.DeepEqual(t, call(t, srv, "DELETE", "/jedis/"+yoda.ID, "", nil), http.StatusNoContent)
+	ensure.DeepEqual(t, call(t, srv, "GET", "/jedis/"+yoda.ID, "", nil), http.StatusNotFound)
+	ensure.DeepEqual(t, call(t, srv, "PATCH", "/jedis/"+yoda.ID, `{"Age":1}`, nil), http.StatusNotFound)
+	ensure.DeepEqual(t, call(t, srv, "DELETE", "/jedis/"+yoda.ID, "", nil), http.StatusNotFound)
+}
+
+func TestList(t *testing.T) {
+	srv := newServer(t, sqjdbhttp.Options[Jedi]{Limit: 2})
+	for _, body := range []string{`{"Name":"yoda","Age":980}`, `{"Name":"luke","Age":42}`, `{"Name":"leia","Age":42}`} {
+		ensure.DeepEqual(t, call(t, srv, "POST", "/jedis/", body, nil), http.StatusCreated)
+	}
+
+	var page sqjdbhttp.Page[Jedi]
+	ensure.DeepEqual(t, call(t, srv, "GET", "/jedis/", "", &page), http.StatusOK)
+	ensure.DeepEqual(t, len(page.Items), 2)
+	ensure.NotDeepEqual(t, page.Next, "")
+	next := page.Next
+	page = sqjdbhttp.Page[Jedi]{}
+	ensure.DeepEqual(t, call(t, srv, "GET", "/jedis/?cursor="+next, "", &page), http.StatusOK)
+	ensure.DeepEqual(t, len(page.Items), 1)
+	ensure.DeepEqual(t, page.Items[0].Name, "leia")
+	ensure.DeepEqual(t, page.Next, "")
+
+	page = sqjdbhttp.Page[Jedi]{}
+	ensure.DeepEqual(t, call(t, srv, "GET", "/jedis/?Age=42&limit=10", "", &page), http.StatusOK)
+	ensure.DeepEqual(t, len(page.Items), 2)
+	ensure.DeepEqual(t, call(t, srv, "GET", "/jedis/?Age[gt]=42&Name[like]=y%25", "", &page), http.StatusOK)
+	ensure.DeepEqual(t, len(page.Items), 1)
+	ensure.DeepEqual(t, page.Items[0].Name, "yoda")
+
+	page = sqjdbhttp.Page[Jedi]{}
+	filter := url.QueryEscape(`{"Name":{"$in":["luke","yoda"]},"Age":{"$lt":100}}`)
+	ensure.DeepEqual(t, call(t, srv, "GET", "/jedis/?filter="+filter, "", &page), http.StatusOK)
+	ensure.DeepEqual(t, len(page.Items), 1)
+	ensure.DeepEqual(t, page.Items[0].Name, "luke")
+
+	ensure.DeepEqual(t, call(t, srv, "GET", "/jedis/?filter="+url.QueryEscape(`{"$where":"1"}`), "", nil),
+		http.StatusBadRequest)
+	ensure.DeepEqual(t, call(t, srv, "GET", "/jedis/?Rank=master", "", nil), http.StatusBadRequest)
+	ensure.DeepEqual(t, call(t, srv, "GET", "/jedis/?Age[between]=1", "", nil), http.StatusBadRequest)
+	ensure.DeepEqual(t, call(t, srv, "GET", "/jedis/?Age=old", "", nil), http.StatusBadRequest)
+	ensure.DeepEqual(t, call(t, srv, "GET", "/jedis/?limit=5000", "", nil), http.StatusBadRequest)
+	ensure.DeepEqual(t, call(t, srv, "GET", "/jedis/?cursor=bogus", "", nil), http.StatusBadRequest)
+}
+
+func TestValidation(t *testing.T) {
+	srv := newServer(t, sqjdbhttp.Options[Jedi]{
+		Validate: func(r *http.Request, op sqjdb.HookOp, doc *Jedi) error {
+			if doc.Age < 0 {
+				return errors.New("age can not be negative")
+			}
+			return nil
+		},
+	})
+	var res map[string]string
+	ensure.DeepEqual(t, call(t, srv, "POST", "/jedis/", `{"Age":1}`, &res), http.StatusUnprocessableEntity)
+	ensure.DeepEqual(t, res["error"], "name required")
+	ensure.DeepEqual(t, call(t, srv, "POST", "/jedis/", `{"Name":"rey","Age":-1}`, &res), http.StatusUnprocessableEntity)
+	ensure.DeepEqual(t, res["error"], "age can not be negative")
+	ensure.DeepEqual(t, call(t, srv, "POST", "/jedis/", `{`, nil), http.StatusBadRequest)
+}
+
+func TestListPromoted(t *testing.T) {
+	srv := newServer(t, sqjdbhttp.Options[Jedi]{}, sqjdb.Promote("Age"))
+	for _, body := range []string{`{"Name":"yoda","Age":980}`, `{"Name":"luke","Age":42}`} {
+		ensure.DeepEqual(t, call(t, srv, "POST", "/jedis/", body, nil), http.StatusCreated)
+	}
+	var page sqjdbhttp.Page[Jedi]
+	ensure.DeepEqual(t, call(t, srv, "GET", "/jedis/?Age[gt]=42", "", &page), http.StatusOK)
+	ensure.DeepEqual(t, len(page.Items), 1)
+	ensure.DeepEqual(t, page.Items[0].Name, "yoda")
+}
+
+func TestBodyLimit(t *testing.T) {
+	srv := newServer(t, sqjdbhttp.Options[Jedi]{MaxBodyBytes: 32})
+	var res map[string]string
+	body := `{"Name":"` + strings.Repeat("a", 64) + `"}`
+	ensure.DeepEqual(t, call(t, srv, "POST", "/jedis/", body, &res), http.StatusRequestEntityTooLarge)
+	ensure.DeepEqual(t, res["error"], "request body too large")
+	ensure.DeepEqual(t, call(t, srv, "POST", "/jedis/", `{"Name":"rey"}`, nil), http.StatusCreated)
+}
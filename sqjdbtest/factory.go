@@ -0,0 +1,65 @@
+package sqjdbtest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+)
+
+// Factory builds documents from defaults, with overrides applied per call,
+// and inserts them. Documents that refer to others can be seeded by using the
+// IDs of created documents in overrides.
+type Factory[T any] struct {
+	table    *sqjdb.Table[T]
+	defaults func(n int) *T
+
+	mu sync.Mutex
+	n  int
+}
+
+// NewFactory returns a Factory for the table. The defaults function returns a
+// new document for the nth document built, starting at 1, which can be used to
+// make unique values.
+func NewFactory[T any](table *sqjdb.Table[T], defaults func(n int) *T) *Factory[T] {
+	return &Factory[T]{table: table, defaults: defaults}
+}
+
+// Build returns a document with the overrides applied in order, without
+// inserting it.
+func (f *Factory[T]) Build(overrides ...func(*T)) *T {
+	f.mu.Lock()
+	f.n++
+	n := f.n
+	f.mu.Unlock()
+	doc := f.defaults(n)
+	for _, override := range overrides {
+		override(doc)
+	}
+	return doc
+}
+
+// Create builds a document and inserts it, returning it as inserted.
+func (f *Factory[T]) Create(t testing.TB, conn *sqlite.Conn, overrides ...func(*T)) *T {
+	t.Helper()
+	doc, err := f.table.Insert(conn, f.Build(overrides...))
+	if err != nil {
+		t.Fatalf("sqjdbtest: %v", err)
+	}
+	return doc
+}
+
+// CreateN builds count documents, with the same overrides, and inserts them.
+func (f *Factory[T]) CreateN(t testing.TB, conn *sqlite.Conn, count int, overrides ...func(*T)) []*T {
+	t.Helper()
+	docs := make([]*T, count)
+	for i := range docs {
+		docs[i] = f.Build(overrides...)
+	}
+	docs, err := f.table.InsertMany(conn, docs)
+	if err != nil {
+		t.Fatalf("sqjdbtest: %v", err)
+	}
+	return docs
+}
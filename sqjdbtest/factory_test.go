@@ -0,0 +1,40 @@
+package sqjdbtest_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"github.com/daaku/sqjdb/sqjdbtest"
+)
+
+type Padawan struct {
+	ID     string `json:",omitempty"`
+	Name   string `json:",omitempty"`
+	Master string `json:",omitempty"`
+}
+
+var padawans = sqjdb.NewTable[Padawan]("padawans")
+
+func TestFactory(t *testing.T) {
+	conn := sqjdbtest.Conn(t, sqjdbtest.Options{Tables: []sqjdbtest.Migrator{&jedis, &padawans}})
+	jediFactory := sqjdbtest.NewFactory(&jedis, func(n int) *Jedi {
+		return &Jedi{Name: fmt.Sprintf("jedi %d", n), Age: 30}
+	})
+	padawanFactory := sqjdbtest.NewFactory(&padawans, func(n int) *Padawan {
+		return &Padawan{Name: fmt.Sprintf("padawan %d", n)}
+	})
+
+	ensure.DeepEqual(t, jediFactory.Build(), &Jedi{Name: "jedi 1", Age: 30})
+	obiwan := jediFactory.Create(t, conn, func(j *Jedi) { j.Name = "obiwan" })
+	ensure.NotDeepEqual(t, obiwan.ID, "")
+	ensure.DeepEqual(t, obiwan.Age, 30)
+
+	created := padawanFactory.CreateN(t, conn, 2, func(p *Padawan) { p.Master = obiwan.ID })
+	ensure.DeepEqual(t, len(created), 2)
+	ensure.DeepEqual(t, created[1].Name, "padawan 2")
+	n, err := padawans.Count(conn, sqjdb.Where("Master").Eq(obiwan.ID).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 2)
+}
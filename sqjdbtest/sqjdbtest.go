@@ -0,0 +1,128 @@
+// Package sqjdbtest provides databases for tests using sqjdb tables.
+package sqjdbtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// Migrator is a table that can be migrated, such as a *sqjdb.Table.
+type Migrator interface {
+	Migrate(conn *sqlite.Conn) error
+}
+
+// Options configures the test database.
+type Options struct {
+	// File stores the database in a file in a temporary directory, instead of
+	// in memory. File databases use WAL mode like production databases.
+	File bool
+	// PoolSize is the number of connections in a Pool. The default is 2.
+	PoolSize int
+	// Tables are migrated when the database is created.
+	Tables []Migrator
+}
+
+var dbCount atomic.Int64
+
+// path returns the path of a new database for the test.
+func (o Options) path(t testing.TB) string {
+	if o.File {
+		return filepath.Join(t.TempDir(), "sqjdb.db")
+	}
+	// Each database has a unique name, so tests running in parallel, or using
+	// more than one, are isolated.
+	return fmt.Sprintf("file:sqjdbtest-%d?mode=memory&cache=shared", dbCount.Add(1))
+}
+
+func (o Options) migrate(t testing.TB, conn *sqlite.Conn) {
+	for _, table := range o.Tables {
+		if err := table.Migrate(conn); err != nil {
+			t.Fatalf("sqjdbtest: %v", err)
+		}
+	}
+}
+
+// Conn returns a connection to a new database with the tables migrated. The
+// connection is closed, and the database removed, when the test ends.
+func Conn(t testing.TB, opts Options) *sqlite.Conn {
+	t.Helper()
+	conn, err := sqjdb.Open(opts.path(t))
+	if err != nil {
+		t.Fatalf("sqjdbtest: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	opts.migrate(t, conn)
+	return conn
+}
+
+// Pool returns a pool of connections to a new database with the tables
+// migrated. The pool is closed, and the database removed, when the test ends.
+func Pool(t testing.TB, opts Options) *sqlitex.Pool {
+	t.Helper()
+	size := opts.PoolSize
+	if size == 0 {
+		size = 2
+	}
+	pool, err := sqlitex.NewPool(opts.path(t), sqlitex.PoolOptions{
+		PoolSize:    size,
+		PrepareConn: sqjdb.RegisterFunctions,
+	})
+	if err != nil {
+		t.Fatalf("sqjdbtest: %v", err)
+	}
+	t.Cleanup(func() { pool.Close() })
+	conn, err := pool.Take(context.Background())
+	if err != nil {
+		t.Fatalf("sqjdbtest: %v", err)
+	}
+	defer pool.Put(conn)
+	opts.migrate(t, conn)
+	return pool
+}
+
+// Load inserts the documents in the JSON file into the table, and returns them
+// as inserted, with generated IDs set. The file contains either an array of
+// documents, or one document per line.
+func Load[T any](t testing.TB, conn *sqlite.Conn, table *sqjdb.Table[T], path string) []*T {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("sqjdbtest: %v", err)
+	}
+	var docs []*T
+	if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '[' {
+		err = json.Unmarshal(data, &docs)
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		for {
+			doc := new(T)
+			if err = dec.Decode(doc); err != nil {
+				break
+			}
+			docs = append(docs, doc)
+		}
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	}
+	if err != nil {
+		t.Fatalf("sqjdbtest: loading %s: %v", path, err)
+	}
+	docs, err = table.InsertMany(conn, docs)
+	if err != nil {
+		t.Fatalf("sqjdbtest: loading %s: %v", path, err)
+	}
+	return docs
+}
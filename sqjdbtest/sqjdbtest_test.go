@@ -0,0 +1,52 @@
+package sqjdbtest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"github.com/daaku/sqjdb/sqjdbtest"
+)
+
+type Jedi struct {
+	ID   string `json:",omitempty"`
+	Name string `json:",omitempty"`
+	Age  int    `json:",omitempty"`
+}
+
+var jedis = sqjdb.NewTable[Jedi]("jedis")
+
+func TestConn(t *testing.T) {
+	for _, file := range []bool{false, true} {
+		conn := sqjdbtest.Conn(t, sqjdbtest.Options{File: file, Tables: []sqjdbtest.Migrator{&jedis}})
+		docs := sqjdbtest.Load(t, conn, &jedis, "testdata/jedis.json")
+		ensure.DeepEqual(t, len(docs), 2)
+		ensure.DeepEqual(t, docs[0].ID, "yoda")
+		ensure.NotDeepEqual(t, docs[1].ID, "")
+		sqjdbtest.Load(t, conn, &jedis, "testdata/jedis.jsonl")
+		n, err := jedis.Count(conn, sqjdb.Where("Age").Eq(42).SQL())
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, n, 2)
+	}
+}
+
+func TestConnIsolated(t *testing.T) {
+	a := sqjdbtest.Conn(t, sqjdbtest.Options{Tables: []sqjdbtest.Migrator{&jedis}})
+	b := sqjdbtest.Conn(t, sqjdbtest.Options{Tables: []sqjdbtest.Migrator{&jedis}})
+	sqjdbtest.Load(t, a, &jedis, "testdata/jedis.json")
+	n, err := jedis.Count(b)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 0)
+}
+
+func TestPool(t *testing.T) {
+	pool := sqjdbtest.Pool(t, sqjdbtest.Options{Tables: []sqjdbtest.Migrator{&jedis}})
+	pjedis := jedis.WithPool(pool)
+	ctx := context.Background()
+	_, err := pjedis.Insert(ctx, &Jedi{Name: "grogu"})
+	ensure.Nil(t, err)
+	n, err := pjedis.Count(ctx)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+}
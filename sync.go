@@ -0,0 +1,308 @@
+package sqjdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// DocChange is a change to a document in a Changeset.
+type DocChange struct {
+	Table string
+	// ID is the ID as stored in the document, so it is an int64 for integer
+	// IDs and a string otherwise.
+	ID any
+	// Op is "upsert" or "delete".
+	Op string
+	// Doc is the document for upserts.
+	Doc json.RawMessage `json:",omitempty"`
+}
+
+// UnmarshalJSON decodes the change, keeping integer IDs as int64 so they match
+// the IDs in the documents.
+func (c *DocChange) UnmarshalJSON(b []byte) error {
+	type docChange DocChange
+	var v struct {
+		docChange
+		ID json.RawMessage
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*c = DocChange(v.docChange)
+	if len(v.ID) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(v.ID))
+	dec.UseNumber()
+	if err := dec.Decode(&c.ID); err != nil {
+		return err
+	}
+	if n, ok := c.ID.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			c.ID = i
+		} else {
+			f, err := n.Float64()
+			if err != nil {
+				return err
+			}
+			c.ID = f
+		}
+	}
+	return nil
+}
+
+// Changeset is a list of changes to documents, which can be encoded as JSON to
+// send it between copies of a database, for example to sync an offline client
+// with a server. Changesets work with the documents rather than the rows, so
+// the copies need not have the same rowids, and tables using a Codec are not
+// supported.
+type Changeset []DocChange
+
+// docIDExpr returns the ID expression of the document table in the schema,
+// which identifies it as a table created by Migrate.
+func docIDExpr(conn *sqlite.Conn, schema, table string) (string, error) {
+	var indexSQL string
+	err := sqlitex.Execute(conn,
+		"select sql from "+schema+".sqlite_schema where type = 'index' and tbl_name = ? and name = ?",
+		&sqlitex.ExecOptions{
+			Args: []any{table, table + "_ID"},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				indexSQL = stmt.ColumnText(0)
+				return nil
+			},
+		})
+	if err != nil {
+		return "", fmt.Errorf("sqjdb: finding ID index on %q: %w", table, err)
+	}
+	if indexSQL == "" {
+		return "", fmt.Errorf("sqjdb: %q is not a document table in %q", table, schema)
+	}
+	return idIndexExpr(table, indexSQL)
+}
+
+// ChangesetSince returns the changes recorded by Changelog after the given
+// sequence number, along with the sequence number to pass next time. Multiple
+// changes to a document are collapsed into one with its current state.
+func ChangesetSince(conn *sqlite.Conn, seq int64) (Changeset, int64, error) {
+	records, err := ChangelogSince(conn, seq, 0)
+	if err != nil {
+		return nil, seq, err
+	}
+	if len(records) == 0 {
+		return nil, seq, nil
+	}
+	last := records[len(records)-1].Seq
+	// Keep the last change to each document, in the order of those changes.
+	seen := map[[2]any]bool{}
+	var latest []ChangeRecord
+	for _, r := range slices.Backward(records) {
+		key := [2]any{r.Table, r.ID}
+		if !seen[key] {
+			seen[key] = true
+			latest = append(latest, r)
+		}
+	}
+	slices.Reverse(latest)
+	idExprs := map[string]string{}
+	var cs Changeset
+	for _, r := range latest {
+		change := DocChange{Table: r.Table, ID: r.ID, Op: "delete"}
+		if r.Op != "delete" {
+			idExpr, ok := idExprs[r.Table]
+			if !ok {
+				if idExpr, err = docIDExpr(conn, "main", r.Table); err != nil {
+					return nil, seq, err
+				}
+				idExprs[r.Table] = idExpr
+			}
+			err := sqlitex.Execute(conn,
+				"select json(data) from "+quoteName(r.Table)+" where "+idExpr+" = ?",
+				&sqlitex.ExecOptions{
+					Args: []any{r.ID},
+					ResultFunc: func(stmt *sqlite.Stmt) error {
+						change.Op = "upsert"
+						change.Doc = json.RawMessage(stmt.ColumnText(0))
+						return nil
+					},
+				})
+			if err != nil {
+				return nil, seq, fmt.Errorf("sqjdb: reading %v from %q: %w", r.ID, r.Table, err)
+			}
+		}
+		cs = append(cs, change)
+	}
+	return cs, last, nil
+}
+
+// Diff returns the changes that make the tables in the from schema match those
+// in the to schema, such as a copy of the database attached with Attach.
+func Diff(conn *sqlite.Conn, from, to string, tables ...string) (Changeset, error) {
+	var cs Changeset
+	for _, table := range tables {
+		idExpr, err := docIDExpr(conn, to, table)
+		if err != nil {
+			return nil, err
+		}
+		src, dst := from+"."+quoteName(table), to+"."+quoteName(table)
+		upserts := "select b." + idExpr + ", json(b.data) from " + dst + " as b" +
+			" where not exists (select 1 from " + src + " as a" +
+			" where a." + idExpr + " = b." + idExpr + " and a.data = b.data)"
+		err = sqlitex.Execute(conn, upserts, &sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				cs = append(cs, DocChange{
+					Table: table,
+					ID:    columnValue(stmt, 0),
+					Op:    "upsert",
+					Doc:   json.RawMessage(stmt.ColumnText(1)),
+				})
+				return nil
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("sqjdb: diffing %q: %w", table, err)
+		}
+		deletes := "select a." + idExpr + " from " + src + " as a" +
+			" where not exists (select 1 from " + dst + " as b" +
+			" where b." + idExpr + " = a." + idExpr + ")"
+		err = sqlitex.Execute(conn, deletes, &sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				cs = append(cs, DocChange{Table: table, ID: columnValue(stmt, 0), Op: "delete"})
+				return nil
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("sqjdb: diffing %q: %w", table, err)
+		}
+	}
+	return cs, nil
+}
+
+// Resolver decides the outcome of a change to a document that differs locally,
+// and is created with Table.Resolve.
+type Resolver struct {
+	table string
+	fn    func(local, remote []byte) ([]byte, error)
+}
+
+// Resolve returns a Resolver for ApplyChangeset that calls fn when a change
+// from the changeset conflicts with the local document, which is when the
+// document exists locally and differs from the changed one. The remote
+// document is nil for deletes. The returned document is stored, or the local
+// one is deleted if it is nil, so returning remote is last write wins, and
+// returning local keeps the local document. Documents that do not exist
+// locally are applied without calling fn.
+func (t *Table[T]) Resolve(fn func(local, remote *T) (*T, error)) Resolver {
+	_, base := splitName(t.Name)
+	return Resolver{
+		table: base,
+		fn: func(localJSON, remoteJSON []byte) ([]byte, error) {
+			local := new(T)
+			if err := t.unmarshal(localJSON, local); err != nil {
+				return nil, fmt.Errorf("sqjdb: invalid json from db: %w\n%s", err, localJSON)
+			}
+			var remote *T
+			if remoteJSON != nil {
+				remote = new(T)
+				if err := t.unmarshal(remoteJSON, remote); err != nil {
+					return nil, fmt.Errorf("sqjdb: invalid json in changeset: %w\n%s", err, remoteJSON)
+				}
+			}
+			resolved, err := fn(local, remote)
+			if err != nil || resolved == nil {
+				return nil, err
+			}
+			return json.Marshal(resolved)
+		},
+	}
+}
+
+// ApplyChangeset applies the changes within a transaction. Changes to tables
+// without a Resolver overwrite the local documents. The documents are written
+// as is, so hooks, validation and versioning are skipped, but the triggers for
+// TrackChanges, Changelog and History still record them.
+func ApplyChangeset(conn *sqlite.Conn, cs Changeset, resolvers ...Resolver) error {
+	byTable := map[string]*Resolver{}
+	for i, r := range resolvers {
+		byTable[r.table] = &resolvers[i]
+	}
+	return WithTx(conn, func(conn *sqlite.Conn) error {
+		idExprs := map[string]string{}
+		for _, change := range cs {
+			idExpr, ok := idExprs[change.Table]
+			if !ok {
+				var err error
+				if idExpr, err = docIDExpr(conn, "main", change.Table); err != nil {
+					return err
+				}
+				idExprs[change.Table] = idExpr
+			}
+			if err := applyChange(conn, idExpr, change, byTable[change.Table]); err != nil {
+				return fmt.Errorf("sqjdb: applying %s of %v to %q: %w",
+					change.Op, change.ID, change.Table, err)
+			}
+		}
+		return nil
+	})
+}
+
+// resolveChange calls the resolver if the change conflicts with the local
+// document, and returns the change to apply.
+func resolveChange(conn *sqlite.Conn, idExpr string, change DocChange, r *Resolver) (DocChange, error) {
+	var remote any
+	if change.Op == "upsert" {
+		remote = string(change.Doc)
+	}
+	var local []byte
+	err := sqlitex.Execute(conn,
+		"select json(data), data is jsonb(?) from "+quoteName(change.Table)+" where "+idExpr+" = ?",
+		&sqlitex.ExecOptions{
+			Args: []any{remote, change.ID},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				if !stmt.ColumnBool(1) {
+					local = []byte(stmt.ColumnText(0))
+				}
+				return nil
+			},
+		})
+	if err != nil || local == nil {
+		return change, err
+	}
+	resolved, err := r.fn(local, change.Doc)
+	if err != nil {
+		return change, err
+	}
+	if resolved == nil {
+		return DocChange{Table: change.Table, ID: change.ID, Op: "delete"}, nil
+	}
+	return DocChange{Table: change.Table, ID: change.ID, Op: "upsert", Doc: resolved}, nil
+}
+
+func applyChange(conn *sqlite.Conn, idExpr string, change DocChange, r *Resolver) error {
+	if r != nil && (change.Op == "upsert" || change.Op == "delete") {
+		var err error
+		if change, err = resolveChange(conn, idExpr, change, r); err != nil {
+			return err
+		}
+	}
+	table := quoteName(change.Table)
+	switch change.Op {
+	case "upsert":
+		err := sqlitex.Execute(conn, "update "+table+" set data = jsonb(?) where "+idExpr+" = ?",
+			&sqlitex.ExecOptions{Args: []any{string(change.Doc), change.ID}})
+		if err != nil || conn.Changes() > 0 {
+			return err
+		}
+		return sqlitex.Execute(conn, "insert into "+table+" (data) values (jsonb(?))",
+			&sqlitex.ExecOptions{Args: []any{string(change.Doc)}})
+	case "delete":
+		return sqlitex.Execute(conn, "delete from "+table+" where "+idExpr+" = ?",
+			&sqlitex.ExecOptions{Args: []any{change.ID}})
+	default:
+		return fmt.Errorf("unknown op %q", change.Op)
+	}
+}
@@ -0,0 +1,201 @@
+package sqjdb_test
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+)
+
+func TestChangesetSince(t *testing.T) {
+	client := newConn(t)
+	synced := sqjdb.NewTable[Jedi]("synced", sqjdb.Changelog())
+	ensure.Nil(t, synced.Migrate(client))
+	a, err := synced.Insert(client, &Jedi{Name: "anakin"})
+	ensure.Nil(t, err)
+	b, err := synced.Insert(client, &Jedi{Name: "obiwan"})
+	ensure.Nil(t, err)
+	_, err = synced.Patch(client, &Jedi{Name: "vader"}, sqjdb.ByID(a.ID))
+	ensure.Nil(t, err)
+	a.Name = "vader"
+	_, err = synced.Delete(client, sqjdb.ByID(b.ID))
+	ensure.Nil(t, err)
+
+	cs, seq, err := sqjdb.ChangesetSince(client, 0)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, seq, int64(4))
+	ensure.DeepEqual(t, len(cs), 2)
+	ensure.DeepEqual(t, cs[0].Op, "upsert")
+	ensure.DeepEqual(t, cs[0].ID, a.ID)
+	ensure.DeepEqual(t, cs[1], sqjdb.DocChange{Table: "synced", ID: b.ID, Op: "delete"})
+	cs, next, err := sqjdb.ChangesetSince(client, seq)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(cs), 0)
+	ensure.DeepEqual(t, next, seq)
+
+	server, err := sqlite.OpenConn(":memory:")
+	ensure.Nil(t, err)
+	defer server.Close()
+	ensure.Nil(t, synced.Migrate(server))
+	_, err = synced.Insert(server, b)
+	ensure.Nil(t, err)
+	cs, _, err = sqjdb.ChangesetSince(client, 0)
+	ensure.Nil(t, err)
+	encoded, err := json.Marshal(cs)
+	ensure.Nil(t, err)
+	var decoded sqjdb.Changeset
+	ensure.Nil(t, json.Unmarshal(encoded, &decoded))
+	ensure.Nil(t, sqjdb.ApplyChangeset(server, decoded))
+	docs, err := synced.All(server)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docs, []*Jedi{a})
+
+	// Applying again is a no-op.
+	ensure.Nil(t, sqjdb.ApplyChangeset(server, decoded))
+	docs, err = synced.All(server)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docs, []*Jedi{a})
+
+	err = sqjdb.ApplyChangeset(server, sqjdb.Changeset{{Table: "sqlite_schema", ID: "x", Op: "delete"}})
+	ensure.StringContains(t, err.Error(), "not a document table")
+}
+
+func TestChangesetIntID(t *testing.T) {
+	client := newConn(t)
+	tickets := sqjdb.NewTable[Ticket]("tickets", sqjdb.Changelog())
+	ensure.Nil(t, tickets.Migrate(client))
+	for i := 1; i <= 3; i++ {
+		_, err := tickets.Insert(client, &Ticket{ID: i, Title: "open"})
+		ensure.Nil(t, err)
+	}
+	server, err := sqlite.OpenConn(":memory:")
+	ensure.Nil(t, err)
+	defer server.Close()
+	ensure.Nil(t, tickets.Migrate(server))
+
+	sync := func(seq int64) int64 {
+		cs, next, err := sqjdb.ChangesetSince(client, seq)
+		ensure.Nil(t, err)
+		encoded, err := json.Marshal(cs)
+		ensure.Nil(t, err)
+		var decoded sqjdb.Changeset
+		ensure.Nil(t, json.Unmarshal(encoded, &decoded))
+		ensure.DeepEqual(t, decoded, cs)
+		ensure.Nil(t, sqjdb.ApplyChangeset(server, decoded))
+		return next
+	}
+	seq := sync(0)
+	_, err = tickets.Patch(client, &Ticket{Title: "closed"}, sqjdb.ByID(2))
+	ensure.Nil(t, err)
+	_, err = tickets.Delete(client, sqjdb.ByID(3))
+	ensure.Nil(t, err)
+	cs, _, err := sqjdb.ChangesetSince(client, seq)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, cs[1], sqjdb.DocChange{Table: "tickets", ID: int64(3), Op: "delete"})
+	sync(seq)
+
+	docs, err := tickets.All(server)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docs, []*Ticket{{ID: 1, Title: "open"}, {ID: 2, Title: "closed"}})
+
+	// Diff reports the integer IDs as they are stored too.
+	ensure.Nil(t, sqjdb.Attach(client, ":memory:", "copy"))
+	copied := sqjdb.NewTable[Ticket]("copy.tickets")
+	ensure.Nil(t, copied.Migrate(client))
+	_, err = copied.Insert(client, &Ticket{ID: 1, Title: "open"})
+	ensure.Nil(t, err)
+	_, err = copied.Insert(client, &Ticket{ID: 4, Title: "open"})
+	ensure.Nil(t, err)
+	cs, err = sqjdb.Diff(client, "copy", "main", "tickets")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, cs, sqjdb.Changeset{
+		{Table: "tickets", ID: int64(2), Op: "upsert", Doc: json.RawMessage(`{"ID":2,"Title":"closed"}`)},
+		{Table: "tickets", ID: int64(4), Op: "delete"},
+	})
+}
+
+func TestDiff(t *testing.T) {
+	conn := newConn(t)
+	ensure.Nil(t, sqjdb.Attach(conn, ":memory:", "copy"))
+	copied := sqjdb.NewTable[Jedi]("copy.jedis")
+	ensure.Nil(t, copied.Migrate(conn))
+	_, err := copied.Insert(conn, &yoda)
+	ensure.Nil(t, err)
+	_, err = copied.Insert(conn, &Jedi{ID: luke.ID, Name: "luke", Age: 19})
+	ensure.Nil(t, err)
+	_, err = copied.Insert(conn, &Jedi{ID: "ahsoka", Name: "ahsoka"})
+	ensure.Nil(t, err)
+
+	cs, err := sqjdb.Diff(conn, "copy", "main", "jedis")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(cs), 3)
+	ensure.DeepEqual(t, cs[2], sqjdb.DocChange{Table: "jedis", ID: "ahsoka", Op: "delete"})
+	ids := []string{cs[0].ID.(string), cs[1].ID.(string)}
+	expected := []string{luke.ID, leia.ID}
+	slices.Sort(ids)
+	slices.Sort(expected)
+	ensure.DeepEqual(t, ids, expected)
+	var doc Jedi
+	for _, change := range cs[:2] {
+		if change.ID == luke.ID {
+			ensure.Nil(t, json.Unmarshal(change.Doc, &doc))
+		}
+	}
+	ensure.DeepEqual(t, doc, luke)
+}
+
+func TestApplyChangesetResolve(t *testing.T) {
+	conn := newConn(t)
+	local := Jedi{ID: luke.ID, Name: "luke", Age: 70}
+	_, err := jedis.Replace(conn, &local, sqjdb.ByID(luke.ID))
+	ensure.Nil(t, err)
+	var conflicts [][2]*Jedi
+	resolver := jedis.Resolve(func(local, remote *Jedi) (*Jedi, error) {
+		conflicts = append(conflicts, [2]*Jedi{local, remote})
+		if remote == nil {
+			return local, nil
+		}
+		merged := *remote
+		merged.Age = max(local.Age, remote.Age)
+		return &merged, nil
+	})
+	doc := func(j Jedi) json.RawMessage {
+		b, err := json.Marshal(j)
+		ensure.Nil(t, err)
+		return b
+	}
+	cs := sqjdb.Changeset{
+		{Table: "jedis", ID: yoda.ID, Op: "upsert", Doc: doc(yoda)},
+		{Table: "jedis", ID: luke.ID, Op: "upsert", Doc: doc(Jedi{ID: luke.ID, Name: "skywalker", Age: 20})},
+		{Table: "jedis", ID: leia.ID, Op: "delete"},
+		{Table: "jedis", ID: "grogu", Op: "upsert", Doc: doc(Jedi{ID: "grogu", Name: "grogu"})},
+	}
+	ensure.Nil(t, sqjdb.ApplyChangeset(conn, cs, resolver))
+	ensure.DeepEqual(t, conflicts, [][2]*Jedi{
+		{&local, {ID: luke.ID, Name: "skywalker", Age: 20}},
+		{&leia, nil},
+	})
+	docs, err := jedis.All(conn, sqjdb.OrderBy("Name", sqjdb.Asc))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docs, []*Jedi{
+		{ID: "grogu", Name: "grogu"},
+		&leia,
+		{ID: luke.ID, Name: "skywalker", Age: 70},
+		&yoda,
+	})
+
+	failing := jedis.Resolve(func(local, remote *Jedi) (*Jedi, error) {
+		return nil, errors.New("conflict")
+	})
+	err = sqjdb.ApplyChangeset(conn, sqjdb.Changeset{
+		{Table: "jedis", ID: "ahsoka", Op: "upsert", Doc: doc(Jedi{ID: "ahsoka", Name: "ahsoka"})},
+		{Table: "jedis", ID: leia.ID, Op: "delete"},
+	}, failing)
+	ensure.StringContains(t, err.Error(), "conflict")
+	_, err = jedis.One(conn, sqjdb.ByID("ahsoka"))
+	ensure.True(t, errors.Is(err, sqjdb.ErrNoDoc))
+}
@@ -0,0 +1,77 @@
+package sqjdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrNoTenant is returned by TenantTable when the context has no tenant.
+var ErrNoTenant = errors.New("sqjdb: no tenant in context")
+
+type tenantKey struct{}
+
+// WithTenant returns a context carrying the tenant used by TenantTable.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant set with WithTenant.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+// TenantTable restricts a Table to the tenant in the context, so documents of
+// other tenants can not be read or written by accident. Use TenantScoped to
+// create one.
+type TenantTable[T any] struct {
+	table *Table[T]
+	field string
+}
+
+// TenantScoped returns a TenantTable that stores the tenant in the named
+// string field, typically TenantID. The field should usually be indexed.
+func TenantScoped[T any](t *Table[T], field string) *TenantTable[T] {
+	return &TenantTable[T]{table: t, field: field}
+}
+
+// For returns a copy of the table scoped to the tenant in the context. Every
+// query on it is restricted to documents where the tenant field matches, and
+// documents written through it have the tenant field set. It returns
+// ErrNoTenant if the context has no tenant.
+func (t *TenantTable[T]) For(ctx context.Context) (*Table[T], error) {
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil, ErrNoTenant
+	}
+	scoped := *t.table
+	scoped.opts.tenant = t.field
+	scoped.opts.tenantID = tenant
+	// Only replace documents belonging to the same tenant.
+	sep := " where "
+	if t.table.opts.kindField != "" {
+		sep = " and "
+	}
+	f := fieldExpr(t.table.FieldName(t.field))
+	scoped.qUpsert = t.table.qUpsert + sep + f + " = excluded." + f
+	return &scoped, nil
+}
+
+// withTenant returns the document as is if the tenant field is already set to
+// the tenant, or a shallow clone with it set.
+func (t *Table[T]) withTenant(doc *T) (*T, error) {
+	v := docField(doc, t.opts.tenant)
+	if !v.IsValid() || v.Kind() != reflect.String {
+		return nil, fmt.Errorf("sqjdb: expected type %T to contain a %s field of type string",
+			doc, t.opts.tenant)
+	}
+	if v.String() == t.opts.tenantID {
+		return doc, nil
+	}
+	docCopy := *doc
+	doc = &docCopy
+	docField(doc, t.opts.tenant).SetString(t.opts.tenantID)
+	return doc, nil
+}
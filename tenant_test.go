@@ -0,0 +1,93 @@
+package sqjdb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+type Note struct {
+	ID       string `json:",omitempty"`
+	TenantID string `json:",omitempty"`
+	Text     string `json:",omitempty"`
+}
+
+func TestTenantScoped(t *testing.T) {
+	conn := newConn(t)
+	notes := sqjdb.NewTable[Note](t.Name(), sqjdb.KeepHistory())
+	ensure.Nil(t, notes.Migrate(conn))
+	scoped := sqjdb.TenantScoped(&notes, "TenantID")
+
+	a, err := scoped.For(sqjdb.WithTenant(context.Background(), "a"))
+	ensure.Nil(t, err)
+	b, err := scoped.For(sqjdb.WithTenant(context.Background(), "b"))
+	ensure.Nil(t, err)
+
+	noteA, err := a.Insert(conn, &Note{Text: "a", TenantID: "b"})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, noteA.TenantID, "a")
+	_, err = b.Insert(conn, &Note{Text: "b"})
+	ensure.Nil(t, err)
+
+	docs, err := a.All(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 1)
+	ensure.DeepEqual(t, docs[0].Text, "a")
+
+	_, err = b.Get(conn, noteA.ID)
+	ensure.DeepEqual(t, err, sqjdb.ErrNoDoc)
+	n, err := b.Patch(conn, &Note{Text: "stolen"}, b.ByID(noteA.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 0)
+	n, err = b.Delete(conn, b.ByID(noteA.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 0)
+	_, err = b.Upsert(conn, &Note{ID: noteA.ID, Text: "stolen"})
+	ensure.NotNil(t, err)
+	entries, err := b.History(conn, noteA.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(entries), 0)
+
+	n, err = a.Replace(conn, &Note{ID: noteA.ID, Text: "moved", TenantID: "b"}, a.ByID(noteA.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+	doc, err := a.Get(conn, noteA.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, doc.Text, "moved")
+	ensure.DeepEqual(t, doc.TenantID, "a")
+}
+
+func TestTenantScopedNoTenant(t *testing.T) {
+	notes := sqjdb.NewTable[Note](t.Name())
+	_, err := sqjdb.TenantScoped(&notes, "TenantID").For(context.Background())
+	ensure.DeepEqual(t, err, sqjdb.ErrNoTenant)
+}
+
+type TaggedNote struct {
+	ID       string `json:"id,omitempty"`
+	TenantID string `json:"tenant,omitempty"`
+	Text     string `json:"text,omitempty"`
+}
+
+func TestTenantScopedJSONTag(t *testing.T) {
+	conn := newConn(t)
+	notes := sqjdb.NewTable[TaggedNote](t.Name())
+	ensure.Nil(t, notes.Migrate(conn))
+	scoped := sqjdb.TenantScoped(&notes, "TenantID")
+	a, err := scoped.For(sqjdb.WithTenant(context.Background(), "a"))
+	ensure.Nil(t, err)
+	b, err := scoped.For(sqjdb.WithTenant(context.Background(), "b"))
+	ensure.Nil(t, err)
+
+	note, err := a.Insert(conn, &TaggedNote{Text: "a"})
+	ensure.Nil(t, err)
+	_, err = a.Upsert(conn, &TaggedNote{ID: note.ID, Text: "replaced"})
+	ensure.Nil(t, err)
+	doc, err := a.Get(conn, note.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, doc.Text, "replaced")
+	_, err = b.Upsert(conn, &TaggedNote{ID: note.ID, Text: "stolen"})
+	ensure.NotNil(t, err)
+}
@@ -0,0 +1,161 @@
+package sqjdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// Statement describes a statement run by a table, as given to a Tracer.
+type Statement struct {
+	// Table is the name of the table.
+	Table string
+	// Op is the operation running the statement, such as "one", "all",
+	// "count", "insert", "update" or "delete".
+	Op string
+	// Query is the SQL of the statement.
+	Query string
+	// Args are the arguments bound to the statement. They may reuse buffers,
+	// so must not be retained after the statement finishes.
+	Args []any
+}
+
+// StatementResult describes a finished statement, as given to a Tracer.
+type StatementResult struct {
+	// Rows is the number of rows returned, or changed by writes.
+	Rows     int
+	Duration time.Duration
+	Err      error
+}
+
+// Tracer is called before a statement runs, and returns the function to call
+// once it finishes. The context is the one given to the PoolTable method, or
+// context.Background when using a Table directly. An OpenTelemetry Tracer
+// starts a span with the Statement as attributes, and ends it with the
+// StatementResult.
+type Tracer func(ctx context.Context, s Statement) func(StatementResult)
+
+// Traced calls the Tracer for the statements run by the table.
+func Traced(tracer Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// connContexts holds the context of connections in use by PoolTables, so
+// tracers can be given the context of the operation.
+var connContexts sync.Map
+
+// withConnContext associates the context with the connection until the
+// returned function is called.
+func withConnContext(conn *sqlite.Conn, ctx context.Context) func() {
+	connContexts.Store(conn, ctx)
+	return func() { connContexts.Delete(conn) }
+}
+
+func connContext(conn *sqlite.Conn) context.Context {
+	if ctx, ok := connContexts.Load(conn); ok {
+		return ctx.(context.Context)
+	}
+	return context.Background()
+}
+
+// QueryError is returned when a statement run by a table fails, and wraps the
+// underlying error with the statement it came from.
+type QueryError struct {
+	// Table is the name of the table.
+	Table string
+	// Op is the operation running the statement, as in Statement.
+	Op string
+	// Query is the SQL of the statement.
+	Query string
+	// Args is the number of arguments bound to the statement.
+	Args int
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("sqjdb: %s on %q: %v", e.Op, e.Table, e.Err)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// span is a statement being run. Errors from it are wrapped in a QueryError,
+// and it is reported to the observers of the table, if any.
+type span struct {
+	table string
+	op    string
+	query string
+	args  int
+	ends  []func(StatementResult)
+	start time.Time
+	rows  int
+}
+
+// trace starts the span for a statement with the given arguments.
+func (t *Table[T]) trace(conn *sqlite.Conn, op, query string, sqls []SQL) *span {
+	sp := &span{table: t.Name, op: op, query: query}
+	for _, part := range sqls {
+		sp.args += len(part.Args)
+	}
+	o := &t.opts
+	if o.tracer == nil && o.metrics == nil && o.logger == nil && o.slowQuery == nil {
+		return sp
+	}
+	s := Statement{Table: t.Name, Op: op, Query: query}
+	for _, part := range sqls {
+		s.Args = append(s.Args, part.Args...)
+	}
+	var ends []func(StatementResult)
+	if o.tracer != nil {
+		ends = append(ends, o.tracer(connContext(conn), s))
+	}
+	if o.metrics != nil {
+		ends = append(ends, func(r StatementResult) { o.metrics.ObserveStatement(s, r) })
+	}
+	if o.logger != nil {
+		ends = append(ends, func(r StatementResult) { o.logStatement(connContext(conn), s, r) })
+	}
+	if o.slowQuery != nil {
+		ends = append(ends, func(r StatementResult) { o.observeSlow(conn, s, r) })
+	}
+	sp.ends = ends
+	sp.start = time.Now()
+	return sp
+}
+
+// addRows counts rows returned or changed by the statement.
+func (s *span) addRows(n int) {
+	s.rows += n
+}
+
+// finish ends the span with the error the statement finished with, and
+// returns the error wrapped by wrap.
+func (s *span) finish(err error) error {
+	if len(s.ends) > 0 {
+		r := StatementResult{Rows: s.rows, Duration: time.Since(s.start), Err: err}
+		for _, end := range s.ends {
+			if end != nil {
+				end(r)
+			}
+		}
+	}
+	return s.wrap(err)
+}
+
+// wrap returns the error in a QueryError, unless it is nil, ErrNoDoc, or
+// already a QueryError.
+func (s *span) wrap(err error) error {
+	var qerr *QueryError
+	if err == nil || errors.Is(err, ErrNoDoc) || errors.As(err, &qerr) {
+		return err
+	}
+	return &QueryError{Table: s.table, Op: s.op, Query: s.query, Args: s.args, Err: err}
+}
@@ -0,0 +1,81 @@
+package sqjdb_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+)
+
+type traceKey struct{}
+
+func TestTraced(t *testing.T) {
+	pjedis := newPool(t)
+	type span struct {
+		ctx    any
+		op     string
+		rows   int
+		failed bool
+	}
+	var spans []span
+	traced := sqjdb.NewTable[Jedi]("jedis", sqjdb.Traced(
+		func(ctx context.Context, s sqjdb.Statement) func(sqjdb.StatementResult) {
+			ensure.DeepEqual(t, s.Table, "jedis")
+			ensure.NotDeepEqual(t, s.Query, "")
+			return func(r sqjdb.StatementResult) {
+				ensure.True(t, r.Duration > 0)
+				spans = append(spans, span{ctx.Value(traceKey{}), s.Op, r.Rows, r.Err != nil})
+			}
+		}))
+	ptraced := traced.WithPool(pjedis.Pool)
+	ctx := context.WithValue(context.Background(), traceKey{}, "request")
+
+	_, err := ptraced.All(ctx, sqjdb.Where("Age").Eq(42).SQL())
+	ensure.Nil(t, err)
+	_, err = ptraced.Insert(ctx, &Jedi{Name: "grogu"})
+	ensure.Nil(t, err)
+	_, err = ptraced.One(ctx, sqjdb.ByID("missing"))
+	ensure.True(t, err != nil)
+	n, err := ptraced.Delete(ctx, sqjdb.Where("Age").Eq(42).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 2)
+	ensure.DeepEqual(t, spans, []span{
+		{"request", "all", 2, false},
+		{"request", "insert", 1, false},
+		{"request", "one", 0, true},
+		{"request", "delete", 2, false},
+	})
+
+	// Operations on a connection taken with Do are given its context.
+	spans = nil
+	err = ptraced.Do(ctx, func(conn *sqlite.Conn) error {
+		_, err := traced.Count(conn)
+		return err
+	})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, spans, []span{{"request", "count", 1, false}})
+}
+
+func TestQueryError(t *testing.T) {
+	conn := newConn(t)
+	_, err := jedis.All(conn, sqjdb.SQL{Query: "where nope(", Args: []any{1}})
+	var qerr *sqjdb.QueryError
+	ensure.True(t, errors.As(err, &qerr), err)
+	ensure.DeepEqual(t, qerr.Table, "jedis")
+	ensure.DeepEqual(t, qerr.Op, "all")
+	ensure.DeepEqual(t, qerr.Query, "select json(data) from jedis where nope(")
+	ensure.DeepEqual(t, qerr.Args, 1)
+	ensure.StringContains(t, err.Error(), `sqjdb: all on "jedis": `)
+
+	_, err = jedis.Insert(conn, &Jedi{ID: yoda.ID})
+	ensure.True(t, errors.As(err, &qerr), err)
+	ensure.DeepEqual(t, qerr.Op, "insert")
+	ensure.True(t, errors.Is(err, sqjdb.ErrUniqueViolation))
+
+	// Not finding a document is not a failure.
+	_, err = jedis.One(conn, sqjdb.ByID("missing"))
+	ensure.DeepEqual(t, err, sqjdb.ErrNoDoc)
+}
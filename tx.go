@@ -0,0 +1,39 @@
+package sqjdb
+
+import (
+	"fmt"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// WithTx calls fn within a transaction, which is committed if fn returns nil,
+// and rolled back if it returns an error or panics. Panics are propagated after
+// the rollback. If the connection is not already in a transaction, an immediate
+// transaction is started so concurrent writers wait for the lock up front
+// instead of failing part way through. Otherwise fn runs within a savepoint of
+// the outer transaction.
+func WithTx(conn *sqlite.Conn, fn func(conn *sqlite.Conn) error) (err error) {
+	defer settleNotifications(conn)
+	if !conn.AutocommitEnabled() {
+		defer sqlitex.Save(conn)(&err)
+		return fn(conn)
+	}
+	end, err := sqlitex.ImmediateTransaction(conn)
+	if err != nil {
+		return fmt.Errorf("sqjdb: beginning transaction: %w", err)
+	}
+	defer end(&err)
+	return fn(conn)
+}
+
+// Savepoint calls fn within a savepoint, which is released if fn returns nil,
+// and rolled back if it returns an error or panics. Unlike an error from
+// WithTx at the top level, rolling back a savepoint only undoes the changes
+// made by fn, so the caller can handle the error and continue with the outer
+// transaction. Savepoints can be nested.
+func Savepoint(conn *sqlite.Conn, fn func(conn *sqlite.Conn) error) (err error) {
+	defer settleNotifications(conn)
+	defer sqlitex.Save(conn)(&err)
+	return fn(conn)
+}
@@ -0,0 +1,83 @@
+package sqjdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite"
+)
+
+func TestWithTx(t *testing.T) {
+	conn := newConn(t)
+	err := sqjdb.WithTx(conn, func(conn *sqlite.Conn) error {
+		_, err := jedis.Insert(conn, &Jedi{Name: "grogu"})
+		return err
+	})
+	ensure.Nil(t, err)
+	n, err := jedis.Count(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 4)
+	ensure.True(t, conn.AutocommitEnabled())
+}
+
+func TestWithTxError(t *testing.T) {
+	conn := newConn(t)
+	errNope := errors.New("nope")
+	err := sqjdb.WithTx(conn, func(conn *sqlite.Conn) error {
+		_, err := jedis.Insert(conn, &Jedi{Name: "grogu"})
+		ensure.Nil(t, err)
+		return errNope
+	})
+	ensure.DeepEqual(t, err, errNope)
+	n, err := jedis.Count(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 3)
+}
+
+func TestWithTxPanic(t *testing.T) {
+	conn := newConn(t)
+	func() {
+		defer func() {
+			ensure.DeepEqual(t, recover(), "nope")
+		}()
+		sqjdb.WithTx(conn, func(conn *sqlite.Conn) error {
+			_, err := jedis.Delete(conn)
+			ensure.Nil(t, err)
+			panic("nope")
+		})
+	}()
+	n, err := jedis.Count(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 3)
+	ensure.True(t, conn.AutocommitEnabled())
+}
+
+func TestSavepoint(t *testing.T) {
+	conn := newConn(t)
+	errNope := errors.New("nope")
+	err := sqjdb.WithTx(conn, func(conn *sqlite.Conn) error {
+		for _, name := range []string{"grogu", "", "din"} {
+			err := sqjdb.Savepoint(conn, func(conn *sqlite.Conn) error {
+				if _, err := jedis.Insert(conn, &Jedi{Name: name}); err != nil {
+					return err
+				}
+				if name == "" {
+					return errNope
+				}
+				return nil
+			})
+			if name == "" {
+				ensure.DeepEqual(t, err, errNope)
+			} else {
+				ensure.Nil(t, err)
+			}
+		}
+		return nil
+	})
+	ensure.Nil(t, err)
+	n, err := jedis.Count(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 5)
+}
@@ -0,0 +1,75 @@
+package sqjdb
+
+import (
+	"fmt"
+	"strings"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// UniqueViolationError is returned when a write conflicts with a unique index.
+// It matches ErrUniqueViolation with errors.Is.
+type UniqueViolationError struct {
+	// Field is the document field that must be unique. It is set for the
+	// standard ID index and single field indexes declared with Unique or
+	// Indexes, and empty for other indexes.
+	Field string
+	// Index is the name of the unique index, if known.
+	Index string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *UniqueViolationError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("sqjdb: unique violation on index %q: %v", e.Index, e.Err)
+	}
+	return fmt.Sprintf("sqjdb: unique violation on field %q: %v", e.Field, e.Err)
+}
+
+func (e *UniqueViolationError) Unwrap() error {
+	return e.Err
+}
+
+func (e *UniqueViolationError) Is(target error) bool {
+	return target == ErrUniqueViolation
+}
+
+// Unique declares fields whose values must be unique across documents. Migrate
+// creates a unique index for each, and writes that violate them return a
+// UniqueViolationError.
+func Unique(fields ...string) Option {
+	return func(o *options) {
+		for _, field := range fields {
+			o.indexes = append(o.indexes, IndexSpec{Fields: []string{field}, Unique: true})
+		}
+	}
+}
+
+// uniqueViolation converts errors caused by unique indexes into a
+// UniqueViolationError, and returns other errors as is.
+func (t *Table[T]) uniqueViolation(err error) error {
+	if sqlite.ErrCode(err) != sqlite.ResultConstraintUnique {
+		return err
+	}
+	msg := err.Error()
+	start := strings.Index(msg, "index '")
+	if start < 0 {
+		return &UniqueViolationError{Err: err}
+	}
+	msg = msg[start+len("index '"):]
+	end := strings.IndexByte(msg, '\'')
+	if end < 0 {
+		return &UniqueViolationError{Err: err}
+	}
+	index := msg[:end]
+	if _, base := splitName(t.Name); index == base+"_ID" {
+		return &UniqueViolationError{Field: t.opts.idKey, Index: index, Err: err}
+	}
+	for _, spec := range t.opts.indexes {
+		if spec.Unique && len(spec.Fields) == 1 && t.indexName(spec) == index {
+			return &UniqueViolationError{Field: spec.Fields[0], Index: index, Err: err}
+		}
+	}
+	return &UniqueViolationError{Index: index, Err: err}
+}
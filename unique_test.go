@@ -0,0 +1,42 @@
+package sqjdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+type User struct {
+	ID    string `json:",omitempty"`
+	Email string `json:",omitempty"`
+	Name  string `json:",omitempty"`
+}
+
+func TestUnique(t *testing.T) {
+	conn := newConn(t)
+	users := sqjdb.NewTable[User](t.Name(), sqjdb.Unique("Email"))
+	ensure.Nil(t, users.Migrate(conn))
+	a, err := users.Insert(conn, &User{Email: "a@example.com"})
+	ensure.Nil(t, err)
+	b, err := users.Insert(conn, &User{Email: "b@example.com"})
+	ensure.Nil(t, err)
+
+	var uerr *sqjdb.UniqueViolationError
+	_, err = users.Insert(conn, &User{Email: a.Email})
+	ensure.True(t, errors.As(err, &uerr))
+	ensure.DeepEqual(t, uerr.Field, "Email")
+
+	_, err = users.Replace(conn, &User{ID: b.ID, Email: a.Email}, sqjdb.ByID(b.ID))
+	ensure.True(t, errors.As(err, &uerr))
+	ensure.DeepEqual(t, uerr.Field, "Email")
+
+	_, err = users.Insert(conn, &User{ID: a.ID, Email: "c@example.com"})
+	ensure.True(t, errors.As(err, &uerr))
+	ensure.DeepEqual(t, uerr.Field, "ID")
+
+	drift, err := users.VerifyIndexes(conn)
+	ensure.Nil(t, err)
+	ensure.True(t, drift.Empty())
+}
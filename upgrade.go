@@ -0,0 +1,131 @@
+package sqjdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// Upgrader upgrades a document from one schema version to the next, by
+// modifying the decoded JSON document in place. Numbers are decoded as
+// json.Number.
+type Upgrader func(doc map[string]any) error
+
+// SchemaVersion stores the schema version of documents in the named int field,
+// and upgrades older documents as they are read by One, All and Iter. The
+// upgrader at index i upgrades a document from version i+1 to i+2, so the
+// current version is one more than the number of upgraders, and documents
+// without a version are at version 1. Inserted documents without a version are
+// set to the current version. Upgrades happen in memory unless
+// WriteBackUpgrades is also used.
+func SchemaVersion(field string, upgraders ...Upgrader) Option {
+	return func(o *options) {
+		o.schemaVersion = field
+		o.upgraders = upgraders
+	}
+}
+
+// WriteBackUpgrades stores documents upgraded by One and All, so they are only
+// upgraded once. Documents changed since they were read are not overwritten.
+// Documents read by Iter are not written back.
+func WriteBackUpgrades() Option {
+	return func(o *options) {
+		o.writeBack = true
+	}
+}
+
+// upgradedDoc is a document upgraded at read time, pending write back.
+type upgradedDoc struct {
+	id    any
+	from  int64
+	jsonS []byte
+}
+
+func (t *Table[T]) currentSchemaVersion() int64 {
+	return int64(len(t.opts.upgraders)) + 1
+}
+
+// withSchemaVersion returns the document as is if it has a schema version, or
+// a shallow clone with the current version set.
+func (t *Table[T]) withSchemaVersion(doc *T) (*T, error) {
+	v := docField(doc, t.opts.schemaVersion)
+	if !v.IsValid() || !v.CanInt() {
+		return nil, fmt.Errorf("sqjdb: expected type %T to contain a %s field of type int",
+			doc, t.opts.schemaVersion)
+	}
+	if v.Int() != 0 {
+		return doc, nil
+	}
+	docCopy := *doc
+	doc = &docCopy
+	docField(doc, t.opts.schemaVersion).SetInt(t.currentSchemaVersion())
+	return doc, nil
+}
+
+// upgrade runs the upgraders needed to bring the document to the current
+// schema version. It returns the upgraded document and the version it was
+// upgraded from, or nil if it is already current.
+func (t *Table[T]) upgrade(jsonS []byte) ([]byte, int64, error) {
+	dec := json.NewDecoder(bytes.NewReader(jsonS))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, 0, fmt.Errorf("sqjdb: invalid json from db: %w\n%s", err, jsonS)
+	}
+	// The document is keyed by JSON names, not Go field names.
+	key := t.FieldName(t.opts.schemaVersion)
+	from := int64(1)
+	if n, ok := doc[key].(json.Number); ok {
+		var err error
+		if from, err = n.Int64(); err != nil {
+			return nil, 0, fmt.Errorf("sqjdb: invalid %s from db: %w", t.opts.schemaVersion, err)
+		}
+	}
+	from = max(from, 1)
+	if from >= t.currentSchemaVersion() {
+		return nil, 0, nil
+	}
+	for version := from; version < t.currentSchemaVersion(); version++ {
+		if err := t.opts.upgraders[version-1](doc); err != nil {
+			return nil, 0, fmt.Errorf("sqjdb: upgrading document from version %d: %w", version, err)
+		}
+	}
+	doc[key] = t.currentSchemaVersion()
+	upgraded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, 0, fmt.Errorf("sqjdb: failed to json.Marshal: %w", err)
+	}
+	return upgraded, from, nil
+}
+
+// writeBack stores the upgraded documents, unless they have changed since
+// they were read.
+func (t *Table[T]) writeBack(conn *sqlite.Conn, docs []upgradedDoc) error {
+	version := fieldExpr(t.FieldName(t.opts.schemaVersion))
+	for _, doc := range docs {
+		sqls := []SQL{
+			t.assignData(SQL{Query: "jsonb(" + jsonParam + ")", Args: []any{doc.jsonS}}),
+			{
+				Query: "where " + fieldExpr(t.opts.idKey) + " = ? and coalesce(" + version + ", 1) = ?",
+				Args:  []any{doc.id, doc.from},
+			},
+		}
+		var query strings.Builder
+		query.WriteString("update ")
+		query.WriteString(t.Name)
+		addSQLQuery(&query, sqls)
+		stmt, err := prepareSQL(conn, query.String(), sqls)
+		if err != nil {
+			return err
+		}
+		_, err = stmt.Step()
+		releaseStmt(stmt)
+		if err != nil {
+			return fmt.Errorf("sqjdb: writing back upgraded document in %q: %w", t.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package sqjdb_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+type ContactV1 struct {
+	ID   string `json:",omitempty"`
+	Name string `json:",omitempty"`
+}
+
+type Contact struct {
+	ID            string `json:",omitempty"`
+	First         string `json:",omitempty"`
+	Last          string `json:",omitempty"`
+	SchemaVersion int    `json:",omitempty"`
+}
+
+var splitName sqjdb.Upgrader = func(doc map[string]any) error {
+	name, _ := doc["Name"].(string)
+	first, last, _ := strings.Cut(name, " ")
+	doc["First"], doc["Last"] = first, last
+	delete(doc, "Name")
+	return nil
+}
+
+func TestSchemaVersion(t *testing.T) {
+	conn := newConn(t)
+	v1 := sqjdb.NewTable[ContactV1](t.Name())
+	ensure.Nil(t, v1.Migrate(conn))
+	old, err := v1.Insert(conn, &ContactV1{Name: "Luke Skywalker"})
+	ensure.Nil(t, err)
+
+	contacts := sqjdb.NewTable[Contact](t.Name(), sqjdb.SchemaVersion("SchemaVersion", splitName))
+	doc, err := contacts.Get(conn, old.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, doc, &Contact{ID: old.ID, First: "Luke", Last: "Skywalker", SchemaVersion: 2})
+	// Without write back the stored document is unchanged.
+	stored, err := v1.Get(conn, old.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, stored.Name, "Luke Skywalker")
+
+	created, err := contacts.Insert(conn, &Contact{First: "Leia"})
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, created.SchemaVersion, 2)
+	docs, err := contacts.All(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 2)
+}
+
+func TestWriteBackUpgrades(t *testing.T) {
+	conn := newConn(t)
+	v1 := sqjdb.NewTable[ContactV1](t.Name())
+	ensure.Nil(t, v1.Migrate(conn))
+	old, err := v1.Insert(conn, &ContactV1{Name: "Luke Skywalker"})
+	ensure.Nil(t, err)
+
+	contacts := sqjdb.NewTable[Contact](t.Name(),
+		sqjdb.SchemaVersion("SchemaVersion", splitName), sqjdb.WriteBackUpgrades())
+	docs, err := contacts.All(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docs[0].First, "Luke")
+	stored, err := v1.Get(conn, old.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, stored.Name, "")
+	n, err := contacts.Count(conn, sqjdb.Where("SchemaVersion").Eq(2).SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+}
+
+type TaggedContact struct {
+	ID            string `json:"id,omitempty"`
+	First         string `json:"first,omitempty"`
+	Last          string `json:"last,omitempty"`
+	SchemaVersion int    `json:"v,omitempty"`
+}
+
+func TestSchemaVersionJSONTag(t *testing.T) {
+	conn := newConn(t)
+	raw := sqjdb.NewTable[map[string]any](t.Name(), sqjdb.IDField("id"))
+	ensure.Nil(t, raw.Migrate(conn))
+	calls := 0
+	upgrader := func(doc map[string]any) error {
+		calls++
+		name, _ := doc["Name"].(string)
+		doc["first"], _, _ = strings.Cut(name, " ")
+		delete(doc, "Name")
+		return nil
+	}
+	contacts := sqjdb.NewTable[TaggedContact](t.Name(),
+		sqjdb.SchemaVersion("SchemaVersion", upgrader), sqjdb.WriteBackUpgrades())
+
+	created, err := contacts.Insert(conn, &TaggedContact{First: "Leia"})
+	ensure.Nil(t, err)
+	_, err = contacts.Get(conn, created.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, calls, 0)
+
+	_, err = raw.InsertRaw(conn, []byte(`{"id":"luke","Name":"Luke Skywalker"}`))
+	ensure.Nil(t, err)
+	for range 3 {
+		doc, err := contacts.Get(conn, "luke")
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, doc.First, "Luke")
+		ensure.DeepEqual(t, doc.SchemaVersion, 2)
+	}
+	// The upgraded document was written back, so it is only upgraded once.
+	ensure.DeepEqual(t, calls, 1)
+}
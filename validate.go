@@ -0,0 +1,68 @@
+package sqjdb
+
+import (
+	"fmt"
+)
+
+// Validator is implemented by documents that validate themselves. Validate is
+// called before inserting or replacing a document.
+type Validator interface {
+	Validate() error
+}
+
+// InsertValidator is implemented by documents with validation specific to
+// inserts. ValidateInsert is called before Validate when inserting a document.
+type InsertValidator interface {
+	ValidateInsert() error
+}
+
+// UpdateValidator is implemented by documents with validation specific to
+// updates. ValidateUpdate is called before Validate when replacing a document,
+// and on its own for patches, since they usually contain only some fields.
+type UpdateValidator interface {
+	ValidateUpdate() error
+}
+
+// ValidationError is returned when a document fails validation.
+type ValidationError struct {
+	// Table is the name of the table.
+	Table string
+	// ID is the ID of the document, if it has one.
+	ID any
+	// Err is the error returned by the document.
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("sqjdb: invalid document %v in %q: %v", e.ID, e.Table, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// validate runs the validators implemented by the document for the operation.
+func (t *Table[T]) validate(doc *T, op HookOp) error {
+	var err error
+	switch op {
+	case HookInsert:
+		if v, ok := any(doc).(InsertValidator); ok {
+			err = v.ValidateInsert()
+		}
+	case HookPatch, HookReplace:
+		if v, ok := any(doc).(UpdateValidator); ok {
+			err = v.ValidateUpdate()
+		}
+	}
+	if v, ok := any(doc).(Validator); ok && err == nil && op != HookPatch {
+		err = v.Validate()
+	}
+	if err == nil {
+		return nil
+	}
+	var id any
+	if v := docField(doc, t.opts.idField); v.IsValid() && !v.IsZero() {
+		id = v.Interface()
+	}
+	return &ValidationError{Table: t.Name, ID: id, Err: err}
+}
@@ -0,0 +1,55 @@
+package sqjdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+var errNoEmail = errors.New("email required")
+
+type Member struct {
+	ID    string `json:",omitempty"`
+	Email string `json:",omitempty"`
+	Admin bool   `json:",omitempty"`
+}
+
+func (m *Member) Validate() error {
+	if m.Email == "" {
+		return errNoEmail
+	}
+	return nil
+}
+
+func (m *Member) ValidateUpdate() error {
+	if m.Admin {
+		return errors.New("admin can not be granted")
+	}
+	return nil
+}
+
+func TestValidator(t *testing.T) {
+	conn := newConn(t)
+	members := sqjdb.NewTable[Member](t.Name())
+	ensure.Nil(t, members.Migrate(conn))
+
+	_, err := members.Insert(conn, &Member{})
+	var ve *sqjdb.ValidationError
+	ensure.True(t, errors.As(err, &ve))
+	ensure.DeepEqual(t, ve.Table, t.Name())
+	ensure.True(t, errors.Is(err, errNoEmail))
+
+	doc, err := members.Insert(conn, &Member{Email: "a@b.c"})
+	ensure.Nil(t, err)
+	// Patches skip Validate since they only contain some fields.
+	n, err := members.Patch(conn, &Member{}, members.ByID(doc.ID))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+	_, err = members.Patch(conn, &Member{Admin: true}, members.ByID(doc.ID))
+	ensure.True(t, errors.As(err, &ve))
+	_, err = members.Replace(conn, &Member{ID: doc.ID}, members.ByID(doc.ID))
+	ensure.True(t, errors.Is(err, errNoEmail))
+	ensure.DeepEqual(t, err.(*sqjdb.ValidationError).ID, doc.ID)
+}
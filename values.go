@@ -0,0 +1,86 @@
+package sqjdb
+
+import (
+	"slices"
+	"strings"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// PresizeResults makes All and AllValues count the matching documents before
+// reading them, so the result slice is allocated once at the right size. The
+// count is an extra query, which is cheaper than growing the slice for large
+// result sets, but not for small ones.
+func PresizeResults() Option {
+	return func(o *options) {
+		o.presize = true
+	}
+}
+
+// presize returns the number of documents the query will return, if the table
+// uses PresizeResults, or 0 otherwise. The first part of the query is the
+// source of documents.
+func (t *Table[T]) presize(conn *sqlite.Conn, sqls []SQL) (_ int, err error) {
+	if !t.opts.presize {
+		return 0, nil
+	}
+	var query strings.Builder
+	query.WriteString("select count(*) from (select 1 from")
+	addSQLQuery(&query, sqls)
+	query.WriteString(")")
+	sp := t.trace(conn, "count", query.String(), sqls)
+	defer func() { err = sp.finish(err) }()
+	stmt, err := prepareSQL(conn, query.String(), sqls)
+	if err != nil {
+		return 0, err
+	}
+	defer releaseStmt(stmt)
+	if _, err := stmt.Step(); err != nil {
+		return 0, err
+	}
+	sp.addRows(1)
+	return stmt.ColumnInt(0), nil
+}
+
+// AllValues is like All, but returns the documents as values rather than
+// pointers, which avoids allocating each document separately.
+func (t *Table[T]) AllValues(conn *sqlite.Conn, sqls ...SQL) (docs []T, err error) {
+	sqls = slices.Concat([]SQL{t.from()}, sqls)
+	n, err := t.presize(conn, sqls)
+	if err != nil {
+		return nil, err
+	}
+	var query strings.Builder
+	query.WriteString("select json(data) from")
+	addSQLQuery(&query, sqls)
+	sp := t.trace(conn, "all", query.String(), sqls)
+	defer func() {
+		sp.addRows(len(docs))
+		err = sp.finish(err)
+	}()
+	stmt, err := prepareSQL(conn, query.String(), sqls)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseStmt(stmt)
+	// Each document is decoded in place, in a slot appended before knowing if
+	// there is another row, so an extra slot is needed.
+	docs = make([]T, 0, n+1)
+	var buf []byte
+	var pending []upgradedDoc
+	for {
+		docs = append(docs, *new(T))
+		rowReturned, err := t.stepInto(stmt, &buf, &pending, &docs[len(docs)-1])
+		if err != nil {
+			return nil, err
+		}
+		if !rowReturned {
+			docs = docs[:len(docs)-1]
+			break
+		}
+	}
+	if err := t.writeBack(conn, pending); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
@@ -0,0 +1,30 @@
+package sqjdb_test
+
+import (
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestAllValues(t *testing.T) {
+	conn := newConn(t)
+	docs, err := jedis.AllValues(conn, sqjdb.OrderBy("Name", sqjdb.Asc))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docs, []Jedi{leia, luke, yoda})
+	docs, err = jedis.AllValues(conn, sqjdb.Where("Name").Eq("nobody").SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 0)
+}
+
+func TestPresizeResults(t *testing.T) {
+	conn := newConn(t)
+	presized := sqjdb.NewTable[Jedi]("jedis", sqjdb.PresizeResults())
+	docs, err := presized.All(conn, sqjdb.OrderBy("Name", sqjdb.Asc), sqjdb.Limit(2))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docs, []*Jedi{&leia, &luke})
+	ensure.DeepEqual(t, cap(docs), 2)
+	values, err := presized.AllValues(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(values), 3)
+}
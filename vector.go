@@ -0,0 +1,46 @@
+package sqjdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// Embedding declares the named field as containing a vector embedding, stored
+// as a JSON array of numbers, for use with Nearest.
+func Embedding(field string) Option {
+	return func(o *options) {
+		o.embedding = field
+	}
+}
+
+// Nearest returns up to k documents with the embedding closest to the given
+// vector by cosine distance, closest first, per the given query. Documents
+// without an embedding, or with one of a different length, are ignored. The
+// distance is computed for every candidate document, so use the query to
+// narrow down large tables. The table must use Embedding, and the connection
+// must have RegisterFunctions applied.
+func (t *Table[T]) Nearest(conn *sqlite.Conn, vector []float64, k int, sqls ...SQL) ([]*T, error) {
+	if t.opts.embedding == "" {
+		return nil, fmt.Errorf("sqjdb: table %q does not use Embedding", t.Name)
+	}
+	jsonS, err := json.Marshal(vector)
+	if err != nil {
+		return nil, fmt.Errorf("sqjdb: failed to json.Marshal: %w", err)
+	}
+	sel := t.scopedSelect()
+	_, base := splitName(t.Name)
+	sqls = slices.Concat(
+		[]SQL{{
+			Query: "(select * from (select *, sqjdb_vec_distance(" +
+				fieldJSONExpr(t.FieldName(t.opts.embedding)) + ", ?) as sqjdb_distance from (" +
+				sel.Query + ")) where sqjdb_distance is not null) as " + base,
+			Args: slices.Concat([]any{string(jsonS)}, sel.Args),
+		}},
+		sqls,
+		[]SQL{{Query: "order by sqjdb_distance"}, Limit(k)},
+	)
+	return t.all(conn, sqls)
+}
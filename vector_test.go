@@ -0,0 +1,52 @@
+package sqjdb_test
+
+import (
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+type Passage struct {
+	ID        string    `json:",omitempty"`
+	Text      string    `json:",omitempty"`
+	Embedding []float64 `json:",omitempty"`
+}
+
+func TestNearest(t *testing.T) {
+	conn := newConn(t)
+	ensure.Nil(t, sqjdb.RegisterFunctions(conn))
+	passages := sqjdb.NewTable[Passage](t.Name(), sqjdb.Embedding("Embedding"))
+	ensure.Nil(t, passages.Migrate(conn))
+	_, err := passages.InsertMany(conn, []*Passage{
+		{Text: "east", Embedding: []float64{1, 0}},
+		{Text: "north", Embedding: []float64{0, 1}},
+		{Text: "north east", Embedding: []float64{1, 1}},
+		{Text: "none"},
+	})
+	ensure.Nil(t, err)
+
+	docs, err := passages.Nearest(conn, []float64{0.9, 0.1}, 2)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 2)
+	ensure.DeepEqual(t, docs[0].Text, "east")
+	ensure.DeepEqual(t, docs[1].Text, "north east")
+
+	docs, err = passages.Nearest(conn, []float64{0.9, 0.1}, 5,
+		sqjdb.Where("Text").Ne("east").SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 2)
+	ensure.DeepEqual(t, docs[0].Text, "north east")
+}
+
+func TestNearestMismatchedLength(t *testing.T) {
+	conn := newConn(t)
+	ensure.Nil(t, sqjdb.RegisterFunctions(conn))
+	passages := sqjdb.NewTable[Passage](t.Name(), sqjdb.Embedding("Embedding"))
+	ensure.Nil(t, passages.Migrate(conn))
+	_, err := passages.Insert(conn, &Passage{Embedding: []float64{1, 0}})
+	ensure.Nil(t, err)
+	docs, err := passages.Nearest(conn, []float64{1, 0, 0}, 1)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 0)
+}
@@ -0,0 +1,34 @@
+package sqjdb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrReadOnly is returned when writing to a table created with NewView.
+var ErrReadOnly = errors.New("sqjdb: read only table")
+
+// NewView creates a read only Table whose documents are selected by the query,
+// which must return them in a column named data, such as
+// "select data from jedis where data->>'Age' > 100", or a JSON object built
+// from other tables. The name is used to refer to the documents in queries. If
+// the query is empty, the name must instead be an SQL view returning a data
+// column. Writes, including Migrate, return ErrReadOnly. Scopes apply as they
+// do to tables, but options that need a table, such as indexes, have no
+// effect.
+func NewView[T any](name string, query SQL, opts ...Option) Table[T] {
+	t := NewTable[T](name, opts...)
+	if query.Query == "" {
+		query = SQL{Query: "select * from " + name}
+	}
+	t.opts.view = &query
+	return t
+}
+
+// writable returns ErrReadOnly for tables created with NewView.
+func (t *Table[T]) writable() error {
+	if t.opts.view != nil {
+		return fmt.Errorf("%w %q", ErrReadOnly, t.Name)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package sqjdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+type JediLabel struct {
+	ID    string
+	Name  string
+	Upper string
+}
+
+func TestView(t *testing.T) {
+	conn := newConn(t)
+	elders := sqjdb.NewView[Jedi]("elders", sqjdb.SQL{
+		Query: "select data from jedis where data->>'Age' > ?",
+		Args:  []any{100},
+	})
+	docs, err := elders.All(conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, docs, []*Jedi{&yoda})
+	n, err := elders.Count(conn, sqjdb.Where("Name").Eq("luke").SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 0)
+	for doc, err := range elders.Iter(conn) {
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, doc, &yoda)
+	}
+
+	ensure.True(t, errors.Is(elders.Migrate(conn), sqjdb.ErrReadOnly))
+	_, err = elders.Insert(conn, &Jedi{Name: "grogu"})
+	ensure.True(t, errors.Is(err, sqjdb.ErrReadOnly))
+	_, err = elders.InsertMany(conn, []*Jedi{{Name: "grogu"}})
+	ensure.True(t, errors.Is(err, sqjdb.ErrReadOnly))
+	_, err = elders.Patch(conn, &Jedi{Name: "grogu"})
+	ensure.True(t, errors.Is(err, sqjdb.ErrReadOnly))
+	_, err = elders.Delete(conn)
+	ensure.StringContains(t, err.Error(), `read only table "elders"`)
+}
+
+func TestViewSQL(t *testing.T) {
+	conn := newConn(t)
+	ensure.Nil(t, sqlitex.ExecuteTransient(conn,
+		"create view jedi_names as select json_object('ID', data->>'ID', 'Name', data->>'Name',"+
+			" 'Upper', upper(data->>'Name')) as data from jedis", nil))
+	names := sqjdb.NewView[JediLabel]("jedi_names", sqjdb.SQL{})
+	doc, err := names.One(conn, sqjdb.Where("Upper").Eq("LEIA").SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, doc, &JediLabel{ID: leia.ID, Name: "leia", Upper: "LEIA"})
+	docs, err := names.All(conn, names.OrderBy("Name", sqjdb.Desc))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(docs), 3)
+	ensure.DeepEqual(t, docs[0].Name, "yoda")
+}
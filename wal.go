@@ -0,0 +1,65 @@
+package sqjdb
+
+import (
+	"fmt"
+	"strconv"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// CheckpointMode controls how much work Checkpoint does, and whether it waits
+// for readers and writers.
+type CheckpointMode string
+
+const (
+	// CheckpointPassive copies as many frames as possible without waiting.
+	CheckpointPassive CheckpointMode = "passive"
+	// CheckpointFull waits for writers, and then copies all frames.
+	CheckpointFull CheckpointMode = "full"
+	// CheckpointRestart is like CheckpointFull, and also waits for readers so
+	// the next writer starts at the beginning of the WAL.
+	CheckpointRestart CheckpointMode = "restart"
+	// CheckpointTruncate is like CheckpointRestart, and also truncates the WAL
+	// file to zero bytes.
+	CheckpointTruncate CheckpointMode = "truncate"
+)
+
+// CheckpointResult is the outcome of Checkpoint.
+type CheckpointResult struct {
+	// Busy is true if the checkpoint could not complete because of other
+	// connections.
+	Busy bool
+	// Log is the number of frames in the WAL.
+	Log int
+	// Checkpointed is the number of frames copied into the database.
+	Checkpointed int
+}
+
+// Checkpoint copies the frames from the WAL into the database. SQLite does so
+// automatically as the WAL grows, but a long running reader can stop the WAL
+// from being reset, so services may want to run CheckpointTruncate when idle,
+// or on shutdown, to keep the file small.
+func Checkpoint(conn *sqlite.Conn, mode CheckpointMode) (CheckpointResult, error) {
+	var r CheckpointResult
+	err := sqlitex.ExecuteTransient(conn, "pragma wal_checkpoint("+string(mode)+")",
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				r.Busy = stmt.ColumnBool(0)
+				r.Log = stmt.ColumnInt(1)
+				r.Checkpointed = stmt.ColumnInt(2)
+				return nil
+			},
+		})
+	if err != nil {
+		return r, fmt.Errorf("sqjdb: checkpoint %s: %w", mode, err)
+	}
+	return r, nil
+}
+
+// AutoCheckpoint sets the number of WAL pages after which a commit triggers a
+// passive checkpoint. The SQLite default is 1000, and 0 disables automatic
+// checkpoints, leaving them to Checkpoint.
+func AutoCheckpoint(pages int) OpenOption {
+	return Pragma("wal_autocheckpoint", strconv.Itoa(pages))
+}
@@ -0,0 +1,39 @@
+package sqjdb_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	conn, err := sqjdb.Open(path, sqjdb.AutoCheckpoint(0))
+	ensure.Nil(t, err)
+	defer conn.Close()
+	ensure.DeepEqual(t, pragma(t, conn, "wal_autocheckpoint"), "0")
+	ensure.Nil(t, jedis.Migrate(conn))
+	for range 10 {
+		_, err := jedis.Insert(conn, &Jedi{Name: "grogu"})
+		ensure.Nil(t, err)
+	}
+
+	r, err := sqjdb.Checkpoint(conn, sqjdb.CheckpointPassive)
+	ensure.Nil(t, err)
+	ensure.False(t, r.Busy)
+	ensure.True(t, r.Log > 0)
+	ensure.DeepEqual(t, r.Checkpointed, r.Log)
+	info, err := os.Stat(path + "-wal")
+	ensure.Nil(t, err)
+	ensure.True(t, info.Size() > 0)
+
+	r, err = sqjdb.Checkpoint(conn, sqjdb.CheckpointTruncate)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, r, sqjdb.CheckpointResult{})
+	info, err = os.Stat(path + "-wal")
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, info.Size(), int64(0))
+}
@@ -0,0 +1,199 @@
+package sqjdb
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+	"time"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// WatchPollInterval is how often Watch checks for new changes.
+var WatchPollInterval = 100 * time.Millisecond
+
+// TrackChanges records every insert, update and delete in a changelog table
+// named with a "_changes" suffix, which is what Watch reads. The table and the
+// triggers that maintain it are created by Migrate. Use TrimChanges to remove
+// old entries.
+func TrackChanges() Option {
+	return func(o *options) {
+		o.changes = true
+	}
+}
+
+// Change is an insert, update or delete of a document.
+type Change[T any] struct {
+	// Seq increases with every change, and can be used to resume watching.
+	Seq int64
+	// Op is the operation, "insert", "update" or "delete".
+	Op string
+	// Doc is the document after the operation, or before it for deletes.
+	Doc *T
+}
+
+func (t *Table[T]) changesName() string {
+	return t.Name + "_changes"
+}
+
+func (t *Table[T]) migrateChanges(conn *sqlite.Conn) error {
+	c := t.changesName()
+	qCreate := "create table if not exists " + c +
+		" (seq integer primary key autoincrement, op text not null, data blob)"
+	if err := sqlitex.ExecuteTransient(conn, qCreate, nil); err != nil {
+		return fmt.Errorf("sqjdb: creating table %q: %w", c, err)
+	}
+	// Triggers can only refer to unqualified tables in their own schema.
+	_, base := splitName(t.Name)
+	_, cBase := splitName(c)
+	for op, row := range map[string]string{"insert": "new", "update": "new", "delete": "old"} {
+		qTrigger := "create trigger if not exists " + c + "_" + op +
+			" after " + op + " on " + base + " begin" +
+			" insert into " + cBase + " (op, data) values ('" + op + "', " + row + ".data);" +
+			" end"
+		if err := sqlitex.ExecuteTransient(conn, qTrigger, nil); err != nil {
+			return fmt.Errorf("sqjdb: creating %s change trigger on %q: %w", op, t.Name, err)
+		}
+	}
+	return nil
+}
+
+// LastChange returns the sequence number of the most recent change, or 0 if
+// there are none. The table must use TrackChanges.
+func (t *Table[T]) LastChange(conn *sqlite.Conn) (int64, error) {
+	if !t.opts.changes {
+		return 0, fmt.Errorf("sqjdb: table %q does not use TrackChanges", t.Name)
+	}
+	query := "select coalesce(max(seq), 0) from " + t.changesName()
+	stmt, err := prepareSQL(conn, query, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer releaseStmt(stmt)
+	if _, err := stmt.Step(); err != nil {
+		return 0, err
+	}
+	return stmt.ColumnInt64(0), nil
+}
+
+// ChangesSince returns the changes after the given sequence number, oldest
+// first, per the given query. The query applies to the changed documents. The
+// table must use TrackChanges.
+func (t *Table[T]) ChangesSince(conn *sqlite.Conn, seq int64, sqls ...SQL) (_ []Change[T], err error) {
+	if !t.opts.changes {
+		return nil, fmt.Errorf("sqjdb: table %q does not use TrackChanges", t.Name)
+	}
+	c := t.changesName()
+	var query strings.Builder
+	query.WriteString("select seq, op, json(data) from")
+	scope := SQL{Query: c + " where seq > ?", Args: []any{seq}}
+	if t.opts.tenant != "" {
+		cond := Where(t.FieldName(t.opts.tenant)).Eq(t.opts.tenantID)
+		scope.Query += " and " + cond.Expr
+		scope.Args = append(scope.Args, cond.Args...)
+	}
+	sqls = slices.Concat(
+		[]SQL{scope, {Query: "and seq in (select seq from " + c}},
+		sqls,
+		[]SQL{{Query: ") order by seq"}},
+	)
+	addSQLQuery(&query, sqls)
+	sp := t.trace(conn, "changes", query.String(), sqls)
+	defer func() { err = sp.finish(err) }()
+	stmt, err := prepareSQL(conn, query.String(), sqls)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseStmt(stmt)
+	var changes []Change[T]
+	var buf []byte
+	for {
+		rowReturned, err := stmt.Step()
+		if err != nil {
+			return nil, err
+		}
+		if !rowReturned {
+			break
+		}
+		sp.addRows(1)
+		jsonS := columnBytes(stmt, 2, &buf)
+		doc := new(T)
+		// Old documents are upgraded, but never written back.
+		if _, err := t.decode(jsonS, doc); err != nil {
+			return nil, err
+		}
+		changes = append(changes, Change[T]{
+			Seq: stmt.ColumnInt64(0),
+			Op:  stmt.ColumnText(1),
+			Doc: doc,
+		})
+	}
+	return changes, nil
+}
+
+// TrimChanges removes changes up to and including the given sequence number.
+// The table must use TrackChanges.
+func (t *Table[T]) TrimChanges(conn *sqlite.Conn, seq int64) error {
+	if !t.opts.changes {
+		return fmt.Errorf("sqjdb: table %q does not use TrackChanges", t.Name)
+	}
+	query := "delete from " + t.changesName() + " where seq <= ?"
+	if err := sqlitex.Execute(conn, query, &sqlitex.ExecOptions{Args: []any{seq}}); err != nil {
+		return fmt.Errorf("sqjdb: failed to trim changes: %w", err)
+	}
+	return nil
+}
+
+// watch polls for changes, using do to run each poll with a connection.
+func (t *Table[T]) watch(ctx context.Context, do func(func(*sqlite.Conn) error) error, sqls []SQL) iter.Seq2[Change[T], error] {
+	return func(yield func(Change[T], error) bool) {
+		var seq int64
+		err := do(func(conn *sqlite.Conn) (err error) {
+			seq, err = t.LastChange(conn)
+			return err
+		})
+		if err != nil {
+			yield(Change[T]{}, err)
+			return
+		}
+		timer := time.NewTimer(WatchPollInterval)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				yield(Change[T]{}, ctx.Err())
+				return
+			case <-timer.C:
+			}
+			var changes []Change[T]
+			err := do(func(conn *sqlite.Conn) (err error) {
+				changes, err = t.ChangesSince(conn, seq, sqls...)
+				return err
+			})
+			if err != nil {
+				yield(Change[T]{}, err)
+				return
+			}
+			for _, change := range changes {
+				seq = change.Seq
+				if !yield(change, nil) {
+					return
+				}
+			}
+			timer.Reset(WatchPollInterval)
+		}
+	}
+}
+
+// Watch returns an iterator over changes made after it starts, per the given
+// query, by polling the changelog. It runs until the context is done, at which
+// point the context error is yielded. The connection is only used while
+// polling and may be used in the loop body. The table must use TrackChanges.
+func (t *Table[T]) Watch(ctx context.Context, conn *sqlite.Conn, sqls ...SQL) iter.Seq2[Change[T], error] {
+	return t.watch(ctx, func(fn func(*sqlite.Conn) error) error {
+		return fn(conn)
+	}, sqls)
+}
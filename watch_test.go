@@ -0,0 +1,78 @@
+package sqjdb_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestChangesSince(t *testing.T) {
+	conn := newConn(t)
+	watched := sqjdb.NewTable[Jedi](t.Name(), sqjdb.TrackChanges())
+	ensure.Nil(t, watched.Migrate(conn))
+	doc, err := watched.Insert(conn, &Jedi{Name: "anakin", Age: 9})
+	ensure.Nil(t, err)
+	_, err = watched.Patch(conn, &Jedi{Name: "vader"}, sqjdb.ByID(doc.ID))
+	ensure.Nil(t, err)
+	_, err = watched.Delete(conn, sqjdb.ByID(doc.ID))
+	ensure.Nil(t, err)
+
+	changes, err := watched.ChangesSince(conn, 0)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(changes), 3)
+	ensure.DeepEqual(t, changes[0].Op, "insert")
+	ensure.DeepEqual(t, changes[0].Doc, doc)
+	ensure.DeepEqual(t, changes[1].Op, "update")
+	ensure.DeepEqual(t, changes[1].Doc.Name, "vader")
+	ensure.DeepEqual(t, changes[2].Op, "delete")
+
+	changes, err = watched.ChangesSince(conn, changes[0].Seq, sqjdb.Where("Name").Eq("vader").SQL())
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(changes), 2)
+
+	last, err := watched.LastChange(conn)
+	ensure.Nil(t, err)
+	ensure.Nil(t, watched.TrimChanges(conn, last))
+	changes, err = watched.ChangesSince(conn, 0)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(changes), 0)
+}
+
+func TestPoolWatch(t *testing.T) {
+	pjedis := newPool(t)
+	table := sqjdb.NewTable[Jedi](t.Name(), sqjdb.TrackChanges())
+	watched := table.WithPool(pjedis.Pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ensure.Nil(t, watched.Migrate(ctx))
+	_, err := watched.Insert(ctx, &Jedi{Name: "before"})
+	ensure.Nil(t, err)
+
+	go func() {
+		time.Sleep(sqjdb.WatchPollInterval)
+		watched.Insert(ctx, &Jedi{Name: "ignored", Age: 1})
+		watched.Insert(ctx, &Jedi{Name: "after", Age: 2})
+	}()
+	for change, err := range watched.Watch(ctx, sqjdb.Where("Age").Eq(2).SQL()) {
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, change.Op, "insert")
+		ensure.DeepEqual(t, change.Doc.Name, "after")
+		break
+	}
+}
+
+func TestChangesSinceDecodeOptions(t *testing.T) {
+	conn := newConn(t)
+	watched := sqjdb.NewTable[Jedi](t.Name(), sqjdb.TrackChanges())
+	ensure.Nil(t, watched.Migrate(conn))
+	_, err := watched.Insert(conn, &Jedi{Name: "anakin", Age: 9})
+	ensure.Nil(t, err)
+
+	strict := sqjdb.NewTable[JediName](t.Name(), sqjdb.TrackChanges(), sqjdb.DisallowUnknownFields())
+	_, err = strict.ChangesSince(conn, 0)
+	ensure.NotNil(t, err)
+	ensure.StringContains(t, err.Error(), `unknown field "Age"`)
+}
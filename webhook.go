@@ -0,0 +1,178 @@
+package sqjdb
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// WebhookSignatureHeader is the header with the hex encoded HMAC-SHA256 of the
+// request body, keyed by the webhook secret, prefixed with "sha256=".
+const WebhookSignatureHeader = "Sqjdb-Signature"
+
+// Webhook is a URL receiving the changes to a table, along with its delivery
+// state.
+type Webhook struct {
+	ID     string `json:",omitempty"`
+	Table  string
+	URL    string
+	Secret string `json:",omitempty"`
+	// Seq is the sequence number of the last change delivered.
+	Seq int64
+	// Attempts is the number of consecutive failed deliveries.
+	Attempts int
+	// Due is the unix time in milliseconds before which delivery is not
+	// retried after a failure.
+	Due       int64
+	LastError string `json:",omitempty"`
+}
+
+// WebhookPayload is the JSON body posted to webhooks.
+type WebhookPayload struct {
+	Webhook string
+	Changes []ChangeRecord
+}
+
+// Webhooks delivers the changes recorded by Changelog to registered URLs, in
+// order and at least once. The registrations and their delivery state are
+// stored in a document table.
+type Webhooks struct {
+	Hooks Table[Webhook]
+	// Client sends the requests, and defaults to http.DefaultClient.
+	Client *http.Client
+	// Policy controls the delay before retrying failed deliveries. Deliveries
+	// are retried until they succeed, so its Attempts is not used.
+	Policy RetryPolicy
+	// BatchSize is the maximum number of changes per request.
+	BatchSize int
+}
+
+// NewWebhooks creates Webhooks stored in the named table, retrying failed
+// deliveries after a second, backing off up to an hour, with up to 100 changes
+// per request.
+func NewWebhooks(name string) Webhooks {
+	return Webhooks{
+		Hooks:     NewTable[Webhook](name),
+		Policy:    RetryPolicy{Backoff: time.Second, MaxBackoff: time.Hour},
+		BatchSize: 100,
+	}
+}
+
+// Migrate creates the table for the webhooks.
+func (w *Webhooks) Migrate(conn *sqlite.Conn) error {
+	return w.Hooks.Migrate(conn)
+}
+
+// Register adds a webhook receiving the changes to the table made after it is
+// registered. The table must use Changelog. The secret is used to sign the
+// requests, which are not signed if it is empty.
+func (w *Webhooks) Register(conn *sqlite.Conn, table, url, secret string) (*Webhook, error) {
+	seq, err := lastChangelogSeq(conn)
+	if err != nil {
+		return nil, err
+	}
+	_, base := splitName(table)
+	return w.Hooks.Insert(conn, &Webhook{Table: base, URL: url, Secret: secret, Seq: seq})
+}
+
+// Deliver posts the pending changes to every webhook that is due, in batches,
+// and records the outcome. It returns the number of changes delivered, and the
+// errors of the failed deliveries, which are retried by a later Deliver once
+// the backoff passes. Call it periodically, for example whenever Watch or a
+// Notifier reports a change, and on a timer to retry failures. The connection
+// is used between the requests.
+func (w *Webhooks) Deliver(ctx context.Context, conn *sqlite.Conn) (int, error) {
+	hooks, err := w.Hooks.All(conn, Where("Due").Lte(time.Now().UnixMilli()).SQL())
+	if err != nil {
+		return 0, err
+	}
+	var delivered int
+	var errs []error
+	for _, hook := range hooks {
+		n, err := w.deliverHook(ctx, conn, hook)
+		delivered += n
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return delivered, errors.Join(errs...)
+}
+
+// deliverHook delivers the pending changes to the webhook, until it is caught
+// up or a delivery fails.
+func (w *Webhooks) deliverHook(ctx context.Context, conn *sqlite.Conn, hook *Webhook) (int, error) {
+	var delivered int
+	for {
+		changes, err := changelogSince(conn, hook.Seq, hook.Table, w.BatchSize)
+		if err != nil || len(changes) == 0 {
+			return delivered, err
+		}
+		if err := w.post(ctx, hook, changes); err != nil {
+			hook.Attempts++
+			_, perr := w.Hooks.PatchFields(conn, map[string]any{
+				"Attempts":  hook.Attempts,
+				"Due":       time.Now().Add(w.Policy.delay(hook.Attempts)).UnixMilli(),
+				"LastError": err.Error(),
+			}, ByID(hook.ID))
+			return delivered, errors.Join(err, perr)
+		}
+		hook.Seq = changes[len(changes)-1].Seq
+		_, err = w.Hooks.PatchFields(conn, map[string]any{
+			"Seq":       hook.Seq,
+			"Attempts":  0,
+			"Due":       0,
+			"LastError": nil,
+		}, ByID(hook.ID))
+		if err != nil {
+			return delivered, err
+		}
+		delivered += len(changes)
+	}
+}
+
+func (w *Webhooks) post(ctx context.Context, hook *Webhook, changes []ChangeRecord) error {
+	body, err := json.Marshal(WebhookPayload{Webhook: hook.ID, Changes: changes})
+	if err != nil {
+		return fmt.Errorf("sqjdb: failed to json.Marshal: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("sqjdb: delivering to webhook %s: %w", hook.ID, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if hook.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(hook.Secret, body))
+	}
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sqjdb: delivering to webhook %s: %w", hook.ID, err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("sqjdb: delivering to webhook %s: unexpected status %s", hook.ID, res.Status)
+	}
+	return nil
+}
+
+// SignWebhook returns the value of the WebhookSignatureHeader for the body, so
+// receivers can verify requests by comparing it with hmac.Equal.
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,90 @@
+package sqjdb_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/daaku/ensure"
+	"github.com/daaku/sqjdb"
+)
+
+func TestWebhooks(t *testing.T) {
+	conn := newConn(t)
+	logged := sqjdb.NewTable[Jedi]("logged_jedis", sqjdb.Changelog())
+	ensure.Nil(t, logged.Migrate(conn))
+	ships := sqjdb.NewTable[Starship]("logged_ships", sqjdb.Changelog())
+	ensure.Nil(t, ships.Migrate(conn))
+	_, err := logged.Insert(conn, &Jedi{Name: "before"})
+	ensure.Nil(t, err)
+
+	var payloads []sqjdb.WebhookPayload
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, r.Header.Get(sqjdb.WebhookSignatureHeader), sqjdb.SignWebhook("secret", body))
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payload sqjdb.WebhookPayload
+		ensure.Nil(t, json.Unmarshal(body, &payload))
+		payloads = append(payloads, payload)
+	}))
+	defer server.Close()
+
+	webhooks := sqjdb.NewWebhooks("webhooks")
+	webhooks.BatchSize = 2
+	ensure.Nil(t, webhooks.Migrate(conn))
+	hook, err := webhooks.Register(conn, "logged_jedis", server.URL, "secret")
+	ensure.Nil(t, err)
+
+	ctx := context.Background()
+	var ids []string
+	for _, name := range []string{"anakin", "obiwan", "ahsoka"} {
+		doc, err := logged.Insert(conn, &Jedi{Name: name})
+		ensure.Nil(t, err)
+		ids = append(ids, doc.ID)
+	}
+	_, err = ships.Insert(conn, &Starship{Name: "falcon"})
+	ensure.Nil(t, err)
+	n, err := webhooks.Deliver(ctx, conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 3)
+	ensure.DeepEqual(t, len(payloads), 2)
+	ensure.DeepEqual(t, payloads[0].Webhook, hook.ID)
+	ensure.DeepEqual(t, len(payloads[0].Changes), 2)
+	ensure.DeepEqual(t, payloads[1].Changes[0].ID, ids[2])
+	ensure.DeepEqual(t, payloads[1].Changes[0].Op, "insert")
+
+	// Failed deliveries are recorded and retried after the backoff.
+	fail = true
+	_, err = logged.Delete(conn, sqjdb.ByID(ids[0]))
+	ensure.Nil(t, err)
+	n, err = webhooks.Deliver(ctx, conn)
+	ensure.StringContains(t, err.Error(), "503")
+	ensure.DeepEqual(t, n, 0)
+	state, err := webhooks.Hooks.Get(conn, hook.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, state.Attempts, 1)
+	ensure.StringContains(t, state.LastError, "503")
+	fail = false
+	n, err = webhooks.Deliver(ctx, conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 0)
+
+	_, err = webhooks.Hooks.PatchFields(conn, map[string]any{"Due": 0}, sqjdb.ByID(hook.ID))
+	ensure.Nil(t, err)
+	n, err = webhooks.Deliver(ctx, conn)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, n, 1)
+	ensure.DeepEqual(t, payloads[2].Changes[0].Op, "delete")
+	state, err = webhooks.Hooks.Get(conn, hook.ID)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, state.Attempts, 0)
+	ensure.DeepEqual(t, state.LastError, "")
+}